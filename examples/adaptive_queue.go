@@ -0,0 +1,166 @@
+package examples
+
+import (
+	"sync"
+	"time"
+)
+
+// adaptiveQueue is a FIFO queue whose capacity follows the load. Go channels
+// can't be resized, so items live in a ring buffer guarded by a mutex, with a
+// condition variable for blocked Push and Pop calls. A monitor goroutine
+// checks the queue every interval: if a Push had to wait for room since the
+// last check the capacity doubles (up to maxCap), and if the queue stayed
+// empty the whole interval it halves (down to minCap).
+type adaptiveQueue[T any] struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []T
+	head, n int
+	closed  bool
+
+	minCap, maxCap int
+	// pushWaited and peak describe the current interval
+	pushWaited bool
+	peak       int
+	// history is the capacity after every change, starting with the initial one
+	history []int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newAdaptiveQueue[T any](minCap, maxCap int, interval time.Duration) *adaptiveQueue[T] {
+	if minCap < 1 {
+		minCap = 1
+	}
+	if maxCap < minCap {
+		maxCap = minCap
+	}
+	q := &adaptiveQueue[T]{
+		buf:     make([]T, minCap),
+		minCap:  minCap,
+		maxCap:  maxCap,
+		history: []int{minCap},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.monitor(interval)
+	return q
+}
+
+// Push adds v, blocking while the queue is at capacity. It reports false if
+// the queue was closed.
+func (q *adaptiveQueue[T]) Push(v T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.n == len(q.buf) && !q.closed {
+		q.pushWaited = true
+		q.cond.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	q.buf[(q.head+q.n)%len(q.buf)] = v
+	q.n++
+	if q.n > q.peak {
+		q.peak = q.n
+	}
+	q.cond.Broadcast()
+	return true
+}
+
+// Pop removes the oldest item, blocking while the queue is empty. It reports
+// false once the queue is closed and drained.
+func (q *adaptiveQueue[T]) Pop() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.n == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	var zero T
+	if q.n == 0 {
+		return zero, false
+	}
+	v := q.buf[q.head]
+	q.buf[q.head] = zero
+	q.head = (q.head + 1) % len(q.buf)
+	q.n--
+	q.cond.Broadcast()
+	return v, true
+}
+
+// Close stops the monitor and wakes every waiter; items already queued can
+// still be popped
+func (q *adaptiveQueue[T]) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	close(q.stop)
+	<-q.done
+}
+
+// Capacity returns the current capacity
+func (q *adaptiveQueue[T]) Capacity() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.buf)
+}
+
+// History returns the capacity after every change, oldest first
+func (q *adaptiveQueue[T]) History() []int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]int(nil), q.history...)
+}
+
+func (q *adaptiveQueue[T]) monitor(interval time.Duration) {
+	defer close(q.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.mu.Lock()
+			switch {
+			case q.pushWaited && len(q.buf) < q.maxCap:
+				q.resize(len(q.buf) * 2)
+			case q.peak == 0 && len(q.buf) > q.minCap:
+				q.resize(len(q.buf) / 2)
+			}
+			q.pushWaited = false
+			q.peak = q.n
+			q.mu.Unlock()
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// resize moves the queued items, in order, into a buffer of the given
+// capacity, clamped to the limits and never below the items queued. Callers
+// must hold q.mu.
+func (q *adaptiveQueue[T]) resize(capacity int) {
+	if capacity > q.maxCap {
+		capacity = q.maxCap
+	}
+	if capacity < q.minCap {
+		capacity = q.minCap
+	}
+	if capacity < q.n || capacity == len(q.buf) {
+		return
+	}
+	buf := make([]T, capacity)
+	for i := 0; i < q.n; i++ {
+		buf[i] = q.buf[(q.head+i)%len(q.buf)]
+	}
+	q.buf, q.head = buf, 0
+	q.history = append(q.history, capacity)
+	// Pushers waiting for room may now fit
+	q.cond.Broadcast()
+}
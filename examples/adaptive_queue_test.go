@@ -0,0 +1,107 @@
+package examples
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAdaptiveQueueGrowsAndShrinks has two bursty producers fill the queue
+// faster than a slow consumer drains it, and checks the capacity grows
+// under each burst, shrinks back to the minimum while idle, and every item
+// is delivered once in its producer's order
+func TestAdaptiveQueueGrowsAndShrinks(t *testing.T) {
+	const producers, bursts, perBurst = 2, 2, 30
+	q := newAdaptiveQueue[Item](2, 32, 5*time.Millisecond)
+	defer q.Close()
+
+	type popped struct {
+		items []Item
+		peak  int
+	}
+	results := make(chan popped, 1)
+	var mu sync.Mutex
+	drained := 0
+	goLabeled(t, "adaptive-queue-consumer", func() {
+		var p popped
+		for {
+			v, ok := q.Pop()
+			if !ok {
+				results <- p
+				return
+			}
+			p.items = append(p.items, v)
+			if c := q.Capacity(); c > p.peak {
+				p.peak = c
+			}
+			mu.Lock()
+			drained++
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	for burst := 0; burst < bursts; burst++ {
+		var wg sync.WaitGroup
+		for p := 0; p < producers; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				for i := 0; i < perBurst; i++ {
+					q.Push(Item{ProducerID: p, Seq: burst*perBurst + i})
+				}
+			}(p)
+		}
+		wg.Wait()
+		if c := q.Capacity(); c <= 2 {
+			t.Fatalf("burst %d: capacity %d after the burst, want growth above the minimum 2", burst, c)
+		}
+		want := (burst + 1) * producers * perBurst
+		waitUntil(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return drained == want
+		}, 5*time.Second, "burst %d never drained", burst)
+		waitUntil(t, func() bool { return q.Capacity() == 2 }, time.Second, "capacity never shrank back to 2 while idle")
+	}
+	q.Close()
+
+	p := requireReceives(t, results, 1, time.Second)[0]
+	if len(p.items) != producers*bursts*perBurst {
+		t.Fatalf("delivered %d of %d items", len(p.items), producers*bursts*perBurst)
+	}
+	next := make(map[int]int)
+	for _, it := range p.items {
+		if it.Seq != next[it.ProducerID] {
+			t.Fatalf("producer %d's item %d delivered when %d was next", it.ProducerID, it.Seq, next[it.ProducerID])
+		}
+		next[it.ProducerID]++
+	}
+	if p.peak > 32 {
+		t.Fatalf("capacity reached %d, cap 32", p.peak)
+	}
+	history := q.History()
+	if history[0] != 2 || history[len(history)-1] != 2 {
+		t.Fatalf("capacity history %v, want it to start and end at 2", history)
+	}
+}
+
+// TestAdaptiveQueueCloseWakesPushers checks Close releases a Push blocked on
+// a full queue, and that queued items can still be popped afterwards
+func TestAdaptiveQueueCloseWakesPushers(t *testing.T) {
+	q := newAdaptiveQueue[int](1, 1, time.Hour)
+	q.Push(1)
+	pushed := make(chan bool, 1)
+	go func() { pushed <- q.Push(2) }()
+	requireNoReceive(t, pushed, 20*time.Millisecond)
+	q.Close()
+	if ok := requireReceives(t, pushed, 1, time.Second)[0]; ok {
+		t.Fatalf("Push into a closed queue reported success")
+	}
+	if v, ok := q.Pop(); !ok || v != 1 {
+		t.Fatalf("Pop after Close returned %d, %v; want the queued 1", v, ok)
+	}
+	if _, ok := q.Pop(); ok {
+		t.Fatalf("Pop on a closed, drained queue reported an item")
+	}
+}
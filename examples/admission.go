@@ -0,0 +1,73 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// admissionController gates work on two resources at once: a concurrency
+// slot from a semaphore and cost tokens from a token bucket. Expensive work
+// consumes more of the rate budget while still counting as one slot.
+type admissionController struct {
+	slots  *Semaphore
+	bucket *tokenBucketLimiter
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func newAdmissionController(maxConcurrent, tokensPerSecond, burst int) *admissionController {
+	return &admissionController{
+		slots:  NewSemaphore(maxConcurrent),
+		bucket: newTokenBucketLimiter(tokensPerSecond, burst),
+	}
+}
+
+// Admit waits for a concurrency slot and then cost tokens. It holds either
+// both or neither: if ctx ends while waiting for tokens, the slot and any
+// tokens already taken are given back and the error wraps ErrTimeout. The
+// returned release frees the slot; calling it more than once is harmless.
+func (a *admissionController) Admit(ctx context.Context, cost int) (release func(), err error) {
+	if err := a.slots.Acquire(ctx); err != nil {
+		return nil, fmt.Errorf("admit: waiting for a slot: %w", err)
+	}
+
+	for taken := 0; taken < cost; taken++ {
+		if err := a.bucket.WaitCtx(ctx); err != nil {
+			a.bucket.refund(taken)
+			a.slots.Release()
+			return nil, fmt.Errorf("admit: %d of %d tokens: %w", taken, cost, err)
+		}
+	}
+
+	a.mu.Lock()
+	a.inFlight++
+	if a.inFlight > a.peak {
+		a.peak = a.inFlight
+	}
+	a.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			a.inFlight--
+			a.mu.Unlock()
+			a.slots.Release()
+		})
+	}, nil
+}
+
+// Stats returns the admitted work still running and the most ever running at once
+func (a *admissionController) Stats() (inFlight, peak int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inFlight, a.peak
+}
+
+// Stop ends the token bucket's refill goroutine
+func (a *admissionController) Stop() {
+	a.bucket.Stop()
+}
@@ -0,0 +1,151 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAdmissionConcurrencyLimit admits jobs with tokens to spare and checks
+// no more than the slot count ever run at once, and every job gets in
+func TestAdmissionConcurrencyLimit(t *testing.T) {
+	a := newAdmissionController(2, 1000, 100)
+	defer a.Stop()
+
+	const jobs = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, jobs)
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := a.Admit(context.Background(), 1)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer release()
+			time.Sleep(20 * time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("job rejected with room to wait: %v", err)
+	}
+	if inFlight, peak := a.Stats(); inFlight != 0 || peak != 2 {
+		t.Fatalf("in flight %d, peak %d after %d jobs; want 0 and 2", inFlight, peak, jobs)
+	}
+	if n := a.slots.InUse(); n != 0 {
+		t.Fatalf("%d slots held after every job released", n)
+	}
+}
+
+// TestAdmissionRateLimit admits more tokens than the burst holds and checks
+// the rest are paced by the refill rate, costs counted in tokens
+func TestAdmissionRateLimit(t *testing.T) {
+	const rate, burst = 20, 2
+	a := newAdmissionController(10, rate, burst)
+	defer a.Stop()
+
+	start := time.Now()
+	for _, cost := range []int{1, 1, 2, 2} {
+		release, err := a.Admit(context.Background(), cost)
+		if err != nil {
+			t.Fatalf("cost-%d job rejected: %v", cost, err)
+		}
+		release()
+	}
+	// 6 tokens, 2 of them from the burst: 4 refills at 50ms each
+	if elapsed, want := time.Since(start), 4*time.Second/rate; elapsed < want*3/4 {
+		t.Fatalf("6 tokens at %d/s with a burst of %d took %v, want about %v", rate, burst, elapsed, want)
+	}
+}
+
+// TestAdmissionRejectsUnderLoad holds the only slot and checks a second job
+// whose deadline passes first is rejected with ErrTimeout, holding nothing,
+// and is admitted once the slot is free
+func TestAdmissionRejectsUnderLoad(t *testing.T) {
+	a := newAdmissionController(1, 1000, 100)
+	defer a.Stop()
+
+	release, err := a.Admit(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := a.Admit(ctx, 1); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("admit with the only slot held returned %v, want ErrTimeout", err)
+	}
+	if inFlight, _ := a.Stats(); inFlight != 1 {
+		t.Fatalf("%d in flight after a rejection, want the 1 admitted", inFlight)
+	}
+
+	release()
+	release2, err := a.Admit(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("admit after the slot was freed: %v", err)
+	}
+	release2()
+}
+
+// TestAdmissionCancelReleasesSlot gives up waiting for tokens part way and
+// checks the slot already acquired and the tokens already taken are given back
+func TestAdmissionCancelReleasesSlot(t *testing.T) {
+	const burst = 3
+	a := newAdmissionController(1, 1, burst)
+	defer a.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// Takes the burst's 3 tokens, then can't get a fourth in time
+	if _, err := a.Admit(ctx, 10); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("cost-10 admit at 1 token/s returned %v, want ErrTimeout", err)
+	}
+	if n := a.slots.InUse(); n != 0 {
+		t.Fatalf("%d slots held after the admit gave up", n)
+	}
+	if n := len(a.bucket.tokens); n != burst {
+		t.Fatalf("%d tokens in the bucket after the admit gave up, want the %d it took back", n, burst)
+	}
+
+	// With both back, a job of the burst's cost gets in at once
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	release, err := a.Admit(ctx, burst)
+	if err != nil {
+		t.Fatalf("cost-%d admit after the refund: %v", burst, err)
+	}
+	release()
+}
+
+// TestAdmissionReleaseIdempotent releases one admission twice and checks
+// it frees a single slot: the limit still holds for the next two jobs
+func TestAdmissionReleaseIdempotent(t *testing.T) {
+	a := newAdmissionController(1, 1000, 100)
+	defer a.Stop()
+
+	release, err := a.Admit(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	release()
+	if inFlight, _ := a.Stats(); inFlight != 0 || a.slots.InUse() != 0 {
+		t.Fatalf("in flight %d, slots held %d after a double release", inFlight, a.slots.InUse())
+	}
+
+	held, err := a.Admit(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := a.Admit(ctx, 1); err == nil {
+		t.Fatalf("a second job was admitted to a 1-slot controller after a double release")
+	}
+}
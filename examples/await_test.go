@@ -0,0 +1,305 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The helpers below replace sleep-and-hope checks in the examples with
+// bounded waits: each returns as soon as its condition holds and fails the
+// test otherwise, rather than sleeping for a fixed time and assuming the
+// goroutines got scheduled in the expected order. They take a testing.TB so
+// the harness tests further down can drive them with a fake.
+
+// leakGrace is how long goroutineCountStable and the labeled goroutine
+// cleanup give goroutines to exit before calling them leaked
+var leakGrace = time.Second
+
+// waitUntil polls cond until it returns true, failing the test if timeout
+// passes first. msgAndArgs, if given, is a format string and its arguments
+// describing what was awaited.
+func waitUntil(t testing.TB, cond func() bool, timeout time.Duration, msgAndArgs ...interface{}) {
+	t.Helper()
+	if pollUntil(cond, 5*time.Millisecond, timeout) {
+		return
+	}
+	if len(msgAndArgs) > 0 {
+		t.Fatalf("%s (waited %v)", fmt.Sprintf(msgAndArgs[0].(string), msgAndArgs[1:]...), timeout)
+	}
+	t.Fatalf("condition not met within %v", timeout)
+}
+
+// requireReceives reads n values from ch and returns them, failing the test
+// if they don't all arrive within timeout or if ch closes early
+func requireReceives[T any](t testing.TB, ch <-chan T, n int, timeout time.Duration) []T {
+	t.Helper()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	got := make([]T, 0, n)
+	for len(got) < n {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				t.Fatalf("channel closed after %d of %d values: %v", len(got), n, got)
+			}
+			got = append(got, v)
+		case <-timer.C:
+			t.Fatalf("received %d of %d values within %v: %v", len(got), n, timeout, got)
+		}
+	}
+	return got
+}
+
+// requireNoReceive fails the test if a value arrives on ch within the given
+// duration; a closed channel counts as nothing arriving
+func requireNoReceive[T any](t testing.TB, ch <-chan T, within time.Duration) {
+	t.Helper()
+	timer := time.NewTimer(within)
+	defer timer.Stop()
+
+	select {
+	case v, ok := <-ch:
+		if ok {
+			t.Fatalf("unexpected value %v", v)
+		}
+	case <-timer.C:
+	}
+}
+
+// labeled tracks the goroutines started with goLabeled that are still
+// running, by label, so a leak report can name them
+var labeled = struct {
+	sync.Mutex
+	running map[string]int
+}{running: make(map[string]int)}
+
+// runningLabels lists the labels of goLabeled goroutines still running
+func runningLabels() []string {
+	labeled.Lock()
+	defer labeled.Unlock()
+	var names []string
+	for name, n := range labeled.running {
+		for i := 0; i < n; i++ {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// goLabeled runs fn on a new goroutine carrying the pprof label
+// test-goroutine=label, so it is named in goroutine dumps, and fails the
+// test at cleanup if it hasn't returned within leakGrace. goroutineCountStable
+// names any labeled goroutines still running when the count doesn't settle.
+func goLabeled(t testing.TB, label string, fn func()) {
+	t.Helper()
+	labeled.Lock()
+	labeled.running[label]++
+	labeled.Unlock()
+
+	done := make(chan struct{})
+	go pprof.Do(context.Background(), pprof.Labels("test-goroutine", label), func(context.Context) {
+		defer func() {
+			labeled.Lock()
+			if labeled.running[label]--; labeled.running[label] == 0 {
+				delete(labeled.running, label)
+			}
+			labeled.Unlock()
+			close(done)
+		}()
+		fn()
+	})
+	t.Cleanup(func() {
+		select {
+		case <-done:
+		case <-time.After(leakGrace):
+			t.Errorf("goroutine %q still running %v after the test", label, leakGrace)
+		}
+	})
+}
+
+// goroutineCountStable waits for the goroutine count to fall back to
+// baseline, failing the test with the labeled goroutines still running if it
+// doesn't within leakGrace
+func goroutineCountStable(t testing.TB, baseline int) {
+	t.Helper()
+	if pollUntil(func() bool { return runtime.NumGoroutine() <= baseline }, 5*time.Millisecond, leakGrace) {
+		return
+	}
+	t.Fatalf("%d goroutines still running %v later, baseline was %d; labeled: %v",
+		runtime.NumGoroutine(), leakGrace, baseline, runningLabels())
+}
+
+// runWithWatchdog runs fn and fails the test with a dump of every goroutine
+// if fn makes no progress for d, so a deadlock fails fast instead of hanging
+// until the test binary's own timeout
+func runWithWatchdog(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+	if err := RunWithWatchdog(d, fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeTB records the first failure of a harness helper instead of failing
+// the enclosing test. Fatalf ends the calling goroutine like the real one,
+// so helpers under test run through fakeTB.run.
+type fakeTB struct {
+	testing.TB
+	mu       sync.Mutex
+	failure  string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failure == "" {
+		f.failure = fmt.Sprintf(format, args...)
+	}
+}
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (f *fakeTB) Cleanup(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cleanups = append(f.cleanups, fn)
+}
+
+// run calls fn on its own goroutine, so a Fatalf ends only fn, then runs the
+// registered cleanups and returns the recorded failure, if any
+func (f *fakeTB) run(fn func()) string {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failure
+}
+
+// TestWaitUntil checks waitUntil returns once its condition holds and fails
+// with the given message when it never does
+func TestWaitUntil(t *testing.T) {
+	ready := make(chan struct{})
+	goLabeled(t, "ready", func() { close(ready) })
+	isReady := func() bool {
+		select {
+		case <-ready:
+			return true
+		default:
+			return false
+		}
+	}
+	f := &fakeTB{}
+	if failure := f.run(func() { waitUntil(f, isReady, time.Second) }); failure != "" {
+		t.Fatalf("satisfied condition failed: %s", failure)
+	}
+	f = &fakeTB{}
+	failure := f.run(func() {
+		waitUntil(f, func() bool { return false }, 20*time.Millisecond, "queue of %d never drained", 3)
+	})
+	if failure != "queue of 3 never drained (waited 20ms)" {
+		t.Fatalf("unmet condition failed with %q", failure)
+	}
+	f = &fakeTB{}
+	if failure = f.run(func() { waitUntil(f, func() bool { return false }, 20*time.Millisecond) }); failure == "" {
+		t.Fatalf("unmet condition without a message did not fail")
+	}
+}
+
+// TestRequireReceives checks requireReceives returns the values in order
+// and fails on a short channel, a closed channel and a timeout
+func TestRequireReceives(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	if got := requireReceives(t, ch, 3, time.Second); fmt.Sprint(got) != "[1 2 3]" {
+		t.Fatalf("received %v, want [1 2 3]", got)
+	}
+
+	ch <- 4
+	f := &fakeTB{}
+	failure := f.run(func() { requireReceives(f, ch, 2, 20*time.Millisecond) })
+	if failure != "received 1 of 2 values within 20ms: [4]" {
+		t.Fatalf("timeout failed with %q", failure)
+	}
+
+	ch <- 5
+	close(ch)
+	f = &fakeTB{}
+	failure = f.run(func() { requireReceives(f, ch, 2, time.Second) })
+	if failure != "channel closed after 1 of 2 values: [5]" {
+		t.Fatalf("early close failed with %q", failure)
+	}
+}
+
+// TestRequireNoReceive checks requireNoReceive passes on a quiet or closed
+// channel and fails on a value
+func TestRequireNoReceive(t *testing.T) {
+	quiet := make(chan string)
+	requireNoReceive(t, quiet, 10*time.Millisecond)
+	close(quiet)
+	requireNoReceive(t, quiet, time.Second)
+
+	noisy := make(chan string, 1)
+	noisy <- "hello"
+	f := &fakeTB{}
+	if failure := f.run(func() { requireNoReceive(f, noisy, time.Second) }); failure != "unexpected value hello" {
+		t.Fatalf("value on the channel failed with %q", failure)
+	}
+}
+
+// TestGoLabeled checks goroutineCountStable names a labeled goroutine that
+// outlives its test, that cleanup flags it as leaked and that the count and
+// the labels settle once it returns
+func TestGoLabeled(t *testing.T) {
+	defer func(d time.Duration) { leakGrace = d }(leakGrace)
+	leakGrace = 50 * time.Millisecond
+
+	baseline := runtime.NumGoroutine()
+	release := make(chan struct{})
+	f := &fakeTB{}
+	failure := f.run(func() {
+		goLabeled(f, "stuck-reader", func() { <-release })
+		goroutineCountStable(f, baseline)
+	})
+	if !strings.Contains(failure, "labeled: [stuck-reader]") {
+		t.Fatalf("stuck goroutine reported as %q", failure)
+	}
+
+	f = &fakeTB{}
+	goLabeled(f, "stuck-writer", func() { <-release })
+	failure = f.run(func() {})
+	if failure != `goroutine "stuck-writer" still running 50ms after the test` {
+		t.Fatalf("cleanup reported %q", failure)
+	}
+	if labels := runningLabels(); fmt.Sprint(labels) != "[stuck-reader stuck-writer]" {
+		t.Fatalf("running labels %v, want both stuck goroutines", labels)
+	}
+
+	close(release)
+	goroutineCountStable(t, baseline)
+	if labels := runningLabels(); len(labels) != 0 {
+		t.Fatalf("labels %v still running after every goroutine returned", labels)
+	}
+}
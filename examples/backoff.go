@@ -0,0 +1,108 @@
+package examples
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff computes how long to wait before the given retry attempt, counted
+// from 1. Reset returns a stateful backoff to its initial state once the
+// operation has recovered.
+type Backoff interface {
+	Next(attempt int) time.Duration
+	Reset()
+}
+
+// ConstantBackoff waits the same delay before every attempt
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+func (b ConstantBackoff) Next(int) time.Duration { return b.Delay }
+
+func (b ConstantBackoff) Reset() {}
+
+// ExponentialBackoff waits Base before the first attempt and multiplies the
+// delay by Factor for each attempt after that, never exceeding Max. A zero
+// Factor doubles, like NewExponentialBackoff.
+type ExponentialBackoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Factor float64
+}
+
+// NewExponentialBackoff returns a backoff that doubles from base up to max
+func NewExponentialBackoff(base, max time.Duration) *ExponentialBackoff {
+	return &ExponentialBackoff{Base: base, Max: max, Factor: 2}
+}
+
+func (b *ExponentialBackoff) Next(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	factor := b.Factor
+	if factor == 0 {
+		factor = 2
+	}
+	d := float64(b.Base) * math.Pow(factor, float64(attempt-1))
+	if d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
+func (b *ExponentialBackoff) Reset() {}
+
+// DecorrelatedJitterBackoff picks each delay at random between Base and three
+// times the previous delay, capped at Max. The spread keeps many clients
+// that failed together from retrying in lockstep. It ignores the attempt
+// number, since each delay depends on the last one. A literal with only Base
+// and Max set works too, drawing from a time-seeded source.
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+
+	mu   sync.Mutex
+	rng  *rand.Rand
+	prev time.Duration
+}
+
+// NewDecorrelatedJitterBackoff returns a jittered backoff; the seed makes
+// its sequence of delays reproducible
+func NewDecorrelatedJitterBackoff(base, max time.Duration, seed int64) *DecorrelatedJitterBackoff {
+	return &DecorrelatedJitterBackoff{
+		Base: base,
+		Max:  max,
+		rng:  rand.New(rand.NewSource(seed)),
+		prev: base,
+	}
+}
+
+func (b *DecorrelatedJitterBackoff) Next(int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rng == nil {
+		b.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if b.prev < b.Base {
+		b.prev = b.Base
+	}
+	upper := 3 * b.prev
+	if upper <= b.Base {
+		upper = b.Base + 1
+	}
+	d := b.Base + time.Duration(b.rng.Int63n(int64(upper-b.Base)))
+	if d > b.Max {
+		d = b.Max
+	}
+	b.prev = d
+	return d
+}
+
+func (b *DecorrelatedJitterBackoff) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prev = b.Base
+}
@@ -0,0 +1,109 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDecorrelatedJitterBackoff checks each delay falls between Base and
+// three times the previous one, the sequence reaches and holds at Max, the
+// same seed replays the same delays, and Reset starts again from Base
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	const base, max = 10 * time.Millisecond, 200 * time.Millisecond
+	b := NewDecorrelatedJitterBackoff(base, max, 42)
+	replay := NewDecorrelatedJitterBackoff(base, max, 42)
+
+	prev, capped := base, 0
+	var first []time.Duration
+	for attempt := 1; attempt <= 50; attempt++ {
+		d := b.Next(attempt)
+		if d < base || d > max || d > 3*prev {
+			t.Fatalf("attempt %d waits %v after %v, want %v to min(%v, %v)", attempt, d, prev, base, 3*prev, max)
+		}
+		if r := replay.Next(attempt); r != d {
+			t.Fatalf("attempt %d: seed 42 gave %v, then %v", attempt, d, r)
+		}
+		if d == max {
+			capped++
+		}
+		first = append(first, d)
+		prev = d
+	}
+	if capped == 0 {
+		t.Fatalf("50 delays never reached the %v cap: %v", max, first)
+	}
+
+	b.Reset()
+	if d := b.Next(1); d >= 3*base {
+		t.Fatalf("first delay after Reset is %v, want under %v", d, 3*base)
+	}
+}
+
+// TestDecorrelatedJitterBackoffLiteral checks a struct literal, with no
+// seeded source, draws delays instead of panicking
+func TestDecorrelatedJitterBackoffLiteral(t *testing.T) {
+	b := &DecorrelatedJitterBackoff{Base: time.Millisecond, Max: 5 * time.Millisecond}
+	prev := b.Base
+	for attempt := 1; attempt <= 20; attempt++ {
+		d := b.Next(attempt)
+		if d < b.Base || d > b.Max || d > 3*prev {
+			t.Fatalf("attempt %d waits %v after %v", attempt, d, prev)
+		}
+		prev = d
+	}
+}
+
+// TestConstantBackoff checks every attempt, out-of-range ones included,
+// waits the same delay, before and after Reset
+func TestConstantBackoff(t *testing.T) {
+	b := ConstantBackoff{Delay: 30 * time.Millisecond}
+	for _, attempt := range []int{-1, 0, 1, 2, 10, 1000} {
+		if d := b.Next(attempt); d != b.Delay {
+			t.Fatalf("attempt %d waits %v, want %v", attempt, d, b.Delay)
+		}
+	}
+	b.Reset()
+	if d := b.Next(1); d != b.Delay {
+		t.Fatalf("after Reset waits %v, want %v", d, b.Delay)
+	}
+}
+
+// TestExponentialBackoff checks the delays grow by Factor from Base, hold at
+// Max however many attempts follow, and treat attempts below 1 as the first
+func TestExponentialBackoff(t *testing.T) {
+	ms := time.Millisecond
+	for _, c := range []struct {
+		name string
+		b    Backoff
+		want []time.Duration
+	}{
+		{"doubling", NewExponentialBackoff(10*ms, 200*ms), []time.Duration{10 * ms, 20 * ms, 40 * ms, 80 * ms, 160 * ms, 200 * ms, 200 * ms}},
+		{"factor 1.5", &ExponentialBackoff{Base: 100 * ms, Max: time.Second, Factor: 1.5}, []time.Duration{100 * ms, 150 * ms, 225 * ms, 337500 * time.Microsecond, 506250 * time.Microsecond, 759375 * time.Microsecond, time.Second}},
+		{"factor 1", &ExponentialBackoff{Base: 5 * ms, Max: time.Second, Factor: 1}, []time.Duration{5 * ms, 5 * ms, 5 * ms}},
+		{"zero factor", &ExponentialBackoff{Base: 10 * ms, Max: 50 * ms}, []time.Duration{10 * ms, 20 * ms, 40 * ms, 50 * ms}},
+		{"base above max", NewExponentialBackoff(time.Second, 100*ms), []time.Duration{100 * ms, 100 * ms}},
+	} {
+		for i, want := range c.want {
+			if d := c.b.Next(i + 1); d != want {
+				t.Fatalf("%s: attempt %d waits %v, want %v", c.name, i+1, d, want)
+			}
+		}
+		c.b.Reset()
+		if d := c.b.Next(1); d != c.want[0] {
+			t.Fatalf("%s: first attempt after Reset waits %v, want %v", c.name, d, c.want[0])
+		}
+	}
+
+	b := NewExponentialBackoff(10*ms, 200*ms)
+	for _, attempt := range []int{0, -5} {
+		if d := b.Next(attempt); d != 10*ms {
+			t.Fatalf("attempt %d waits %v, want the base %v", attempt, d, 10*ms)
+		}
+	}
+	// Far past the cap the multiplier overflows a Duration; Max still holds
+	for _, attempt := range []int{64, 1000, 1 << 30} {
+		if d := b.Next(attempt); d != 200*ms {
+			t.Fatalf("attempt %d waits %v, want the cap %v", attempt, d, 200*ms)
+		}
+	}
+}
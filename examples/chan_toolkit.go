@@ -0,0 +1,107 @@
+package examples
+
+import "context"
+
+// ChanMap applies fn to every value from in and sends the result on the
+// returned channel, which is closed once in is closed and drained.
+func ChanMap[T, U any](in <-chan T, fn func(T) U) <-chan U {
+	out := make(chan U)
+	go func() {
+		defer close(out)
+		for v := range in {
+			out <- fn(v)
+		}
+	}()
+	return out
+}
+
+// Stage runs fn over every value from in, in its own goroutine, and sends
+// the results on the returned channel, which is closed once in is closed and
+// drained. In and Out may differ, so one stage can turn ints into strings or
+// structs for the next.
+func Stage[In, Out any](in <-chan In, fn func(In) Out) <-chan Out {
+	return StageCtx(context.Background(), in, fn)
+}
+
+// StageCtx is Stage that stops when ctx is done: it sends nothing more, not
+// even a result fn was computing, and closes its output without draining in,
+// so the stages upstream should stop on the same ctx.
+func StageCtx[In, Out any](ctx context.Context, in <-chan In, fn func(In) Out) <-chan Out {
+	return StageBufferedCtx(ctx, in, 0, fn)
+}
+
+// StageBufferedCtx is StageCtx with room for buffer results on its output,
+// so it can run that far ahead of the next stage. Cancelling ctx still
+// closes the output straight away; results left in the buffer are never
+// read, and a consumer ranging over the output after the cancel sees at most
+// those before it closes.
+func StageBufferedCtx[In, Out any](ctx context.Context, in <-chan In, buffer int, fn func(In) Out) <-chan Out {
+	out := make(chan Out, buffer)
+	go func() {
+		defer close(out)
+		for v := range in {
+			heartbeat()
+			result := fn(v)
+			if ctx.Err() != nil || !sendCtx(ctx, out, result) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// sendCtx sends v on out unless ctx is done first, and reports whether it
+// was sent
+func sendCtx[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ChanFilter forwards only the values from in for which pred returns true.
+// The returned channel is closed once in is closed and drained.
+func ChanFilter[T any](in <-chan T, pred func(T) bool) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for v := range in {
+			if pred(v) {
+				out <- v
+			}
+		}
+	}()
+	return out
+}
+
+// ChanReduce folds every value from in into an accumulator starting at
+// initial. It blocks until in is closed and returns the final accumulator.
+func ChanReduce[T, R any](in <-chan T, initial R, fn func(R, T) R) R {
+	acc := initial
+	for v := range in {
+		acc = fn(acc, v)
+	}
+	return acc
+}
+
+// Histogram counts the values from in per bucket of bucketSize, keyed by each
+// bucket's lower bound, so with a size of 10 the value 17 counts towards 10
+// and -3 towards -10. A size of 1 (or less) counts each value on its own. It
+// blocks until in is closed.
+func Histogram(in <-chan int, bucketSize int) map[int]int {
+	if bucketSize < 1 {
+		bucketSize = 1
+	}
+	counts := make(map[int]int)
+	for v := range in {
+		bucket := v / bucketSize
+		if v%bucketSize < 0 {
+			// Integer division truncates towards zero; round negatives down
+			bucket--
+		}
+		counts[bucket*bucketSize]++
+	}
+	return counts
+}
@@ -0,0 +1,116 @@
+package examples
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestChanMapFilterReduce composes map, filter and reduce over 1..100 and
+// checks the folded result, and that the map and filter goroutines exit once
+// the input closes
+func TestChanMapFilterReduce(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 1; i <= 100; i++ {
+			in <- i
+		}
+	}()
+
+	squares := ChanMap(in, func(v int) int { return v * v })
+	even := ChanFilter(squares, func(v int) bool { return v%2 == 0 })
+	sum := ChanReduce(even, 0, func(acc, v int) int { return acc + v })
+
+	// The squares of 2, 4, ..., 100: 4 * (1² + ... + 50²)
+	if want := 4 * 50 * 51 * 101 / 6; sum != want {
+		t.Fatalf("sum of even squares is %d, want %d", sum, want)
+	}
+	goroutineCountStable(t, goroutines)
+}
+
+// TestChanMapChangesType maps ints to strings and reduces them in order
+func TestChanMapChangesType(t *testing.T) {
+	in := make(chan int, 5)
+	for i := 1; i <= 5; i++ {
+		in <- i
+	}
+	close(in)
+	got := ChanReduce(ChanMap(in, strconv.Itoa), "", func(acc, s string) string { return acc + s })
+	if got != "12345" {
+		t.Fatalf("got %q, want %q", got, "12345")
+	}
+}
+
+// TestChanReduceEmpty checks reducing a closed, empty channel returns the
+// initial accumulator
+func TestChanReduceEmpty(t *testing.T) {
+	in := make(chan int)
+	close(in)
+	if got := ChanReduce(ChanFilter(in, func(int) bool { return true }), 7, func(acc, v int) int { return acc + v }); got != 7 {
+		t.Fatalf("reducing nothing returned %d, want the initial 7", got)
+	}
+}
+
+// TestHistogramBuckets checks values land in the bucket at or below them,
+// negatives included, and that a bucket size of 1 or less counts each value
+func TestHistogramBuckets(t *testing.T) {
+	for _, c := range []struct {
+		size   int
+		values []int
+		want   map[int]int
+	}{
+		{10, []int{0, 9, 10, 17, 99, 100}, map[int]int{0: 2, 10: 2, 90: 1, 100: 1}},
+		{10, []int{-1, -3, -10, -11, -20}, map[int]int{-10: 3, -20: 2}},
+		{25, []int{-26, -25, -1, 0, 24, 25}, map[int]int{-50: 1, -25: 2, 0: 2, 25: 1}},
+		{1, []int{-2, -2, 0, 3, 3, 3}, map[int]int{-2: 2, 0: 1, 3: 3}},
+		{0, []int{-1, 4, 4}, map[int]int{-1: 1, 4: 2}},
+		{-5, []int{7}, map[int]int{7: 1}},
+		{10, nil, map[int]int{}},
+	} {
+		in := make(chan int, len(c.values))
+		for _, v := range c.values {
+			in <- v
+		}
+		close(in)
+		if got := Histogram(in, c.size); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Fatalf("size %d, values %v: got %v, want %v", c.size, c.values, got, c.want)
+		}
+	}
+}
+
+// TestHistogramConcurrentSenders fills the input from several goroutines and
+// checks every value is counted once, in the right bucket
+func TestHistogramConcurrentSenders(t *testing.T) {
+	const senders = 8
+	in := make(chan int)
+	var wg sync.WaitGroup
+	for s := 0; s < senders; s++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := -50; v < 50; v++ {
+				in <- v
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(in)
+	}()
+
+	got := Histogram(in, 10)
+	total := 0
+	for lo := -50; lo < 50; lo += 10 {
+		if got[lo] != senders*10 {
+			t.Fatalf("bucket %d holds %d values, want %d: %v", lo, got[lo], senders*10, got)
+		}
+		total += got[lo]
+	}
+	if len(got) != 10 || total != senders*100 {
+		t.Fatalf("%d buckets holding %d values, want 10 holding %d: %v", len(got), total, senders*100, got)
+	}
+}
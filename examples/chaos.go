@@ -0,0 +1,225 @@
+package examples
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// FaultInjector decides when to inject delays, panics, failures and slow
+// subscribers. Each decision is derived from the seed and the site plus keys
+// it is asked about (item, attempt, ...), not from a shared random stream, so
+// the same seed injects the same faults however the goroutines get scheduled.
+type FaultInjector struct {
+	Seed      int64
+	MaxDelay  time.Duration
+	DelayRate float64
+	PanicRate float64
+	FailRate  float64
+	SlowRate  float64
+}
+
+// NewFaultInjector returns the aggressive profile used by --chaos
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{
+		Seed:      seed,
+		MaxDelay:  time.Second,
+		DelayRate: 0.2,
+		PanicRate: 0.1,
+		FailRate:  0.2,
+		SlowRate:  0.5,
+	}
+}
+
+// roll returns a reproducible value in [0, 1) for the site, kind and keys
+func (f *FaultInjector) roll(site, kind string, keys []int) float64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%s", f.Seed, site, kind)
+	for _, k := range keys {
+		fmt.Fprintf(h, "/%d", k)
+	}
+	// FNV alone mixes short inputs poorly, so finish with splitmix64
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11) / (1 << 53)
+}
+
+// Delay returns how long the handler at site should stall, often zero
+func (f *FaultInjector) Delay(site string, keys ...int) time.Duration {
+	if f == nil || f.roll(site, "delay", keys) >= f.DelayRate {
+		return 0
+	}
+	return time.Duration(f.roll(site, "delay-length", keys) * float64(f.MaxDelay))
+}
+
+// Panics reports whether the worker at site should panic
+func (f *FaultInjector) Panics(site string, keys ...int) bool {
+	return f != nil && f.roll(site, "panic", keys) < f.PanicRate
+}
+
+// Fails reports whether the item at site should fail
+func (f *FaultInjector) Fails(site string, keys ...int) bool {
+	return f != nil && f.roll(site, "fail", keys) < f.FailRate
+}
+
+// SlowSubscriber reports whether the subscriber at site should be slow
+func (f *FaultInjector) SlowSubscriber(site string, keys ...int) bool {
+	return f != nil && f.roll(site, "slow", keys) < f.SlowRate
+}
+
+// chaosInjector returns the injector for --chaos, or nil when it is off
+func chaosInjector() *FaultInjector {
+	if !opts.Chaos {
+		return nil
+	}
+	return NewFaultInjector(opts.Seed)
+}
+
+// chaosSummary is the honest account of a chaos run
+type chaosSummary struct {
+	Produced     int
+	Succeeded    int
+	Retried      int
+	Panics       int
+	DeadLettered int
+}
+
+// chaosAttempt is one delivery of an item
+type chaosAttempt struct {
+	item    int
+	attempt int
+}
+
+// chaosTracker runs attempts at items under fault injection for the pools
+// and producer-consumer examples and keeps their summary. A failed or
+// panicking attempt is redelivered after a delay growing with each attempt
+// until maxAttempts, after which the item is dead-lettered, so every
+// produced item ends up either succeeded or dead-lettered.
+type chaosTracker struct {
+	site        string
+	fi          *FaultInjector
+	maxAttempts int
+
+	mu      sync.Mutex
+	summary chaosSummary
+}
+
+func newChaosTracker(site string, fi *FaultInjector, maxAttempts int) *chaosTracker {
+	return &chaosTracker{site: site, fi: fi, maxAttempts: maxAttempts}
+}
+
+// produced counts an item entering the run
+func (c *chaosTracker) produced() {
+	c.mu.Lock()
+	c.summary.Produced++
+	c.mu.Unlock()
+}
+
+// chaosOutcome is what became of one attempt at an item
+type chaosOutcome int
+
+const (
+	chaosSucceeded chaosOutcome = iota
+	chaosRedelivered
+	chaosDeadLettered
+)
+
+// attempt runs work for one attempt at an item on worker, with the injected
+// delay before it and an injected panic or failure after. A failed attempt
+// is handed to redeliver with its backoff while attempts remain; once they
+// run out, or redeliver turns it away, the item is dead-lettered.
+func (c *chaosTracker) attempt(worker int, a chaosAttempt, work func(), redeliver func(after time.Duration) error) chaosOutcome {
+	ok, panicked := c.run(a, work)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if panicked {
+		c.summary.Panics++
+		fmt.Fprintf(output(), "[chaos %s] worker %d recovered from a panic on item %d\n", c.site, worker, a.item)
+	}
+	switch {
+	case ok:
+		c.summary.Succeeded++
+		return chaosSucceeded
+	case a.attempt < c.maxAttempts && redeliver(time.Duration(a.attempt)*10*time.Millisecond) == nil:
+		c.summary.Retried++
+		fmt.Fprintf(output(), "[chaos %s] item %d failed attempt %d, redelivering\n", c.site, a.item, a.attempt)
+		return chaosRedelivered
+	default:
+		c.summary.DeadLettered++
+		fmt.Fprintf(output(), "[chaos %s] item %d dead-lettered after %d attempts\n", c.site, a.item, a.attempt)
+		return chaosDeadLettered
+	}
+}
+
+// run does one attempt, turning an injected panic into a failure
+func (c *chaosTracker) run(a chaosAttempt, work func()) (ok, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok, panicked = false, true
+		}
+	}()
+
+	pause(c.fi.Delay(c.site, a.item, a.attempt))
+	work()
+	if c.fi.Panics(c.site, a.item, a.attempt) {
+		panic(fmt.Sprintf("injected panic on item %d", a.item))
+	}
+	return !c.fi.Fails(c.site, a.item, a.attempt), false
+}
+
+// Summary returns what the run has produced, retried and lost so far
+func (c *chaosTracker) Summary() chaosSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.summary
+}
+
+// chaosRedelivery puts failed attempts back on a queue once their backoff
+// is up, through the shared retry scheduler. It must only be closed once
+// every item is settled, so no redelivery is left waiting.
+type chaosRedelivery[T any] struct {
+	scheduler *retryScheduler[T]
+	done      chan struct{}
+}
+
+// newChaosRedelivery forwards to queue; capacity should cover every item,
+// so a redelivery is never turned away
+func newChaosRedelivery[T any](capacity int, queue chan<- T) *chaosRedelivery[T] {
+	r := &chaosRedelivery[T]{scheduler: newRetryScheduler[T](capacity), done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		for v := range r.scheduler.C {
+			queue <- v
+		}
+	}()
+	return r
+}
+
+// redeliver is the redeliver func for chaosTracker.attempt, putting v back
+func (r *chaosRedelivery[T]) redeliver(v T) func(after time.Duration) error {
+	return func(after time.Duration) error {
+		return r.scheduler.Schedule(v, after)
+	}
+}
+
+// close stops the forwarding once every item is settled
+func (r *chaosRedelivery[T]) close() {
+	r.scheduler.Close(false)
+	<-r.done
+}
+
+// printChaosSummary prints the summary and fails the run if items went missing
+func printChaosSummary(site string, s chaosSummary) {
+	fmt.Fprintf(output(), "[chaos %s] produced %d, succeeded %d, retried %d, panics %d, dead-lettered %d\n",
+		site, s.Produced, s.Succeeded, s.Retried, s.Panics, s.DeadLettered)
+	record("chaos", s)
+	if s.Produced != s.Succeeded+s.DeadLettered {
+		fail("chaos %s: produced %d != succeeded %d + dead-lettered %d", site, s.Produced, s.Succeeded, s.DeadLettered)
+	}
+}
@@ -0,0 +1,63 @@
+package examples
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// TestChaosConservesItems runs the real worker pool and producer-consumer
+// under the seeded --chaos profile and checks each terminates within a
+// deadline with every item either succeeded once or dead-lettered, and that
+// faults were actually injected
+func TestChaosConservesItems(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	var faults chaosSummary
+	conserved := func(site string, seed int64, s chaosSummary, items int) {
+		t.Helper()
+		if s.Produced != items || s.Produced != s.Succeeded+s.DeadLettered {
+			t.Fatalf("%s, seed %d: %+v; want %d produced, each succeeded or dead-lettered", site, seed, s, items)
+		}
+		faults.Retried += s.Retried
+		faults.Panics += s.Panics
+	}
+
+	for seed := int64(1); seed <= 2; seed++ {
+		fi := NewFaultInjector(seed)
+		// The aggressive profile, with shorter stalls so the test stays quick
+		fi.MaxDelay = 50 * time.Millisecond
+
+		pool := make(chan chaosSummary, 1)
+		goLabeled(t, "chaos-pool", func() { pool <- runChaosPool(fi, 3, 9, 3) })
+		conserved("pools", seed, requireReceives(t, pool, 1, 10*time.Second)[0], 9)
+
+		chaos := newChaosTracker("producer-consumer", fi, 3)
+		type run struct{ produced, consumed []int }
+		runs := make(chan run, 1)
+		goLabeled(t, "chaos-producer-consumer", func() {
+			produced, consumed := runProducerConsumer(producerConsumerConfig{
+				Producers: 2, Consumers: 3, Items: 10, Buffer: 2,
+				ConsumeDelay: 5 * time.Millisecond, Chaos: chaos,
+			})
+			runs <- run{produced, consumed}
+		})
+		r := requireReceives(t, runs, 1, 10*time.Second)[0]
+		s := chaos.Summary()
+		conserved("producer-consumer", seed, s, 20)
+		if len(r.produced) != 20 || len(r.consumed) != s.Succeeded {
+			t.Fatalf("producer-consumer, seed %d: produced %d, consumed %d, summary %+v", seed, len(r.produced), len(r.consumed), s)
+		}
+		seen := make(map[int]bool)
+		for _, n := range r.consumed {
+			if seen[n] || n < 0 || n >= 20 {
+				t.Fatalf("producer-consumer, seed %d: item %d consumed twice or never produced: %v", seed, n, r.consumed)
+			}
+			seen[n] = true
+		}
+	}
+	if faults.Retried == 0 || faults.Panics == 0 {
+		t.Fatalf("chaos injected %d retries and %d panics, want some of each", faults.Retried, faults.Panics)
+	}
+}
@@ -0,0 +1,92 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// breakerState is where a circuitBreaker is in its closed, open, half-open cycle
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops calling a dependency that keeps failing. After
+// threshold consecutive failures it opens, and calls fail at once with
+// ErrCircuitOpen until cooldown has passed. The first call after that is a
+// trial: if it succeeds the breaker closes, and if it fails the breaker opens
+// for another cooldown. Calls made while the trial runs fail fast too.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	clock     retryClock
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &circuitBreaker{name: name, threshold: threshold, cooldown: cooldown, clock: realClock{}}
+}
+
+// Do calls fn unless the breaker is open, and returns its error. A rejected
+// call returns an error wrapping ErrCircuitOpen without calling fn.
+func (b *circuitBreaker) Do(fn func() error) error {
+	b.mu.Lock()
+	switch b.state {
+	case breakerOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.cooldown {
+			b.mu.Unlock()
+			return fmt.Errorf("%s: %w", b.name, ErrCircuitOpen)
+		}
+		// This call is the trial
+		b.state = breakerHalfOpen
+	case breakerHalfOpen:
+		b.mu.Unlock()
+		return fmt.Errorf("%s: %w, trial call in flight", b.name, ErrCircuitOpen)
+	}
+	b.mu.Unlock()
+
+	err := fn()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.state, b.failures = breakerClosed, 0
+		return nil
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt = breakerOpen, b.clock.Now()
+	}
+	return err
+}
+
+// State returns the breaker's current state. An open breaker whose cooldown
+// has passed still reports open until the next call tries the dependency.
+func (b *circuitBreaker) State() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
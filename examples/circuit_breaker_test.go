@@ -0,0 +1,85 @@
+package examples
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestCircuitBreaker steps a breaker through its cycle on a fake clock: it
+// opens after threshold consecutive failures, rejects calls with
+// ErrCircuitOpen without making them while cooling down, reopens when the
+// trial fails, and closes when a later trial succeeds
+func TestCircuitBreaker(t *testing.T) {
+	clock := &fakeRetryClock{now: time.Unix(0, 0)}
+	b := newCircuitBreaker("db", 3, time.Second)
+	b.clock = clock
+
+	down := errors.New("connection refused")
+	calls := 0
+	failing := func() error { calls++; return down }
+	healthy := func() error { calls++; return nil }
+
+	// A success resets the consecutive-failure count
+	for _, fn := range []func() error{failing, failing, healthy, failing, failing} {
+		b.Do(fn)
+	}
+	if b.State() != breakerClosed {
+		t.Fatalf("breaker %v after two failures, a success and two failures, want closed", b.State())
+	}
+	if err := b.Do(failing); !errors.Is(err, down) || b.State() != breakerOpen {
+		t.Fatalf("third consecutive failure returned %v with the breaker %v, want the call's error and open", err, b.State())
+	}
+
+	calls = 0
+	clock.now = clock.now.Add(999 * time.Millisecond)
+	if err := b.Do(healthy); !errors.Is(err, ErrCircuitOpen) || calls != 0 {
+		t.Fatalf("call while cooling down returned %v after %d calls, want ErrCircuitOpen without calling", err, calls)
+	}
+
+	// The first trial still fails, so the breaker cools down again
+	clock.now = clock.now.Add(time.Millisecond)
+	if err := b.Do(failing); !errors.Is(err, down) || b.State() != breakerOpen {
+		t.Fatalf("failed trial returned %v with the breaker %v, want the call's error and open", err, b.State())
+	}
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	if err := b.Do(healthy); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("call after a failed trial returned %v, want ErrCircuitOpen", err)
+	}
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	if err := b.Do(healthy); err != nil || b.State() != breakerClosed {
+		t.Fatalf("successful trial returned %v with the breaker %v, want nil and closed", err, b.State())
+	}
+	if calls != 2 {
+		t.Fatalf("dependency called %d times since opening, want only the 2 trials", calls)
+	}
+}
+
+// TestCircuitBreakerSingleTrial checks that while a trial call is running,
+// other callers are rejected instead of piling onto the recovering dependency
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	clock := &fakeRetryClock{now: time.Unix(0, 0)}
+	b := newCircuitBreaker("db", 1, time.Second)
+	b.clock = clock
+	b.Do(func() error { return errors.New("down") })
+	clock.now = clock.now.Add(time.Second)
+
+	inTrial, release := make(chan struct{}), make(chan struct{})
+	trial := make(chan error, 1)
+	go func() {
+		trial <- b.Do(func() error {
+			close(inTrial)
+			<-release
+			return nil
+		})
+	}()
+	<-inTrial
+	if err := b.Do(func() error { return nil }); !errors.Is(err, ErrCircuitOpen) || b.State() != breakerHalfOpen {
+		t.Fatalf("call during the trial returned %v with the breaker %v, want ErrCircuitOpen and half-open", err, b.State())
+	}
+	close(release)
+	if err := requireReceives(t, trial, 1, time.Second)[0]; err != nil || b.State() != breakerClosed {
+		t.Fatalf("trial returned %v with the breaker %v, want nil and closed", err, b.State())
+	}
+}
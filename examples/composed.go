@@ -0,0 +1,318 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RunComposed chains several of the patterns into one ingestion flow: a
+// rate-limited generator feeds a parse → enrich pipeline, a worker pool does
+// the expensive scoring, and the broadcaster fans the results out to a
+// metrics aggregator and a printer. Everything runs under one context.
+func RunComposed(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Composed Ingestion Example ===")
+
+	phase("full")
+	fmt.Fprintln(output(), "\n1. Full run (40 readings, seed 7):")
+	goroutines := runtime.NumGoroutine()
+	stats := runComposed(ctx, composedConfig{Count: 40, Seed: 7, PrintAll: true})
+	printComposedStats(stats)
+	record("full", stats)
+	if err := stats.conserved(); err != nil {
+		fail("composed run: %v", err)
+	}
+	if stats.Generated != 40 || stats.Published != 40 {
+		fail("composed run: generated %d and published %d readings, want 40 of each", stats.Generated, stats.Published)
+	}
+	if err := waitForGoroutines(goroutines, time.Second); err != nil {
+		fail("composed run: %v", err)
+	}
+
+	phase("shutdown")
+	fmt.Fprintln(output(), "\n2. Shut down after 300ms (200 readings planned):")
+	shutdownCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	stats = runComposed(shutdownCtx, composedConfig{Count: 200, Seed: 7})
+	cancel()
+	printComposedStats(stats)
+	record("shutdown", stats)
+	if err := stats.conserved(); err != nil {
+		fail("composed shutdown: %v", err)
+	}
+	if stats.Generated >= 200 {
+		fail("composed shutdown: generated all %d readings despite the shutdown", stats.Generated)
+	}
+	if err := waitForGoroutines(goroutines, time.Second); err != nil {
+		fail("composed shutdown: %v", err)
+	}
+
+	fmt.Fprintln(output(), "\nComposed example completed!")
+}
+
+// sensorReading is one reading as it moves through the composed flow
+type sensorReading struct {
+	ID     int
+	Sensor string
+	Value  int
+	Level  string
+	Score  int
+	// TraceID follows the reading through every stage
+	TraceID string
+}
+
+// composedStats is the consolidated report of a composed run, one part per
+// component
+type composedStats struct {
+	Generated   int
+	Parsed      int
+	Enriched    int
+	Processed   int
+	Published   int
+	Broadcaster broadcasterStats
+	// Received counts messages per subscriber
+	Received map[string]int
+	// PerSensor is what the metrics subscriber aggregated
+	PerSensor map[string]int
+	// Shutdown is how the two-phase shutdown at the end of the run went
+	Shutdown shutdownReport
+	Elapsed  time.Duration
+}
+
+// conserved checks that no reading went missing: each one generated was
+// published, and every subscriber received everything published except
+// what it dropped at the shutdown deadline
+func (s composedStats) conserved() error {
+	if s.Generated != s.Published {
+		return fmt.Errorf("generated %d readings, published %d", s.Generated, s.Published)
+	}
+	if s.Broadcaster.Published != s.Published {
+		return fmt.Errorf("broadcaster counted %d publishes, %d readings were published", s.Broadcaster.Published, s.Published)
+	}
+	for _, name := range []string{"metrics", "printer"} {
+		if s.Received[name]+s.Shutdown.Dropped[name] != s.Published {
+			return fmt.Errorf("subscriber %s received %d and dropped %d of %d messages",
+				name, s.Received[name], s.Shutdown.Dropped[name], s.Published)
+		}
+	}
+	return nil
+}
+
+func printComposedStats(s composedStats) {
+	fmt.Fprintf(output(), "Generated %d, parsed %d, enriched %d, processed %d, published %d in %v\n",
+		s.Generated, s.Parsed, s.Enriched, s.Processed, s.Published, s.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(output(), "Broadcaster: %d delivered, %d dropped; received: metrics %d, printer %d\n",
+		s.Broadcaster.Delivered, s.Broadcaster.Dropped, s.Received["metrics"], s.Received["printer"])
+	fmt.Fprintf(output(), "Readings per sensor: %v\n", s.PerSensor)
+	printShutdownReport(s.Shutdown)
+}
+
+// composedConfig sets up one run of the composed flow
+type composedConfig struct {
+	Count    int
+	Seed     int64
+	PrintAll bool
+	// Deadline is how long subscribers get to finish their backlogs once
+	// the broadcaster closes; zero means a second
+	Deadline time.Duration
+	// MetricsDelay is how long the metrics subscriber spends on a message
+	MetricsDelay time.Duration
+}
+
+// composedComponents are the components phase one waits for, from the
+// source to the publisher that fans the pool's results in
+var composedComponents = []string{"generator", "parse", "enrich", "pool", "publisher"}
+
+// runComposed runs the whole flow for up to cfg.Count readings. Cancelling
+// ctx shuts it down in two phases: the generator stops and every stage
+// drains what it already holds into the broadcaster, then the broadcaster
+// closes and the subscribers finish their backlogs, or drop what is left at
+// the deadline. Every goroutine exits before runComposed returns. If ctx
+// carries a RunContext, every reading gets a TraceID and each stage records
+// a hop against it.
+func runComposed(ctx context.Context, cfg composedConfig) composedStats {
+	start := time.Now()
+	rc := RunContextFrom(ctx)
+	if cfg.Deadline == 0 {
+		cfg.Deadline = time.Second
+	}
+	sourceCtx, stopSources := context.WithCancel(ctx)
+	defer stopSources()
+	coord := newShutdownCoordinator(stopSources, cfg.Deadline)
+	var generated, parsed, enriched, processed int64
+
+	// Rate-limited generator: 200 readings per second, bursts of 10
+	limiter := newTokenBucketLimiter(200, 10)
+	defer limiter.Stop()
+	raw := make(chan string)
+	generator := coord.component("generator")
+	go func() {
+		defer generator.stop(Completed)
+		defer close(raw)
+		rng := rand.New(rand.NewSource(cfg.Seed))
+		for i := 1; i <= cfg.Count; i++ {
+			if limiter.WaitCtx(sourceCtx) != nil {
+				return
+			}
+			heartbeat()
+			traceID := rc.NextTraceID()
+			line := fmt.Sprintf("%d,sensor-%d,%d,%s", i, rng.Intn(3)+1, rng.Intn(100), traceID)
+			// Hops are recorded before the handoff, so they stay in order
+			traceHop(traceID, "generated")
+			select {
+			case raw <- line:
+				atomic.AddInt64(&generated, 1)
+			case <-sourceCtx.Done():
+				traceHop(traceID, "abandoned")
+				return
+			}
+		}
+	}()
+
+	// Downstream of the generator nothing watches ctx: each stage drains
+	// what it was handed and stops when its input closes
+
+	// Pipeline stage 1: parse the raw lines
+	readings := make(chan sensorReading)
+	parse := coord.component("parse")
+	go func() {
+		defer parse.stop(Completed)
+		defer close(readings)
+		for line := range raw {
+			fields := strings.Split(line, ",")
+			id, _ := strconv.Atoi(fields[0])
+			value, _ := strconv.Atoi(fields[2])
+			r := sensorReading{ID: id, Sensor: fields[1], Value: value, TraceID: fields[3]}
+			traceHop(r.TraceID, "parsed")
+			readings <- r
+			atomic.AddInt64(&parsed, 1)
+		}
+	}()
+
+	// Pipeline stage 2: enrich each reading with a level
+	enrichedReadings := make(chan sensorReading)
+	enrich := coord.component("enrich")
+	go func() {
+		defer enrich.stop(Completed)
+		defer close(enrichedReadings)
+		for r := range readings {
+			r.Level = "normal"
+			if r.Value >= 80 {
+				r.Level = "high"
+			}
+			traceHop(r.TraceID, "enriched")
+			enrichedReadings <- r
+			atomic.AddInt64(&enriched, 1)
+		}
+	}()
+
+	// Worker pool: three workers do the expensive scoring
+	scored := make(chan sensorReading)
+	pool := coord.component("pool")
+	var workers sync.WaitGroup
+	for w := 0; w < 3; w++ {
+		workers.Add(1)
+		go func(w int) {
+			defer workers.Done()
+			for r := range enrichedReadings {
+				time.Sleep(10 * time.Millisecond)
+				r.Score = r.Value * r.Value % 97
+				traceHop(r.TraceID, fmt.Sprintf("scored by worker %d", w))
+				scored <- r
+				atomic.AddInt64(&processed, 1)
+			}
+		}(w + 1)
+	}
+	go func() {
+		defer pool.stop(Completed)
+		workers.Wait()
+		close(scored)
+	}()
+
+	// Two subscribers, a metrics aggregator and a printer, each with room
+	// for a backlog
+	b := newBroadcaster[string]()
+	received := map[string]int{}
+	perSensor := map[string]int{}
+	var mu sync.Mutex
+	backlog := subscriberPolicy{Buffer: 16, Drop: Block}
+	metrics, _ := b.subscribeWith(backlog)
+	printer, _ := b.subscribeWith(backlog)
+	metricsSub, printerSub := coord.subscriber(), coord.subscriber()
+	go func() {
+		defer metricsSub.stop(Completed)
+		for msg := range metrics {
+			if !coord.process("metrics", cfg.MetricsDelay) {
+				continue
+			}
+			traceHop(messageTraceID(msg), "consumed by metrics")
+			mu.Lock()
+			received["metrics"]++
+			perSensor[strings.Fields(msg)[1]]++
+			mu.Unlock()
+		}
+	}()
+	go func() {
+		defer printerSub.stop(Completed)
+		for msg := range printer {
+			if !coord.process("printer", 0) {
+				continue
+			}
+			traceHop(messageTraceID(msg), "consumed by printer")
+			mu.Lock()
+			received["printer"]++
+			n := received["printer"]
+			mu.Unlock()
+			if cfg.PrintAll || n%10 == 0 {
+				fmt.Fprintf(output(), "Printer: %s\n", msg)
+			}
+		}
+	}()
+
+	// The publisher fans the pool's results in and publishes every one
+	published := 0
+	publisher := coord.component("publisher")
+	go func() {
+		defer publisher.stop(Completed)
+		for r := range scored {
+			heartbeat()
+			msg := fmt.Sprintf("reading-%d %s value=%d level=%s score=%d trace=%s", r.ID, r.Sensor, r.Value, r.Level, r.Score, r.TraceID)
+			traceHop(r.TraceID, "published")
+			b.publish(msg)
+			published++
+		}
+	}()
+
+	shutdown := coord.run(ctx, b.close)
+
+	return composedStats{
+		Generated:   int(atomic.LoadInt64(&generated)),
+		Parsed:      int(atomic.LoadInt64(&parsed)),
+		Enriched:    int(atomic.LoadInt64(&enriched)),
+		Processed:   int(atomic.LoadInt64(&processed)),
+		Published:   published,
+		Broadcaster: b.Stats(),
+		Received:    received,
+		PerSensor:   perSensor,
+		Shutdown:    shutdown,
+		Elapsed:     time.Since(start),
+	}
+}
+
+// messageTraceID returns the TraceID at the end of a published message
+func messageTraceID(msg string) string {
+	i := strings.LastIndex(msg, " trace=")
+	if i < 0 {
+		return ""
+	}
+	return msg[i+len(" trace="):]
+}
+
+// composedHops are the hops every published reading makes, in order; the
+// two subscribers may consume it in either order
+var composedHops = []string{"generated", "parsed", "enriched", "scored", "published"}
@@ -0,0 +1,161 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shutdownCoordinator shuts the composed flow down in two phases, so the
+// order things close in can't lose what is already inside. Closing the
+// broadcaster before the pool drains would drop the last results, and
+// closing anything before the generator stops would leave the pipeline
+// filling forever. Phase one stops the sources and waits for every component
+// between them and the broadcaster to report drained through its
+// WorkerLifecycle. Phase two closes the broadcaster and gives the
+// subscribers until the deadline to finish their backlogs; a subscriber
+// still busy then stops processing and counts the rest of its backlog as
+// dropped.
+type shutdownCoordinator struct {
+	stopSources context.CancelFunc
+	deadline    time.Duration
+
+	components  sync.WaitGroup
+	subscribers sync.WaitGroup
+	// expired is closed when phase two runs past the deadline
+	expired chan struct{}
+
+	mu      sync.Mutex
+	drained []string
+	dropped map[string]int
+}
+
+// shutdownReport is how a two-phase shutdown went
+type shutdownReport struct {
+	// Drained lists the components in the order they drained
+	Drained  []string
+	PhaseOne time.Duration
+	PhaseTwo time.Duration
+	// DeadlineHit is set when subscribers were cut off at the deadline
+	DeadlineHit bool
+	// Dropped counts the messages each subscriber had left at the deadline
+	Dropped map[string]int
+}
+
+func newShutdownCoordinator(stopSources context.CancelFunc, deadline time.Duration) *shutdownCoordinator {
+	return &shutdownCoordinator{
+		stopSources: stopSources,
+		deadline:    deadline,
+		expired:     make(chan struct{}),
+		dropped:     make(map[string]int),
+	}
+}
+
+// component registers a source or an intermediate component. It stops the
+// returned lifecycle once it has drained: its input is done and it has
+// closed its output.
+func (c *shutdownCoordinator) component(name string) *WorkerLifecycle {
+	c.components.Add(1)
+	w := &WorkerLifecycle{}
+	w.OnStop(func(StopReason) {
+		c.mu.Lock()
+		c.drained = append(c.drained, name)
+		c.mu.Unlock()
+		c.components.Done()
+	})
+	return w
+}
+
+// subscriber registers a subscriber, which stops the returned lifecycle once
+// its channel is closed and it has worked through or dropped its backlog
+func (c *shutdownCoordinator) subscriber() *WorkerLifecycle {
+	c.subscribers.Add(1)
+	w := &WorkerLifecycle{}
+	w.OnStop(func(StopReason) { c.subscribers.Done() })
+	return w
+}
+
+// process spends d on a message for the named subscriber. It returns false,
+// counting the message as dropped, if the deadline has passed or passes
+// while it works.
+func (c *shutdownCoordinator) process(name string, d time.Duration) bool {
+	select {
+	case <-c.expired:
+	default:
+		if d == 0 || sleepOrDone(d, c.expired) {
+			return true
+		}
+	}
+	c.mu.Lock()
+	c.dropped[name]++
+	c.mu.Unlock()
+	return false
+}
+
+// run waits for ctx to end or for the flow to drain on its own, then shuts
+// it down with closeBroadcaster between the two phases
+func (c *shutdownCoordinator) run(ctx context.Context, closeBroadcaster func()) shutdownReport {
+	drained := make(chan struct{})
+	go func() {
+		c.components.Wait()
+		close(drained)
+	}()
+	select {
+	case <-ctx.Done():
+	case <-drained:
+	}
+
+	var report shutdownReport
+	start := time.Now()
+	c.stopSources()
+	<-drained
+	report.PhaseOne = time.Since(start)
+
+	start = time.Now()
+	closeBroadcaster()
+	finished := make(chan struct{})
+	go func() {
+		c.subscribers.Wait()
+		close(finished)
+	}()
+	timer := time.NewTimer(c.deadline)
+	defer timer.Stop()
+	select {
+	case <-finished:
+	case <-timer.C:
+		report.DeadlineHit = true
+		close(c.expired)
+		<-finished
+	}
+	report.PhaseTwo = time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report.Drained = append([]string(nil), c.drained...)
+	report.Dropped = make(map[string]int, len(c.dropped))
+	for name, n := range c.dropped {
+		report.Dropped[name] = n
+	}
+	return report
+}
+
+func printShutdownReport(r shutdownReport) {
+	fmt.Fprintf(output(), "Phase one: %s drained in %v\n", strings.Join(r.Drained, ", "), r.PhaseOne.Round(time.Millisecond))
+	if !r.DeadlineHit {
+		fmt.Fprintf(output(), "Phase two: subscribers finished their backlogs in %v\n", r.PhaseTwo.Round(time.Millisecond))
+		return
+	}
+	names := make([]string, 0, len(r.Dropped))
+	for name := range r.Dropped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var dropped []string
+	for _, name := range names {
+		dropped = append(dropped, fmt.Sprintf("%s %d", name, r.Dropped[name]))
+	}
+	fmt.Fprintf(output(), "Phase two: deadline hit after %v, dropped %s\n", r.PhaseTwo.Round(time.Millisecond), strings.Join(dropped, ", "))
+}
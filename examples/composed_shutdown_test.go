@@ -0,0 +1,56 @@
+package examples
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestComposedShutdown shuts the composed flow down mid-stream with a slow
+// metrics subscriber, once with a deadline it can meet and once with one it
+// can't, and checks the first loses nothing and the second counts exactly
+// what each subscriber didn't process
+func TestComposedShutdown(t *testing.T) {
+	cases := []struct {
+		name     string
+		deadline time.Duration
+	}{
+		{"generous deadline", time.Second},
+		{"tight deadline", time.Millisecond},
+	}
+	for _, c := range cases {
+		goroutines := runtime.NumGoroutine()
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		stats := runComposed(ctx, composedConfig{Count: 200, Seed: 3, Deadline: c.deadline, MetricsDelay: 8 * time.Millisecond})
+		cancel()
+		if stats.Generated == 0 || stats.Generated >= 200 {
+			t.Fatalf("%s: generated %d of 200 readings, want a shutdown mid-stream", c.name, stats.Generated)
+		}
+		if err := stats.conserved(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		// Every message reached each subscriber's channel, so what they
+		// processed and dropped must add up to the broadcaster's deliveries
+		handled := 0
+		for _, name := range []string{"metrics", "printer"} {
+			handled += stats.Received[name] + stats.Shutdown.Dropped[name]
+		}
+		if handled != stats.Broadcaster.Delivered {
+			t.Fatalf("%s: subscribers processed or dropped %d messages, broadcaster delivered %d", c.name, handled, stats.Broadcaster.Delivered)
+		}
+		drops := stats.Shutdown.Dropped["metrics"] + stats.Shutdown.Dropped["printer"]
+		if c.deadline == time.Second && (stats.Shutdown.DeadlineHit || drops != 0) {
+			t.Fatalf("%s: dropped %v", c.name, stats.Shutdown.Dropped)
+		}
+		if c.deadline == time.Millisecond && (!stats.Shutdown.DeadlineHit || stats.Shutdown.Dropped["metrics"] == 0) {
+			t.Fatalf("%s: deadline hit %v, dropped %v; want the slow subscriber cut off", c.name, stats.Shutdown.DeadlineHit, stats.Shutdown.Dropped)
+		}
+		if len(stats.Shutdown.Drained) != len(composedComponents) {
+			t.Fatalf("%s: drained %v, want %v", c.name, stats.Shutdown.Drained, composedComponents)
+		}
+		if err := waitForGoroutines(goroutines, time.Second); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestRunTraces runs the composed flow twice at once under separate
+// RunContexts, then follows readings through every hop and checks the two
+// runs' TraceIDs don't overlap
+func TestRunTraces(t *testing.T) {
+	runs := []*RunContext{NewRunContext(), NewRunContext()}
+	stats := make([]composedStats, len(runs))
+	var wg sync.WaitGroup
+	for i, rc := range runs {
+		wg.Add(1)
+		go func(i int, rc *RunContext) {
+			defer wg.Done()
+			stats[i] = runComposed(WithRunContext(context.Background(), rc), composedConfig{Count: 20, Seed: int64(i)})
+		}(i, rc)
+	}
+	wg.Wait()
+
+	seen := make(map[string]string)
+	for i, rc := range runs {
+		ids := rc.TraceIDs()
+		if len(ids) != stats[i].Published {
+			t.Fatalf("run %s traced %d readings, published %d", rc.RunID, len(ids), stats[i].Published)
+		}
+		for _, id := range ids {
+			if !strings.HasPrefix(id, rc.RunID+"-") {
+				t.Fatalf("run %s logged TraceID %s", rc.RunID, id)
+			}
+			if other, ok := seen[id]; ok {
+				t.Fatalf("TraceID %s appears in runs %s and %s", id, other, rc.RunID)
+			}
+			seen[id] = rc.RunID
+			if err := checkComposedHops(id, rc.Hops(id)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// checkComposedHops checks one reading made every hop exactly once
+func checkComposedHops(id string, hops []string) error {
+	if len(hops) != len(composedHops)+2 {
+		return fmt.Errorf("%s made hops %v", id, hops)
+	}
+	for i, want := range composedHops {
+		if !strings.HasPrefix(hops[i], want) {
+			return fmt.Errorf("%s made hops %v, want %s at hop %d", id, hops, want, i+1)
+		}
+	}
+	consumers := hops[len(composedHops):]
+	if consumers[0] == consumers[1] || !strings.HasPrefix(consumers[0], "consumed by ") || !strings.HasPrefix(consumers[1], "consumed by ") {
+		return fmt.Errorf("%s made hops %v, want one consumption per subscriber", id, hops)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package examples
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+)
+
+// loopWithCancel runs body for i in [0, n), checking ctx before every every-th
+// iteration. A CPU-bound loop has no channel operation to select on, so these
+// checkpoints are the only place it can notice cancellation: it stops within
+// every iterations of ctx being done. It returns how many iterations ran, and
+// ctx's error if it stopped early.
+func loopWithCancel(ctx context.Context, n int, every int, body func(i int)) (int, error) {
+	if every < 1 {
+		every = 1
+	}
+	for i := 0; i < n; i++ {
+		if i%every == 0 {
+			if err := ctx.Err(); err != nil {
+				return i, err
+			}
+		}
+		body(i)
+	}
+	return n, nil
+}
+
+// cancelLatency is how one checkpointed loop responded to cancellation
+type cancelLatency struct {
+	Every     int
+	Completed int
+	// Latency is the time from cancel to the loop returning
+	Latency time.Duration
+}
+
+// hashUntilCancelled runs an iterative SHA-256 chain with a checkpoint every
+// every iterations and cancels it after cancelAfter
+func hashUntilCancelled(every int, cancelAfter time.Duration) (cancelLatency, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cancelled := make(chan time.Time, 1)
+	go func() {
+		time.Sleep(cancelAfter)
+		cancelled <- time.Now()
+		cancel()
+	}()
+
+	var sum [sha256.Size]byte
+	completed, err := loopWithCancel(ctx, 1<<30, every, func(int) {
+		sum = sha256.Sum256(sum[:])
+	})
+	returned := time.Now()
+	if err == nil {
+		return cancelLatency{Every: every, Completed: completed}, nil
+	}
+	return cancelLatency{Every: every, Completed: completed, Latency: returned.Sub(<-cancelled)}, err
+}
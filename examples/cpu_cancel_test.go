@@ -0,0 +1,64 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLoopWithCancel cancels loops from inside their body at a known
+// iteration and checks each stops at the next checkpoint, reporting the
+// iterations completed and ctx's error, while an uncancelled loop runs to n
+func TestLoopWithCancel(t *testing.T) {
+	for _, c := range []struct {
+		every, cancelAt, want int
+	}{
+		{every: 1000, cancelAt: 2500, want: 3000},
+		{every: 1000, cancelAt: 2999, want: 3000},
+		{every: 1000, cancelAt: 3000, want: 4000},
+		{every: 1, cancelAt: 2500, want: 2501},
+		{every: 0, cancelAt: 10, want: 11}, // clamped to every iteration
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		ran := 0
+		completed, err := loopWithCancel(ctx, 1000000, c.every, func(i int) {
+			ran++
+			if i == c.cancelAt {
+				cancel()
+			}
+		})
+		cancel()
+		if !errors.Is(err, context.Canceled) || completed != c.want || ran != c.want {
+			t.Fatalf("every %d, cancelled at %d: completed %d (body ran %d), error %v; want %d and context.Canceled",
+				c.every, c.cancelAt, completed, ran, err, c.want)
+		}
+	}
+
+	completed, err := loopWithCancel(context.Background(), 5000, 1000, func(int) {})
+	if completed != 5000 || err != nil {
+		t.Fatalf("uncancelled loop completed %d, error %v; want 5000 and nil", completed, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if completed, err := loopWithCancel(ctx, 5000, 1000, func(int) {}); completed != 0 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("loop with a done ctx completed %d, error %v; want 0 and context.Canceled", completed, err)
+	}
+}
+
+// TestHashUntilCancelled cancels the SHA-256 chain with frequent and rare
+// checkpoints and checks each stops on a checkpoint, the frequent one soon
+// after the cancel. The bound is generous: a thousand hashes take well under
+// a millisecond, so only a checkpoint that isn't honoured gets near it.
+func TestHashUntilCancelled(t *testing.T) {
+	for _, every := range []int{1000, 1000000} {
+		l, err := hashUntilCancelled(every, 20*time.Millisecond)
+		if !errors.Is(err, context.Canceled) || l.Completed%every != 0 || l.Completed == 0 {
+			t.Fatalf("checkpoint every %d: stopped after %d iterations, error %v", every, l.Completed, err)
+		}
+		if every == 1000 && l.Latency > 200*time.Millisecond {
+			t.Fatalf("checkpoint every 1000 took %v to stop after the cancel", l.Latency)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sentinel errors shared by the examples. Failures are wrapped with %w so
+// callers can match them with errors.Is regardless of the added context.
+var (
+	// ErrTimeout means an operation ran out of time
+	ErrTimeout = errors.New("operation timed out")
+	// ErrQueueFull means a bounded queue rejected an item
+	ErrQueueFull = errors.New("queue full")
+	// ErrPoolClosed means a pool was used after it was closed
+	ErrPoolClosed = errors.New("pool closed")
+	// ErrCircuitOpen means a circuit breaker rejected a call to a failing dependency
+	ErrCircuitOpen = errors.New("circuit open")
+)
+
+// ErrRetriesExhausted is returned when an operation kept failing until it ran out of attempts
+type ErrRetriesExhausted struct {
+	Attempts int
+	Last     error
+}
+
+func (e *ErrRetriesExhausted) Error() string {
+	if e.Last == nil {
+		return fmt.Sprintf("retries exhausted after %d attempts", e.Attempts)
+	}
+	return fmt.Sprintf("retries exhausted after %d attempts: %v", e.Attempts, e.Last)
+}
+
+func (e *ErrRetriesExhausted) Unwrap() error {
+	return e.Last
+}
+
+// ErrStageTimeout is returned when a named stage of a larger job times out.
+// It matches ErrTimeout with errors.Is.
+type ErrStageTimeout struct {
+	Stage string
+	Err   error
+}
+
+func (e *ErrStageTimeout) Error() string {
+	return fmt.Sprintf("stage %s timed out: %v", e.Stage, e.Err)
+}
+
+func (e *ErrStageTimeout) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrStageTimeout) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// ErrAllWorkersFailed is returned when every worker of a pool or fan-out
+// removed itself. Errs holds each worker's terminal error by worker ID.
+type ErrAllWorkersFailed struct {
+	Errs map[int]error
+}
+
+func (e *ErrAllWorkersFailed) Error() string {
+	ids := make([]int, 0, len(e.Errs))
+	for id := range e.Errs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("worker %d: %v", id, e.Errs[id])
+	}
+	return fmt.Sprintf("all %d workers failed (%s)", len(ids), strings.Join(parts, "; "))
+}
+
+func (e *ErrAllWorkersFailed) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// ErrRetentionGap is returned when a subscriber resumes from a sequence the
+// broadcaster no longer retains: the messages after Resume up to Oldest-1
+// are gone, so the subscriber can't continue without a gap.
+type ErrRetentionGap struct {
+	Resume uint64
+	Oldest uint64
+}
+
+func (e *ErrRetentionGap) Error() string {
+	return fmt.Sprintf("cannot resume after seq %d: oldest retained message is seq %d", e.Resume, e.Oldest)
+}
+
+// errorKind names the category of err for example summaries. The wrapping
+// types come first, so they name the failure rather than the cause they wrap.
+func errorKind(err error) string {
+	var retries *ErrRetriesExhausted
+	var workers *ErrAllWorkersFailed
+	var gap *ErrRetentionGap
+	switch {
+	case err == nil:
+		return "none"
+	case errors.As(err, &retries):
+		return "retries exhausted"
+	case errors.As(err, &workers):
+		return "all workers failed"
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrQueueFull):
+		return "queue full"
+	case errors.Is(err, ErrPoolClosed):
+		return "pool closed"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit open"
+	case errors.As(err, &gap):
+		return "retention gap"
+	default:
+		return "other"
+	}
+}
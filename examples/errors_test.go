@@ -0,0 +1,232 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestErrorMatching checks the wrapped error types match their sentinels
+// with errors.Is, unwrap to their own type with errors.As, and get the
+// expected errorKind, even under extra %w context
+func TestErrorMatching(t *testing.T) {
+	cause := errors.New("disk on fire")
+	cases := []struct {
+		name string
+		err  error
+		is   []error
+		as   func(error) bool
+		kind string
+	}{
+		{
+			name: "stage timeout",
+			err:  &ErrStageTimeout{Stage: "map", Err: fmt.Errorf("deadline: %w", ErrTimeout)},
+			is:   []error{ErrTimeout},
+			as:   func(err error) bool { var e *ErrStageTimeout; return errors.As(err, &e) && e.Stage == "map" },
+			kind: "timeout",
+		},
+		{
+			name: "stage timeout with a foreign cause",
+			err:  &ErrStageTimeout{Stage: "reduce", Err: cause},
+			is:   []error{ErrTimeout, cause},
+			as:   func(err error) bool { var e *ErrStageTimeout; return errors.As(err, &e) && e.Stage == "reduce" },
+			kind: "timeout",
+		},
+		{
+			name: "retries exhausted",
+			err:  &ErrRetriesExhausted{Attempts: 3, Last: fmt.Errorf("get: %w", ErrTimeout)},
+			is:   []error{ErrTimeout},
+			as:   func(err error) bool { var e *ErrRetriesExhausted; return errors.As(err, &e) && e.Attempts == 3 },
+			kind: "retries exhausted",
+		},
+		{
+			name: "all workers failed",
+			err:  &ErrAllWorkersFailed{Errs: map[int]error{1: ErrQueueFull, 2: cause}},
+			is:   []error{ErrQueueFull, cause},
+			as:   func(err error) bool { var e *ErrAllWorkersFailed; return errors.As(err, &e) && len(e.Errs) == 2 },
+			kind: "all workers failed",
+		},
+		{
+			name: "retention gap",
+			err:  &ErrRetentionGap{Resume: 1, Oldest: 4},
+			as:   func(err error) bool { var e *ErrRetentionGap; return errors.As(err, &e) && e.Oldest == 4 },
+			kind: "retention gap",
+		},
+		{
+			name: "circuit open",
+			err:  fmt.Errorf("upstream: %w", ErrCircuitOpen),
+			is:   []error{ErrCircuitOpen},
+			as:   func(err error) bool { var e *ErrRetriesExhausted; return !errors.As(err, &e) },
+			kind: "circuit open",
+		},
+		{
+			name: "pool closed",
+			err:  ErrPoolClosed,
+			is:   []error{ErrPoolClosed},
+			as:   func(err error) bool { var e *ErrStageTimeout; return !errors.As(err, &e) },
+			kind: "pool closed",
+		},
+	}
+	for _, c := range cases {
+		for _, err := range []error{c.err, fmt.Errorf("outer: %w", c.err)} {
+			for _, target := range c.is {
+				if !errors.Is(err, target) {
+					t.Fatalf("%s: %v doesn't match %v", c.name, err, target)
+				}
+			}
+			if !c.as(err) {
+				t.Fatalf("%s: errors.As didn't recover the wrapped type from %v", c.name, err)
+			}
+			if got := errorKind(err); got != c.kind {
+				t.Fatalf("%s: errorKind is %q, want %q", c.name, got, c.kind)
+			}
+		}
+	}
+	if errors.Is(&ErrRetentionGap{Resume: 1, Oldest: 4}, ErrTimeout) {
+		t.Fatalf("a retention gap matched ErrTimeout")
+	}
+}
+
+// TestFailurePathErrors drives each example's failure paths and checks they
+// return the error their doc comments promise
+func TestFailurePathErrors(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	expired := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		t.Cleanup(cancel)
+		<-ctx.Done()
+		return ctx
+	}
+	exhaustedPool := func() *dbConnectionPool {
+		p := newDBConnectionPool(1, 1)
+		p.getConnection()
+		return p
+	}
+	cases := []struct {
+		name string
+		run  func() error
+		is   error
+		as   func(error) bool
+		kind string
+	}{
+		{
+			name: "exhausted pool",
+			run:  func() error { _, err := exhaustedPool().getConnectionCtx(expired()); return err },
+			is:   ErrTimeout,
+			kind: "timeout",
+		},
+		{
+			name: "closed pool",
+			run: func() error {
+				p := newDBConnectionPool(1, 1)
+				p.close()
+				_, err := p.getConnectionCtx(context.Background())
+				return err
+			},
+			is:   ErrPoolClosed,
+			kind: "pool closed",
+		},
+		{
+			name: "retries exhausted",
+			run: func() error {
+				policy := BackoffPolicy{Base: time.Millisecond, Max: time.Millisecond, MaxAttempts: 2, AttemptTimeout: time.Millisecond}
+				_, err := exhaustedPool().GetWithRetry(context.Background(), policy)
+				return err
+			},
+			is:   ErrTimeout,
+			as:   func(err error) bool { var e *ErrRetriesExhausted; return errors.As(err, &e) && e.Attempts == 2 },
+			kind: "retries exhausted",
+		},
+		{
+			name: "open circuit",
+			run: func() error {
+				b := newCircuitBreaker("upstream", 1, time.Hour)
+				b.Do(func() error { return errors.New("down") })
+				return b.Do(func() error { return nil })
+			},
+			is:   ErrCircuitOpen,
+			kind: "circuit open",
+		},
+		{
+			name: "full retry scheduler",
+			run: func() error {
+				s := newRetryScheduler[int](1)
+				defer s.Close(false)
+				s.Schedule(1, time.Hour)
+				return s.Schedule(2, time.Hour)
+			},
+			is:   ErrQueueFull,
+			kind: "queue full",
+		},
+		{
+			name: "semaphore",
+			run: func() error {
+				s := NewSemaphore(1)
+				s.Acquire(context.Background())
+				return s.Acquire(expired())
+			},
+			is:   ErrTimeout,
+			kind: "timeout",
+		},
+		{
+			name: "mapreduce deadline",
+			run: func() error {
+				_, err := MapReduceTimeout([]string{"a b c d e f g h"}, time.Millisecond)
+				return err
+			},
+			is:   context.DeadlineExceeded,
+			as:   func(err error) bool { var e *ErrStageTimeout; return errors.As(err, &e) && e.Stage != "" },
+			kind: "timeout",
+		},
+		{
+			name: "every fan-out worker revoked",
+			run: func() error {
+				jobs, generated := make(chan WorkItem), make(chan struct{})
+				go func() {
+					defer close(generated)
+					defer close(jobs)
+					for i := 0; i < 10; i++ {
+						jobs <- WorkItem{ID: i}
+					}
+				}()
+				results, errc := fanOutDegradable(jobs, 2, revokingWorker(map[int]int{1: 1, 2: 1}))
+				for range results {
+				}
+				<-generated
+				return <-errc
+			},
+			as:   func(err error) bool { var e *ErrAllWorkersFailed; return errors.As(err, &e) && len(e.Errs) == 2 },
+			kind: "all workers failed",
+		},
+		{
+			name: "resume past retention",
+			run: func() error {
+				b := newOrderedBroadcaster(1)
+				defer b.close()
+				b.Publish("m1")
+				b.Publish("m2")
+				_, err := b.Subscribe(0)
+				return err
+			},
+			as:   func(err error) bool { var e *ErrRetentionGap; return errors.As(err, &e) && e.Oldest == 2 },
+			kind: "retention gap",
+		},
+	}
+	for _, c := range cases {
+		err := c.run()
+		if c.is != nil && !errors.Is(err, c.is) {
+			t.Fatalf("%s: returned %v, want a match for %v", c.name, err, c.is)
+		}
+		if c.as != nil && !c.as(err) {
+			t.Fatalf("%s: errors.As didn't recover the documented type from %v", c.name, err)
+		}
+		if got := errorKind(err); got != c.kind {
+			t.Fatalf("%s: errorKind is %q, want %q", c.name, got, c.kind)
+		}
+	}
+}
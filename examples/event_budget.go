@@ -0,0 +1,66 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// handlerBudget bounds how long the handler for one kind of event may run.
+// Past Warn the loop logs a warning and counts the call as over budget; at
+// Cap, if set, the handler's context is cancelled. Cancellation only helps
+// if the handler watches its context.
+type handlerBudget struct {
+	Warn time.Duration
+	Cap  time.Duration
+}
+
+// eventBudgets are the budgets RunEventLoop uses. The system budget is
+// deliberately tighter than the system handler's 150ms, so warnings appear.
+var eventBudgets = map[string]handlerBudget{
+	"user":   {Warn: 200 * time.Millisecond},
+	"system": {Warn: 100 * time.Millisecond, Cap: 300 * time.Millisecond},
+	"timer":  {Warn: 100 * time.Millisecond},
+}
+
+// eventLoopMiddleware is the middleware stack of the event loop: timing
+// outermost, then the handler budgets. backlog, if set, reports how many
+// events are waiting for the loop.
+func eventLoopMiddleware(metrics *eventLoopMetrics, backlog func() int) []eventMiddleware {
+	return []eventMiddleware{metrics.timed, budgeted(eventBudgets, metrics, backlog, nil)}
+}
+
+// budgeted is middleware enforcing per-kind handler budgets. A slow handler
+// stalls the whole loop, so the warning includes the intake backlog that
+// built up behind it. warn, if set, receives each warning instead of stdout.
+func budgeted(budgets map[string]handlerBudget, metrics *eventLoopMetrics, backlog func() int, warn func(string)) eventMiddleware {
+	if warn == nil {
+		warn = func(msg string) { fmt.Fprintln(output(), msg) }
+	}
+	return func(kind string, next eventHandler) eventHandler {
+		budget, ok := budgets[kind]
+		if !ok {
+			return next
+		}
+		return func(ctx context.Context, event string, at time.Time) {
+			if budget.Cap > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, budget.Cap)
+				defer cancel()
+			}
+			start := time.Now()
+			next(ctx, event, at)
+			took := time.Since(start)
+			if budget.Warn <= 0 || took <= budget.Warn {
+				return
+			}
+			waiting := 0
+			if backlog != nil {
+				waiting = backlog()
+			}
+			metrics.recordOverBudget(kind)
+			warn(fmt.Sprintf("Event Loop: WARNING %s handler took %v on %q (budget %v), %d events waiting",
+				kind, took.Round(time.Millisecond), event, budget.Warn, waiting))
+		}
+	}
+}
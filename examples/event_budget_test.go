@@ -0,0 +1,41 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestHandlerBudget runs a scripted slow handler through the budget
+// middleware: it must be warned about, counted, and have its context
+// cancelled at the cap
+func TestHandlerBudget(t *testing.T) {
+	metrics := &eventLoopMetrics{}
+	var warnings []string
+	mw := budgeted(map[string]handlerBudget{"slow": {Warn: 10 * time.Millisecond, Cap: 50 * time.Millisecond}},
+		metrics, func() int { return 3 }, func(msg string) { warnings = append(warnings, msg) })
+
+	var ctxErr error
+	var ranFor time.Duration
+	slow := mw("slow", func(ctx context.Context, event string, at time.Time) {
+		start := time.Now()
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			ctxErr = ctx.Err()
+		}
+		ranFor = time.Since(start)
+	})
+	slow(context.Background(), "scripted", time.Now())
+
+	if !errors.Is(ctxErr, context.DeadlineExceeded) {
+		t.Fatalf("handler context ended with %v, want a deadline at the cap", ctxErr)
+	}
+	if ranFor < 50*time.Millisecond || ranFor > 250*time.Millisecond {
+		t.Fatalf("handler was cancelled after %v, cap is 50ms", ranFor)
+	}
+	if len(warnings) != 1 || metrics.Stats().OverBudget["slow"] != 1 {
+		t.Fatalf("%d warnings and %d over-budget calls, want 1 of each", len(warnings), metrics.Stats().OverBudget["slow"])
+	}
+}
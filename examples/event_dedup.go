@@ -0,0 +1,119 @@
+package examples
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// eventDedup drops events the loop has already seen within a sliding
+// window. Events are strings on the wire, so by default an event's key is its
+// kind and payload, which carries the producer's ID for it ("login
+// (user_3)"); a key function can pick out something else. The first arrival
+// of a key opens its window; a repeat inside it is a duplicate, and one after
+// it is processed as new and opens a fresh window. Keys whose window has
+// passed are forgotten by expire, which the loop calls on its own ticker, so
+// memory stays bounded by the keys seen in the last window. It is safe for
+// concurrent use.
+type eventDedup struct {
+	window time.Duration
+	key    func(kind, event string) string
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// newEventDedup creates a dedup stage; a nil key keys events by kind and
+// payload
+func newEventDedup(window time.Duration, key func(kind, event string) string) *eventDedup {
+	if key == nil {
+		key = func(kind, event string) string { return kind + ":" + event }
+	}
+	return &eventDedup{window: window, key: key, seen: make(map[string]time.Time)}
+}
+
+// duplicate reports whether the event received at the given time repeats one
+// seen within the window, and otherwise remembers it. A nil dedup lets every
+// event through.
+func (d *eventDedup) duplicate(kind, event string, at time.Time) bool {
+	if d == nil {
+		return false
+	}
+	k := d.key(kind, event)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if first, ok := d.seen[k]; ok && at.Sub(first) < d.window {
+		return true
+	}
+	d.seen[k] = at
+	return false
+}
+
+// expire forgets the keys whose window had passed by now and returns how
+// many it forgot
+func (d *eventDedup) expire(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	forgotten := 0
+	for k, first := range d.seen {
+		if now.Sub(first) >= d.window {
+			delete(d.seen, k)
+			forgotten++
+		}
+	}
+	return forgotten
+}
+
+// Len returns how many keys the dedup stage is tracking
+func (d *eventDedup) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.seen)
+}
+
+// expiryInterval is how often the loop expires keys: twice per window, so a
+// key outlives its window by at most half of one
+func (d *eventDedup) expiryInterval() time.Duration {
+	return d.window / 2
+}
+
+// eventResender sends events the way an at-least-once source does: about
+// rate of them are sent a second time shortly after, as a retry would be
+type eventResender struct {
+	rate  float64
+	delay time.Duration
+
+	mu     sync.Mutex
+	resent map[string]int
+}
+
+func newEventResender(rate float64, delay time.Duration) *eventResender {
+	return &eventResender{rate: rate, delay: delay, resent: make(map[string]int)}
+}
+
+// send queues event, then maybe queues it again; a nil resender sends it
+// once. A resend is counted only once the queue has taken it.
+func (r *eventResender) send(q *eventQueue, event string) {
+	q.Send(event)
+	if r == nil || rand.Float64() >= r.rate {
+		return
+	}
+	time.Sleep(r.delay)
+	if !q.Send(event) {
+		return
+	}
+	r.mu.Lock()
+	r.resent[q.kind]++
+	r.mu.Unlock()
+}
+
+// Resent returns how many events of each kind were queued twice
+func (r *eventResender) Resent() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]int, len(r.resent))
+	for kind, n := range r.resent {
+		out[kind] = n
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package examples
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEventDedup scripts arrivals at set times: repeats inside the window
+// are dropped and counted, the originals and repeats after the window are
+// processed, expiry forgets keys as their windows pass, a key function can
+// treat different payloads as one event, and the loop's own ticker expires
+// keys while it runs
+func TestEventDedup(t *testing.T) {
+	base := time.Unix(0, 0)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+	metrics := &eventLoopMetrics{}
+	dedup := newEventDedup(100*time.Millisecond, nil)
+	cfg := eventLoopConfig{metrics: metrics, dedup: dedup}
+
+	// "test" has no handler, so dispatch only records the event
+	script := []struct {
+		ms    int
+		event string
+	}{
+		{0, "a"}, {10, "b"},
+		{50, "a"},  // inside a's window: dropped
+		{150, "a"}, // after it: processed, and opens a new window
+		{120, "b"}, // after b's window: processed
+		{200, "a"}, // inside a's second window: dropped
+	}
+	for _, s := range script {
+		dispatchEvent(cfg, "test", s.event, at(s.ms))
+	}
+	stats := metrics.Stats()
+	if stats.Duplicates["test"] != 2 || stats.Processed != 4 || stats.Received != 6 {
+		t.Fatalf("received %d, processed %d, dropped %d duplicates; want 6, 4 and 2",
+			stats.Received, stats.Processed, stats.Duplicates["test"])
+	}
+
+	if dedup.Len() != 2 {
+		t.Fatalf("tracking %d keys, want 2", dedup.Len())
+	}
+	if n := dedup.expire(at(230)); n != 1 || dedup.Len() != 1 {
+		t.Fatalf("expiry at 230ms forgot %d keys, %d left; want b forgotten and a kept", n, dedup.Len())
+	}
+	if n := dedup.expire(at(250)); n != 1 || dedup.Len() != 0 {
+		t.Fatalf("expiry at 250ms forgot %d keys, %d left; want none left", n, dedup.Len())
+	}
+
+	// Keyed by the producer's ID alone, a retried login with a new action is
+	// still the same event
+	byID := newEventDedup(100*time.Millisecond, func(kind, event string) string {
+		_, id, _ := strings.Cut(event, "(")
+		return id
+	})
+	if byID.duplicate("user", "login (user_1)", at(0)) || !byID.duplicate("user", "logout (user_1)", at(20)) ||
+		byID.duplicate("user", "login (user_2)", at(30)) {
+		t.Fatalf("key function: duplicates not matched by ID")
+	}
+
+	// The running loop drops the repeat, then expires the key on its own
+	// ticker once the loop is idle
+	loopMetrics := &eventLoopMetrics{}
+	loopDedup := newEventDedup(200*time.Millisecond, nil)
+	timers := make(chan string, 2)
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		eventLoop(nil, nil, timers, shutdown, eventLoopConfig{metrics: loopMetrics, dedup: loopDedup})
+	}()
+	timers <- "tick 1"
+	timers <- "tick 1"
+	dropped := pollUntil(func() bool { return loopMetrics.Stats().Duplicates["timer"] == 1 }, 5*time.Millisecond, time.Second)
+	tracked := loopDedup.Len()
+	forgotten := pollUntil(func() bool { return loopDedup.Len() == 0 }, 5*time.Millisecond, time.Second)
+	close(shutdown)
+	<-done
+	if !dropped || tracked != 1 || !forgotten {
+		t.Fatalf("running loop: repeat dropped %v, %d keys tracked after it, expired %v", dropped, tracked, forgotten)
+	}
+}
+
+// TestEventResenderCountsQueuedResends checks a resend is counted only when
+// the queue takes it: a full drop-newest queue turns the resend away
+func TestEventResenderCountsQueuedResends(t *testing.T) {
+	metrics := &eventLoopMetrics{}
+	resender := newEventResender(1, 0)
+
+	open := newEventQueue("user", 2, DropNewest, metrics)
+	resender.send(open, "login (user_1)")
+	if n := resender.Resent()["user"]; n != 1 || open.Len() != 2 {
+		t.Fatalf("queue with room: %d resends counted, %d events queued; want 1 and 2", n, open.Len())
+	}
+
+	full := newEventQueue("system", 1, DropNewest, metrics)
+	resender.send(full, "backup (system_1)")
+	if n := resender.Resent()["system"]; n != 0 {
+		t.Fatalf("full queue: %d resends counted, the resend was dropped", n)
+	}
+	if n := metrics.Stats().Dropped["system"]; n != 1 {
+		t.Fatalf("full queue: %d events dropped, want 1", n)
+	}
+}
@@ -0,0 +1,98 @@
+package examples
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// loggedEvent is one line of the event log
+type loggedEvent struct {
+	Seq     int       `json:"seq"`
+	Kind    string    `json:"kind"`
+	Payload string    `json:"payload"`
+	At      time.Time `json:"at"`
+}
+
+// eventLog appends every dispatched event to a file as a JSON line
+type eventLog struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	seq  int
+}
+
+func newEventLog(path string) (*eventLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open event log: %w", err)
+	}
+	return &eventLog{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// append records an event; a nil log records nothing
+func (l *eventLog) append(kind, payload string, at time.Time) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	if err := l.enc.Encode(loggedEvent{Seq: l.seq, Kind: kind, Payload: payload, At: at}); err != nil {
+		return fmt.Errorf("append event %d: %w", l.seq, err)
+	}
+	return nil
+}
+
+func (l *eventLog) close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// readEventLog loads a log written by eventLog, checking that no sequence
+// numbers are missing
+func readEventLog(path string) ([]loggedEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open event log: %w", err)
+	}
+	defer f.Close()
+
+	var events []loggedEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e loggedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("event log line %d: %w", len(events)+1, err)
+		}
+		if e.Seq != len(events)+1 {
+			return nil, fmt.Errorf("event log: expected seq %d, found %d", len(events)+1, e.Seq)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read event log: %w", err)
+	}
+	return events, nil
+}
+
+// replayEvents feeds logged events through the loop's handlers in their
+// original order. With realtime set, the gaps between the original
+// timestamps are reproduced; otherwise events are replayed back to back.
+func replayEvents(events []loggedEvent, realtime bool, cfg eventLoopConfig) {
+	start := time.Now()
+	for _, e := range events {
+		if realtime {
+			if wait := e.At.Sub(events[0].At) - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		fmt.Fprintf(output(), "Replay #%d: ", e.Seq)
+		dispatchEvent(cfg, e.Kind, e.Payload, e.At)
+	}
+}
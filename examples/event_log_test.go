@@ -0,0 +1,126 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// handledEvent is one call that reached the end of the handler chain
+type handledEvent struct {
+	kind, event string
+	at          time.Time
+}
+
+// recordingHandler is innermost middleware that records each call instead
+// of running the slow simulated handler
+type recordingHandler struct {
+	mu    sync.Mutex
+	calls []handledEvent
+}
+
+func (r *recordingHandler) middleware(kind string, _ eventHandler) eventHandler {
+	return func(_ context.Context, event string, at time.Time) {
+		r.mu.Lock()
+		r.calls = append(r.calls, handledEvent{kind, event, at})
+		r.mu.Unlock()
+	}
+}
+
+func (r *recordingHandler) handled() []handledEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]handledEvent(nil), r.calls...)
+}
+
+// TestEventLogReplay records a run of the loop that includes repeated
+// events, replays the log through the same handler chain, and checks the
+// handlers see the same events with the same timestamps in the same order,
+// and the per-kind counts and the store match the recording
+func TestEventLogReplay(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	log, err := newEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := &recordingHandler{}
+	recMetrics := &eventLoopMetrics{}
+	recCfg := newEventLoopConfig(recMetrics, nil)
+	recCfg.log = log
+	recCfg.middleware = append(recCfg.middleware, recorded.middleware)
+
+	user, system, timer := make(chan string), make(chan string), make(chan string)
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		eventLoop(user, system, timer, shutdown, recCfg)
+	}()
+	sends := []struct {
+		ch    chan string
+		event string
+	}{
+		{user, "login (user_1)"}, {timer, "tick 1"}, {system, "backup (system_1)"},
+		{user, "login (user_1)"}, // resent within the dedup window
+		{user, "click (user_2)"}, {timer, "tick 2"}, {system, "backup (system_1)"},
+		{system, "alert (system_2)"}, {user, "scroll (user_3)"}, {timer, "tick 3"},
+	}
+	for _, s := range sends {
+		s.ch <- s.event
+	}
+	close(shutdown)
+	<-done
+	if err := log.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := readEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := &recordingHandler{}
+	repMetrics := &eventLoopMetrics{}
+	repCfg := newEventLoopConfig(repMetrics, nil)
+	repCfg.middleware = append(repCfg.middleware, replayed.middleware)
+	replayEvents(events, false, repCfg)
+
+	want, got := recorded.handled(), replayed.handled()
+	if len(want) != len(sends)-2 || len(events) != len(want) {
+		t.Fatalf("recording handled %d of %d events and logged %d, want the 2 resends dropped", len(want), len(sends), len(events))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("replay handled %d events, the recording %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].kind != want[i].kind || got[i].event != want[i].event || !got[i].at.Equal(want[i].at) {
+			t.Fatalf("handler call %d: replay saw %+v, recording %+v", i, got[i], want[i])
+		}
+	}
+
+	rec, rep := recMetrics.Stats(), repMetrics.Stats()
+	if fmt.Sprint(rep.ByKind) != fmt.Sprint(rec.ByKind) || fmt.Sprint(rep.Timed) != fmt.Sprint(rec.Timed) {
+		t.Fatalf("replay processed %v and timed %v, recording %v and %v", rep.ByKind, rep.Timed, rec.ByKind, rec.Timed)
+	}
+	if rec.Duplicates["user"] != 1 || rec.Duplicates["system"] != 1 || len(rep.Duplicates) != 0 {
+		t.Fatalf("duplicates: recording %v, replay %v; want one user and one system, then none", rec.Duplicates, rep.Duplicates)
+	}
+	for _, kind := range []string{"user", "system", "timer"} {
+		var wantPayloads, gotPayloads []string
+		for _, e := range recCfg.store.Query(kind, time.Time{}) {
+			wantPayloads = append(wantPayloads, e.Payload)
+		}
+		for _, e := range repCfg.store.Query(kind, time.Time{}) {
+			gotPayloads = append(gotPayloads, e.Payload)
+		}
+		if fmt.Sprint(gotPayloads) != fmt.Sprint(wantPayloads) {
+			t.Fatalf("%s events in the store: replay %v, recording %v", kind, gotPayloads, wantPayloads)
+		}
+	}
+}
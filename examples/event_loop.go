@@ -1,114 +1,538 @@
 package examples
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 )
 
 // RunEventLoop demonstrates the event loop pattern.
-func RunEventLoop() {
-	fmt.Println("=== Event Loop Pattern Example ===")
+func RunEventLoop(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Event Loop Pattern Example ===")
 
-	// Create event channels
-	userEvents := make(chan string, 10)
-	systemEvents := make(chan string, 10)
-	timerEvents := make(chan string, 10)
+	if opts.Replay != "" {
+		replayEventLoop(opts.Replay, opts.ReplayRealtime)
+		return
+	}
+
+	policy, err := parseDropPolicy(opts.EventDropPolicy)
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+
+	phase("queues")
+	// Create event queues; policy decides what a full one does
+	metrics := &eventLoopMetrics{}
+	userEvents := newEventQueue("user", 10, policy, metrics)
+	systemEvents := newEventQueue("system", 10, policy, metrics)
+	timerEvents := newEventQueue("timer", 10, policy, metrics)
 	shutdown := make(chan struct{})
+	fmt.Fprintf(output(), "Event queues hold 10 events each, %s when full\n", policy)
 
-	// Start event producers
-	go userEventProducer(userEvents)
-	go systemEventProducer(systemEvents)
-	go timerEventProducer(timerEvents)
+	// Start event producers; like at-least-once sources, the user and system
+	// producers send about 20% of their events twice
+	resender := newEventResender(0.2, 50*time.Millisecond)
+	stopProducers := make(chan struct{})
+	var producers sync.WaitGroup
+	producers.Add(3)
+	go func() {
+		defer producers.Done()
+		userEventProducer(userEvents, resender, stopProducers)
+	}()
+	go func() {
+		defer producers.Done()
+		systemEventProducer(systemEvents, resender, stopProducers)
+	}()
+	go func() {
+		defer producers.Done()
+		timerEventProducer(timerEvents, stopProducers)
+	}()
 
 	// Start the event loop
-	go eventLoop(userEvents, systemEvents, timerEvents, shutdown)
+	publishStats("event_loop", func() interface{} { return metrics.Stats() })
+	backlog := func() int { return userEvents.Len() + systemEvents.Len() + timerEvents.Len() }
+	cfg := newEventLoopConfig(metrics, backlog)
+	store, dedup := cfg.store, cfg.dedup
+	cfg.idleTimeout = 500 * time.Millisecond
+	cfg.run = RunContextFrom(ctx)
+	cfg.onIdle = func() {
+		fmt.Fprintln(output(), "Event Loop: No events for 500ms, running idle maintenance")
+	}
+	if opts.EventLog != "" {
+		log, err := newEventLog(opts.EventLog)
+		if err != nil {
+			fail("%v", err)
+			return
+		}
+		defer func() {
+			if err := log.close(); err != nil {
+				fail("close event log: %v", err)
+			}
+		}()
+		cfg.log = log
+		fmt.Fprintf(output(), "Recording events to %s\n", opts.EventLog)
+	}
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		eventLoop(userEvents.C(), systemEvents.C(), timerEvents.C(), shutdown, cfg)
+	}()
 
+	phase("run")
 	// Let the system run for a while
-	time.Sleep(5 * time.Second)
+	pause(5 * time.Second)
 
-	// Shutdown
-	fmt.Println("Shutting down event loop...")
+	phase("shutdown")
+	// Stop the producers first and let the loop drain what they queued, so
+	// every event sent, resends included, is dispatched before it stops
+	fmt.Fprintln(output(), "Shutting down event loop...")
+	close(stopProducers)
+	producers.Wait()
+	if !pollUntil(func() bool { return backlog() == 0 }, 10*time.Millisecond, 2*time.Second) {
+		fmt.Fprintf(output(), "Event loop: %d events still queued at shutdown\n", backlog())
+	}
 	close(shutdown)
+	<-loopDone
+	stats := metrics.Stats()
+	printEventLoopStats(stats)
+	record("stats", stats)
+
+	// Every resend arrives 50ms after its original, well inside the 1s
+	// window, so each is dropped unless a full queue dropped it first
+	resent := resender.Resent()
+	fmt.Fprintf(output(), "Dedup (1s window): producers resent %d user and %d system events; dropped %d and %d as duplicates; %d keys still tracked\n",
+		resent["user"], resent["system"], stats.Duplicates["user"], stats.Duplicates["system"], dedup.Len())
+	record("dedup", map[string]interface{}{"resent": resent, "duplicates": stats.Duplicates, "tracked_keys": dedup.Len()})
+	verify("event dedup", func() error {
+		if policy != Block {
+			return nil
+		}
+		for _, kind := range []string{"user", "system"} {
+			if stats.Duplicates[kind] != resent[kind] {
+				return fmt.Errorf("%d %s events resent, %d dropped as duplicates", resent[kind], kind, stats.Duplicates[kind])
+			}
+		}
+		return nil
+	})
+
+	phase("store")
+	// What happened recently, from the bounded event store
+	since := time.Now().Add(-2 * time.Second)
+	recent := store.Query("system", since)
+	fmt.Fprintf(output(), "System events in the last 2s (store holds the latest %d of %d processed):\n", store.Len(), stats.Processed)
+	for _, e := range recent {
+		fmt.Fprintf(output(), "  %s %s\n", e.At.Format("15:04:05.000"), e.Payload)
+	}
+	record("recent_system_events", recent)
+	verify("event store", func() error {
+		for _, e := range recent {
+			if e.Type != "system" || e.At.Before(since) {
+				return fmt.Errorf("query for system events since %v returned %s event at %v", since, e.Type, e.At)
+			}
+		}
+		if store.Len() > 10 {
+			return fmt.Errorf("holds %d events, capacity 10", store.Len())
+		}
+		return nil
+	})
+	fmt.Fprintln(output(), "Event loop example completed!")
+}
 
-	// Wait a bit for cleanup
-	time.Sleep(500 * time.Millisecond)
-	fmt.Println("Event loop example completed!")
+// newEventLoopConfig is the handler chain RunEventLoop dispatches through,
+// and replays use: dedup, then the timing and budget middleware around each
+// handler, then the store. backlog, if set, reports the events waiting.
+func newEventLoopConfig(metrics *eventLoopMetrics, backlog func() int) eventLoopConfig {
+	return eventLoopConfig{
+		metrics:    metrics,
+		store:      newEventStore(10),
+		dedup:      newEventDedup(time.Second, nil),
+		middleware: eventLoopMiddleware(metrics, backlog),
+	}
+}
+
+// replayEventLoop runs a recorded event log back through the same handler
+// chain the loop used to record it
+func replayEventLoop(path string, realtime bool) {
+	events, err := readEventLog(path)
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+	fmt.Fprintf(output(), "Replaying %d events from %s\n", len(events), path)
+	metrics := &eventLoopMetrics{}
+	cfg := newEventLoopConfig(metrics, nil)
+	replayEvents(events, realtime, cfg)
+	stats := metrics.Stats()
+	printEventLoopStats(stats)
+	record("stats", stats)
+	fmt.Fprintf(output(), "Store holds the latest %d of %d replayed events\n", cfg.store.Len(), stats.Processed)
+	fmt.Fprintln(output(), "Event loop replay completed!")
+}
+
+func printEventLoopStats(stats eventLoopStats) {
+	fmt.Fprintf(output(), "Event loop stats: received %d, processed %d (user %d, system %d, timer %d)\n",
+		stats.Received, stats.Processed, stats.ByKind["user"], stats.ByKind["system"], stats.ByKind["timer"])
+	for _, kind := range []string{"user", "system", "timer"} {
+		if n := stats.Timed[kind]; n > 0 {
+			fmt.Fprintf(output(), "  %s handler: %d calls, %v average, %d over budget\n",
+				kind, n, (stats.Durations[kind] / time.Duration(n)).Round(time.Millisecond), stats.OverBudget[kind])
+		}
+		if n := stats.Dropped[kind]; n > 0 {
+			fmt.Fprintf(output(), "  %s queue: %d events dropped while full\n", kind, n)
+		}
+		if n := stats.Duplicates[kind]; n > 0 {
+			fmt.Fprintf(output(), "  %s events: %d duplicates dropped\n", kind, n)
+		}
+	}
+}
+
+// eventLoopStats is a snapshot of the event loop's counters. It is built under
+// one lock, so Processed never exceeds Received in any snapshot.
+type eventLoopStats struct {
+	Received  int
+	Processed int
+	ByKind    map[string]int
+	// Timed and Durations are the calls and total handler time per kind, as
+	// seen by the timing middleware
+	Timed     map[string]int
+	Durations map[string]time.Duration
+	// OverBudget counts the handler calls per kind that ran past their budget
+	OverBudget map[string]int
+	// Dropped counts the events per kind a full queue discarded
+	Dropped map[string]int
+	// Duplicates counts the events per kind the dedup stage discarded; they
+	// are received but not processed
+	Duplicates map[string]int
+}
+
+// eventLoopMetrics collects counters from the loop goroutine for readers elsewhere
+type eventLoopMetrics struct {
+	mu         sync.Mutex
+	received   int
+	processed  int
+	byKind     map[string]int
+	calls      map[string]int
+	durations  map[string]time.Duration
+	overBudget map[string]int
+	dropped    map[string]int
+	duplicates map[string]int
+	// now is the timing middleware's clock; nil means time.Now
+	now func() time.Time
+}
+
+func (m *eventLoopMetrics) recordReceived() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.received++
+	m.mu.Unlock()
+}
+
+func (m *eventLoopMetrics) recordProcessed(kind string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.processed++
+	if m.byKind == nil {
+		m.byKind = make(map[string]int)
+	}
+	m.byKind[kind]++
+	m.mu.Unlock()
+}
+
+// timed is middleware recording how long each handler call takes. Only the
+// call itself is timed, not the time the event spent waiting in the select.
+func (m *eventLoopMetrics) timed(kind string, next eventHandler) eventHandler {
+	now := m.now
+	if now == nil {
+		now = time.Now
+	}
+	return func(ctx context.Context, event string, at time.Time) {
+		start := now()
+		next(ctx, event, at)
+		took := now().Sub(start)
+
+		m.mu.Lock()
+		if m.calls == nil {
+			m.calls = make(map[string]int)
+			m.durations = make(map[string]time.Duration)
+		}
+		m.calls[kind]++
+		m.durations[kind] += took
+		m.mu.Unlock()
+	}
+}
+
+func (m *eventLoopMetrics) recordOverBudget(kind string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	if m.overBudget == nil {
+		m.overBudget = make(map[string]int)
+	}
+	m.overBudget[kind]++
+	m.mu.Unlock()
+}
+
+func (m *eventLoopMetrics) recordDropped(kind string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	if m.dropped == nil {
+		m.dropped = make(map[string]int)
+	}
+	m.dropped[kind]++
+	m.mu.Unlock()
+}
+
+func (m *eventLoopMetrics) recordDuplicate(kind string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	if m.duplicates == nil {
+		m.duplicates = make(map[string]int)
+	}
+	m.duplicates[kind]++
+	m.mu.Unlock()
+}
+
+// Stats returns a consistent snapshot of the loop's counters
+func (m *eventLoopMetrics) Stats() eventLoopStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := eventLoopStats{
+		Received:   m.received,
+		Processed:  m.processed,
+		ByKind:     make(map[string]int, len(m.byKind)),
+		Timed:      make(map[string]int, len(m.calls)),
+		Durations:  make(map[string]time.Duration, len(m.durations)),
+		OverBudget: make(map[string]int, len(m.overBudget)),
+		Dropped:    make(map[string]int, len(m.dropped)),
+		Duplicates: make(map[string]int, len(m.duplicates)),
+	}
+	for kind, n := range m.dropped {
+		stats.Dropped[kind] = n
+	}
+	for kind, n := range m.duplicates {
+		stats.Duplicates[kind] = n
+	}
+	for kind, n := range m.overBudget {
+		stats.OverBudget[kind] = n
+	}
+	for kind, n := range m.byKind {
+		stats.ByKind[kind] = n
+	}
+	for kind, n := range m.calls {
+		stats.Timed[kind] = n
+		stats.Durations[kind] = m.durations[kind]
+	}
+	return stats
+}
+
+// eventLoopConfig holds the optional behaviors of the event loop
+type eventLoopConfig struct {
+	// metrics, if set, counts received and processed events
+	metrics *eventLoopMetrics
+
+	// middleware wraps every handler call, first entry outermost
+	middleware []eventMiddleware
+
+	// idleTimeout is how long the loop may go without an event before onIdle
+	// fires. Zero disables idle detection.
+	idleTimeout time.Duration
+	onIdle      func()
+
+	// log, if set, records every dispatched event for --replay
+	log *eventLog
+
+	// store, if set, keeps the most recent processed events for queries
+	store *eventStore
+
+	// run, if set, gives every dispatched event a TraceID
+	run *RunContext
+
+	// dedup, if set, drops events repeated within its window; the loop
+	// expires its old keys on a ticker
+	dedup *eventDedup
 }
 
 // Event loop that processes events from multiple sources
-func eventLoop(userEvents, systemEvents, timerEvents <-chan string, shutdown <-chan struct{}) {
-	fmt.Println("Event loop started...")
+func eventLoop(userEvents, systemEvents, timerEvents <-chan string, shutdown <-chan struct{}, cfg eventLoopConfig) {
+	fmt.Fprintln(output(), "Event loop started...")
+
+	// The idle timer is re-armed after every event, so it only fires when
+	// nothing has arrived for a full idleTimeout
+	var idle <-chan time.Time
+	var idleTimer *time.Timer
+	if cfg.idleTimeout > 0 && cfg.onIdle != nil {
+		idleTimer = time.NewTimer(cfg.idleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+	resetIdle := func() {
+		if idleTimer == nil {
+			return
+		}
+		if !idleTimer.Stop() {
+			select {
+			case <-idleTimer.C:
+			default:
+			}
+		}
+		idleTimer.Reset(cfg.idleTimeout)
+	}
+
+	// The dedup stage forgets keys on its own ticker, whether or not events
+	// are arriving
+	var expiry <-chan time.Time
+	if cfg.dedup != nil {
+		ticker := time.NewTicker(cfg.dedup.expiryInterval())
+		defer ticker.Stop()
+		expiry = ticker.C
+	}
 
 	for {
+		heartbeat()
 		select {
 		case event := <-userEvents:
-			fmt.Printf("Event Loop: Processing user event: %s\n", event)
-			processUserEvent(event)
+			dispatchEvent(cfg, "user", event, time.Now())
+			resetIdle()
 
 		case event := <-systemEvents:
-			fmt.Printf("Event Loop: Processing system event: %s\n", event)
-			processSystemEvent(event)
+			dispatchEvent(cfg, "system", event, time.Now())
+			resetIdle()
 
 		case event := <-timerEvents:
-			fmt.Printf("Event Loop: Processing timer event: %s\n", event)
-			processTimerEvent(event)
+			dispatchEvent(cfg, "timer", event, time.Now())
+			resetIdle()
+
+		case <-idle:
+			cfg.onIdle()
+			idleTimer.Reset(cfg.idleTimeout)
+
+		case now := <-expiry:
+			cfg.dedup.expire(now)
 
 		case <-shutdown:
-			fmt.Println("Event Loop: Shutdown signal received, cleaning up...")
+			fmt.Fprintln(output(), "Event Loop: Shutdown signal received, cleaning up...")
 			return
 		}
 	}
 }
 
-// Event producers
-func userEventProducer(events chan<- string) {
+// dispatchEvent records an event and hands it to its handler, unless the
+// dedup stage drops it as a repeat, in which case it isn't logged either.
+// Handlers get the time the event was received rather than reading the
+// clock, so a replayed event is handled exactly as it was originally.
+func dispatchEvent(cfg eventLoopConfig, kind, event string, at time.Time) {
+	traceID := cfg.run.NextTraceID()
+	traceHop(traceID, "received "+kind+" event")
+	cfg.metrics.recordReceived()
+	if cfg.dedup.duplicate(kind, event, at) {
+		fmt.Fprintf(output(), "Event Loop: Dropping duplicate %s event: %s\n", kind, event)
+		traceHop(traceID, "dropped as duplicate")
+		cfg.metrics.recordDuplicate(kind)
+		return
+	}
+	if err := cfg.log.append(kind, event, at); err != nil {
+		fmt.Fprintf(output(), "Event Loop: %v\n", err)
+	}
+	fmt.Fprintf(output(), "Event Loop: Processing %s event: %s\n", kind, event)
+	if handler, ok := eventHandlers[kind]; ok {
+		for i := len(cfg.middleware) - 1; i >= 0; i-- {
+			handler = cfg.middleware[i](kind, handler)
+		}
+		handler(context.Background(), event, at)
+		traceHop(traceID, "handled")
+	}
+	cfg.metrics.recordProcessed(kind)
+	cfg.store.add(Event{Type: kind, Payload: event, At: at, TraceID: traceID})
+}
+
+// eventHandler processes one event received at the given time. Handlers
+// should give up when ctx is done.
+type eventHandler func(ctx context.Context, event string, at time.Time)
+
+// eventMiddleware wraps the handler for one kind of event, so behavior like
+// timing can be added to every processor without editing them
+type eventMiddleware func(kind string, next eventHandler) eventHandler
+
+// eventHandlers maps each event kind to its processor
+var eventHandlers = map[string]eventHandler{
+	"user":   processUserEvent,
+	"system": processSystemEvent,
+	"timer":  processTimerEvent,
+}
+
+// Event producers; each returns once stop is closed
+func userEventProducer(events *eventQueue, resender *eventResender, stop <-chan struct{}) {
 	userActions := []string{"login", "logout", "click", "scroll", "submit"}
 	for i := 0; i < 8; i++ {
-		time.Sleep(time.Duration(rand.Intn(800)+200) * time.Millisecond)
+		if !sleepOrDone(time.Duration(rand.Intn(800)+200)*time.Millisecond, stop) {
+			return
+		}
 		action := userActions[rand.Intn(len(userActions))]
-		events <- fmt.Sprintf("%s (user_%d)", action, i+1)
+		resender.send(events, fmt.Sprintf("%s (user_%d)", action, i+1))
 	}
 }
 
-func systemEventProducer(events chan<- string) {
+func systemEventProducer(events *eventQueue, resender *eventResender, stop <-chan struct{}) {
 	systemEvents := []string{"backup", "update", "maintenance", "alert", "sync"}
 	for i := 0; i < 6; i++ {
-		time.Sleep(time.Duration(rand.Intn(1000)+500) * time.Millisecond)
+		if !sleepOrDone(time.Duration(rand.Intn(1000)+500)*time.Millisecond, stop) {
+			return
+		}
 		event := systemEvents[rand.Intn(len(systemEvents))]
-		events <- fmt.Sprintf("%s (system_%d)", event, i+1)
+		resender.send(events, fmt.Sprintf("%s (system_%d)", event, i+1))
 	}
 }
 
-func timerEventProducer(events chan<- string) {
+func timerEventProducer(events *eventQueue, stop <-chan struct{}) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	count := 0
-	for range ticker.C {
-		if count >= 5 {
-			break
+	for count := 0; count < 5; count++ {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
 		}
-		events <- fmt.Sprintf("heartbeat (timer_%d)", count+1)
-		count++
+		events.Send(fmt.Sprintf("heartbeat (timer_%d)", count+1))
 	}
 }
 
 // Event processors
-func processUserEvent(event string) {
+func processUserEvent(ctx context.Context, event string, at time.Time) {
 	// Simulate processing time
-	time.Sleep(100 * time.Millisecond)
-	fmt.Printf("  -> User event processed: %s\n", event)
+	if !sleepOrDone(100*time.Millisecond, ctx.Done()) {
+		fmt.Fprintf(output(), "  -> User event abandoned: %s (%v)\n", event, ctx.Err())
+		return
+	}
+	fmt.Fprintf(output(), "  -> User event processed: %s (received %s)\n", event, at.Format("15:04:05.000"))
 }
 
-func processSystemEvent(event string) {
+func processSystemEvent(ctx context.Context, event string, at time.Time) {
 	// Simulate processing time
-	time.Sleep(150 * time.Millisecond)
-	fmt.Printf("  -> System event processed: %s\n", event)
+	if !sleepOrDone(150*time.Millisecond, ctx.Done()) {
+		fmt.Fprintf(output(), "  -> System event abandoned: %s (%v)\n", event, ctx.Err())
+		return
+	}
+	fmt.Fprintf(output(), "  -> System event processed: %s (received %s)\n", event, at.Format("15:04:05.000"))
 }
 
-func processTimerEvent(event string) {
+func processTimerEvent(ctx context.Context, event string, at time.Time) {
 	// Simulate processing time
-	time.Sleep(50 * time.Millisecond)
-	fmt.Printf("  -> Timer event processed: %s\n", event)
+	if !sleepOrDone(50*time.Millisecond, ctx.Done()) {
+		fmt.Fprintf(output(), "  -> Timer event abandoned: %s (%v)\n", event, ctx.Err())
+		return
+	}
+	fmt.Fprintf(output(), "  -> Timer event processed: %s (received %s)\n", event, at.Format("15:04:05.000"))
 }
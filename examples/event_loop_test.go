@@ -0,0 +1,159 @@
+package examples
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestEventLoopIdle keeps timer events flowing and checks onIdle stays
+// quiet, then stops the producer and checks onIdle fires once the idle
+// timeout has passed since the last event
+func TestEventLoopIdle(t *testing.T) {
+	const idleTimeout = 200 * time.Millisecond
+	timerEvents := make(chan string)
+	shutdown := make(chan struct{})
+	idled := make(chan time.Time, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		eventLoop(nil, nil, timerEvents, shutdown, eventLoopConfig{
+			idleTimeout: idleTimeout,
+			onIdle:      func() { idled <- time.Now() },
+		})
+	}()
+	defer func() {
+		close(shutdown)
+		<-done
+	}()
+
+	// Timer events take 50ms each, so sending every 100ms never leaves the
+	// loop without an event for the idle timeout
+	var last time.Time
+	for i := 0; i < 8; i++ {
+		timerEvents <- "tick"
+		last = time.Now()
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := len(idled); n != 0 {
+		t.Fatalf("onIdle fired %d times while events were flowing", n)
+	}
+
+	select {
+	case at := <-idled:
+		// The last event's 50ms of handling runs before the timer re-arms
+		if quiet := at.Sub(last); quiet < idleTimeout || quiet > idleTimeout+300*time.Millisecond {
+			t.Fatalf("onIdle fired %v after the last event, idle timeout %v", quiet, idleTimeout)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("onIdle didn't fire within 2s of the producer stopping")
+	}
+}
+
+// TestEventLoopStatsSnapshot floods the loop from three producers, with a
+// middleware that skips the slow handlers, while snapshotting its metrics,
+// and checks no snapshot shows more events processed than received
+func TestEventLoopStatsSnapshot(t *testing.T) {
+	metrics := &eventLoopMetrics{}
+	skip := func(string, eventHandler) eventHandler {
+		return func(context.Context, string, time.Time) {}
+	}
+	user, system, timer := make(chan string), make(chan string), make(chan string)
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		eventLoop(user, system, timer, shutdown, eventLoopConfig{metrics: metrics, middleware: []eventMiddleware{skip}})
+	}()
+
+	const each = 300
+	var producers sync.WaitGroup
+	for _, ch := range []chan string{user, system, timer} {
+		producers.Add(1)
+		go func(ch chan string) {
+			defer producers.Done()
+			for i := 0; i < each; i++ {
+				ch <- "event"
+			}
+		}(ch)
+	}
+	sent := make(chan struct{})
+	go func() {
+		producers.Wait()
+		close(sent)
+	}()
+
+	for flowing := true; flowing; {
+		select {
+		case <-sent:
+			flowing = false
+		default:
+			if s := metrics.Stats(); s.Processed > s.Received {
+				t.Fatalf("torn snapshot: %d processed, %d received", s.Processed, s.Received)
+			}
+		}
+	}
+	close(shutdown)
+	<-done
+	if s := metrics.Stats(); s.Received != 3*each || s.Processed != 3*each {
+		t.Fatalf("final stats: %d received, %d processed, want %d of each", s.Received, s.Processed, 3*each)
+	}
+}
+
+// TestEventLoopTimedMiddleware drives the loop with handlers that advance a
+// fake clock by a fixed time per kind, while events also sit waiting in the
+// select, and checks the timing middleware counts every call and records
+// exactly the handler time
+func TestEventLoopTimedMiddleware(t *testing.T) {
+	simulated := map[string]time.Duration{"user": 100 * time.Millisecond, "system": 150 * time.Millisecond, "timer": 50 * time.Millisecond}
+	var mu sync.Mutex
+	clock := time.Unix(0, 0)
+	metrics := &eventLoopMetrics{now: func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}}
+	// Replaces the real handler, so the clock moves only while one runs
+	fake := func(kind string, _ eventHandler) eventHandler {
+		return func(context.Context, string, time.Time) {
+			mu.Lock()
+			clock = clock.Add(simulated[kind])
+			mu.Unlock()
+		}
+	}
+
+	user, system, timer := make(chan string), make(chan string), make(chan string)
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		eventLoop(user, system, timer, shutdown, eventLoopConfig{metrics: metrics, middleware: []eventMiddleware{metrics.timed, fake}})
+	}()
+	sent := map[string]int{"user": 3, "system": 2, "timer": 4}
+	total := 0
+	for kind, ch := range map[string]chan string{"user": user, "system": system, "timer": timer} {
+		for i := 0; i < sent[kind]; i++ {
+			// An hour passes between handler calls, which is not handler time
+			mu.Lock()
+			clock = clock.Add(time.Hour)
+			mu.Unlock()
+			ch <- kind
+			total++
+			waitUntil(t, func() bool { return metrics.Stats().Processed == total }, time.Second, "event not processed")
+		}
+	}
+	close(shutdown)
+	<-done
+
+	stats := metrics.Stats()
+	for kind, want := range simulated {
+		n := sent[kind]
+		if stats.Timed[kind] != n || stats.ByKind[kind] != n {
+			t.Fatalf("%s: timed %d, processed %d, sent %d", kind, stats.Timed[kind], stats.ByKind[kind], n)
+		}
+		if got := stats.Durations[kind]; got != time.Duration(n)*want {
+			t.Fatalf("%s: recorded %v of handler time over %d calls, want %v each", kind, got, n, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package examples
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DropPolicy decides what an eventQueue does with an event that arrives
+// while the queue is full
+type DropPolicy int
+
+const (
+	// Block makes the producer wait for room, as a plain buffered channel does
+	Block DropPolicy = iota
+	// DropNewest discards the arriving event and keeps the queued ones
+	DropNewest
+	// DropOldest discards the longest-queued event to make room for the new one
+	DropOldest
+)
+
+func (p DropPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	}
+	return fmt.Sprintf("DropPolicy(%d)", int(p))
+}
+
+// parseDropPolicy is the inverse of String; the empty string means Block
+func parseDropPolicy(s string) (DropPolicy, error) {
+	for _, p := range []DropPolicy{Block, DropNewest, DropOldest} {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+	if s == "" {
+		return Block, nil
+	}
+	return Block, fmt.Errorf("unknown drop policy %q (want block, drop-newest or drop-oldest)", s)
+}
+
+// eventQueue is one of the event loop's input channels with a policy for
+// when it fills. Producers Send; the loop receives from C. Discarded events
+// are counted per kind in metrics.
+type eventQueue struct {
+	kind    string
+	ch      chan string
+	policy  DropPolicy
+	metrics *eventLoopMetrics
+
+	// sendMu serializes senders under DropOldest, so evicting the oldest
+	// event and queueing the new one happen as one step for each producer
+	sendMu sync.Mutex
+}
+
+func newEventQueue(kind string, capacity int, policy DropPolicy, metrics *eventLoopMetrics) *eventQueue {
+	return &eventQueue{kind: kind, ch: make(chan string, capacity), policy: policy, metrics: metrics}
+}
+
+// C returns the channel the event loop receives from
+func (q *eventQueue) C() <-chan string {
+	return q.ch
+}
+
+// Len returns how many events are waiting
+func (q *eventQueue) Len() int {
+	return len(q.ch)
+}
+
+// Send queues event, applying the queue's policy if it is full, and reports
+// whether event was queued; only DropNewest turns it away. It blocks only
+// under Block.
+func (q *eventQueue) Send(event string) bool {
+	switch q.policy {
+	case DropNewest:
+		select {
+		case q.ch <- event:
+			return true
+		default:
+			q.metrics.recordDropped(q.kind)
+			return false
+		}
+	case DropOldest:
+		q.sendMu.Lock()
+		defer q.sendMu.Unlock()
+		for {
+			select {
+			case q.ch <- event:
+				return true
+			default:
+			}
+			// Full: evict the oldest, unless the loop took it first
+			select {
+			case <-q.ch:
+				q.metrics.recordDropped(q.kind)
+			default:
+			}
+		}
+	default:
+		q.ch <- event
+		return true
+	}
+}
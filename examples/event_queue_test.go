@@ -0,0 +1,66 @@
+package examples
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestDropOldest overwhelms an event loop whose handler is slow with a
+// burst under DropOldest, and checks that the producer never blocks, that
+// events were dropped and counted, and that the newest events survived
+func TestDropOldest(t *testing.T) {
+	metrics := &eventLoopMetrics{}
+	queue := newEventQueue("user", 4, DropOldest, metrics)
+	release := make(chan struct{})
+	shutdown := make(chan struct{})
+	var handled []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// A loop stuck on its first event while the burst arrives
+		first := <-queue.C()
+		handled = append(handled, first)
+		<-release
+		for {
+			select {
+			case e := <-queue.C():
+				handled = append(handled, e)
+			case <-shutdown:
+				return
+			}
+		}
+	}()
+
+	const burst = 50
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < burst; i++ {
+			queue.Send(fmt.Sprintf("event-%d", i))
+		}
+	}()
+	if !pollUntil(func() bool { return stopped(sent) }, 5*time.Millisecond, time.Second) {
+		close(release)
+		close(shutdown)
+		t.Fatalf("producer blocked on a full queue under DropOldest")
+	}
+	close(release)
+	waitUntil(t, func() bool { return queue.Len() == 0 }, time.Second, "queue never drained after the handler was released")
+	close(shutdown)
+	<-done
+
+	dropped := metrics.Stats().Dropped["user"]
+	if dropped == 0 {
+		t.Fatalf("a burst of %d into a queue of 4 dropped nothing", burst)
+	}
+	if len(handled)+dropped != burst {
+		t.Fatalf("%d handled + %d dropped, want %d sent", len(handled), dropped, burst)
+	}
+	tail := handled[len(handled)-4:]
+	for i, e := range tail {
+		if want := fmt.Sprintf("event-%d", burst-4+i); e != want {
+			t.Fatalf("handled %v last, want the newest 4 events ending event-%d", tail, burst-1)
+		}
+	}
+}
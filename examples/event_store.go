@@ -0,0 +1,74 @@
+package examples
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a processed event as kept by an eventStore
+type Event struct {
+	Type    string
+	Payload string
+	At      time.Time
+	// TraceID is set when the loop runs under a RunContext
+	TraceID string
+}
+
+// eventStore keeps the most recent processed events in a ring buffer, so
+// "what happened in the last few seconds" can be answered without the store
+// growing with the loop's lifetime. It is safe for concurrent use.
+type eventStore struct {
+	mu     sync.Mutex
+	events []Event
+	next   int // where the next event goes
+	full   bool
+}
+
+func newEventStore(capacity int) *eventStore {
+	return &eventStore{events: make([]Event, capacity)}
+}
+
+// add stores an event, overwriting the oldest once the store is full; a nil
+// store keeps nothing
+func (s *eventStore) add(e Event) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[s.next] = e
+	s.next++
+	if s.next == len(s.events) {
+		s.next = 0
+		s.full = true
+	}
+}
+
+// Query returns the stored events of type typ received at or after since,
+// oldest first. An empty typ matches every type.
+func (s *eventStore) Query(typ string, since time.Time) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start, n := 0, s.next
+	if s.full {
+		start, n = s.next, len(s.events)
+	}
+	var out []Event
+	for i := 0; i < n; i++ {
+		e := s.events[(start+i)%len(s.events)]
+		if (typ == "" || e.Type == typ) && !e.At.Before(since) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Len returns how many events the store holds
+func (s *eventStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.full {
+		return len(s.events)
+	}
+	return s.next
+}
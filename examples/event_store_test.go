@@ -0,0 +1,33 @@
+package examples
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestEventStore fills a small store past its capacity and checks that
+// queries only see the newest events and honour the type and time filters
+func TestEventStore(t *testing.T) {
+	store := newEventStore(4)
+	base := time.Now()
+	types := []string{"user", "system", "timer"}
+	for i := 0; i < 9; i++ {
+		store.add(Event{Type: types[i%3], Payload: fmt.Sprintf("event-%d", i), At: base.Add(time.Duration(i) * time.Second)})
+	}
+	if n := store.Len(); n != 4 {
+		t.Fatalf("store of capacity 4 holds %d events", n)
+	}
+	all := store.Query("", time.Time{})
+	if len(all) != 4 || all[0].Payload != "event-5" || all[3].Payload != "event-8" {
+		t.Fatalf("query for everything returned %v, want event-5 to event-8", all)
+	}
+	users := store.Query("user", time.Time{})
+	if len(users) != 1 || users[0].Payload != "event-6" {
+		t.Fatalf("query for user events returned %v, want only event-6", users)
+	}
+	recent := store.Query("timer", base.Add(6*time.Second))
+	if len(recent) != 1 || recent[0].Payload != "event-8" {
+		t.Fatalf("query for timer events since 6s returned %v, want only event-8", recent)
+	}
+}
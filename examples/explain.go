@@ -0,0 +1,399 @@
+package examples
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// explainPause is how long --explain holds each phase's explanation on
+// screen before the phase runs
+const explainPause = 400 * time.Millisecond
+
+// PatternInfo describes a pattern for --explain: what it solves, the types to
+// read, the mistakes it guards against, and the phases its example runs in
+// order
+type PatternInfo struct {
+	Name     string
+	Problem  string
+	KeyTypes []string
+	Pitfalls []string
+	Phases   []PatternPhase
+	// Metrics are the recorded results printed at the end, tied back to what
+	// the phases set out to show
+	Metrics []PatternMetric
+}
+
+// PatternPhase is one step of an example, announced by a phase hook in its
+// Run function
+type PatternPhase struct {
+	Key  string
+	What string
+	Why  string
+}
+
+// PatternMetric names a result the example records and what it shows
+type PatternMetric struct {
+	Result  string
+	Meaning string
+}
+
+// patterns holds the metadata for every example, keyed by its flag name
+var patterns = map[string]PatternInfo{
+	"pipeline": {
+		Name:     "Pipeline",
+		Problem:  "Processing a stream in stages that run concurrently, each handing its output to the next over a channel",
+		KeyTypes: []string{"<-chan int stage outputs", "windowWithLateness / WindowResult", "adaptiveLink"},
+		Pitfalls: []string{"A stage that never closes its output leaves every later stage waiting forever", "Unbuffered links make the slowest stage set the pace for all of them"},
+		Phases: []PatternPhase{
+			{"stages", "Wire generate -> square -> filter -> addTen", "Each stage is a goroutine that owns its output channel and closes it when its input ends"},
+			{"collect", "Drain the last stage", "Ranging over the final channel ends exactly when every stage has finished"},
+			{"windows", "Sum samples in event-time windows", "Late samples are corrected within the allowed lateness and dropped after it"},
+			{"adaptive", "Grow the buffers of links that stay blocked", "Buffering where a stage waits smooths bursts without buffering everywhere"},
+			{"backpressure", "Let a slow sink set the pace", "A bounded pipeline can't run ahead of its consumer, so memory stays flat"},
+			{"credit", "Bound the items in flight with credits", "The sink hands a credit back for each finished item, so the generator can't have more than the window anywhere in the pipeline"},
+			{"buffering", "Buffer each stage's output", "Buffers let the stages keep working while the consumer pauses, and cancelling still closes every output"},
+			{"cancel", "Cancel the generator part way", "Closing at the source is enough to end every stage downstream"},
+		},
+		Metrics: []PatternMetric{
+			{"even_square_sum", "Sum of the even squares, from the declarative pipeline"},
+			{"stage_throughput", "Items each stage handled, the time it worked on them and its items per second"},
+			{"credit_window", "Most items in flight with and without the credit window"},
+			{"buffering", "Wall-clock time of the same run with unbuffered and buffered stages"},
+			{"cancelable_generator", "Values received before the generator was cancelled"},
+		},
+	},
+	"fan": {
+		Name:     "Fan-out/Fan-in",
+		Problem:  "Spreading independent work items across workers and merging their results into one stream",
+		KeyTypes: []string{"WorkItem", "Result", "FanSummary", "ForEach / MapSlice"},
+		Pitfalls: []string{"Results arrive in completion order, not input order", "Closing the merged channel before every worker has finished loses results or panics"},
+		Phases: []PatternPhase{
+			{"fan-out", "Distribute the items to four workers and merge their results", "One shared input channel balances the load; a WaitGroup closes the merged output"},
+			{"ordered", "Restore input order with a bounded reorder buffer", "A slow item holds back later results only up to the buffer limit"},
+			{"degraded", "Lose workers mid-run", "The survivors take over the queue; losing them all is reported as an error"},
+			{"replicated", "Run each item on three workers and vote", "Majority voting catches a worker that corrupts its output"},
+			{"dedup", "Deduplicate at-least-once results", "Retries can deliver the same item twice; the fan-in keeps the first"},
+			{"slices", "Bounded fan-out over a slice", "ForEach stops on the first error; MapSlice keeps each result at its index"},
+			{"affinity", "Route items by key", "Affinity keeps a key on one worker; overflow stops a hot key starving the rest"},
+			{"priority", "Merge results by priority", "Urgent results already waiting are delivered first"},
+			{"leaks", "Cancel fan-outs at every stage", "Cancellation must leave no goroutine behind"},
+		},
+		Metrics: []PatternMetric{
+			{"deduplicated", "Distinct items after deduplicating 12 deliveries"},
+			{"for_each", "Files imported before ForEach stopped on the corrupt one"},
+		},
+	},
+	"pools": {
+		Name:     "Worker Pools",
+		Problem:  "Bounding concurrency with a fixed set of workers pulling jobs from a shared queue",
+		KeyTypes: []string{"jobPool", "ctxJobPool / JobMiddleware", "SLOPool", "supervisedPool", "admissionController"},
+		Pitfalls: []string{"Submitting to a pool nobody drains blocks the submitter", "A panicking worker silently shrinks the pool unless something replaces it"},
+		Phases: []PatternPhase{
+			{"pool", "Run 15 jobs on three workers", "The workers share one jobs channel, so a free worker always takes the next job"},
+			{"cost", "Route jobs by cost", "Sending each job to the least-loaded worker evens out uneven jobs"},
+			{"plan", "Plan the queue before running it", "Simulating earliest-free-worker dispatch with the estimates predicts the makespan, and the real run shows how estimate error moves it"},
+			{"rate", "Rate-limit each worker", "A token bucket per worker caps the aggregate rate at workers x rate"},
+			{"ordered", "Emit results in submission order", "A window of pending jobs bounds how far ahead of the slowest job the pool runs"},
+			{"slo", "Size the pool to a latency target", "Adding workers while p99 is high and shedding them when it is low follows the load"},
+			{"tuned", "Hill-climb to the most throughput", "Trying a worker more or less each interval, and keeping a change only past the noise, settles near the optimum without oscillating"},
+			{"middleware", "Put a deadline on every job", "WithTimeout wraps the job function, so no job needs its own timeout code"},
+			{"supervised", "Replace workers that panic", "The pool keeps its size and reports the job that crashed"},
+			{"admission", "Admit jobs by slot and cost", "Expensive jobs use more of the rate budget while counting as one slot"},
+			{"tenants", "Share the pool fairly between tenants", "Round-robin across tenant queues keeps a flood from starving light tenants"},
+		},
+		Metrics: []PatternMetric{
+			{"plan", "Predicted and actual makespan of the planned queue"},
+			{"live_throughput_jobs", "Jobs completed while the rate meter was watching"},
+			{"timed_out_jobs", "Jobs the timeout middleware cut short"},
+			{"tuned_pool", "Worker count and throughput of every control interval of the tuned pool"},
+			{"supervised", "Supervised pool outcomes and worker restarts"},
+		},
+	},
+	"producer-consumer": {
+		Name:     "Producer-Consumer",
+		Problem:  "Decoupling the rate things are produced from the rate they are consumed with a bounded buffer",
+		KeyTypes: []string{"chan int buffer", "checkpointTracker", "batchSizer", "adaptiveQueue"},
+		Pitfalls: []string{"Closing the buffer while a producer is still sending panics", "An unbounded buffer hides a consumer that can't keep up until memory runs out"},
+		Phases: []PatternPhase{
+			{"run", "Start producers and consumers on a shared buffer", "Producers block when the buffer is full, consumers when it is empty"},
+			{"drain", "Close the buffer once every producer is done", "Consumers finish what is buffered and stop when the channel closes"},
+			{"checkpoint", "Crash part way and resume from the checkpoint", "Committed progress survives a restart without consuming anything twice"},
+			{"batching", "Size batches from flush latency", "Growing batches while flushes are fast and halving them when slow keeps within budget"},
+			{"buffer", "Resize the buffer with the load", "The queue grows during bursts and shrinks back when idle"},
+		},
+		Metrics: []PatternMetric{
+			{"producers", "Items produced and consumed per producer"},
+		},
+	},
+	"supervisor": {
+		Name:     "Supervisor/Restart",
+		Problem:  "Keeping a worker running by watching it and restarting it when it fails",
+		KeyTypes: []string{"supervisor", "Backoff", "timeoutSchedule", "supervisorGroup / workerSpec"},
+		Pitfalls: []string{"Restarting immediately turns a crash loop into a busy loop", "Restarting a worker without its dependents leaves them holding stale state"},
+		Phases: []PatternPhase{
+			{"restart", "Supervise a worker that fails three times", "Backoff spaces out the restarts, and a stable run resets it"},
+			{"flapping", "Adapt a flapping worker's timeouts", "Each quick failure gives the next incarnation more grace and a shorter deadline"},
+			{"group", "Supervise a group and roll it", "One-for-one restarts leave healthy workers alone; a rollout halts at a worker that isn't ready"},
+			{"dependencies", "Start and restart by dependency", "Workers start after what they depend on, and a restart cascades to dependents"},
+		},
+		Metrics: []PatternMetric{
+			{"restarts", "Restarts before the worker healed"},
+			{"panics", "Restarts caused by panics"},
+		},
+	},
+	"pubsub": {
+		Name:     "Publish-Subscribe",
+		Problem:  "Delivering every published message to every current subscriber without the publisher knowing who they are",
+		KeyTypes: []string{"broadcaster[T]", "Topic[T] / Broker", "subscriberWorkers"},
+		Pitfalls: []string{"One stalled subscriber can block every publish", "Publishing after close must not panic on a closed channel"},
+		Phases: []PatternPhase{
+			{"subscribe", "Register three subscribers", "Each gets its own channel from a copy-on-write subscriber list"},
+			{"publish", "Publish to all of them", "Every subscriber receives every message published while it is subscribed"},
+			{"stalled", "Publish to a stalled subscriber", "A publish deadline bounds how long one subscriber can hold up the rest"},
+			{"extensions", "Stress, ordered delivery, worker subscribers, typed topics and gap detection", "The same broadcaster underneath, with acks, handler pools, compile-time typed topics and sequence gaps"},
+		},
+		Metrics: []PatternMetric{
+			{"received_per_subscriber", "Messages received by each subscriber"},
+			{"broadcaster", "Broadcaster counters"},
+			{"gaps", "Sequence numbers a slow subscriber found it lost"},
+		},
+	},
+	"timeout-cancellation": {
+		Name:     "Timeouts and Cancellation",
+		Problem:  "Bounding how long an operation may take and stopping work nobody is waiting for",
+		KeyTypes: []string{"context.Context", "time.After", "Watchdog"},
+		Pitfalls: []string{"A goroutine blocked on a send nobody receives leaks after its caller times out", "A timeout the callee never checks doesn't stop any work"},
+		Phases: []PatternPhase{
+			{"context-timeout", "Run an operation under a context deadline", "The caller gets ctx.Err() when the deadline passes first"},
+			{"channel-timeout", "Race a result against time.After", "select takes whichever is ready first"},
+			{"cancel", "Cancel an operation explicitly", "Cancelling the context tells every goroutine watching it to stop"},
+			{"watchdog", "Catch a stalled example", "No heartbeat for too long means a hang, reported with a goroutine dump"},
+		},
+		Metrics: []PatternMetric{
+			{"context_timeout", "Outcome of the context-bound operation"},
+			{"channel_timeout", "Outcome of the time.After race"},
+			{"cancellation", "Outcome of the cancelled operation"},
+		},
+	},
+	"rate-limiting": {
+		Name:     "Rate Limiting",
+		Problem:  "Keeping requests under a rate a downstream service can take",
+		KeyTypes: []string{"time.Ticker", "tokenBucketLimiter"},
+		Pitfalls: []string{"A fixed ticker can't absorb bursts", "Waiting for a token without a deadline can block forever"},
+		Phases: []PatternPhase{
+			{"fixed", "Process requests at a fixed rate", "A ticker releases one request per tick"},
+			{"token-bucket", "Allow bursts with a token bucket", "Saved-up tokens let a burst through, then the refill rate applies"},
+			{"bounded-wait", "Wait a bounded time for a token", "WaitCtx gives up when its context ends instead of waiting forever"},
+		},
+		Metrics: []PatternMetric{
+			{"token_bucket", "Requests granted by the token bucket"},
+		},
+	},
+	"mapreduce": {
+		Name:     "MapReduce",
+		Problem:  "Splitting a computation into parallel map tasks whose partial results are combined by reducers",
+		KeyTypes: []string{"SyncMap", "reduceTree"},
+		Pitfalls: []string{"Reducers sharing a map need synchronization", "A reduce that waits for every mapper must also stop when the job is cancelled"},
+		Phases: []PatternPhase{
+			{"map", "Count words in parallel", "Mappers count their chunk; reducers merge the counts per word"},
+			{"tree", "Reduce a large input as a tree", "Pairwise merges keep every level parallel"},
+			{"deadline", "Run the same job under a deadline", "A job that can't finish in time returns a timeout instead of a partial count"},
+		},
+		Metrics: []PatternMetric{
+			{"tree_reduce", "The tree reduction's total"},
+			{"timeout_error", "Error from the job under a deadline"},
+		},
+	},
+	"singleflight": {
+		Name:     "Singleflight",
+		Problem:  "Collapsing concurrent requests for the same key into one execution whose result they all share",
+		KeyTypes: []string{"singleflight", "limitedSingleflight", "cancelableSingleflight"},
+		Pitfalls: []string{"Callers arriving after a flight completes start a new one", "One caller cancelling must not fail the others sharing its flight"},
+		Phases: []PatternPhase{
+			{"dedup", "Send concurrent requests for one key", "Only the first starts the work; the rest wait for its result"},
+			{"keys", "Request different keys", "Flights are per key, so different keys run independently"},
+			{"barrier", "Hold the flight until every caller joined", "The dedup is then deterministic rather than timing-dependent"},
+			{"stampede", "Expire many keys at once", "A limit and jitter stop distinct keys hitting the backend together"},
+			{"cancel", "Abandon a flight", "When the last caller leaves, the flight's context is cancelled"},
+			{"loader", "Cache loads, failures included", "Caching errors briefly stops a failing backend being hit by every request"},
+		},
+		Metrics: []PatternMetric{
+			{"stampede", "Keys, concurrency limit and peak concurrent flights"},
+			{"abandoned_flight_ms", "How long the abandoned flight ran"},
+			{"loader_backend_loads", "Backend loads behind 40 cached requests"},
+		},
+	},
+	"event-loop": {
+		Name:     "Event Loop",
+		Problem:  "Handling events from several sources in one goroutine, so handlers never race with each other",
+		KeyTypes: []string{"eventQueue / DropPolicy", "eventHandler / eventMiddleware", "eventStore"},
+		Pitfalls: []string{"A slow handler delays every other event", "Producers block on full queues unless a drop policy says otherwise"},
+		Phases: []PatternPhase{
+			{"queues", "Create the input queues and producers", "Each source has its own queue, and a policy decides what happens when it fills"},
+			{"run", "Run the loop for five seconds", "One select dispatches whichever event is ready; idle time runs maintenance"},
+			{"shutdown", "Shut the loop down", "Closing the shutdown channel ends the select loop cleanly"},
+			{"store", "Query recent events", "A ring buffer answers what happened lately in bounded memory"},
+		},
+		Metrics: []PatternMetric{
+			{"stats", "Events received, processed, timed and dropped"},
+			{"dedup", "Events resent by the producers and dropped as duplicates"},
+		},
+	},
+	"resource-pooling": {
+		Name:     "Resource Pooling",
+		Problem:  "Reusing expensive resources like connections instead of creating one per request",
+		KeyTypes: []string{"dbConnectionPool", "httpClientPool", "PartitionedPool"},
+		Pitfalls: []string{"Not returning a resource exhausts the pool", "An exhausted pool needs a timeout, or callers wait forever"},
+		Phases: []PatternPhase{
+			{"db", "Share a database connection pool", "Connections are taken and returned instead of dialled per query"},
+			{"http", "Share HTTP clients", "The same pool shape works for any reusable resource"},
+			{"errors", "Exhaust and close the pool", "Failures surface as typed errors callers can check"},
+			{"degraded", "Degrade under exhaustion", "Best-effort connections keep serving when the pool is empty"},
+			{"warmup", "Warm the pool up", "Dialling ahead of demand takes the setup latency off the first requests"},
+			{"partitioned", "Partition the pool per host", "Per-host sub-pools under a global cap, with fair queueing for slots"},
+			{"sticky", "Pin sessions to connections", "Hashing a key to a connection keeps a session on one connection while it is free"},
+		},
+		Metrics: []PatternMetric{
+			{"db_pool", "Database pool counters"},
+			{"warmup", "Latency of the first requests with and without warmup"},
+		},
+	},
+	"composed": {
+		Name:     "Composed Ingestion",
+		Problem:  "Chaining rate limiting, a pipeline, a worker pool and pub/sub into one flow under one context",
+		KeyTypes: []string{"sensorReading", "composedStats", "shutdownCoordinator", "RunContext"},
+		Pitfalls: []string{"Closing the broadcaster before the pool drains drops the last results", "Closing anything before the generator stops leaves the pipeline filling forever", "Goroutines left behind by one stage outlive the whole flow"},
+		Phases: []PatternPhase{
+			{"full", "Run the flow to completion", "Every generated reading must be published and reach both subscribers"},
+			{"shutdown", "Shut the flow down part way", "The generator stops and every stage drains before the broadcaster closes; subscribers then get a deadline for their backlogs"},
+		},
+		Metrics: []PatternMetric{
+			{"shutdown", "Counts per stage after the early shutdown"},
+		},
+	},
+}
+
+// Pattern returns the metadata for the example with the given flag name
+func Pattern(key string) (PatternInfo, bool) {
+	info, ok := patterns[key]
+	return info, ok
+}
+
+var (
+	phaseMu sync.Mutex
+	running string   // flag name of the running example
+	fired   []string // phases the running example has entered, in order
+)
+
+// BeginPattern is called by main.go before an example runs. With --explain
+// it prints what the pattern is for and what to watch out for.
+func BeginPattern(key string) {
+	phaseMu.Lock()
+	running, fired = key, nil
+	phaseMu.Unlock()
+
+	info, ok := patterns[key]
+	if !opts.Explain || !ok {
+		return
+	}
+	fmt.Fprintf(output(), "\n[explain] %s: %s\n", info.Name, info.Problem)
+	fmt.Fprintf(output(), "[explain] Key types: %s\n", strings.Join(info.KeyTypes, ", "))
+	for _, p := range info.Pitfalls {
+		fmt.Fprintf(output(), "[explain] Pitfall: %s\n", p)
+	}
+	fmt.Fprintf(output(), "[explain] %d phases follow\n\n", len(info.Phases))
+}
+
+// runningPattern returns the flag name of the running example, if any
+func runningPattern() string {
+	phaseMu.Lock()
+	defer phaseMu.Unlock()
+	return running
+}
+
+// phase marks the start of one of the running example's documented phases.
+// With --explain it prints the phase's explanation and pauses before the
+// phase runs.
+func phase(key string) {
+	heartbeat()
+	phaseMu.Lock()
+	fired = append(fired, key)
+	info, ok := patterns[running]
+	phaseMu.Unlock()
+	if !opts.Explain || !ok {
+		return
+	}
+	for i, p := range info.Phases {
+		if p.Key == key {
+			fmt.Fprintf(output(), "\n[explain] Step %d of %d: %s\n", i+1, len(info.Phases), p.What)
+			fmt.Fprintf(output(), "[explain] Why: %s\n", p.Why)
+			time.Sleep(explainPause)
+			return
+		}
+	}
+}
+
+// EndPattern is called by main.go once an example returns. With --explain it
+// prints the example's key metrics; with --verify it checks the phases fired
+// in their documented order; with --golden or --check-golden it writes or
+// checks the example's golden file.
+func EndPattern() {
+	phaseMu.Lock()
+	key, seen := running, append([]string(nil), fired...)
+	running, fired = "", nil
+	phaseMu.Unlock()
+
+	info, ok := patterns[key]
+	if opts.Explain && ok {
+		results := Results()
+		fmt.Fprintf(output(), "\n[explain] What %s showed:\n", info.Name)
+		for _, m := range info.Metrics {
+			if v, ok := results[m.Result]; ok {
+				fmt.Fprintf(output(), "[explain]   %s: %v\n", m.Meaning, v)
+			}
+		}
+	}
+	// Soak, chaos and replay runs take a shortcut through the example
+	complete := opts.Soak == 0 && !opts.Chaos && opts.Replay == ""
+	verify(key+" phases", func() error { return checkPhaseOrder(key, seen, complete) })
+	goldenEnd(key)
+}
+
+// checkPhaseOrder checks that every phase in seen is documented for the
+// pattern key and that they fired in the documented order; with complete set
+// every documented phase must have fired
+func checkPhaseOrder(key string, seen []string, complete bool) error {
+	info, ok := patterns[key]
+	if !ok {
+		return fmt.Errorf("no metadata for %q", key)
+	}
+	index := make(map[string]int, len(info.Phases))
+	for i, p := range info.Phases {
+		index[p.Key] = i
+	}
+	last := -1
+	for _, s := range seen {
+		i, ok := index[s]
+		if !ok {
+			return fmt.Errorf("phase %q fired but is not documented", s)
+		}
+		if i <= last {
+			return fmt.Errorf("phases fired as %v, documented order is %v", seen, phaseKeys(info))
+		}
+		last = i
+	}
+	if complete && len(seen) != len(info.Phases) {
+		return fmt.Errorf("phases fired as %v, documented %v", seen, phaseKeys(info))
+	}
+	return nil
+}
+
+func phaseKeys(info PatternInfo) []string {
+	keys := make([]string, len(info.Phases))
+	for i, p := range info.Phases {
+		keys[i] = p.Key
+	}
+	return keys
+}
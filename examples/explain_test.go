@@ -0,0 +1,31 @@
+package examples
+
+import (
+	"sort"
+	"testing"
+)
+
+// TestPatternInfo checks that every pattern's metadata is filled in
+func TestPatternInfo(t *testing.T) {
+	keys := make([]string, 0, len(patterns))
+	for key := range patterns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		info := patterns[key]
+		switch {
+		case info.Name == "" || info.Problem == "":
+			t.Fatalf("%s: missing name or problem", key)
+		case len(info.KeyTypes) == 0 || len(info.Pitfalls) == 0:
+			t.Fatalf("%s: missing key types or pitfalls", key)
+		case len(info.Phases) == 0 || len(info.Metrics) == 0:
+			t.Fatalf("%s: missing phases or metrics", key)
+		}
+		for _, p := range info.Phases {
+			if p.Key == "" || p.What == "" || p.Why == "" {
+				t.Fatalf("%s: incomplete phase %+v", key, p)
+			}
+		}
+	}
+}
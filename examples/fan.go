@@ -1,43 +1,362 @@
 package examples
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Fan demonstrates the fan-out/fan-in pattern
-func RunFan() {
-	fmt.Println("=== Fan-out/Fan-in Pattern Example ===")
+func RunFan(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Fan-out/Fan-in Pattern Example ===")
 
-	// Generate work items
-	workItems := generateWorkItems(20)
-
-	// Fan out: Distribute work across multiple workers
+	items := 20
+	if opts.FanItems > 0 {
+		items = opts.FanItems
+	}
 	numWorkers := 4
-	results := fanOut(workItems, numWorkers)
+	phase("fan-out")
+	if items > fanStreamThreshold {
+		// Too many results to keep: summarize them as they stream past
+		runLargeFan(items, numWorkers)
+	} else {
+		// Generate work items
+		workItems := generateTracedWorkItems(RunContextFrom(ctx), items)
+
+		// Fan out: Distribute work across multiple workers
+		results, counters := fanOut(workItems, numWorkers, true)
+
+		// Fan in: Collect results from all workers, then a summary of the run
+		finalResults, summary := fanInSummary(results, counters)
+
+		fmt.Fprintf(output(), "Distributing %d work items across %d workers...\n", items, numWorkers)
+		fmt.Fprintln(output())
+
+		// Collect and display results
+		var processedResults []Result
+		delivered := make(map[int]int)
+		for result := range finalResults {
+			traceHop(result.TraceID, "collected")
+			fmt.Fprintf(output(), "Processed: Item %d -> %s (by Worker %d)\n", result.OriginalID, result.Processed, result.WorkerID)
+			processedResults = append(processedResults, result)
+			delivered[result.WorkerID]++
+		}
+		record("results", processedResults)
+		end := <-summary
+		record("summary", end)
+		for id := 1; id <= numWorkers; id++ {
+			if end.Processed[id] != delivered[id] {
+				fail("fan-in summary: worker %d processed %d items, %d were delivered", id, end.Processed[id], delivered[id])
+			}
+		}
+		verify("fan", func() error {
+			ids := make([]int, len(processedResults))
+			for i, r := range processedResults {
+				ids[i] = r.OriginalID
+			}
+			return checkIDs(ids, items)
+		})
+
+		fmt.Fprintf(output(), "\nFan-out/Fan-in completed! Processed %d items in %v.\n", end.Total, end.Elapsed.Round(time.Millisecond))
+		for id := 1; id <= numWorkers; id++ {
+			fmt.Fprintf(output(), "  Worker %d: %d processed, %d errors\n", id, end.Processed[id], end.Errors[id])
+		}
+	}
+
+	phase("ordered")
+	// Ordered fan-out: parallel processing, results in input order
+	fmt.Fprintln(output(), "\nOrdered parallel map (item 2 is slow, reorder buffer limit 4):")
+	ordered, stats := OrderedParallelMap(generateWorkItems(10), numWorkers, 4, func(item WorkItem) Result {
+		delay := 20 * time.Millisecond
+		if item.ID == 2 {
+			delay = 300 * time.Millisecond
+		}
+		time.Sleep(delay)
+		return Result{OriginalID: item.ID, Processed: "processed-" + item.Data}
+	})
+	var orderedIDs []int
+	for result := range ordered {
+		heartbeat()
+		fmt.Fprintf(output(), "In order: Item %d -> %s\n", result.OriginalID, result.Processed)
+		orderedIDs = append(orderedIDs, result.OriginalID)
+	}
+	fmt.Fprintf(output(), "Reorder buffer high-water mark: %d, input stalls: %d\n", stats.MaxBuffered, stats.Stalls)
+	record("ordered", map[string]interface{}{"ids": orderedIDs, "stats": stats})
+
+	phase("degraded")
+	// Degradation: workers that lose their connection remove themselves
+	fmt.Fprintln(output(), "\nDegraded fan-out (workers 2 and 3 lose their connections):")
+	degraded, errc := fanOutDegradable(generateWorkItems(12), numWorkers, revokingWorker(map[int]int{2: 2, 3: 3}))
+	seen := make(map[int]bool)
+	for result := range degraded {
+		heartbeat()
+		seen[result.OriginalID] = true
+	}
+	partialErr := <-errc
+	fmt.Fprintf(output(), "Processed %d of 12 items with the surviving workers\n", len(seen))
+	verify("degraded fan-out", func() error {
+		if partialErr != nil {
+			return partialErr
+		}
+		if len(seen) != 12 {
+			return fmt.Errorf("processed %d of 12 items", len(seen))
+		}
+		return nil
+	})
+
+	fmt.Fprintln(output(), "\nDegraded fan-out (every worker loses its connection):")
+	degraded, errc = fanOutDegradable(generateWorkItems(12), numWorkers, revokingWorker(map[int]int{1: 1, 2: 2, 3: 2, 4: 3}))
+	processed := 0
+	for range degraded {
+		processed++
+	}
+	err := <-errc
+	fmt.Fprintf(output(), "Run failed after %d items (%s): %v\n", processed, errorKind(err), err)
+	record("degraded", map[string]interface{}{
+		"partial_processed": len(seen),
+		"total_processed":   processed,
+		"total_error":       err.Error(),
+	})
+
+	phase("replicated")
+	// Replicated execution: every item runs on 3 workers, one of which corrupts its output
+	fmt.Fprintln(output(), "\nReplicated fan-out (k=3, worker 1 corrupts its output, majority required):")
+	emitted := make(map[int]int)
+	flagged := 0
+	var quorum []QuorumResult
+	for q := range fanOutReplicated(generateWorkItems(8), numWorkers, 3, true, corruptingWorker(1)) {
+		emitted[q.OriginalID]++
+		quorum = append(quorum, q)
+		if !q.Disagreed {
+			fmt.Fprintf(output(), "Item %d: %s (replicas agree)\n", q.OriginalID, q.Processed)
+			continue
+		}
+		flagged++
+		fmt.Fprintf(output(), "Item %d: %s (accepted: %t) DISAGREEMENT:", q.OriginalID, q.Processed, q.Accepted)
+		for _, r := range q.Replicas {
+			fmt.Fprintf(output(), " [worker %d: %s]", r.WorkerID, r.Processed)
+		}
+		fmt.Fprintln(output())
+	}
+	fmt.Fprintf(output(), "%d items emitted, %d flagged for disagreeing replicas\n", len(emitted), flagged)
+	verify("replicated fan-out", func() error {
+		for id, n := range emitted {
+			if n != 1 {
+				return fmt.Errorf("emitted item %d %d times", id, n)
+			}
+		}
+		return nil
+	})
+
+	fmt.Fprintln(output(), "\nReplicated fan-out (k=3, first result wins):")
+	corrupted := 0
+	for q := range fanOutReplicated(generateWorkItems(8), numWorkers, 3, false, corruptingWorker(1)) {
+		heartbeat()
+		if q.Processed != fmt.Sprintf("processed-data-%d", q.OriginalID) {
+			corrupted++
+		}
+	}
+	fmt.Fprintf(output(), "First-result mode accepted %d corrupt outputs\n", corrupted)
+	record("replicated", map[string]interface{}{"majority": quorum, "first_result_corrupt": corrupted})
+
+	phase("dedup")
+	// At-least-once delivery: retried jobs can deliver a result twice
+	fmt.Fprintln(output(), "\nDeduplicated fan-in (items 3, 6 and 9 were retried after their first result arrived):")
+	var attempts []<-chan Result
+	for w := 0; w < 3; w++ {
+		ch := make(chan Result, 8)
+		for id := w + 1; id <= 9; id += 3 {
+			ch <- Result{OriginalID: id, Processed: fmt.Sprintf("processed-data-%d", id), WorkerID: w + 1}
+			if id%3 == 0 {
+				ch <- Result{OriginalID: id, Processed: fmt.Sprintf("processed-data-%d", id), WorkerID: w + 1}
+			}
+		}
+		close(ch)
+		attempts = append(attempts, ch)
+	}
+	deduped := make(map[int]int)
+	for r := range DedupResults(fanIn(attempts)) {
+		deduped[r.OriginalID]++
+	}
+	fmt.Fprintf(output(), "12 results in, %d distinct items out\n", len(deduped))
+	record("deduplicated", len(deduped))
+	for id := 1; id <= 9; id++ {
+		if deduped[id] != 1 {
+			fail("deduplicated fan-in: item %d emitted %d times", id, deduped[id])
+		}
+	}
 
-	// Fan in: Collect results from all workers
-	finalResults := fanIn(results)
+	phase("slices")
+	// Slice input: a bounded fan-out without wiring up a channel
+	fmt.Fprintln(output(), "\nForEach over a slice (12 files, 3 workers, file 8 is corrupt):")
+	files := make([]string, 12)
+	for i := range files {
+		files[i] = fmt.Sprintf("file-%02d.csv", i+1)
+	}
+	var imported int32
+	err = ForEach(files, numWorkers-1, func(name string) error {
+		time.Sleep(30 * time.Millisecond)
+		if name == "file-08.csv" {
+			return fmt.Errorf("import %s: corrupt header", name)
+		}
+		atomic.AddInt32(&imported, 1)
+		return nil
+	})
+	fmt.Fprintf(output(), "Imported %d of %d files before stopping: %v\n", imported, len(files), err)
+	record("for_each", map[string]interface{}{"imported": imported, "error": err.Error()})
+	if err == nil || imported == int32(len(files)) {
+		fail("ForEach: imported %d of %d files with error %v", imported, len(files), err)
+	}
+
+	// MapSlice keeps each result at its item's index
+	sizes, err := MapSlice(files, numWorkers, func(name string) (int, error) {
+		time.Sleep(time.Duration(rand.Intn(30)) * time.Millisecond)
+		var n int
+		_, err := fmt.Sscanf(name, "file-%d.csv", &n)
+		return n * 512, err
+	})
+	if err != nil {
+		fail("MapSlice: %v", err)
+	}
+	fmt.Fprintf(output(), "MapSlice sized %d files, in input order: %v\n", len(sizes), sizes)
+	record("map_slice", sizes)
 
-	fmt.Printf("Distributing %d work items across %d workers...\n", 20, numWorkers)
-	fmt.Println()
+	phase("affinity")
+	// Dynamic fan-in: a worker spawned mid-run joins the merge
+	fmt.Fprintln(output(), "\nDynamic fan-in (a third worker joins after the first 4 results):")
+	queue := generateBulkWorkItems(12)
+	merged := NewDynamicFanIn()
+	counters := newFanCounters(numWorkers)
+	spawn := func(id int) {
+		out := make(chan Result)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go worker(id, queue, out, counters, &wg, false)
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+		merged.Add(out)
+	}
+	spawn(1)
+	spawn(2)
+	perWorker := make(map[int]int)
+	total := 0
+	for r := range merged.Out() {
+		perWorker[r.WorkerID]++
+		if total++; total == 4 {
+			spawn(3)
+			merged.Close()
+		}
+	}
+	fmt.Fprintf(output(), "%d results from %d inputs, per worker %v\n", total, merged.Inputs(), perWorker)
+	record("dynamic_fan_in", perWorker)
+	if total != 12 {
+		fail("dynamic fan-in delivered %d of 12 results", total)
+	}
 
-	// Collect and display results
-	count := 0
-	for result := range finalResults {
-		fmt.Printf("Processed: Item %d -> %s (by Worker %d)\n", result.OriginalID, result.Processed, result.WorkerID)
-		count++
+	// Filtered views of one fan-in stream, without re-running the workers
+	fmt.Fprintln(output(), "\nSplit fan-in (views: even items, worker 1, everything else):")
+	results, _ := fanOut(generateBulkWorkItems(16), numWorkers, false)
+	views := collectViews(splitResults(fanIn(results), map[string]func(Result) bool{
+		"even":     func(r Result) bool { return r.OriginalID%2 == 0 },
+		"worker-1": func(r Result) bool { return r.WorkerID == 1 },
+	}))
+	for _, name := range []string{"even", "worker-1", unmatchedView} {
+		sort.Ints(views[name])
+		fmt.Fprintf(output(), "  %-10s %v\n", name+":", views[name])
+	}
+	record("split_views", views)
+	distinct := make(map[int]bool)
+	for _, ids := range views {
+		for _, id := range ids {
+			distinct[id] = true
+		}
+	}
+	if len(distinct) != 16 {
+		fail("split fan-in: %d of 16 items reached a view", len(distinct))
+	}
+
+	// Key affinity under skewed load: most items share one key
+	fmt.Fprintln(output(), "\nKey affinity with skewed load (18 of 24 items share key \"hot\"):")
+	var skewed []WorkItem
+	for i := 0; i < 24; i++ {
+		key := "hot"
+		if i%4 == 3 {
+			key = fmt.Sprintf("cold-%d", i)
+		}
+		skewed = append(skewed, WorkItem{ID: i, Data: key})
+	}
+	byKey := func(item WorkItem) string { return item.Data }
+	work := func(int, WorkItem) { time.Sleep(40 * time.Millisecond) }
+	strict := fanOutAffinity(skewed, numWorkers, 2, false, byKey, work)
+	hybrid := fanOutAffinity(skewed, numWorkers, 2, true, byKey, work)
+	fmt.Fprintf(output(), "Strict affinity: %v, items per worker %v\n", strict.Elapsed.Round(time.Millisecond), strict.PerWorker)
+	fmt.Fprintf(output(), "With overflow:   %v, items per worker %v (from overflow %v)\n",
+		hybrid.Elapsed.Round(time.Millisecond), hybrid.PerWorker, hybrid.Overflow)
+	record("affinity", map[string]affinityStats{"strict": strict, "overflow": hybrid})
+
+	phase("priority")
+	// Priority fan-in: results are already buffered when the consumer reads
+	fmt.Fprintln(output(), "\nPriority fan-in (urgent results preempt ones that arrived earlier):")
+	var sources []<-chan Result
+	for w := 0; w < 3; w++ {
+		ch := make(chan Result, 4)
+		for i := 0; i < 4; i++ {
+			id := w*4 + i
+			priority := 0
+			if id%5 == 4 {
+				priority = 10 // urgent
+			}
+			ch <- Result{OriginalID: id, Processed: fmt.Sprintf("processed-data-%d", id), WorkerID: w + 1, Priority: priority}
+		}
+		close(ch)
+		sources = append(sources, ch)
+	}
+	prioritized := fanInPriority(sources)
+	time.Sleep(50 * time.Millisecond) // let every result reach the buffer
+	var byPriority []Result
+	for r := range prioritized {
+		fmt.Fprintf(output(), "Item %d (worker %d, priority %d)\n", r.OriginalID, r.WorkerID, r.Priority)
+		byPriority = append(byPriority, r)
 	}
+	record("priority", byPriority)
+	verify("priority fan-in", func() error {
+		// Within a priority, each worker's results keep their arrival order
+		lastSeen := make(map[[2]int]int)
+		for i, r := range byPriority {
+			if i > 0 && r.Priority > byPriority[i-1].Priority {
+				return fmt.Errorf("item %d (priority %d) emitted after item %d (priority %d)",
+					r.OriginalID, r.Priority, byPriority[i-1].OriginalID, byPriority[i-1].Priority)
+			}
+			key := [2]int{r.WorkerID, r.Priority}
+			if last, ok := lastSeen[key]; ok && r.OriginalID < last {
+				return fmt.Errorf("worker %d's item %d emitted after item %d", r.WorkerID, r.OriginalID, last)
+			}
+			lastSeen[key] = r.OriginalID
+		}
+		return nil
+	})
 
-	fmt.Printf("\nFan-out/Fan-in completed! Processed %d items.\n", count)
+	phase("leaks")
+	// Cancellation must leave nothing running, however far the fan-out got
+	fmt.Fprintln(output(), "\nCancelable fan-out leak check:")
+	runFanOutCancel()
+
+	printTrace()
 }
 
 // WorkItem represents a unit of work
 type WorkItem struct {
 	ID   int
 	Data string
+	// TraceID is set when the item is generated under a RunContext
+	TraceID string
 }
 
 // Result represents the processed work item
@@ -45,19 +364,32 @@ type Result struct {
 	OriginalID int
 	Processed  string
 	WorkerID   int
+	// Priority orders results in fanInPriority, higher first
+	Priority int
+	// Latency is how long the worker took to process the item
+	Latency time.Duration
+	// TraceID is the TraceID of the work item
+	TraceID string
 }
 
 // Generate work items
 func generateWorkItems(count int) <-chan WorkItem {
+	return generateTracedWorkItems(nil, count)
+}
+
+// generateTracedWorkItems generates work items with TraceIDs from rc
+func generateTracedWorkItems(rc *RunContext, count int) <-chan WorkItem {
 	out := make(chan WorkItem)
 	go func() {
 		defer close(out)
 		for i := 0; i < count; i++ {
 			item := WorkItem{
-				ID:   i,
-				Data: fmt.Sprintf("data-%d", i),
+				ID:      i,
+				Data:    fmt.Sprintf("data-%d", i),
+				TraceID: rc.NextTraceID(),
 			}
-			fmt.Printf("Generated work item: %d\n", i)
+			fmt.Fprintf(output(), "Generated work item: %d\n", i)
+			traceHop(item.TraceID, "generated")
 			out <- item
 			time.Sleep(50 * time.Millisecond)
 		}
@@ -65,29 +397,60 @@ func generateWorkItems(count int) <-chan WorkItem {
 	return out
 }
 
-// Worker function that processes work items
-func worker(id int, jobs <-chan WorkItem, results chan<- Result, wg *sync.WaitGroup) {
+// generateBulkWorkItems generates count work items as fast as they are
+// taken, without the commentary, for runs too large to narrate
+func generateBulkWorkItems(count int) <-chan WorkItem {
+	out := make(chan WorkItem, 64)
+	go func() {
+		defer close(out)
+		for i := 0; i < count; i++ {
+			out <- WorkItem{ID: i, Data: "data-" + strconv.Itoa(i)}
+		}
+	}()
+	return out
+}
+
+// Worker function that processes work items. With simulate unset it skips
+// the simulated work and the commentary, for bulk runs.
+func worker(id int, jobs <-chan WorkItem, results chan<- Result, counters *fanCounters, wg *sync.WaitGroup, simulate bool) {
 	defer wg.Done()
 
 	for job := range jobs {
+		heartbeat()
+		span := tracer.Start("fan.worker")
+		span.SetTag("worker", id)
+		span.SetTag("item", job.ID)
+		began := time.Now()
+
 		// Simulate processing work
-		time.Sleep(time.Duration(rand.Intn(200)+100) * time.Millisecond)
+		if simulate {
+			time.Sleep(time.Duration(rand.Intn(200)+100) * time.Millisecond)
+		}
 
 		result := Result{
 			OriginalID: job.ID,
-			Processed:  fmt.Sprintf("processed-%s-by-worker-%d", job.Data, id),
+			Processed:  "processed-" + job.Data + "-by-worker-" + strconv.Itoa(id),
 			WorkerID:   id,
+			Latency:    time.Since(began),
+			TraceID:    job.TraceID,
 		}
+		span.End()
+		traceHop(job.TraceID, fmt.Sprintf("processed by worker %d", id))
 
-		fmt.Printf("Worker %d processed item %d\n", id, job.ID)
+		if simulate {
+			fmt.Fprintf(output(), "Worker %d processed item %d\n", id, job.ID)
+		}
 		results <- result
+		counters.done(id, nil)
 	}
 }
 
-// Fan out: Distribute work across multiple workers
-func fanOut(jobs <-chan WorkItem, numWorkers int) []<-chan Result {
+// Fan out: Distribute work across multiple workers, which keep count of
+// their work in the returned counters
+func fanOut(jobs <-chan WorkItem, numWorkers int, simulate bool) ([]<-chan Result, *fanCounters) {
 	var workers []chan Result
 	var wg sync.WaitGroup
+	counters := newFanCounters(numWorkers)
 
 	// Create workers
 	for i := 0; i < numWorkers; i++ {
@@ -95,7 +458,7 @@ func fanOut(jobs <-chan WorkItem, numWorkers int) []<-chan Result {
 		workers = append(workers, workerResults)
 
 		wg.Add(1)
-		go worker(i+1, jobs, workerResults, &wg)
+		go worker(i+1, jobs, workerResults, counters, &wg, simulate)
 	}
 
 	// Close worker result channels when all workers are done
@@ -112,7 +475,7 @@ func fanOut(jobs <-chan WorkItem, numWorkers int) []<-chan Result {
 		resultChannels = append(resultChannels, ch)
 	}
 
-	return resultChannels
+	return resultChannels, counters
 }
 
 // Fan in: Collect results from multiple channels
@@ -124,6 +487,7 @@ func fanIn(inputs []<-chan Result) <-chan Result {
 	forward := func(c <-chan Result) {
 		defer wg.Done()
 		for result := range c {
+			heartbeat()
 			out <- result
 		}
 	}
@@ -0,0 +1,104 @@
+package examples
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// affinityStats reports how an affinity fan-out spread its work
+type affinityStats struct {
+	Elapsed time.Duration
+	// PerWorker counts the items each worker processed, Overflow how many
+	// of those it took from the shared overflow channel
+	PerWorker []int
+	Overflow  []int
+}
+
+// fanOutAffinity routes every item to the worker owning its key, so items
+// with the same key always land on the same worker's local queue. With
+// overflow set, an item whose owner's queue is full may instead go to a
+// shared overflow channel, which workers read only when their own queue is
+// empty: affinity holds while the owner keeps up, and idle workers help when
+// the load is skewed. It blocks until every item is processed.
+func fanOutAffinity(items []WorkItem, numWorkers, queueSize int, overflow bool, key func(WorkItem) string, process func(workerID int, item WorkItem)) affinityStats {
+	start := time.Now()
+	stats := affinityStats{PerWorker: make([]int, numWorkers), Overflow: make([]int, numWorkers)}
+
+	locals := make([]chan WorkItem, numWorkers)
+	for i := range locals {
+		locals[i] = make(chan WorkItem, queueSize)
+	}
+	var shared chan WorkItem
+	if overflow {
+		shared = make(chan WorkItem)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			local, spill := locals[i], shared
+			for local != nil || spill != nil {
+				heartbeat()
+				// Own queue first; only an idle worker looks at the overflow
+				select {
+				case item, ok := <-local:
+					if !ok {
+						local = nil
+						continue
+					}
+					process(i+1, item)
+					stats.PerWorker[i]++
+					continue
+				default:
+				}
+				select {
+				case item, ok := <-local:
+					if !ok {
+						local = nil
+						continue
+					}
+					process(i+1, item)
+					stats.PerWorker[i]++
+				case item, ok := <-spill:
+					if !ok {
+						spill = nil
+						continue
+					}
+					process(i+1, item)
+					stats.PerWorker[i]++
+					stats.Overflow[i]++
+				}
+			}
+		}(i)
+	}
+
+	for _, item := range items {
+		h := fnv.New32a()
+		h.Write([]byte(key(item)))
+		owner := locals[int(h.Sum32()%uint32(numWorkers))]
+
+		select {
+		case owner <- item:
+			continue
+		default:
+		}
+		// The owner is backed up: wait for it or, with overflow on, any idle worker
+		select {
+		case owner <- item:
+		case shared <- item:
+		}
+	}
+	for _, local := range locals {
+		close(local)
+	}
+	if shared != nil {
+		close(shared)
+	}
+	wg.Wait()
+
+	stats.Elapsed = time.Since(start)
+	return stats
+}
@@ -0,0 +1,70 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestFanOutAffinityOverflow runs a skewed load, most items sharing one key,
+// with strict affinity and with the overflow channel, and checks every item
+// runs once either way, strict affinity keeps the hot key on one worker, and
+// with overflow idle workers take some of it and the run finishes sooner
+func TestFanOutAffinityOverflow(t *testing.T) {
+	var items []WorkItem
+	for i := 0; i < 24; i++ {
+		key := "hot"
+		if i%4 == 3 {
+			key = fmt.Sprintf("cold-%d", i)
+		}
+		items = append(items, WorkItem{ID: i, Data: key})
+	}
+	byKey := func(item WorkItem) string { return item.Data }
+
+	run := func(overflow bool) (affinityStats, map[int][]int) {
+		var mu sync.Mutex
+		ranOn := make(map[int][]int) // item ID -> workers that processed it
+		stats := fanOutAffinity(items, 4, 2, overflow, byKey, func(worker int, item WorkItem) {
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			ranOn[item.ID] = append(ranOn[item.ID], worker)
+			mu.Unlock()
+		})
+		return stats, ranOn
+	}
+
+	strict, strictRan := run(false)
+	hybrid, hybridRan := run(true)
+	for name, ranOn := range map[string]map[int][]int{"strict": strictRan, "overflow": hybridRan} {
+		for _, item := range items {
+			if len(ranOn[item.ID]) != 1 {
+				t.Fatalf("%s: item %d ran on workers %v", name, item.ID, ranOn[item.ID])
+			}
+		}
+	}
+
+	hotWorker := strictRan[0][0]
+	for _, n := range strict.Overflow {
+		if n != 0 {
+			t.Fatalf("strict affinity took %v from an overflow channel", strict.Overflow)
+		}
+	}
+	for _, item := range items {
+		if item.Data == "hot" && strictRan[item.ID][0] != hotWorker {
+			t.Fatalf("strict affinity ran hot item %d on worker %d, the rest on %d", item.ID, strictRan[item.ID][0], hotWorker)
+		}
+	}
+	helped := 0
+	for i, n := range hybrid.Overflow {
+		if i+1 != hotWorker {
+			helped += n
+		}
+	}
+	if helped == 0 {
+		t.Fatalf("with overflow, workers took %v from the overflow channel; idle workers should help with the hot key", hybrid.Overflow)
+	}
+	if hybrid.Elapsed >= strict.Elapsed*3/4 {
+		t.Fatalf("with overflow took %v, strict affinity %v; want a clear improvement", hybrid.Elapsed, strict.Elapsed)
+	}
+}
@@ -0,0 +1,91 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"time"
+)
+
+// fanOutCtx is fanOut with cancellation. Each worker owns its result channel
+// and closes it when it exits, so no send can ever hit a closed channel; once
+// ctx is done every worker stops taking jobs, abandons the item in hand and
+// exits, closing its channel. A consumer that drains the fanned-in results
+// after cancelling therefore sees them close promptly, with nothing left
+// running.
+func fanOutCtx(ctx context.Context, jobs <-chan WorkItem, numWorkers int) []<-chan Result {
+	outs := make([]<-chan Result, numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		results := make(chan Result)
+		outs[i] = results
+		go workerCtx(ctx, i+1, jobs, results)
+	}
+	return outs
+}
+
+func workerCtx(ctx context.Context, id int, jobs <-chan WorkItem, results chan<- Result) {
+	defer close(results)
+	for {
+		var job WorkItem
+		select {
+		case j, ok := <-jobs:
+			if !ok {
+				return
+			}
+			job = j
+		case <-ctx.Done():
+			return
+		}
+
+		// Simulate processing work
+		if !sleepOrDone(time.Duration(rand.Intn(20)+10)*time.Millisecond, ctx.Done()) {
+			return
+		}
+		select {
+		case results <- Result{OriginalID: job.ID, Processed: "processed-" + job.Data, WorkerID: id}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// generateWorkItemsCtx is generateWorkItems that stops, closing its channel,
+// once ctx is done
+func generateWorkItemsCtx(ctx context.Context, count int) <-chan WorkItem {
+	out := make(chan WorkItem)
+	go func() {
+		defer close(out)
+		for i := 0; i < count; i++ {
+			select {
+			case out <- WorkItem{ID: i, Data: fmt.Sprintf("data-%d", i)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// runFanOutCancel cancels a fan-out of 30 items part way through and shows
+// the merged results closing with every worker gone
+func runFanOutCancel() {
+	baseline := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	merged := fanIn(fanOutCtx(ctx, generateWorkItemsCtx(ctx, 30), 4))
+	received := 0
+	for range merged {
+		received++
+		if received == 5 {
+			cancel()
+		}
+	}
+	cancel()
+	fmt.Fprintf(output(), "Cancelled after 5 results; %d of 30 arrived before the merged channel closed\n", received)
+	record("cancelled_fan_out", received)
+	if err := waitForGoroutines(baseline, time.Second); err != nil {
+		fail("fan-out cancellation: %v", err)
+		return
+	}
+	fmt.Fprintln(output(), "No fan-out goroutines left running")
+}
@@ -0,0 +1,50 @@
+package examples
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestFanOutNoLeak runs the cancelable fan-out cancelled before any result,
+// cancelled mid-stream and run to completion, and checks every goroutine it
+// started is gone afterwards
+func TestFanOutNoLeak(t *testing.T) {
+	cases := []struct {
+		name        string
+		cancelAfter int // results to receive before cancelling; -1 never cancels
+		wantAll     bool
+	}{
+		{"cancel before any result", 0, false},
+		{"cancel mid-stream", 5, false},
+		{"normal completion", -1, true},
+	}
+	for _, c := range cases {
+		baseline := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		if c.cancelAfter == 0 {
+			cancel()
+		}
+		merged := fanIn(fanOutCtx(ctx, generateWorkItemsCtx(ctx, 30), 4))
+
+		received := 0
+		for range merged {
+			received++
+			if received == c.cancelAfter {
+				cancel()
+			}
+		}
+		cancel()
+
+		if c.wantAll && received != 30 {
+			t.Fatalf("%s: received %d of 30 results", c.name, received)
+		}
+		if !c.wantAll && received >= 30 {
+			t.Fatalf("%s: all 30 results arrived despite the cancel", c.name)
+		}
+		if err := waitForGoroutines(baseline, time.Second); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+	}
+}
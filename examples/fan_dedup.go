@@ -0,0 +1,25 @@
+package examples
+
+// DedupResults forwards the first result for each OriginalID and drops any
+// later duplicates, such as a second result from a job that was retried
+// after its first result had already been delivered. Results keep their
+// arrival order.
+//
+// The set of seen IDs grows with every distinct ID and is only freed when in
+// closes, so a long-lived stream over a large ID space needs a bounded
+// window instead (for example, forgetting IDs below a low-water mark).
+func DedupResults(in <-chan Result) <-chan Result {
+	out := make(chan Result)
+	go func() {
+		defer close(out)
+		seen := make(map[int]struct{})
+		for r := range in {
+			if _, dup := seen[r.OriginalID]; dup {
+				continue
+			}
+			seen[r.OriginalID] = struct{}{}
+			out <- r
+		}
+	}()
+	return out
+}
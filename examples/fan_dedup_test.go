@@ -0,0 +1,38 @@
+package examples
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestDedupResults feeds results with duplicates for some IDs, including
+// back-to-back and far-apart repeats, and checks that each ID comes out
+// exactly once, in the order it first arrived
+func TestDedupResults(t *testing.T) {
+	ids := []int{1, 2, 2, 3, 1, 4, 5, 3, 3, 6, 2, 7}
+	want := []int{1, 2, 3, 4, 5, 6, 7}
+
+	in := make(chan Result)
+	go func() {
+		defer close(in)
+		for attempt, id := range ids {
+			in <- Result{OriginalID: id, Processed: fmt.Sprintf("attempt-%d", attempt)}
+		}
+	}()
+	var got []Result
+	for r := range DedupResults(in) {
+		got = append(got, r)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%d results out, want %d: %v", len(got), len(want), got)
+	}
+	for i, r := range got {
+		if r.OriginalID != want[i] {
+			t.Fatalf("result %d is item %d, want item %d", i, r.OriginalID, want[i])
+		}
+	}
+	// The first attempt is the one kept
+	if got[1].Processed != "attempt-1" || got[2].Processed != "attempt-3" {
+		t.Fatalf("kept %s for item 2 and %s for item 3, want their first attempts", got[1].Processed, got[2].Processed)
+	}
+}
@@ -0,0 +1,140 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errConnectionRevoked is the simulated unrecoverable failure of a fan worker
+var errConnectionRevoked = errors.New("connection revoked")
+
+// fanEvent is what a degradable fan worker reports for each item it takes:
+// either a result, or the terminal error that made it remove itself
+type fanEvent struct {
+	workerID int
+	item     WorkItem
+	result   Result
+	err      error
+}
+
+// fanOutDegradable distributes jobs across numWorkers workers like fanOut,
+// but a worker whose process call returns an error removes itself: it hands
+// its item back to the coordinator and stops pulling jobs, and the surviving
+// workers absorb its share. The results channel closes when every item has
+// been processed or every worker is gone. The error channel then yields nil,
+// or an ErrAllWorkersFailed if no worker survived, in which case the rest of
+// jobs is drained so its producer isn't left blocked.
+func fanOutDegradable(jobs <-chan WorkItem, numWorkers int, process func(workerID int, item WorkItem) (Result, error)) (<-chan Result, <-chan error) {
+	out := make(chan Result)
+	errc := make(chan error, 1)
+	work := make(chan WorkItem)
+	events := make(chan fanEvent)
+
+	for i := 1; i <= numWorkers; i++ {
+		go func(id int) {
+			for item := range work {
+				result, err := process(id, item)
+				events <- fanEvent{workerID: id, item: item, result: result, err: err}
+				if err != nil {
+					return
+				}
+			}
+		}(i)
+	}
+
+	// Coordinator: owns the queues, so no locking is needed for the bookkeeping
+	go func() {
+		defer close(out)
+		src := jobs
+		var pending []WorkItem
+		var outq []Result
+		inflight := 0
+		alive := numWorkers
+		dead := make(map[int]error)
+
+		for alive > 0 && (src != nil || len(pending) > 0 || inflight > 0 || len(outq) > 0) {
+			// nil channels disable the cases that have nothing to do
+			var workCh chan<- WorkItem
+			var next WorkItem
+			srcCh := src
+			if len(pending) > 0 {
+				workCh, next = work, pending[0]
+				srcCh = nil
+			}
+			var outCh chan<- Result
+			var nextOut Result
+			if len(outq) > 0 {
+				outCh, nextOut = out, outq[0]
+			}
+
+			select {
+			case item, ok := <-srcCh:
+				if !ok {
+					src = nil
+					continue
+				}
+				pending = append(pending, item)
+			case workCh <- next:
+				pending = pending[1:]
+				inflight++
+			case outCh <- nextOut:
+				outq = outq[1:]
+			case ev := <-events:
+				inflight--
+				if ev.err == nil {
+					outq = append(outq, ev.result)
+					continue
+				}
+				alive--
+				dead[ev.workerID] = ev.err
+				pending = append(pending, ev.item)
+				fmt.Fprintf(output(), "Degraded: worker %d removed itself (%v), item %d handed back, %d workers left\n",
+					ev.workerID, ev.err, ev.item.ID, alive)
+			}
+		}
+		close(work)
+		for _, r := range outq {
+			out <- r
+		}
+
+		if alive == 0 {
+			if src != nil {
+				go func() {
+					for range src {
+					}
+				}()
+			}
+			errc <- &ErrAllWorkersFailed{Errs: dead}
+			return
+		}
+		errc <- nil
+	}()
+
+	return out, errc
+}
+
+// revokingWorker returns a process function whose listed workers lose their
+// connection on the given attempt, counted per worker
+func revokingWorker(revokeOn map[int]int) func(workerID int, item WorkItem) (Result, error) {
+	// Each worker only touches its own counter, but the map itself must not grow
+	// concurrently, so it is populated up front
+	taken := make(map[int]*int)
+	for id := range revokeOn {
+		taken[id] = new(int)
+	}
+	return func(workerID int, item WorkItem) (Result, error) {
+		time.Sleep(30 * time.Millisecond)
+		if n, ok := taken[workerID]; ok {
+			*n++
+			if *n == revokeOn[workerID] {
+				return Result{}, fmt.Errorf("item %d: %w", item.ID, errConnectionRevoked)
+			}
+		}
+		return Result{
+			OriginalID: item.ID,
+			Processed:  fmt.Sprintf("processed-%s-by-worker-%d", item.Data, workerID),
+			WorkerID:   workerID,
+		}, nil
+	}
+}
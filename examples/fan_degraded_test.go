@@ -0,0 +1,84 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestFanOutDegradablePartialDeath revokes two of four workers' connections
+// and checks the survivors process every item exactly once and the run
+// reports no error
+func TestFanOutDegradablePartialDeath(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+	goroutines := runtime.NumGoroutine()
+
+	results, errc := fanOutDegradable(generateWorkItems(12), 4, revokingWorker(map[int]int{2: 2, 3: 3}))
+	got := requireReceives(t, results, 12, 5*time.Second)
+	if _, ok := <-results; ok {
+		t.Fatalf("results still open after all 12 items")
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("run with two surviving workers returned %v", err)
+	}
+	seen := make(map[int]bool)
+	for _, r := range got {
+		if seen[r.OriginalID] {
+			t.Fatalf("item %d processed twice", r.OriginalID)
+		}
+		seen[r.OriginalID] = true
+		if want := fmt.Sprintf("processed-data-%d-by-worker-%d", r.OriginalID, r.WorkerID); r.Processed != want {
+			t.Fatalf("item %d processed as %q", r.OriginalID, r.Processed)
+		}
+	}
+	goroutineCountStable(t, goroutines)
+}
+
+// TestFanOutDegradableTotalDeath revokes every worker's connection and
+// checks the run fails with each worker's terminal error, and that the
+// generator, with items still to send, is unblocked and finishes
+func TestFanOutDegradableTotalDeath(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+	goroutines := runtime.NumGoroutine()
+
+	const items, workers = 50, 3
+	jobs := make(chan WorkItem)
+	generated := make(chan int, 1)
+	go func() {
+		defer close(jobs)
+		for i := 0; i < items; i++ {
+			jobs <- WorkItem{ID: i, Data: fmt.Sprintf("data-%d", i)}
+		}
+		generated <- items
+	}()
+
+	results, errc := fanOutDegradable(jobs, workers, revokingWorker(map[int]int{1: 1, 2: 2, 3: 2}))
+	processed := 0
+	for range results {
+		processed++
+	}
+	err := <-errc
+	var all *ErrAllWorkersFailed
+	if !errors.As(err, &all) || len(all.Errs) != workers {
+		t.Fatalf("run with every worker revoked returned %v, want each of %d workers' errors", err, workers)
+	}
+	for id, werr := range all.Errs {
+		if !errors.Is(werr, errConnectionRevoked) {
+			t.Fatalf("worker %d failed with %v, want a revoked connection", id, werr)
+		}
+	}
+	if !errors.Is(err, errConnectionRevoked) {
+		t.Fatalf("%v doesn't wrap the workers' errors", err)
+	}
+	// Worker 1 fails at once and workers 2 and 3 on their second item
+	if processed != 2 {
+		t.Fatalf("%d items processed before every worker failed, want 2", processed)
+	}
+	requireReceives(t, generated, 1, time.Second)
+	goroutineCountStable(t, goroutines)
+}
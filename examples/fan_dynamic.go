@@ -0,0 +1,73 @@
+package examples
+
+import (
+	"errors"
+	"sync"
+)
+
+// errFanInClosed is returned by DynamicFanIn.Add after Close
+var errFanInClosed = errors.New("fan-in closed")
+
+// DynamicFanIn merges result channels into one, like fanIn, but inputs can
+// join after the merge has started, for example from workers spawned while
+// it runs. The output closes once Close has been called and every input
+// added before it has drained.
+type DynamicFanIn struct {
+	out chan Result
+	wg  sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	inputs int
+}
+
+func NewDynamicFanIn() *DynamicFanIn {
+	return &DynamicFanIn{out: make(chan Result)}
+}
+
+// Out returns the merged channel
+func (f *DynamicFanIn) Out() <-chan Result {
+	return f.out
+}
+
+// Add starts forwarding ch to the output. It fails once Close was called.
+func (f *DynamicFanIn) Add(ch <-chan Result) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errFanInClosed
+	}
+	// Under mu, so no Add can race with the Wait in Close
+	f.wg.Add(1)
+	f.inputs++
+	go func() {
+		defer f.wg.Done()
+		for result := range ch {
+			heartbeat()
+			f.out <- result
+		}
+	}()
+	return nil
+}
+
+// Inputs returns how many channels have been added
+func (f *DynamicFanIn) Inputs() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inputs
+}
+
+// Close stops accepting inputs; the output closes once the added ones drain.
+// Calling it again does nothing.
+func (f *DynamicFanIn) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	go func() {
+		f.wg.Wait()
+		close(f.out)
+	}()
+}
@@ -0,0 +1,67 @@
+package examples
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestDynamicFanIn starts a merge with one input, adds a second while the
+// first is still sending, and checks every value from both arrives, that the
+// output stays open until Close, and that Add fails afterwards
+func TestDynamicFanIn(t *testing.T) {
+	fanIn := NewDynamicFanIn()
+	first, second := make(chan Result), make(chan Result)
+	if err := fanIn.Add(first); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer close(first)
+		for i := 0; i < 10; i++ {
+			first <- Result{OriginalID: i, WorkerID: 1}
+		}
+	}()
+
+	seen := make(map[int]bool)
+	got := requireReceives(t, fanIn.Out(), 5, time.Second)
+	for _, r := range got {
+		seen[r.OriginalID] = true
+	}
+
+	// Mid-stream: the first input still has values to send
+	if err := fanIn.Add(second); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer close(second)
+		for i := 100; i < 110; i++ {
+			second <- Result{OriginalID: i, WorkerID: 2}
+		}
+	}()
+	got = requireReceives(t, fanIn.Out(), 15, time.Second)
+	for _, r := range got {
+		seen[r.OriginalID] = true
+	}
+	for _, id := range []int{0, 9, 100, 109} {
+		if !seen[id] {
+			t.Fatalf("value %d never arrived", id)
+		}
+	}
+	if len(seen) != 20 {
+		t.Fatalf("%d distinct values arrived, want 20", len(seen))
+	}
+
+	// Both inputs are drained, but the merge is still open for more
+	select {
+	case _, ok := <-fanIn.Out():
+		t.Fatalf("output delivered or closed (open=%v) before Close", ok)
+	case <-time.After(20 * time.Millisecond):
+	}
+	fanIn.Close()
+	if _, ok := <-fanIn.Out(); ok {
+		t.Fatalf("output delivered a value after Close with every input drained")
+	}
+	if err := fanIn.Add(make(chan Result)); !errors.Is(err, errFanInClosed) {
+		t.Fatalf("Add after Close returned %v", err)
+	}
+}
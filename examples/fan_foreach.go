@@ -0,0 +1,66 @@
+package examples
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ForEach runs fn on every item of a slice using at most workers goroutines
+// and returns the first error fn returns, or nil. Once an item fails no
+// further items are started; items already running finish, and their errors
+// are discarded. Workers take the next unstarted index themselves, so no
+// channel has to be fed and closed.
+func ForEach[T any](items []T, workers int, fn func(T) error) error {
+	return forEachIndex(len(items), workers, func(i int) error { return fn(items[i]) })
+}
+
+// MapSlice is ForEach that also collects fn's results: out[i] is the result
+// for items[i], whatever order the items were processed in. Each worker
+// writes only the indices it took, so the output slice needs no lock. On an
+// error the first one is returned along with the partial results.
+func MapSlice[T, R any](items []T, workers int, fn func(T) (R, error)) ([]R, error) {
+	out := make([]R, len(items))
+	err := forEachIndex(len(items), workers, func(i int) error {
+		r, err := fn(items[i])
+		out[i] = r
+		return err
+	})
+	return out, err
+}
+
+// forEachIndex runs fn for the indices 0 to n-1 on at most workers goroutines,
+// stopping at the first error
+func forEachIndex(n, workers int, fn func(i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+	var (
+		next     int64 = -1
+		failed   int32
+		firstErr error
+		once     sync.Once
+		wg       sync.WaitGroup
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				if err := fn(i); err != nil {
+					once.Do(func() { firstErr = err })
+					atomic.StoreInt32(&failed, 1)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
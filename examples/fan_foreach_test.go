@@ -0,0 +1,103 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestForEach processes a slice with a worker cap and checks that every
+// item is processed once and the cap holds, then that a failure on one item
+// is returned and stops the items not yet started
+func TestForEach(t *testing.T) {
+	items := make([]int, 200)
+	for i := range items {
+		items[i] = i
+	}
+	seen := make([]int32, len(items))
+	var running, peak int32
+	track := func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&seen[i], 1)
+		atomic.AddInt32(&running, -1)
+	}
+	err := ForEach(items, 5, func(i int) error {
+		track(i)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach without failures returned %v", err)
+	}
+	for i, n := range seen {
+		if n != 1 {
+			t.Fatalf("item %d processed %d times", i, n)
+		}
+	}
+	if peak > 5 {
+		t.Fatalf("%d items ran at once, cap 5", peak)
+	}
+
+	errBad := errors.New("bad item")
+	var processed int32
+	err = ForEach(items, 5, func(i int) error {
+		atomic.AddInt32(&processed, 1)
+		time.Sleep(time.Millisecond)
+		if i == 20 {
+			return fmt.Errorf("item %d: %w", i, errBad)
+		}
+		return nil
+	})
+	if !errors.Is(err, errBad) {
+		t.Fatalf("ForEach with a failing item returned %v, want the item's error", err)
+	}
+	// Item 20 is picked up after at most 20 others and the rest stop soon after
+	if n := atomic.LoadInt32(&processed); n >= int32(len(items)) {
+		t.Fatalf("all %d items were started despite the failure", n)
+	}
+}
+
+// TestMapSlice maps a slice with random per-item delays, so items finish
+// out of order, and checks every result landed at its item's index, then
+// that an error from one item is returned
+func TestMapSlice(t *testing.T) {
+	items := make([]int, 300)
+	for i := range items {
+		items[i] = i * 7
+	}
+	square := func(v int) (string, error) {
+		time.Sleep(time.Duration(v%5) * 100 * time.Microsecond)
+		return fmt.Sprintf("%d^2=%d", v, v*v), nil
+	}
+	out, err := MapSlice(items, 8, square)
+	if err != nil {
+		t.Fatalf("MapSlice without failures returned %v", err)
+	}
+	if len(out) != len(items) {
+		t.Fatalf("%d results for %d items", len(out), len(items))
+	}
+	for i, v := range items {
+		if want, _ := square(v); out[i] != want {
+			t.Fatalf("out[%d] = %q, want %q", i, out[i], want)
+		}
+	}
+
+	errBad := errors.New("bad item")
+	_, err = MapSlice(items, 8, func(v int) (string, error) {
+		if v == 7*150 {
+			return "", fmt.Errorf("item %d: %w", v, errBad)
+		}
+		return square(v)
+	})
+	if !errors.Is(err, errBad) {
+		t.Fatalf("MapSlice with a failing item returned %v, want the item's error", err)
+	}
+}
@@ -0,0 +1,100 @@
+package examples
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ReorderStats reports how the reorder buffer of OrderedParallelMap behaved.
+// Read it once the output channel has closed.
+type ReorderStats struct {
+	// Stalls counts how often the input was held back because the reorder buffer was full
+	Stalls int64
+	// MaxBuffered is the most completed items ever waiting for an earlier one
+	MaxBuffered int64
+}
+
+// OrderedParallelMap applies fn to every value from in using the given number
+// of workers, but emits results in input order. At most bufLimit items may be
+// in flight or waiting in the reorder buffer; when one slow item holds up the
+// rest, the input is backpressured and a stall is recorded instead of letting
+// the buffer grow without bound. workers and bufLimit below 1 are treated as 1.
+func OrderedParallelMap[T, R any](in <-chan T, workers, bufLimit int, fn func(T) R) (<-chan R, *ReorderStats) {
+	if workers < 1 {
+		workers = 1
+	}
+	if bufLimit < 1 {
+		bufLimit = 1
+	}
+	type task struct {
+		seq int
+		val T
+	}
+	type done struct {
+		seq int
+		val R
+	}
+
+	stats := &ReorderStats{}
+	slots := make(chan struct{}, bufLimit)
+	tasks := make(chan task)
+	results := make(chan done)
+	out := make(chan R)
+
+	// Dispatcher: take a slot for every item before handing it to a worker
+	go func() {
+		defer close(tasks)
+		seq := 0
+		for v := range in {
+			select {
+			case slots <- struct{}{}:
+			default:
+				atomic.AddInt64(&stats.Stalls, 1)
+				slots <- struct{}{}
+			}
+			tasks <- task{seq: seq, val: v}
+			seq++
+		}
+	}()
+
+	// Workers
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				results <- done{seq: t.seq, val: fn(t.val)}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Reorderer: hold early results until their predecessors arrive
+	go func() {
+		defer close(out)
+		pending := make(map[int]R)
+		next := 0
+		for r := range results {
+			pending[r.seq] = r.val
+			if n := int64(len(pending)); n > atomic.LoadInt64(&stats.MaxBuffered) {
+				atomic.StoreInt64(&stats.MaxBuffered, n)
+			}
+			for {
+				v, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				out <- v
+				<-slots
+				next++
+			}
+		}
+	}()
+
+	return out, stats
+}
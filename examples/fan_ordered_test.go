@@ -0,0 +1,97 @@
+package examples
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestOrderedParallelMapOrderAndBound maps items with random latencies
+// through a slow, irregular consumer and checks results come out in input
+// order with never more than bufLimit items in flight
+func TestOrderedParallelMapOrderAndBound(t *testing.T) {
+	const items, workers, bufLimit = 60, 6, 4
+	rng := rand.New(rand.NewSource(7))
+	delays := make([]time.Duration, items)
+	for i := range delays {
+		delays[i] = time.Duration(rng.Intn(8)) * time.Millisecond
+	}
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 0; i < items; i++ {
+			in <- i
+		}
+	}()
+
+	var started int64
+	out, stats := OrderedParallelMap(in, workers, bufLimit, func(i int) int {
+		atomic.AddInt64(&started, 1)
+		time.Sleep(delays[i])
+		return i * i
+	})
+
+	received := 0
+	for v := range out {
+		if v != received*received {
+			t.Fatalf("result %d is %d, want %d", received, v, received*received)
+		}
+		received++
+		// Every slot beyond the first bufLimit was freed by a result this
+		// consumer has already received
+		if s := atomic.LoadInt64(&started); s-int64(received) > bufLimit {
+			t.Fatalf("%d items started after %d were received, more than %d in flight", s, received, bufLimit)
+		}
+		time.Sleep(time.Duration(rng.Intn(3)) * time.Millisecond)
+	}
+	if received != items {
+		t.Fatalf("received %d of %d results", received, items)
+	}
+	if stats.MaxBuffered > bufLimit {
+		t.Fatalf("reorder buffer held %d results, limit %d", stats.MaxBuffered, bufLimit)
+	}
+}
+
+// TestOrderedParallelMapStopsAtBufLimit leaves the output unread and checks
+// exactly bufLimit items start, however many workers are idle
+func TestOrderedParallelMapStopsAtBufLimit(t *testing.T) {
+	const bufLimit = 3
+	var started int64
+	out, _ := OrderedParallelMap(generateInts(20), 8, bufLimit, func(i int) int {
+		atomic.AddInt64(&started, 1)
+		return i
+	})
+	waitUntil(t, func() bool { return atomic.LoadInt64(&started) == bufLimit }, time.Second, "fewer than %d items started with the output unread", bufLimit)
+	time.Sleep(20 * time.Millisecond)
+	if s := atomic.LoadInt64(&started); s != bufLimit {
+		t.Fatalf("%d items started with the output unread, limit %d", s, bufLimit)
+	}
+	for range out {
+	}
+}
+
+// TestOrderedParallelMapClampsArguments checks zero workers and a zero
+// buffer limit run as one of each instead of deadlocking
+func TestOrderedParallelMapClampsArguments(t *testing.T) {
+	for _, c := range []struct{ workers, bufLimit int }{{0, 4}, {4, 0}, {-1, -1}} {
+		out, _ := OrderedParallelMap(generateInts(5), c.workers, c.bufLimit, func(i int) int { return i })
+		got := requireReceives(t, out, 5, time.Second)
+		for i, v := range got {
+			if v != i {
+				t.Fatalf("workers %d, bufLimit %d: got %v", c.workers, c.bufLimit, got)
+			}
+		}
+	}
+}
+
+func generateInts(n int) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			out <- i
+		}
+	}()
+	return out
+}
@@ -0,0 +1,82 @@
+package examples
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// prioritizedResult is a buffered result and the order it arrived in
+type prioritizedResult struct {
+	Result
+	arrival int
+}
+
+// resultHeap orders buffered results by priority, highest first, then by arrival
+type resultHeap []prioritizedResult
+
+func (h resultHeap) Len() int { return len(h) }
+func (h resultHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].arrival < h[j].arrival
+}
+func (h resultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x interface{}) { *h = append(*h, x.(prioritizedResult)) }
+func (h *resultHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// fanInPriority merges the inputs like fanIn, but whenever the consumer is
+// ready it gets the highest-priority result buffered so far rather than the
+// oldest. Results of equal priority keep their arrival order. Only results
+// that have already arrived compete: a high-priority result still being
+// worked on can't hold back one that is ready.
+func fanInPriority(inputs []<-chan Result) <-chan Result {
+	merged := make(chan Result)
+	var wg sync.WaitGroup
+	wg.Add(len(inputs))
+	for _, input := range inputs {
+		go func(c <-chan Result) {
+			defer wg.Done()
+			for result := range c {
+				merged <- result
+			}
+		}(input)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	out := make(chan Result)
+	go func() {
+		defer close(out)
+		var buffered resultHeap
+		arrivals := 0
+		in := merged
+		for in != nil || buffered.Len() > 0 {
+			// Only offer a result when one is buffered
+			var send chan Result
+			var next Result
+			if buffered.Len() > 0 {
+				send, next = out, buffered[0].Result
+			}
+			select {
+			case r, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				heap.Push(&buffered, prioritizedResult{Result: r, arrival: arrivals})
+				arrivals++
+			case send <- next:
+				heap.Pop(&buffered)
+			}
+		}
+	}()
+	return out
+}
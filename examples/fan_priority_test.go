@@ -0,0 +1,54 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFanInPriorityDrainsUrgentFirst buffers low-priority results ahead of
+// urgent ones and checks the urgent ones come out first, each priority in
+// arrival order
+func TestFanInPriorityDrainsUrgentFirst(t *testing.T) {
+	low, high := make(chan Result), make(chan Result)
+	out := fanInPriority([]<-chan Result{low, high})
+
+	// Every low-priority result arrives before any urgent one
+	for i := 0; i < 3; i++ {
+		low <- Result{OriginalID: i}
+	}
+	for i := 3; i < 5; i++ {
+		high <- Result{OriginalID: i, Priority: 10}
+	}
+	close(low)
+	close(high)
+	time.Sleep(20 * time.Millisecond) // the last send may still be reaching the buffer
+
+	var got []int
+	for _, r := range requireReceives(t, out, 5, time.Second) {
+		got = append(got, r.OriginalID)
+	}
+	want := []int{3, 4, 0, 1, 2}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("emitted %v, want the urgent 3 and 4 first, then %v", got, want[2:])
+		}
+	}
+	requireNoReceive(t, out, 20*time.Millisecond)
+}
+
+// TestFanInPriorityReadyBeatsPending checks a buffered low-priority result
+// is delivered while an urgent one is still being worked on
+func TestFanInPriorityReadyBeatsPending(t *testing.T) {
+	low, high := make(chan Result, 1), make(chan Result)
+	out := fanInPriority([]<-chan Result{low, high})
+	low <- Result{OriginalID: 1}
+	close(low)
+	if r := requireReceives(t, out, 1, time.Second)[0]; r.OriginalID != 1 {
+		t.Fatalf("got item %d, want the ready item 1", r.OriginalID)
+	}
+	high <- Result{OriginalID: 2, Priority: 10}
+	close(high)
+	if r := requireReceives(t, out, 1, time.Second)[0]; r.OriginalID != 2 {
+		t.Fatalf("got item %d, want the urgent item 2", r.OriginalID)
+	}
+}
@@ -0,0 +1,127 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// QuorumResult is the accepted outcome of a replicated work item. Disagreed
+// is set when the replicas' Processed strings were not all equal, in which
+// case Replicas holds every replica's output. Accepted is false when no
+// majority agreed, so Processed can't be trusted.
+type QuorumResult struct {
+	OriginalID int
+	Processed  string
+	Accepted   bool
+	Disagreed  bool
+	Replicas   []Result
+}
+
+// fanOutReplicated sends every item to k different workers, k clamped to
+// between 1 and numWorkers. With majority unset, the first replica to
+// finish is emitted and the rest are discarded. With majority set, all k
+// replicas are awaited and the item is emitted with the output a majority
+// agreed on, flagged if any replica disagreed. Each item is emitted exactly
+// once.
+func fanOutReplicated(items <-chan WorkItem, numWorkers, k int, majority bool, process func(workerID int, item WorkItem) Result) <-chan QuorumResult {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	if k < 1 {
+		k = 1
+	}
+	if k > numWorkers {
+		k = numWorkers
+	}
+	queues := make([]chan WorkItem, numWorkers)
+	replicas := make(chan Result)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan WorkItem, k)
+		wg.Add(1)
+		go func(id int, queue <-chan WorkItem) {
+			defer wg.Done()
+			for item := range queue {
+				r := process(id, item)
+				r.OriginalID, r.WorkerID = item.ID, id
+				replicas <- r
+			}
+		}(i+1, queues[i])
+	}
+
+	// Dispatcher: item n goes to k consecutive workers starting at n mod numWorkers
+	go func() {
+		n := 0
+		for item := range items {
+			for j := 0; j < k; j++ {
+				queues[(n+j)%numWorkers] <- item
+			}
+			n++
+		}
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+		close(replicas)
+	}()
+
+	// Collector: owns the per-item replica lists
+	out := make(chan QuorumResult)
+	go func() {
+		defer close(out)
+		pending := make(map[int][]Result)
+		for r := range replicas {
+			got := append(pending[r.OriginalID], r)
+			pending[r.OriginalID] = got
+			if len(got) < k {
+				if !majority && len(got) == 1 {
+					out <- QuorumResult{OriginalID: r.OriginalID, Processed: r.Processed, Accepted: true}
+				}
+				continue
+			}
+			delete(pending, r.OriginalID)
+			if !majority {
+				if k == 1 {
+					out <- QuorumResult{OriginalID: r.OriginalID, Processed: r.Processed, Accepted: true}
+				}
+				continue
+			}
+			out <- quorumOf(got)
+		}
+	}()
+
+	return out
+}
+
+// quorumOf picks the output a majority of the replicas agree on
+func quorumOf(replicas []Result) QuorumResult {
+	votes := make(map[string]int)
+	for _, r := range replicas {
+		votes[r.Processed]++
+	}
+	q := QuorumResult{OriginalID: replicas[0].OriginalID}
+	for processed, n := range votes {
+		if n > len(replicas)/2 {
+			q.Processed, q.Accepted = processed, true
+		}
+	}
+	if len(votes) > 1 {
+		q.Disagreed = true
+		q.Replicas = replicas
+	}
+	return q
+}
+
+// corruptingWorker processes items honestly except on the given worker,
+// which garbles its output
+func corruptingWorker(corruptID int) func(workerID int, item WorkItem) Result {
+	return func(workerID int, item WorkItem) Result {
+		time.Sleep(time.Duration(10+5*workerID) * time.Millisecond)
+		processed := "processed-" + item.Data
+		if workerID == corruptID {
+			processed = fmt.Sprintf("pr0c3ss3d-%s-%d", item.Data, item.ID*7)
+		}
+		return Result{Processed: processed}
+	}
+}
@@ -0,0 +1,139 @@
+package examples
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+)
+
+// collectQuorum reads a replicated fan-out to the end and fails the test if
+// any item is emitted more than once or missing
+func collectQuorum(t *testing.T, out <-chan QuorumResult, items int) map[int]QuorumResult {
+	t.Helper()
+	got := make(map[int]QuorumResult)
+	for _, q := range requireReceives(t, out, items, 5*time.Second) {
+		if _, dup := got[q.OriginalID]; dup {
+			t.Fatalf("item %d emitted twice", q.OriginalID)
+		}
+		got[q.OriginalID] = q
+	}
+	requireNoReceive(t, out, 50*time.Millisecond)
+	if len(got) != items {
+		t.Fatalf("%d distinct items emitted, want %d", len(got), items)
+	}
+	return got
+}
+
+// TestReplicatedMajority runs every item on 3 of 4 workers, worker 1
+// corrupting its output, and checks items that reached worker 1 are flagged
+// with all three replicas yet accept the honest majority, and the rest agree
+func TestReplicatedMajority(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	got := collectQuorum(t, fanOutReplicated(generateWorkItems(8), 4, 3, true, corruptingWorker(1)), 8)
+	for id, q := range got {
+		if !q.Accepted || q.Processed != fmt.Sprintf("processed-data-%d", id) {
+			t.Fatalf("item %d: %+v, want the honest majority accepted", id, q)
+		}
+		// Item n runs on workers n, n+1 and n+2 mod 4, so n%4 == 1 skips worker 1
+		if skipsCorrupt := id%4 == 1; skipsCorrupt {
+			if q.Disagreed || q.Replicas != nil {
+				t.Fatalf("item %d never reached worker 1 but was flagged: %+v", id, q)
+			}
+			continue
+		}
+		if !q.Disagreed || len(q.Replicas) != 3 {
+			t.Fatalf("item %d reached worker 1 but wasn't flagged with 3 replicas: %+v", id, q)
+		}
+		var workers []int
+		for _, r := range q.Replicas {
+			if r.OriginalID != id {
+				t.Fatalf("item %d carries a replica of item %d", id, r.OriginalID)
+			}
+			workers = append(workers, r.WorkerID)
+		}
+		sort.Ints(workers)
+		if workers[0] != 1 || workers[1] == workers[2] {
+			t.Fatalf("item %d replicas came from workers %v", id, workers)
+		}
+	}
+}
+
+// TestReplicatedNoMajority runs every item on 2 workers, one of them
+// corrupt, and checks the disagreeing items are flagged as not accepted
+// with both replicas attached
+func TestReplicatedNoMajority(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	got := collectQuorum(t, fanOutReplicated(generateWorkItems(6), 3, 2, true, corruptingWorker(2)), 6)
+	for id, q := range got {
+		// Item n runs on workers n and n+1 mod 3, so n%3 == 2 skips worker 2
+		if id%3 == 2 {
+			if !q.Accepted || q.Disagreed {
+				t.Fatalf("item %d on two honest workers: %+v", id, q)
+			}
+			continue
+		}
+		if q.Accepted || !q.Disagreed || len(q.Replicas) != 2 {
+			t.Fatalf("item %d split 1-1: %+v, want unaccepted and flagged with both replicas", id, q)
+		}
+	}
+}
+
+// TestReplicatedFirstWins checks first-result mode emits each item once,
+// with the output of whichever replica finished first
+func TestReplicatedFirstWins(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	// Worker 3 answers at once, the others only after 100ms
+	process := func(workerID int, item WorkItem) Result {
+		if workerID != 3 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return Result{Processed: fmt.Sprintf("%s-by-%d", item.Data, workerID)}
+	}
+	// Both items run on every worker, worker 3 included
+	start := time.Now()
+	out := fanOutReplicated(generateWorkItems(2), 3, 3, false, process)
+	first := requireReceives(t, out, 2, time.Second)
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Fatalf("first results took %v, waiting on the slow replicas", elapsed)
+	}
+	for _, q := range first {
+		if !q.Accepted || q.Processed != fmt.Sprintf("data-%d-by-3", q.OriginalID) {
+			t.Fatalf("item %d: %+v, want worker 3's output", q.OriginalID, q)
+		}
+	}
+	if first[0].OriginalID == first[1].OriginalID {
+		t.Fatalf("item %d emitted twice", first[0].OriginalID)
+	}
+	// The slow replicas are discarded, not emitted
+	for q := range out {
+		t.Fatalf("late replica emitted: %+v", q)
+	}
+}
+
+// TestReplicatedClampsK checks a k above the worker count runs each item
+// on every worker, and a k below 1 runs it once, instead of deadlocking
+func TestReplicatedClampsK(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	for id, q := range collectQuorum(t, fanOutReplicated(generateWorkItems(4), 2, 5, true, corruptingWorker(1)), 4) {
+		if q.Accepted || len(q.Replicas) != 2 {
+			t.Fatalf("k=5 on 2 workers, item %d: %+v, want both workers' replicas, split 1-1", id, q)
+		}
+	}
+	for _, k := range []int{0, -1} {
+		for id, q := range collectQuorum(t, fanOutReplicated(generateWorkItems(4), 3, k, true, corruptingWorker(0)), 4) {
+			if !q.Accepted || q.Disagreed {
+				t.Fatalf("k=%d, item %d: %+v, want one replica accepted", k, id, q)
+			}
+		}
+	}
+}
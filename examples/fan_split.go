@@ -0,0 +1,79 @@
+package examples
+
+import (
+	"sort"
+	"sync"
+)
+
+// unmatchedView is the key splitResults uses for results no filter matched
+const unmatchedView = "unmatched"
+
+// splitResults delivers each result from in to every view whose filter
+// matches it, which may be several, and results matching none to the
+// unmatchedView channel. Views are delivered to one after another, so every
+// returned channel must be read or the split stalls. All of them close once
+// in does. A filter named unmatchedView is replaced by the default view.
+func splitResults(in <-chan Result, filters map[string]func(Result) bool) map[string]<-chan Result {
+	names := make([]string, 0, len(filters))
+	outs := make(map[string]chan Result, len(filters)+1)
+	for name := range filters {
+		if name == unmatchedView {
+			continue
+		}
+		names = append(names, name)
+		outs[name] = make(chan Result)
+	}
+	// A fixed order, so each consumer sees results in arrival order
+	sort.Strings(names)
+	outs[unmatchedView] = make(chan Result)
+
+	go func() {
+		defer func() {
+			for _, ch := range outs {
+				close(ch)
+			}
+		}()
+		for result := range in {
+			heartbeat()
+			matched := false
+			for _, name := range names {
+				if filters[name](result) {
+					outs[name] <- result
+					matched = true
+				}
+			}
+			if !matched {
+				outs[unmatchedView] <- result
+			}
+		}
+	}()
+
+	views := make(map[string]<-chan Result, len(outs))
+	for name, ch := range outs {
+		views[name] = ch
+	}
+	return views
+}
+
+// collectViews drains every view concurrently and returns the OriginalIDs
+// each one received
+func collectViews(views map[string]<-chan Result) map[string][]int {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	got := make(map[string][]int, len(views))
+	for name, ch := range views {
+		wg.Add(1)
+		go func(name string, ch <-chan Result) {
+			defer wg.Done()
+			var ids []int
+			for r := range ch {
+				ids = append(ids, r.OriginalID)
+			}
+			mu.Lock()
+			got[name] = ids
+			mu.Unlock()
+		}(name, ch)
+	}
+	wg.Wait()
+	return got
+}
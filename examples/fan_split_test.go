@@ -0,0 +1,56 @@
+package examples
+
+import (
+	"testing"
+)
+
+// TestSplitResults feeds 20 results through three overlapping filters and
+// checks each result reached exactly the views it matches, that results
+// matching nothing reached the default view, and that every view closed
+func TestSplitResults(t *testing.T) {
+	in := make(chan Result)
+	go func() {
+		defer close(in)
+		for i := 0; i < 20; i++ {
+			in <- Result{OriginalID: i, WorkerID: i%4 + 1}
+		}
+	}()
+	filters := map[string]func(Result) bool{
+		"even":     func(r Result) bool { return r.OriginalID%2 == 0 },
+		"worker-1": func(r Result) bool { return r.WorkerID == 1 },
+		"small":    func(r Result) bool { return r.OriginalID < 5 },
+	}
+	views := splitResults(in, filters)
+	if len(views) != 4 {
+		t.Fatalf("%d views for 3 filters, want 4 with the default", len(views))
+	}
+	// collectViews only returns once every view has closed
+	got := collectViews(views)
+
+	for name, ids := range got {
+		count := make(map[int]int)
+		for _, id := range ids {
+			count[id]++
+		}
+		for i := 0; i < 20; i++ {
+			r := Result{OriginalID: i, WorkerID: i%4 + 1}
+			want := 0
+			if name == unmatchedView {
+				want = 1
+				for _, f := range filters {
+					if f(r) {
+						want = 0
+					}
+				}
+			} else if filters[name](r) {
+				want = 1
+			}
+			if count[i] != want {
+				t.Fatalf("view %s got result %d %d times, want %d", name, i, count[i], want)
+			}
+		}
+	}
+	if len(got[unmatchedView]) == 0 {
+		t.Fatalf("the default view caught nothing")
+	}
+}
@@ -0,0 +1,159 @@
+package examples
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// fanStreamThreshold is the item count above which RunFan summarizes
+// results as they stream past instead of collecting them
+const fanStreamThreshold = 10000
+
+// latencyBuckets is the size of a streamSummary's latency histogram. Bucket 0
+// counts latencies under 1µs, bucket i those under 2^i µs, and the last one
+// everything slower.
+const latencyBuckets = 24
+
+// streamSummary aggregates a stream of results in fixed space: counts, a
+// latency histogram, per-worker tallies and a reservoir sample of K results.
+// Its memory depends on K and the number of workers, never on the number of
+// results.
+type streamSummary struct {
+	Count      int
+	PerWorker  map[int]int
+	Histogram  [latencyBuckets]int
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// TotalLatency is the sum of all latencies, for the mean
+	TotalLatency time.Duration
+	// Sample holds up to K results chosen uniformly from the whole stream
+	Sample []Result
+	// Spilled counts results written to the spill file, if there was one
+	Spilled int
+}
+
+// summarizeStream consumes in until it closes, keeping a reservoir sample of
+// k results chosen with rng. If spill is non-nil every result is also written
+// to it as a JSON line, so the full stream can be inspected later.
+func summarizeStream(in <-chan Result, k int, rng *rand.Rand, spill io.Writer) (*streamSummary, error) {
+	s := &streamSummary{PerWorker: make(map[int]int), Sample: make([]Result, 0, k)}
+	var enc *json.Encoder
+	var buf *bufio.Writer
+	if spill != nil {
+		buf = bufio.NewWriter(spill)
+		enc = json.NewEncoder(buf)
+	}
+	var spillErr error
+	for r := range in {
+		s.Count++
+		s.PerWorker[r.WorkerID]++
+		s.Histogram[latencyBucket(r.Latency)]++
+		if s.Count == 1 || r.Latency < s.MinLatency {
+			s.MinLatency = r.Latency
+		}
+		if r.Latency > s.MaxLatency {
+			s.MaxLatency = r.Latency
+		}
+		s.TotalLatency += r.Latency
+
+		// Reservoir sampling: the n-th result replaces a kept one with
+		// probability k/n
+		if len(s.Sample) < k {
+			s.Sample = append(s.Sample, r)
+		} else if j := rng.Intn(s.Count); j < k {
+			s.Sample[j] = r
+		}
+
+		// Keep draining after a spill error so the producers can finish
+		if enc != nil && spillErr == nil {
+			if spillErr = enc.Encode(r); spillErr == nil {
+				s.Spilled++
+			}
+		}
+	}
+	if buf != nil && spillErr == nil {
+		spillErr = buf.Flush()
+	}
+	if spillErr != nil {
+		return s, fmt.Errorf("spilling results: %w", spillErr)
+	}
+	return s, nil
+}
+
+func latencyBucket(d time.Duration) int {
+	us := d.Microseconds()
+	b := 0
+	for us > 0 && b < latencyBuckets-1 {
+		us >>= 1
+		b++
+	}
+	return b
+}
+
+// runLargeFan fans count items out with no simulated work and summarizes the
+// results in a stream, spilling them to opts.FanSpill if set
+func runLargeFan(count, numWorkers int) {
+	fmt.Fprintf(output(), "Fanning out %d work items across %d workers, summarizing as results stream in...\n", count, numWorkers)
+	results, counters := fanOut(generateBulkWorkItems(count), numWorkers, false)
+	merged, summary := fanInSummary(results, counters)
+
+	var spill io.Writer
+	if opts.FanSpill != "" {
+		f, err := os.Create(opts.FanSpill)
+		if err != nil {
+			fail("fan spill: %v", err)
+		} else {
+			defer f.Close()
+			spill = f
+		}
+	}
+	s, err := summarizeStream(merged, 10, rand.New(rand.NewSource(opts.Seed)), spill)
+	if err != nil {
+		fail("fan stream: %v", err)
+	}
+	end := <-summary
+
+	fmt.Fprintf(output(), "\nFan-out/Fan-in completed! Processed %d items in %v.\n", s.Count, end.Elapsed.Round(time.Millisecond))
+	for id := 1; id <= numWorkers; id++ {
+		fmt.Fprintf(output(), "  Worker %d: %d processed\n", id, s.PerWorker[id])
+	}
+	if s.Count > 0 {
+		fmt.Fprintf(output(), "Latency: min %v, mean %v, max %v\n", s.MinLatency, s.TotalLatency/time.Duration(s.Count), s.MaxLatency)
+	}
+	for _, r := range s.Sample {
+		fmt.Fprintf(output(), "Sampled: Item %d -> %s (by Worker %d)\n", r.OriginalID, r.Processed, r.WorkerID)
+	}
+	if spill != nil {
+		fmt.Fprintf(output(), "Spilled %d results to %s\n", s.Spilled, opts.FanSpill)
+	}
+	record("stream_summary", s)
+	record("summary", end)
+	if err := s.matches(end); err != nil {
+		fail("fan stream: %v", err)
+	}
+}
+
+// matches compares the summary with the workers' own exact counters
+func (s *streamSummary) matches(exact FanSummary) error {
+	if s.Count != exact.Total {
+		return fmt.Errorf("summarized %d results, the workers counted %d", s.Count, exact.Total)
+	}
+	for id, n := range exact.Processed {
+		if s.PerWorker[id] != n {
+			return fmt.Errorf("summarized %d results from worker %d, it counted %d", s.PerWorker[id], id, n)
+		}
+	}
+	inHistogram := 0
+	for _, n := range s.Histogram {
+		inHistogram += n
+	}
+	if inHistogram != s.Count {
+		return fmt.Errorf("latency histogram holds %d results of %d", inHistogram, s.Count)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package examples
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// TestStreamSummary streams 200,000 results with no delays through the
+// summarizer and checks its aggregates against the workers' exact counters,
+// the reservoir size, the spill file, and that the heap doesn't grow with
+// the number of results
+func TestStreamSummary(t *testing.T) {
+	const count, k = 200000, 25
+	spill, err := os.CreateTemp("", "fan-spill-*.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(spill.Name())
+	defer spill.Close()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	results, counters := fanOut(generateBulkWorkItems(count), 4, false)
+	merged, summary := fanInSummary(results, counters)
+	s, err := summarizeStream(merged, k, rand.New(rand.NewSource(1)), spill)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exact := <-summary
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	// Keeping the results would take well over 20MB
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 2<<20 {
+		t.Fatalf("heap grew by %d bytes while summarizing %d results", grown, count)
+	}
+	runtime.KeepAlive(s)
+
+	if err := s.matches(exact); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count != count {
+		t.Fatalf("summarized %d of %d results", s.Count, count)
+	}
+	if len(s.Sample) != k {
+		t.Fatalf("reservoir holds %d results, want %d", len(s.Sample), k)
+	}
+	seen := make(map[int]bool, k)
+	for _, r := range s.Sample {
+		if seen[r.OriginalID] || r.OriginalID < 0 || r.OriginalID >= count {
+			t.Fatalf("reservoir holds item %d twice or out of range", r.OriginalID)
+		}
+		seen[r.OriginalID] = true
+	}
+
+	if _, err := spill.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	scanner := bufio.NewScanner(spill)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != count || s.Spilled != count {
+		t.Fatalf("spill file has %d lines and %d spilled, want %d", lines, s.Spilled, count)
+	}
+}
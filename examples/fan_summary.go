@@ -0,0 +1,79 @@
+package examples
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fanCounters are kept by fan-out workers as they go, indexed by worker ID - 1
+type fanCounters struct {
+	start     time.Time
+	processed []int64
+	errors    []int64
+}
+
+func newFanCounters(numWorkers int) *fanCounters {
+	return &fanCounters{
+		start:     time.Now(),
+		processed: make([]int64, numWorkers),
+		errors:    make([]int64, numWorkers),
+	}
+}
+
+// done counts an item worker workerID finished, failed if err is non-nil
+func (c *fanCounters) done(workerID int, err error) {
+	if err != nil {
+		atomic.AddInt64(&c.errors[workerID-1], 1)
+		return
+	}
+	atomic.AddInt64(&c.processed[workerID-1], 1)
+}
+
+// FanSummary describes a finished fan-out: what each worker processed and how
+// long the whole run took
+type FanSummary struct {
+	// Processed and Errors are indexed by worker ID
+	Processed map[int]int
+	Errors    map[int]int
+	Total     int
+	Elapsed   time.Duration
+}
+
+// fanInSummary merges the inputs like fanIn and, once every input has closed
+// and the merged channel is closed behind the last result, sends a single
+// FanSummary built from the workers' counters on the second channel
+func fanInSummary(inputs []<-chan Result, counters *fanCounters) (<-chan Result, <-chan FanSummary) {
+	summary := make(chan FanSummary, 1)
+	merged := make(chan Result)
+	var wg sync.WaitGroup
+	wg.Add(len(inputs))
+	for _, input := range inputs {
+		go func(c <-chan Result) {
+			defer wg.Done()
+			for result := range c {
+				traceHop(result.TraceID, "fan-in")
+				merged <- result
+			}
+		}(input)
+	}
+
+	go func() {
+		wg.Wait()
+		close(merged)
+		s := FanSummary{
+			Processed: make(map[int]int, len(counters.processed)),
+			Errors:    make(map[int]int, len(counters.errors)),
+			Elapsed:   time.Since(counters.start),
+		}
+		for i := range counters.processed {
+			s.Processed[i+1] = int(atomic.LoadInt64(&counters.processed[i]))
+			s.Errors[i+1] = int(atomic.LoadInt64(&counters.errors[i]))
+			s.Total += s.Processed[i+1]
+		}
+		summary <- s
+		close(summary)
+	}()
+
+	return merged, summary
+}
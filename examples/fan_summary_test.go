@@ -0,0 +1,54 @@
+package examples
+
+import (
+	"testing"
+)
+
+// TestFanInSummary reads results and the summary in a single select and
+// checks that nothing follows the summary and that it counts every result
+func TestFanInSummary(t *testing.T) {
+	const numWorkers, perWorker = 3, 50
+	counters := newFanCounters(numWorkers)
+	var inputs []<-chan Result
+	for w := 1; w <= numWorkers; w++ {
+		ch := make(chan Result)
+		go func(w int) {
+			defer close(ch)
+			for i := 0; i < perWorker; i++ {
+				ch <- Result{OriginalID: i, WorkerID: w}
+				counters.done(w, nil)
+			}
+		}(w)
+		inputs = append(inputs, ch)
+	}
+
+	results, summary := fanInSummary(inputs, counters)
+	delivered := make(map[int]int)
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				// Stop selecting on the closed channel
+				results = nil
+				continue
+			}
+			delivered[r.WorkerID]++
+		case s := <-summary:
+			if results != nil {
+				// The merged channel is closed before the summary is sent
+				if r, ok := <-results; ok {
+					t.Fatalf("worker %d's item %d arrived after the summary", r.WorkerID, r.OriginalID)
+				}
+			}
+			for w := 1; w <= numWorkers; w++ {
+				if s.Processed[w] != delivered[w] {
+					t.Fatalf("summary counts %d items from worker %d, %d were delivered", s.Processed[w], w, delivered[w])
+				}
+			}
+			if s.Total != numWorkers*perWorker {
+				t.Fatalf("summary total %d, want %d", s.Total, numWorkers*perWorker)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package examples
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// goldenClass says how a recorded result is compared against its golden file
+type goldenClass int
+
+const (
+	// goldenOrdered results come out the same, in the same order, on every
+	// deterministic run
+	goldenOrdered goldenClass = iota
+	// goldenUnordered results hold the same elements on every run, but
+	// goroutine scheduling decides their order, so lists are sorted first
+	goldenUnordered
+)
+
+// goldenResults classifies the results each example records that a seeded
+// run reproduces. A path is a result name, or name.field for one field of a
+// recorded object. Results left out depend on timing (durations, rates,
+// which worker ran what, how far a run got before a deadline) and aren't
+// compared; an example with no entry has no golden file and is listed in
+// goldenExcluded.
+var goldenResults = map[string]map[string]goldenClass{
+	"pipeline": {
+		"results":                        goldenOrdered,
+		"histogram":                      goldenOrdered,
+		"even_square_sum":                goldenOrdered,
+		"windows":                        goldenOrdered,
+		"backpressure.sink_interval_ms":  goldenOrdered,
+		"backpressure.buffered_capacity": goldenOrdered,
+		"credit_window.window":           goldenOrdered,
+		"cancelable_generator":           goldenOrdered,
+		"cancelled_pipeline":             goldenOrdered,
+	},
+	"fan": {
+		"summary.Total":              goldenOrdered,
+		"summary.Errors":             goldenOrdered,
+		"ordered.ids":                goldenOrdered,
+		"degraded.partial_processed": goldenOrdered,
+		"degraded.total_processed":   goldenOrdered,
+		"deduplicated":               goldenOrdered,
+		"for_each.error":             goldenOrdered,
+		"map_slice":                  goldenOrdered,
+		"split_views.even":           goldenOrdered,
+		"priority":                   goldenUnordered,
+	},
+	"pools": {
+		"cost_balanced":              goldenOrdered,
+		"plan.predicted_makespan_ms": goldenOrdered,
+		"rate_limited.jobs":          goldenOrdered,
+		"live_throughput_jobs":       goldenOrdered,
+		"ordered_pool.emitted":       goldenOrdered,
+		"timed_out_jobs":             goldenOrdered,
+		"worker_cleanup":             goldenOrdered,
+		"supervised":                 goldenOrdered,
+		"admission.jobs":             goldenOrdered,
+	},
+	"producer-consumer": {
+		"producers":                 goldenOrdered,
+		"checkpointed.crashed":      goldenOrdered,
+		"checkpointed.started_from": goldenOrdered,
+		"adaptive_buffer.delivered": goldenOrdered,
+	},
+	"supervisor": {
+		"restarts":           goldenOrdered,
+		"panics":             goldenOrdered,
+		"flapping_configs":   goldenOrdered,
+		"group_history":      goldenOrdered,
+		"dependency_history": goldenOrdered,
+	},
+	"pubsub": {
+		"received_per_subscriber":     goldenOrdered,
+		"broadcaster":                 goldenOrdered,
+		"stalled_subscriber_buffer":   goldenOrdered,
+		"stress.subscribers_at_close": goldenOrdered,
+		"ordered":                     goldenOrdered,
+		"typed_topics_settled":        goldenOrdered,
+	},
+	"timeout-cancellation": {
+		"context_timeout":    goldenOrdered,
+		"channel_timeout":    goldenOrdered,
+		"cancellation":       goldenOrdered,
+		"watchdog_exit_code": goldenOrdered,
+	},
+	"rate-limiting": {
+		"token_bucket": goldenOrdered,
+	},
+	"mapreduce": {
+		"word_counts":       goldenOrdered,
+		"tree_reduce.total": goldenOrdered,
+	},
+	"singleflight": {
+		"barrier":        goldenOrdered,
+		"stampede.keys":  goldenOrdered,
+		"stampede.limit": goldenOrdered,
+	},
+	"resource-pooling": {
+		"db_pool":                    goldenOrdered,
+		"db_pool_retries.GaveUp":     goldenOrdered,
+		"degraded_pool":              goldenOrdered,
+		"warmup.created":             goldenOrdered,
+		"partitioned_pool.GlobalMax": goldenOrdered,
+	},
+	"composed": {
+		"full.Generated":            goldenOrdered,
+		"full.Parsed":               goldenOrdered,
+		"full.Enriched":             goldenOrdered,
+		"full.Processed":            goldenOrdered,
+		"full.Published":            goldenOrdered,
+		"full.Broadcaster":          goldenOrdered,
+		"full.Received":             goldenOrdered,
+		"full.PerSensor":            goldenOrdered,
+		"full.Shutdown.Drained":     goldenOrdered,
+		"full.Shutdown.DeadlineHit": goldenOrdered,
+	},
+}
+
+// goldenExcluded says why an example has no golden file
+var goldenExcluded = map[string]string{
+	"event-loop": "its producers tick on timers over a fixed 5s run and resend at random, " +
+		"so how many events arrive, and of which kinds, differs from run to run",
+}
+
+// goldenTimestamp replaces wall-clock timestamps in a golden file
+const goldenTimestamp = "<timestamp>"
+
+// goldenMode reports whether the run writes or checks golden files
+func goldenMode() bool {
+	return opts.Golden != "" || opts.CheckGolden != ""
+}
+
+// exampleRand returns the source of an example's random input: seeded from
+// --seed in the golden modes, so reruns generate the same input, and from
+// the clock otherwise
+func exampleRand() *rand.Rand {
+	if goldenMode() {
+		return rand.New(rand.NewSource(opts.Seed))
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// goldenPath is where the golden file of the example key lives in dir
+func goldenPath(dir, key string) string {
+	return filepath.Join(dir, key+".json")
+}
+
+// normalizeGolden renders the classified results as indented JSON with
+// sorted keys, unordered lists sorted and timestamps replaced, so two runs
+// that agree on everything deterministic render identically
+func normalizeGolden(results map[string]interface{}, classes map[string]goldenClass) ([]byte, error) {
+	raw, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{})
+	for path, class := range classes {
+		v, ok := goldenLookup(generic, path)
+		if !ok {
+			continue
+		}
+		v = scrubTimestamps(v)
+		if class == goldenUnordered {
+			v = sortElements(v)
+		}
+		goldenSet(out, path, v)
+	}
+	var doc bytes.Buffer
+	enc := json.NewEncoder(&doc)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	return doc.Bytes(), nil
+}
+
+func goldenLookup(doc map[string]interface{}, path string) (interface{}, bool) {
+	name, field, nested := strings.Cut(path, ".")
+	v, ok := doc[name]
+	if !ok || !nested {
+		return v, ok
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return goldenLookup(obj, field)
+}
+
+func goldenSet(doc map[string]interface{}, path string, v interface{}) {
+	name, field, nested := strings.Cut(path, ".")
+	if !nested {
+		doc[name] = v
+		return
+	}
+	obj, ok := doc[name].(map[string]interface{})
+	if !ok {
+		obj = make(map[string]interface{})
+		doc[name] = obj
+	}
+	goldenSet(obj, field, v)
+}
+
+// scrubTimestamps replaces every RFC 3339 timestamp string in v
+func scrubTimestamps(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return goldenTimestamp
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = scrubTimestamps(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = scrubTimestamps(v[k])
+		}
+	}
+	return v
+}
+
+// sortElements sorts a list by the JSON of its elements; anything else is
+// returned as it is
+func sortElements(v interface{}) interface{} {
+	list, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	keys := make([]string, len(list))
+	for i, e := range list {
+		b, _ := json.Marshal(e)
+		keys[i] = string(b)
+	}
+	sort.Sort(byGoldenKey{list, keys})
+	return list
+}
+
+type byGoldenKey struct {
+	list []interface{}
+	keys []string
+}
+
+func (s byGoldenKey) Len() int           { return len(s.list) }
+func (s byGoldenKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byGoldenKey) Swap(i, j int) {
+	s.list[i], s.list[j] = s.list[j], s.list[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// writeGolden stores the normalized results of the example key in dir
+func writeGolden(dir, key string, results map[string]interface{}) error {
+	doc, err := normalizeGolden(results, goldenResults[key])
+	if err != nil {
+		return fmt.Errorf("golden %s: %w", key, err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("golden %s: %w", key, err)
+	}
+	return os.WriteFile(goldenPath(dir, key), doc, 0o644)
+}
+
+// errGoldenMismatch is returned when a run's results differ from its golden
+// file
+var errGoldenMismatch = errors.New("results differ from the golden file")
+
+// compareGolden diffs the normalized results of the example key against its
+// golden file in dir, returning a unified diff with errGoldenMismatch when
+// they differ
+func compareGolden(dir, key string, results map[string]interface{}) (string, error) {
+	want, err := os.ReadFile(goldenPath(dir, key))
+	if err != nil {
+		return "", fmt.Errorf("golden %s: %w", key, err)
+	}
+	got, err := normalizeGolden(results, goldenResults[key])
+	if err != nil {
+		return "", fmt.Errorf("golden %s: %w", key, err)
+	}
+	if bytes.Equal(want, got) {
+		return "", nil
+	}
+	diff := unifiedDiff(goldenPath(dir, key), "this run", splitLines(string(want)), splitLines(string(got)))
+	return diff, fmt.Errorf("golden %s: %w", key, errGoldenMismatch)
+}
+
+// goldenEnd writes or checks the golden file of the example key that just
+// finished, as --golden or --check-golden asked
+func goldenEnd(key string) {
+	if !goldenMode() {
+		return
+	}
+	if _, ok := goldenResults[key]; !ok {
+		if why, ok := goldenExcluded[key]; ok {
+			fmt.Fprintf(output(), "%s has no golden file: %s\n", key, why)
+			return
+		}
+		fmt.Fprintf(output(), "No golden results are classified for %s; skipped\n", key)
+		return
+	}
+	results := patternResults(key)
+	if opts.Golden != "" {
+		if err := writeGolden(opts.Golden, key, results); err != nil {
+			fail("%v", err)
+			return
+		}
+		fmt.Fprintf(output(), "Wrote golden file %s\n", goldenPath(opts.Golden, key))
+	}
+	if opts.CheckGolden != "" {
+		diff, err := compareGolden(opts.CheckGolden, key, results)
+		if err != nil {
+			fmt.Fprint(output(), diff)
+			fail("%v", err)
+			return
+		}
+		fmt.Fprintf(output(), "Matched golden file %s\n", goldenPath(opts.CheckGolden, key))
+	}
+}
+
+func splitLines(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// goldenDiffContext is how many unchanged lines a diff hunk shows around a
+// change
+const goldenDiffContext = 3
+
+// unifiedDiff returns a unified diff turning a into b, from a longest common
+// subsequence of their lines
+func unifiedDiff(nameA, nameB string, a, b []string) string {
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the table into edit lines: ' ' kept, '-' only in a, '+' only in b
+	type edit struct {
+		op   byte
+		text string
+		// ai and bi are the line's index in a and b, or where it would go
+		ai, bi int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i, j})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+	for start := 0; start < len(edits); {
+		// Find the next change and the hunk around it
+		for start < len(edits) && edits[start].op == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		from := start - goldenDiffContext
+		if from < 0 {
+			from = 0
+		}
+		end, kept := start, 0
+		for end < len(edits) && kept <= 2*goldenDiffContext {
+			if edits[end].op == ' ' {
+				kept++
+			} else {
+				kept = 0
+			}
+			end++
+		}
+		// Trim the trailing context back to goldenDiffContext lines
+		if kept > goldenDiffContext {
+			end -= kept - goldenDiffContext
+		}
+
+		countA, countB := 0, 0
+		for _, e := range edits[from:end] {
+			if e.op != '+' {
+				countA++
+			}
+			if e.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", edits[from].ai+1, countA, edits[from].bi+1, countB)
+		for _, e := range edits[from:end] {
+			fmt.Fprintf(&sb, "%c%s\n", e.op, e.text)
+		}
+		start = end
+	}
+	return sb.String()
+}
@@ -0,0 +1,134 @@
+package examples
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// goldenSample computes the golden results of the pipeline and mapreduce
+// examples without their simulated work: the first example's generated
+// numbers through square and add ten, and the word count of lines
+func goldenSample(seed int64, lines []string) (map[string]interface{}, map[string]interface{}, error) {
+	rng := rand.New(rand.NewSource(seed))
+	var outputs []int
+	for i := 0; i < 10; i++ {
+		n := rng.Intn(10) + 1
+		outputs = append(outputs, n*n+10)
+	}
+	freqs, err := WordCount(lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	counts := make(map[string]int, len(freqs))
+	for _, wf := range freqs {
+		counts[wf.Word] = wf.Count
+	}
+	pipeline := map[string]interface{}{
+		"results": outputs,
+		"windows": []WindowResult{{Start: time.Now(), End: time.Now(), Count: 2}},
+		// Timing-dependent, so never compared
+		"adaptive": map[string]int64{"adaptive_ms": time.Now().UnixNano() % 1000},
+	}
+	mapreduce := map[string]interface{}{
+		"word_counts": counts,
+		"tree_reduce": map[string]interface{}{"total": len(lines), "tree_us": time.Now().UnixNano() % 1000},
+	}
+	return pipeline, mapreduce, nil
+}
+
+// TestGoldenFiles writes golden files for the pipeline and mapreduce
+// results, checks a rerun with the same inputs matches despite different
+// timings and timestamps, then changes the seed and an input line and checks
+// both mismatches are caught with a diff naming the changed values
+func TestGoldenFiles(t *testing.T) {
+	dir, err := os.MkdirTemp("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lines := []string{"hello world", "hello go", "go concurrency"}
+	pipeline, mapreduce, err := goldenSample(1, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeGolden(dir, "pipeline", pipeline); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeGolden(dir, "mapreduce", mapreduce); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := os.ReadFile(goldenPath(dir, "pipeline"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(doc), "adaptive") || !strings.Contains(string(doc), goldenTimestamp) {
+		t.Fatalf("golden file keeps timings or timestamps:\n%s", doc)
+	}
+
+	time.Sleep(time.Millisecond)
+	pipeline, mapreduce, err = goldenSample(1, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, results := range map[string]map[string]interface{}{"pipeline": pipeline, "mapreduce": mapreduce} {
+		if diff, err := compareGolden(dir, key, results); err != nil {
+			t.Fatalf("identical rerun of %s: %v\n%s", key, err, diff)
+		}
+	}
+
+	pipeline, mapreduce, err = goldenSample(2, append(lines, "hello again"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := compareGolden(dir, "pipeline", pipeline)
+	if !errors.Is(err, errGoldenMismatch) || !strings.Contains(diff, "@@ ") {
+		t.Fatalf("different seed: error %v, diff %q", err, diff)
+	}
+	diff, err = compareGolden(dir, "mapreduce", mapreduce)
+	if !errors.Is(err, errGoldenMismatch) {
+		t.Fatalf("extra input line: error %v", err)
+	}
+	for _, change := range []string{`-    "hello": 2,`, `+    "hello": 3,`, `+    "again": 1,`, `-    "total": 3`, `+    "total": 4`} {
+		if !strings.Contains(diff, change+"\n") {
+			t.Fatalf("extra input line: diff lacks %q:\n%s", change, diff)
+		}
+	}
+}
+
+// TestGoldenComposedPaths checks the composed example's classification
+// reaches two levels into its recorded stats, keeping the drain order while
+// leaving out the timings beside it, and that no example is both classified
+// and excluded
+func TestGoldenComposedPaths(t *testing.T) {
+	stats := composedStats{
+		Generated: 40,
+		Published: 40,
+		Shutdown:  shutdownReport{Drained: []string{"generator", "parse"}, PhaseOne: time.Millisecond},
+		Elapsed:   time.Second,
+	}
+	doc, err := normalizeGolden(map[string]interface{}{"full": stats, "shutdown": stats}, goldenResults["composed"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Drained": [`, `"parse"`, `"DeadlineHit": false`, `"Generated": 40`} {
+		if !strings.Contains(string(doc), want) {
+			t.Fatalf("composed golden file lacks %s:\n%s", want, doc)
+		}
+	}
+	for _, unwanted := range []string{"PhaseOne", "Elapsed", `"shutdown"`} {
+		if strings.Contains(string(doc), unwanted) {
+			t.Fatalf("composed golden file keeps %s:\n%s", unwanted, doc)
+		}
+	}
+
+	for key := range goldenExcluded {
+		if _, ok := goldenResults[key]; ok {
+			t.Fatalf("%s is both classified and excluded", key)
+		}
+	}
+}
@@ -1,17 +1,20 @@
 package examples
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
 // RunMapReduce demonstrates the MapReduce pattern.
-func RunMapReduce() {
-	fmt.Println("=== MapReduce Pattern Example ===")
+func RunMapReduce(ctx context.Context) {
+	fmt.Fprintln(output(), "=== MapReduce Pattern Example ===")
 
+	phase("map")
 	// Sample data: words to count
 	data := []string{
 		"hello world",
@@ -24,41 +27,198 @@ func RunMapReduce() {
 		"patterns in go",
 	}
 
-	fmt.Printf("Input data: %v\n", data)
+	fmt.Fprintf(output(), "Input data: %v\n", data)
 
+	freqs, err := WordCount(data)
+	if err != nil {
+		fail("mapreduce word count: %v", err)
+	}
+
+	// Display results
+	fmt.Fprintln(output(), "\nWord count results (most frequent first):")
+	result := make(map[string]int, len(freqs))
+	for _, wf := range freqs {
+		fmt.Fprintf(output(), "  %s: %d\n", wf.Word, wf.Count)
+		result[wf.Word] = wf.Count
+	}
+	record("word_counts", result)
+	verify("mapreduce", func() error {
+		want := make(map[string]int)
+		for _, line := range data {
+			for _, word := range strings.Fields(line) {
+				want[word]++
+			}
+		}
+		if len(result) != len(want) {
+			return fmt.Errorf("%d distinct words, sequential count has %d", len(result), len(want))
+		}
+		for word, n := range want {
+			if result[word] != n {
+				return fmt.Errorf("%q counted %d times, sequential count says %d", word, result[word], n)
+			}
+		}
+		return nil
+	})
+
+	fmt.Fprintln(output(), "\nTop 3 words:")
+	for i, wf := range TopK(result, 3) {
+		fmt.Fprintf(output(), "  %d. %s (%d)\n", i+1, wf.Word, wf.Count)
+	}
+
+	phase("tree")
+	// Tree reduction: one global total over a large input
+	fmt.Fprintln(output(), "\nGlobal word count over 2,000,000 synthetic lines:")
+	lineCounts := make([]int, 2000000)
+	rng := rand.New(rand.NewSource(1))
+	for i := range lineCounts {
+		lineCounts[i] = rng.Intn(12) + 1
+	}
+	sum := func(a, b int) int { return a + b }
+	start := time.Now()
+	sequential := foldSequential(lineCounts, sum)
+	sequentialTook := time.Since(start)
+	start = time.Now()
+	tree := reduceTree(lineCounts, sum, 4)
+	treeTook := time.Since(start)
+	fmt.Fprintf(output(), "Sequential fold: %d words in %v\n", sequential, sequentialTook.Round(time.Microsecond))
+	fmt.Fprintf(output(), "Reduce tree (parallelism 4): %d words in %v\n", tree, treeTook.Round(time.Microsecond))
+	record("tree_reduce", map[string]interface{}{
+		"total":         tree,
+		"sequential_us": sequentialTook.Microseconds(),
+		"tree_us":       treeTook.Microseconds(),
+	})
+	if tree != sequential {
+		fail("mapreduce reduce tree: total %d, sequential fold %d", tree, sequential)
+	}
+
+	phase("deadline")
+	// Timeout: the same job under a deadline too short to finish
+	fmt.Fprintln(output(), "\nMapReduce with a 20ms timeout:")
+	if _, err := MapReduceTimeout(data, 20*time.Millisecond); err != nil {
+		fmt.Fprintf(output(), "MapReduce stopped (%s): %v\n", errorKind(err), err)
+		record("timeout_error", err.Error())
+	}
+
+	fmt.Fprintln(output(), "\nMapReduce example completed!")
+}
+
+// WordFreq is how many times a word occurs in a WordCount input
+type WordFreq struct {
+	Word  string
+	Count int
+}
+
+// WordCount runs the word count job to completion and returns the counts
+// sorted by count, highest first, with ties in alphabetical order. Words are
+// lowercased. The in-memory job can't fail yet, so the error is always nil.
+func WordCount(data []string) ([]WordFreq, error) {
 	// Map phase: split words and emit (word, 1) pairs
-	mapped := mapPhase(data)
+	mapped := mapPhase(data, nil, mapReduceDelays{})
 
 	// Shuffle phase: group by key
-	grouped := shufflePhase(mapped)
+	grouped := shufflePhase(mapped, nil)
 
 	// Reduce phase: count occurrences
-	result := reducePhase(grouped)
+	return sortWordFreqs(reducePhase(grouped, nil, mapReduceDelays{})), nil
+}
 
-	// Display results
-	fmt.Println("\nWord count results:")
-	for word, count := range result {
-		fmt.Printf("  %s: %d\n", word, count)
+// sortWordFreqs orders word counts by count descending, then word ascending
+func sortWordFreqs(counts map[string]int) []WordFreq {
+	freqs := make([]WordFreq, 0, len(counts))
+	for word, count := range counts {
+		freqs = append(freqs, WordFreq{Word: word, Count: count})
+	}
+	sort.Slice(freqs, func(i, j int) bool {
+		if freqs[i].Count != freqs[j].Count {
+			return freqs[i].Count > freqs[j].Count
+		}
+		return freqs[i].Word < freqs[j].Word
+	})
+	return freqs
+}
+
+// MapReduceTimeout runs the word count job and gives up if it doesn't finish
+// within timeout. The error is an *ErrStageTimeout naming the phase that was
+// running and matches both ErrTimeout and context.DeadlineExceeded. Every phase
+// watches the deadline, so no goroutines are left running once it returns.
+func MapReduceTimeout(data []string, timeout time.Duration) (map[string]int, error) {
+	return mapReduceTimeout(data, timeout, mapReduceDelays{})
+}
+
+// mapReduceTimeout is MapReduceTimeout with the simulated work of each
+// phase given by delays
+func mapReduceTimeout(data []string, timeout time.Duration, delays mapReduceDelays) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	mapped := mapPhase(data, ctx.Done(), delays)
+	grouped := shufflePhase(mapped, ctx.Done())
+	if err := ctx.Err(); err != nil {
+		return nil, &ErrStageTimeout{Stage: "map", Err: err}
 	}
 
-	fmt.Println("\nMapReduce example completed!")
+	result := reducePhase(grouped, ctx.Done(), delays)
+	if err := ctx.Err(); err != nil {
+		return nil, &ErrStageTimeout{Stage: "reduce", Err: err}
+	}
+	return result, nil
+}
+
+// sleepOrDone sleeps for d and reports false if done closed first
+func sleepOrDone(d time.Duration, done <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// mapReduceDelays is the simulated processing time of each word in the map
+// phase and each key in the reduce phase. A nil func picks a random delay,
+// up to 50ms for map and 100ms for reduce.
+type mapReduceDelays struct {
+	Map, Reduce func(word string) time.Duration
 }
 
-// MapPhase splits text into words and emits (word, 1) pairs
-func mapPhase(data []string) <-chan KeyValue {
+func (d mapReduceDelays) mapDelay(word string) time.Duration {
+	if d.Map == nil {
+		return time.Duration(rand.Intn(50)) * time.Millisecond
+	}
+	return d.Map(word)
+}
+
+func (d mapReduceDelays) reduceDelay(word string) time.Duration {
+	if d.Reduce == nil {
+		return time.Duration(rand.Intn(100)) * time.Millisecond
+	}
+	return d.Reduce(word)
+}
+
+// MapPhase splits text into words and emits (word, 1) pairs until done is closed
+func mapPhase(data []string, done <-chan struct{}, delays mapReduceDelays) <-chan KeyValue {
 	out := make(chan KeyValue, len(data)*10) // Buffer for multiple words per line
 
 	var wg sync.WaitGroup
 	for _, line := range data {
+		heartbeat()
 		wg.Add(1)
 		go func(text string) {
 			defer wg.Done()
 			words := strings.Fields(strings.ToLower(text))
 			for _, word := range words {
 				// Simulate some processing time
-				time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond)
-				out <- KeyValue{Key: word, Value: 1}
-				fmt.Printf("Map: emitted (%s, 1)\n", word)
+				if !sleepOrDone(delays.mapDelay(word), done) {
+					return
+				}
+				select {
+				case out <- KeyValue{Key: word, Value: 1}:
+				case <-done:
+					return
+				}
+				fmt.Fprintf(output(), "Map: emitted (%s, 1)\n", word)
 			}
 		}(line)
 	}
@@ -71,31 +231,48 @@ func mapPhase(data []string) <-chan KeyValue {
 	return out
 }
 
-// ShufflePhase groups key-value pairs by key
-func shufflePhase(mapped <-chan KeyValue) map[string][]int {
-	grouped := make(map[string][]int)
-	var mu sync.Mutex
+// ShufflePhase groups key-value pairs by key. If done is closed before mapped
+// is, it stops reading and returns what it has grouped so far.
+func shufflePhase(mapped <-chan KeyValue, done <-chan struct{}) map[string][]int {
+	var grouped SyncMap[string, []int]
 
 	var wg sync.WaitGroup
-	for kv := range mapped {
+read:
+	for {
+		var kv KeyValue
+		select {
+		case next, ok := <-mapped:
+			if !ok {
+				break read
+			}
+			kv = next
+		case <-done:
+			break read
+		}
+		heartbeat()
 		wg.Add(1)
 		go func(kv KeyValue) {
 			defer wg.Done()
-			mu.Lock()
-			grouped[kv.Key] = append(grouped[kv.Key], kv.Value)
-			mu.Unlock()
-			fmt.Printf("Shuffle: grouped %s -> %v\n", kv.Key, grouped[kv.Key])
+			// Copy on append, so the slice printed below is never written again
+			values := grouped.Update(kv.Key, func(old []int, _ bool) []int {
+				return append(old[:len(old):len(old)], kv.Value)
+			})
+			fmt.Fprintf(output(), "Shuffle: grouped %s -> %v\n", kv.Key, values)
 		}(kv)
 	}
 
 	wg.Wait()
-	return grouped
+	out := make(map[string][]int, grouped.Len())
+	grouped.Range(func(key string, values []int) bool {
+		out[key] = values
+		return true
+	})
+	return out
 }
 
-// ReducePhase counts occurrences of each word
-func reducePhase(grouped map[string][]int) map[string]int {
-	result := make(map[string]int)
-	var mu sync.Mutex
+// ReducePhase counts occurrences of each word, abandoning work once done is closed
+func reducePhase(grouped map[string][]int, done <-chan struct{}, delays mapReduceDelays) map[string]int {
+	var result SyncMap[string, int]
 
 	var wg sync.WaitGroup
 	for word, counts := range grouped {
@@ -103,22 +280,27 @@ func reducePhase(grouped map[string][]int) map[string]int {
 		go func(word string, counts []int) {
 			defer wg.Done()
 			// Simulate some processing time
-			time.Sleep(time.Duration(rand.Intn(100)) * time.Millisecond)
+			if !sleepOrDone(delays.reduceDelay(word), done) {
+				return
+			}
 
 			total := 0
 			for _, count := range counts {
 				total += count
 			}
 
-			mu.Lock()
-			result[word] = total
-			mu.Unlock()
-			fmt.Printf("Reduce: %s -> %d\n", word, total)
+			result.Update(word, func(int, bool) int { return total })
+			fmt.Fprintf(output(), "Reduce: %s -> %d\n", word, total)
 		}(word, counts)
 	}
 
 	wg.Wait()
-	return result
+	out := make(map[string]int, result.Len())
+	result.Range(func(word string, total int) bool {
+		out[word] = total
+		return true
+	})
+	return out
 }
 
 // KeyValue represents a key-value pair
@@ -0,0 +1,76 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestWordCount runs WordCount on a small input with ties at two counts and
+// compares the exact result
+func TestWordCount(t *testing.T) {
+	got, err := WordCount([]string{"Go is fun", "go go fun", "is it"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WordFreq{{"go", 3}, {"fun", 2}, {"is", 2}, {"it", 1}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// TestMapReduceTimeout runs a job whose reducer is far too slow for its
+// deadline and checks it returns promptly with an error naming the reduce
+// stage and matching both timeouts, and that every mapper, shuffler and
+// reducer goroutine exits
+func TestMapReduceTimeout(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+	goroutines := runtime.NumGoroutine()
+
+	data := []string{"the quick brown fox", "jumps over the lazy dog"}
+	delays := mapReduceDelays{
+		Map:    func(string) time.Duration { return 0 },
+		Reduce: func(string) time.Duration { return time.Hour },
+	}
+	start := time.Now()
+	result, err := mapReduceTimeout(data, 50*time.Millisecond, delays)
+	if took := time.Since(start); took > 300*time.Millisecond {
+		t.Fatalf("returned %v after a 50ms deadline", took)
+	}
+	var stage *ErrStageTimeout
+	if result != nil || !errors.As(err, &stage) || !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, %v; want an *ErrStageTimeout matching ErrTimeout and context.DeadlineExceeded", result, err)
+	}
+	if stage.Stage != "reduce" {
+		t.Fatalf("timed out in stage %q, want reduce", stage.Stage)
+	}
+	goroutineCountStable(t, goroutines)
+}
+
+// TestShufflePhaseStopsOnDone checks the shuffle returns what it has grouped
+// once done closes, even though its input never does
+func TestShufflePhaseStopsOnDone(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	mapped := make(chan KeyValue)
+	done := make(chan struct{})
+	grouped := make(chan map[string][]int, 1)
+	go func() { grouped <- shufflePhase(mapped, done) }()
+	mapped <- KeyValue{Key: "go", Value: 1}
+	mapped <- KeyValue{Key: "go", Value: 1}
+	close(done)
+	got := requireReceives(t, grouped, 1, time.Second)[0]
+	if len(got) != 1 || len(got["go"]) != 2 {
+		t.Fatalf("grouped %v before done, want go -> [1 1]", got)
+	}
+}
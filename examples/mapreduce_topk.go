@@ -0,0 +1,51 @@
+package examples
+
+import "container/heap"
+
+// rankedBefore reports whether a ranks ahead of b: higher count first, then
+// alphabetical, the same order sortWordFreqs uses
+func rankedBefore(a, b WordFreq) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return a.Word < b.Word
+}
+
+// wordFreqHeap is a min-heap with the lowest-ranked word at the root, so
+// TopK can drop it as soon as something better arrives
+type wordFreqHeap []WordFreq
+
+func (h wordFreqHeap) Len() int            { return len(h) }
+func (h wordFreqHeap) Less(i, j int) bool  { return rankedBefore(h[j], h[i]) }
+func (h wordFreqHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *wordFreqHeap) Push(x interface{}) { *h = append(*h, x.(WordFreq)) }
+func (h *wordFreqHeap) Pop() interface{} {
+	old := *h
+	wf := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return wf
+}
+
+// TopK returns the k highest-count words, highest first, with ties broken
+// alphabetically. It keeps a heap of at most k words, so it costs O(n log k)
+// instead of sorting the whole map.
+func TopK(counts map[string]int, k int) []WordFreq {
+	if k <= 0 {
+		return nil
+	}
+	h := make(wordFreqHeap, 0, k)
+	for word, count := range counts {
+		wf := WordFreq{Word: word, Count: count}
+		if h.Len() < k {
+			heap.Push(&h, wf)
+		} else if rankedBefore(wf, h[0]) {
+			h[0] = wf
+			heap.Fix(&h, 0)
+		}
+	}
+	top := make([]WordFreq, h.Len())
+	for i := len(top) - 1; i >= 0; i-- {
+		top[i] = heap.Pop(&h).(WordFreq)
+	}
+	return top
+}
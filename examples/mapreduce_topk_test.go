@@ -0,0 +1,37 @@
+package examples
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestTopK checks TopK on a known distribution with a tie across the k
+// boundary, and against a full sort on a large random one
+func TestTopK(t *testing.T) {
+	counts := map[string]int{"go": 9, "chan": 7, "select": 7, "mutex": 4, "atomic": 4, "defer": 4, "panic": 1}
+	// Three words share count 4 but only two fit, so "mutex" loses on the word
+	want := []WordFreq{{"go", 9}, {"chan", 7}, {"select", 7}, {"atomic", 4}, {"defer", 4}}
+	if got := TopK(counts, 5); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("TopK(5) = %v, want %v", got, want)
+	}
+	if got := TopK(counts, 0); len(got) != 0 {
+		t.Fatalf("TopK(0) = %v, want nothing", got)
+	}
+	if got, all := TopK(counts, 20), sortWordFreqs(counts); fmt.Sprint(got) != fmt.Sprint(all) {
+		t.Fatalf("TopK(20) of %d words = %v, want all of them %v", len(counts), got, all)
+	}
+
+	// Zipf-like counts with plenty of ties
+	rng := rand.New(rand.NewSource(3))
+	large := make(map[string]int)
+	for i := 0; i < 5000; i++ {
+		large[fmt.Sprintf("w%04d", i)] = 1000/(rng.Intn(200)+1) + 1
+	}
+	sorted := sortWordFreqs(large)
+	for _, k := range []int{1, 10, 137} {
+		if got := TopK(large, k); fmt.Sprint(got) != fmt.Sprint(sorted[:k]) {
+			t.Fatalf("TopK(%d) of %d words disagrees with a full sort", k, len(large))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package examples
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// reduceTree folds values with combine, which must be associative but need
+// not be commutative. The slice is split into four chunks per unit of
+// parallelism, so one slow chunk doesn't leave the others idle; the chunks are
+// folded concurrently, and the partial results are combined pairwise, left
+// with right, up a tree. A semaphore keeps at most parallelism folds or
+// combines running at once. An empty slice reduces to 0.
+func reduceTree(values []int, combine func(a, b int) int, parallelism int) int {
+	result, _ := reduceTreePeak(values, combine, parallelism)
+	return result
+}
+
+// reduceTreePeak is reduceTree that also reports the most folds and combines
+// that ran at the same time
+func reduceTreePeak(values []int, combine func(a, b int) int, parallelism int) (result, peak int) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	sem := make(chan struct{}, parallelism)
+	var running, highWater int64
+	bounded := func(fn func()) {
+		sem <- struct{}{}
+		n := atomic.AddInt64(&running, 1)
+		for {
+			old := atomic.LoadInt64(&highWater)
+			if n <= old || atomic.CompareAndSwapInt64(&highWater, old, n) {
+				break
+			}
+		}
+		fn()
+		atomic.AddInt64(&running, -1)
+		<-sem
+	}
+
+	// Leaves: fold each chunk sequentially
+	chunks := 4 * parallelism
+	if chunks > len(values) {
+		chunks = len(values)
+	}
+	size := (len(values) + chunks - 1) / chunks
+	var partials []int
+	for start := 0; start < len(values); start += size {
+		partials = append(partials, 0)
+	}
+	var wg sync.WaitGroup
+	for i := range partials {
+		chunk := values[i*size:]
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+		wg.Add(1)
+		go func(i int, chunk []int) {
+			defer wg.Done()
+			bounded(func() {
+				acc := chunk[0]
+				for _, v := range chunk[1:] {
+					acc = combine(acc, v)
+				}
+				partials[i] = acc
+			})
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	// Inner nodes: combine neighbours pairwise until one value is left
+	for len(partials) > 1 {
+		next := make([]int, (len(partials)+1)/2)
+		for i := 0; i+1 < len(partials); i += 2 {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				bounded(func() { next[i/2] = combine(partials[i], partials[i+1]) })
+			}(i)
+		}
+		if len(partials)%2 == 1 {
+			// An odd one out moves up a level unchanged
+			next[len(next)-1] = partials[len(partials)-1]
+		}
+		wg.Wait()
+		partials = next
+	}
+	return partials[0], int(atomic.LoadInt64(&highWater))
+}
+
+// foldSequential is the reference reduceTree is checked against
+func foldSequential(values []int, combine func(a, b int) int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	acc := values[0]
+	for _, v := range values[1:] {
+		acc = combine(acc, v)
+	}
+	return acc
+}
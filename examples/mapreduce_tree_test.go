@@ -0,0 +1,38 @@
+package examples
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestReduceTree compares reduceTree with a sequential fold on seeded random
+// inputs, from empty and single-element slices up, with a commutative
+// combine and with order-sensitive ones, and checks the concurrency bound
+func TestReduceTree(t *testing.T) {
+	combines := map[string]func(a, b int) int{
+		"sum":   func(a, b int) int { return a + b },
+		"first": func(a, b int) int { return a },
+		"last":  func(a, b int) int { return b },
+	}
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 200; trial++ {
+		n := rng.Intn(300)
+		if trial < 2 {
+			n = trial // the empty and single-element cases come first
+		}
+		values := make([]int, n)
+		for i := range values {
+			values[i] = rng.Intn(1000) - 500
+		}
+		parallelism := rng.Intn(8) + 1
+		for name, combine := range combines {
+			got, peak := reduceTreePeak(values, combine, parallelism)
+			if want := foldSequential(values, combine); got != want {
+				t.Fatalf("%s over %d values with parallelism %d: got %d, sequential fold %d", name, n, parallelism, got, want)
+			}
+			if peak > parallelism {
+				t.Fatalf("%s over %d values: %d reductions at once, parallelism %d", name, n, peak, parallelism)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package examples
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Options holds the settings main.go passes down to the examples
+type Options struct {
+	// Resume makes the producer-consumer example start from its last checkpoint
+	Resume bool
+	// CheckpointDir is where the producer-consumer example keeps its
+	// checkpoint; empty means os.TempDir()
+	CheckpointDir string
+	// Soak runs the producer-consumer, pubsub and pools examples under steady
+	// load for this long instead of a fixed number of items
+	Soak time.Duration
+	// Chaos injects seeded delays, panics, failures and slow subscribers into
+	// the examples that can recover from them
+	Chaos bool
+	// Seed drives every random decision made by chaos mode, and the random
+	// input of the examples in the golden modes
+	Seed int64
+	// Trace records spans for pipeline stages, fan workers, pool jobs and
+	// singleflight flights and prints them when the example finishes
+	Trace bool
+	// EventLog records every event the event loop dispatches to this file
+	EventLog string
+	// Replay feeds a recorded event log through the event loop's handlers
+	// instead of running the live producers
+	Replay string
+	// ReplayRealtime keeps the original gaps between replayed events
+	ReplayRealtime bool
+	// EventDropPolicy is what the event loop's full input queues do with a
+	// new event: "block" (the default), "drop-newest" or "drop-oldest"
+	EventDropPolicy string
+	// Verify makes each example check its core invariant before returning
+	Verify bool
+	// Explain slows the example down and annotates each phase with what it
+	// does and why, from the pattern's metadata
+	Explain bool
+	// FanItems is how many work items the fan example distributes; above
+	// fanStreamThreshold the results are summarized as they stream in
+	FanItems int
+	// FanSpill writes every result of a streamed fan run to this file
+	FanSpill string
+	// PipelineCount is how many numbers the pipeline example generates; zero
+	// means 10
+	PipelineCount int
+	// PipelineDelay is how long every pipeline stage works on each item; zero
+	// keeps each stage's own delay (100ms to generate, 150ms to square, 100ms
+	// to add ten)
+	PipelineDelay time.Duration
+	// Golden writes each example's normalized results to a file in this
+	// directory; CheckGolden reruns and diffs them against the files there
+	Golden      string
+	CheckGolden string
+	// Out receives the examples' running commentary; nil means os.Stdout.
+	// Examples write to it from many goroutines, so it must be safe for
+	// concurrent use.
+	Out io.Writer
+}
+
+var opts Options
+
+// output is where the examples write their commentary
+func output() io.Writer {
+	if opts.Out != nil {
+		return opts.Out
+	}
+	return os.Stdout
+}
+
+// SetOptions configures the examples before one of the Run functions is called
+func SetOptions(o Options) {
+	opts = o
+	if o.Trace {
+		tracer = NewRecordingTracer()
+	} else {
+		tracer = noopTracer{}
+	}
+}
+
+var (
+	failuresMu sync.Mutex
+	failures   []string
+)
+
+// fail reports a failed check; main.go exits non-zero once the example returns
+func fail(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintf(output(), "FAIL: %s\n", msg)
+
+	failuresMu.Lock()
+	failures = append(failures, msg)
+	failuresMu.Unlock()
+}
+
+// Failed reports whether any example recorded a failure
+func Failed() bool {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	return len(failures) > 0
+}
@@ -0,0 +1,40 @@
+package examples
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// lockedBuffer is a bytes.Buffer safe for the examples' concurrent writes
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestOutputWriter checks an example writes its commentary to Options.Out,
+// which is how --json keeps it off stdout
+func TestOutputWriter(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	var buf lockedBuffer
+	opts = Options{Out: &buf}
+	RunMapReduce(context.Background())
+	got := buf.String()
+	if !strings.HasPrefix(got, "=== MapReduce") || !strings.Contains(got, "MapReduce example completed!") {
+		t.Fatalf("Options.Out got %d bytes, not the example's commentary:\n%s", len(got), got)
+	}
+}
@@ -1,79 +1,567 @@
 package examples
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
+
+	"concurrency-model-patterns/pipeline"
 )
 
 // Pipeline demonstrates a multi-stage data processing pipeline
-func RunPipeline() {
-	fmt.Println("=== Pipeline Pattern Example ===")
+func RunPipeline(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Pipeline Pattern Example ===")
+
+	// Spans per item are only recorded when --trace is on
+	trace := newPipelineTrace(tracer)
+
+	// Every stage counts its items and the time it works on them
+	metrics := &pipelineMetrics{}
+	publishStats("pipeline", func() interface{} { return metrics.Stats() })
+	// The stages get the delay through their config, not from opts
+	count, delay := pipelineShape()
+	cfg := pipelineConfig{Metrics: metrics, Delay: delay}
+	start := time.Now()
 
+	phase("stages")
 	// Stage 1: Generate numbers
-	numbers := generateNumbers(10)
+	numbers := generateNumbers(ctx, count, cfg, trace)
 
-	// Stage 2: Square the numbers
-	squared := square(numbers)
+	// Keep the inputs so --verify can check every output against them
+	var inputs []int
+	numbers = ChanMap(numbers, func(n int) int {
+		inputs = append(inputs, n)
+		return n
+	})
 
-	// Stage 3: Add 10 to each number
-	result := addTen(squared)
+	// Stage 2 squares the numbers, stage 3 drops the odd squares and stage 4
+	// adds 10 to the rest; the builder chains them in the order they are added
+	even := func(n int) bool { return n%2 == 0 }
+	result := pipeline.New[int]().
+		AddStage(func(in <-chan int) <-chan int { return square(ctx, in, cfg, trace) }).
+		AddStage(func(in <-chan int) <-chan int { return filter(ctx, in, even, cfg, trace) }).
+		AddStage(func(in <-chan int) <-chan int { return addTen(ctx, in, cfg, trace) }).
+		Run(numbers)
 
+	// Stage 5 changes the type: each int becomes a labeled result
+	labeled := StageCtx(ctx, result, labelResult)
+
+	phase("collect")
 	// Collect and display results
-	fmt.Println("Pipeline stages:")
-	fmt.Println("1. Generate numbers")
-	fmt.Println("2. Square numbers")
-	fmt.Println("3. Add 10")
-	fmt.Println()
+	fmt.Fprintln(output(), "Pipeline stages:")
+	fmt.Fprintln(output(), "1. Generate numbers")
+	fmt.Fprintln(output(), "2. Square numbers")
+	fmt.Fprintln(output(), "3. Drop odd squares")
+	fmt.Fprintln(output(), "4. Add 10")
+	fmt.Fprintln(output(), "5. Label odd and even results")
+	fmt.Fprintln(output())
+
+	// Results are also fed to a histogram sink for a summary at the end
+	collected := make(chan int, count)
+	var outputs []int
+	for r := range labeled {
+		heartbeat()
+		fmt.Fprintf(output(), "Result: %s\n", r.Label)
+		collected <- r.Value
+		outputs = append(outputs, r.Value)
+	}
+	close(collected)
+	record("results", outputs)
+	verify("pipeline", func() error {
+		var want []int
+		for _, in := range inputs {
+			if even(in * in) {
+				want = append(want, in*in+10)
+			}
+		}
+		if len(outputs) != len(want) {
+			return fmt.Errorf("%d outputs for %d even squares", len(outputs), len(want))
+		}
+		for i := range want {
+			if outputs[i] != want[i] {
+				return fmt.Errorf("output %d is %d, want %d", i+1, outputs[i], want[i])
+			}
+		}
+		return nil
+	})
+
+	fmt.Fprintln(output(), "Pipeline completed!")
+
+	// The slowest stage sets the pace: the pipeline can't take items in any
+	// faster than that stage can work through them
+	stageStats := metrics.Stats()
+	printPipelineStats(stageStats, len(outputs), time.Since(start))
+	record("stage_throughput", stageStats)
+
+	hist := Histogram(collected, 25)
+	record("histogram", hist)
+	fmt.Fprintln(output(), "Result histogram (bucket size 25):")
+	for lo := 0; lo <= 100; lo += 25 {
+		fmt.Fprintf(output(), "  %3d-%3d: %s\n", lo, lo+24, strings.Repeat("#", hist[lo]))
+	}
+
+	if rec, ok := tracer.(*RecordingTracer); ok {
+		fmt.Fprintln(output(), "\nPer-item trace:")
+		printSpanTree(rec.Spans(), "item")
+	}
+
+	// The same shape built from the generic channel toolkit
+	fmt.Fprintln(output(), "\nDeclarative pipeline (map -> filter -> reduce):")
+	squares := ChanMap(generateNumbers(ctx, 5, pipelineConfig{Delay: delay}, nil), func(n int) int { return n * n })
+	evens := ChanFilter(squares, func(n int) bool { return n%2 == 0 })
+	sum := ChanReduce(evens, 0, func(acc, n int) int { return acc + n })
+	fmt.Fprintf(output(), "Sum of even squares: %d\n", sum)
+	record("even_square_sum", sum)
+
+	phase("windows")
+	// Event-time windows over a scripted timeline with late arrivals
+	fmt.Fprintln(output(), "\nSliding windows (size 100ms, slide 50ms) over timestamped samples:")
+	base := time.Unix(0, 0)
+	timeline := []struct {
+		at    time.Duration
+		value float64
+	}{
+		{10 * time.Millisecond, 4}, {30 * time.Millisecond, 8}, {60 * time.Millisecond, 6},
+		{90 * time.Millisecond, 2}, {120 * time.Millisecond, 10},
+		{70 * time.Millisecond, 5}, // late, but within the allowed lateness
+		{160 * time.Millisecond, 3}, {210 * time.Millisecond, 7},
+		{40 * time.Millisecond, 9}, // too late, dropped
+		{260 * time.Millisecond, 1},
+	}
+	samples := make(chan Sample)
+	go func() {
+		defer close(samples)
+		for _, s := range timeline {
+			samples <- Sample{At: base.Add(s.at), Value: s.value}
+		}
+	}()
+	dropped := 0
+	var windows []WindowResult
+	for w := range window(samples, 100*time.Millisecond, 50*time.Millisecond) {
+		kind := "window"
+		if w.Correction {
+			kind = "correction"
+		}
+		fmt.Fprintf(output(), "  %-10s [%3d,%3dms) count %d sum %4.1f mean %4.1f max %4.1f\n", kind,
+			w.Start.Sub(base).Milliseconds(), w.End.Sub(base).Milliseconds(), w.Count, w.Sum, w.Mean, w.Max)
+		dropped = w.Dropped
+		windows = append(windows, w)
+	}
+	fmt.Fprintf(output(), "  late samples dropped: %d\n", dropped)
+	record("windows", windows)
+
+	phase("adaptive")
+	// Adaptive buffering: start unbuffered and grow links that stay blocked
+	fmt.Fprintln(output(), "\nAdaptive buffer sizing (slow downstream stage):")
+	stages := []adaptiveStage{
+		{name: "square", fn: func(n int) int { return n * n }, delay: 15 * time.Millisecond},
+		{name: "addTen", fn: func(n int) int { return n + 10 }, delay: 20 * time.Millisecond},
+	}
+	baseline := runAdaptivePipeline(60, stages, false, 50*time.Millisecond, 8)
+	adaptive := runAdaptivePipeline(60, stages, true, 50*time.Millisecond, 8)
+	fmt.Fprintf(output(), "Unbuffered baseline: %v\n", baseline.elapsed.Round(time.Millisecond))
+	fmt.Fprintf(output(), "Adaptive run: %v (final buffers: square=%d, addTen=%d)\n",
+		adaptive.elapsed.Round(time.Millisecond), adaptive.capacities["square"], adaptive.capacities["addTen"])
+	record("adaptive", map[string]interface{}{
+		"baseline_ms": baseline.elapsed.Milliseconds(),
+		"adaptive_ms": adaptive.elapsed.Milliseconds(),
+		"buffers":     adaptive.capacities,
+	})
+
+	phase("backpressure")
+	// Backpressure: the sink sets the pace for the whole pipeline
+	const bpItems, sinkInterval = 20, 50 * time.Millisecond
+	fmt.Fprintf(output(), "\nBackpressure (sink takes 1 item per %v, unbuffered links):\n", sinkInterval)
+	unbuffered := runBackpressure(bpItems, 0, sinkInterval)
+	printBackpressureTimeline(unbuffered)
+	// The first few sends only fill the stages in front of the sink
+	interval := unbuffered.steadyEmitInterval(4)
+	fmt.Fprintf(output(), "Generator settled at one item per %v\n", interval.Round(time.Millisecond))
+
+	fmt.Fprintf(output(), "\nBackpressure (sink takes 1 item per %v, links buffered to 5):\n", sinkInterval)
+	buffered := runBackpressure(bpItems, 5, sinkInterval)
+	printBackpressureTimeline(buffered)
+	fmt.Fprintf(output(), "Generator finished at %v, sink at %v; buffers peaked at %d/%d\n",
+		buffered.emitted[bpItems-1].Round(time.Millisecond), buffered.consumed[bpItems-1].Round(time.Millisecond),
+		buffered.peakBuffered(), buffered.capacity)
+
+	record("backpressure", map[string]interface{}{
+		"sink_interval_ms":            sinkInterval.Milliseconds(),
+		"unbuffered_emit_interval_ms": interval.Milliseconds(),
+		"buffered_peak":               buffered.peakBuffered(),
+		"buffered_capacity":           buffered.capacity,
+	})
+	verify("pipeline backpressure", func() error {
+		if interval < sinkInterval*3/4 || interval > sinkInterval*3/2 {
+			return fmt.Errorf("unbuffered generator emitted every %v, sink interval %v", interval, sinkInterval)
+		}
+		if buffered.peakBuffered() < buffered.capacity {
+			return fmt.Errorf("buffers peaked at %d, capacity %d", buffered.peakBuffered(), buffered.capacity)
+		}
+		return nil
+	})
+
+	phase("credit")
+	// Credit-based flow control bounds the items in the whole pipeline
+	const creditWindow = 8
+	fmt.Fprintf(output(), "\nCredit-based flow control (window %d, links buffered to 8, addTen takes 10ms):\n", creditWindow)
+	unbounded := runCreditPipeline(40, 0, 8, 0, 10*time.Millisecond, 0)
+	credited := runCreditPipeline(40, creditWindow, 8, 0, 10*time.Millisecond, 0)
+	fmt.Fprintf(output(), "Without credits: at most %d items in flight, %v\n", unbounded.MaxInFlight, unbounded.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(output(), "With %d credits: at most %d items in flight, %v\n", creditWindow, credited.MaxInFlight, credited.Elapsed.Round(time.Millisecond))
+	record("credit_window", map[string]int{
+		"window":                creditWindow,
+		"max_in_flight":         credited.MaxInFlight,
+		"max_in_flight_no_flow": unbounded.MaxInFlight,
+	})
+	verify("pipeline credits", func() error {
+		if credited.MaxInFlight > creditWindow {
+			return fmt.Errorf("%d items in flight, window %d", credited.MaxInFlight, creditWindow)
+		}
+		return nil
+	})
+
+	phase("buffering")
+	// The same workload with unbuffered and buffered stage outputs, into a
+	// consumer that stops every few results
+	const burst, pause = 3, 450 * time.Millisecond
+	fmt.Fprintf(output(), "\nPer-stage buffering (%d numbers, consumer pauses %v after every %d results):\n", count, pause, burst)
+	bufferedCfg := bufferedStages
+	bufferedCfg.Delay = delay
+	unbufferedResults, unbufferedTime := runBufferedPipeline(ctx, count, pipelineConfig{Quiet: true, Delay: delay}, burst, pause)
+	bufferedResults, bufferedTime := runBufferedPipeline(ctx, count, bufferedCfg, burst, pause)
+	fmt.Fprintf(output(), "Unbuffered: %d results in %v\n", len(unbufferedResults), unbufferedTime.Round(time.Millisecond))
+	fmt.Fprintf(output(), "Buffered (%d per stage): %d results in %v\n", bufferedCfg.AddTen, len(bufferedResults), bufferedTime.Round(time.Millisecond))
+	record("buffering", map[string]int64{
+		"unbuffered_ms": unbufferedTime.Milliseconds(),
+		"buffered_ms":   bufferedTime.Milliseconds(),
+	})
+
+	// Cancelling the generator closes its channel early, ending the pipeline
+	phase("cancel")
+	fmt.Fprintln(output(), "\nCancelable generator (cancelled after 3 of 10 values):")
+	goroutines := runtime.NumGoroutine()
+	genCtx, cancel := context.WithCancel(ctx)
+	generated := generateNumbers(genCtx, 10, pipelineConfig{Delay: delay}, nil)
+	var received []int
+	for num := range generated {
+		received = append(received, num)
+		if len(received) == 3 {
+			cancel()
+		}
+	}
+	cancel()
+	fmt.Fprintf(output(), "Received %d values before the channel closed: %v\n", len(received), received)
+	record("cancelable_generator", received)
+	exited := waitForGoroutines(goroutines, time.Second)
+	verify("cancelable generator", func() error {
+		if len(received) != 3 {
+			return fmt.Errorf("received %d values after cancelling at 3", len(received))
+		}
+		return exited
+	})
+
+	// Cancelling the whole pipeline: the consumer stops reading part way and
+	// every stage, blocked on a send nobody will take, exits on ctx.Done()
+	fmt.Fprintln(output(), "\nCancelling the whole pipeline after 3 of 10 results:")
+	goroutines = runtime.NumGoroutine()
+	received, live := runCancelledPipeline(ctx, 10, 3, pipelineConfig{Delay: delay})
+	exited = waitForGoroutines(goroutines, time.Second)
+	fmt.Fprintf(output(), "Consumer stopped after %v; goroutines %d before, %d when it stopped, %d once the stages exited\n",
+		received, goroutines, live, runtime.NumGoroutine())
+	record("cancelled_pipeline", received)
+	verify("cancelled pipeline", func() error { return exited })
+}
+
+// pipelineTrace groups each item's stage spans under one span for the item.
+// Every stage is a single goroutine passing items on in order, so the nth
+// item a stage sees is the nth item generated. A nil trace records nothing.
+type pipelineTrace struct {
+	tracer Tracer
+	mu     sync.Mutex
+	items  map[int]*itemTrace
+	// passed lists the items a filter let through, in order, so the stages
+	// after it can tell which item their nth one is
+	passed   []int
+	filtered bool
+}
+
+// itemTrace is the span for one item and how many of its stage spans are open.
+// Stages keep working after handing an item on, so the item span ends only
+// once the last stage is done with it and every stage span has ended.
+type itemTrace struct {
+	span Span
+	open int
+	done bool
+}
+
+func newPipelineTrace(t Tracer) *pipelineTrace {
+	if _, ok := t.(noopTracer); ok {
+		return nil
+	}
+	return &pipelineTrace{tracer: t, items: make(map[int]*itemTrace)}
+}
 
-	for num := range result {
-		fmt.Printf("Result: %d\n", num)
+// stage starts the span for item passing through the named stage
+func (pt *pipelineTrace) stage(item int, name string) Span {
+	if pt == nil {
+		return noopSpan{}
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	it, ok := pt.items[item]
+	if !ok {
+		it = &itemTrace{span: pt.tracer.Start("item")}
+		it.span.SetTag("item", item)
+		pt.items[item] = it
 	}
+	it.open++
 
-	fmt.Println("Pipeline completed!")
+	span := it.span.Start(name)
+	span.SetTag("item", item)
+	return &stageSpan{Span: span, trace: pt, item: item}
 }
 
-// Stage 1: Generate random numbers
-func generateNumbers(count int) <-chan int {
-	out := make(chan int)
+// pass records whether the filter let item through
+func (pt *pipelineTrace) pass(item int, kept bool) {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	pt.filtered = true
+	if kept {
+		pt.passed = append(pt.passed, item)
+	}
+	pt.mu.Unlock()
+	if !kept {
+		pt.finish(item)
+	}
+}
+
+// after returns which item is the nth to reach a stage after the filter
+func (pt *pipelineTrace) after(n int) int {
+	if pt == nil {
+		return n
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if !pt.filtered {
+		return n
+	}
+	return pt.passed[n-1]
+}
+
+// finish marks the item as having left the last stage
+func (pt *pipelineTrace) finish(item int) {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if it, ok := pt.items[item]; ok {
+		it.done = true
+		pt.endIfDone(item, it)
+	}
+}
+
+func (pt *pipelineTrace) endIfDone(item int, it *itemTrace) {
+	if it.done && it.open == 0 {
+		it.span.End()
+		delete(pt.items, item)
+	}
+}
+
+// stageSpan tells the pipeline trace when a stage span ends
+type stageSpan struct {
+	Span
+	trace *pipelineTrace
+	item  int
+	ended bool
+}
+
+func (s *stageSpan) End() {
+	s.Span.End()
+	s.trace.mu.Lock()
+	defer s.trace.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+	if it, ok := s.trace.items[s.item]; ok {
+		it.open--
+		s.trace.endIfDone(s.item, it)
+	}
+}
+
+// Stage 1: Generate random numbers. Every stage stops when ctx is
+// cancelled: its send and its simulated work both select on ctx.Done(), so
+// cancellation closes each channel and ends each goroutine promptly, even
+// when nobody is reading downstream any more.
+func generateNumbers(ctx context.Context, count int, cfg pipelineConfig, trace *pipelineTrace) <-chan int {
+	out := make(chan int, cfg.Generate)
+	rng := exampleRand()
 	go func() {
 		defer close(out)
 		for i := 0; i < count; i++ {
-			num := rand.Intn(10) + 1
-			fmt.Printf("Generated: %d\n", num)
-			out <- num
-			time.Sleep(100 * time.Millisecond) // Simulate work
+			span := trace.stage(i+1, "generate")
+			num := rng.Intn(10) + 1
+			sent := sendCtx(ctx, out, num)
+			if sent {
+				cfg.printf("Generated: %d\n", num)
+			}
+			start := time.Now()
+			if !sent || !sleepOrDone(cfg.stageDelay(100*time.Millisecond), ctx.Done()) { // Simulate work
+				span.End()
+				return
+			}
+			cfg.Metrics.observe("generate", time.Since(start))
+			span.End()
 		}
 	}()
 	return out
 }
 
 // Stage 2: Square the numbers
-func square(in <-chan int) <-chan int {
-	out := make(chan int)
+func square(ctx context.Context, in <-chan int, cfg pipelineConfig, trace *pipelineTrace) <-chan int {
+	item := 0
+	return StageBufferedCtx(ctx, in, cfg.Square, func(num int) int {
+		item++
+		span := trace.stage(item, "square")
+		defer span.End()
+		start := time.Now()
+		squared := num * num
+		cfg.printf("Squared %d -> %d\n", num, squared)
+		sleepOrDone(cfg.stageDelay(150*time.Millisecond), ctx.Done()) // Simulate work
+		cfg.Metrics.observe("square", time.Since(start))
+		return squared
+	})
+}
+
+// Stage 3: Keep only the numbers pred accepts. A rejected number is never
+// sent, so the stage goes straight back to its input rather than waiting on
+// a reader; it closes its output once in is closed and drained.
+func filter(ctx context.Context, in <-chan int, pred func(int) bool, cfg pipelineConfig, trace *pipelineTrace) <-chan int {
+	out := make(chan int, cfg.Filter)
 	go func() {
 		defer close(out)
+		item := 0
 		for num := range in {
-			squared := num * num
-			fmt.Printf("Squared %d -> %d\n", num, squared)
-			out <- squared
-			time.Sleep(150 * time.Millisecond) // Simulate work
+			heartbeat()
+			item++
+			span := trace.stage(item, "filter")
+			start := time.Now()
+			kept := pred(num)
+			cfg.Metrics.observe("filter", time.Since(start))
+			span.End()
+			trace.pass(item, kept)
+			if !kept {
+				cfg.printf("Filtered out %d\n", num)
+				continue
+			}
+			if ctx.Err() != nil || !sendCtx(ctx, out, num) {
+				return
+			}
 		}
 	}()
 	return out
 }
 
-// Stage 3: Add 10 to each number
-func addTen(in <-chan int) <-chan int {
-	out := make(chan int)
-	go func() {
-		defer close(out)
-		for num := range in {
-			result := num + 10
-			fmt.Printf("Added 10 to %d -> %d\n", num, result)
-			out <- result
-			time.Sleep(100 * time.Millisecond) // Simulate work
+// Stage 4: Add 10 to each number
+func addTen(ctx context.Context, in <-chan int, cfg pipelineConfig, trace *pipelineTrace) <-chan int {
+	n := 0
+	return StageBufferedCtx(ctx, in, cfg.AddTen, func(num int) int {
+		n++
+		item := trace.after(n)
+		span := trace.stage(item, "addTen")
+		defer trace.finish(item)
+		defer span.End()
+		start := time.Now()
+		result := num + 10
+		cfg.printf("Added 10 to %d -> %d\n", num, result)
+		sleepOrDone(cfg.stageDelay(100*time.Millisecond), ctx.Done()) // Simulate work
+		cfg.Metrics.observe("addTen", time.Since(start))
+		return result
+	})
+}
+
+// pipelineConfig sizes the output channel of each pipeline stage. A stage
+// with no buffer lock-steps with the next, handing over each item only when
+// the next stage is ready for it; with a buffer it can run that many items
+// ahead. Quiet stops the stages printing each item, and Metrics, if set,
+// collects how many items each stage handles and how long it works on them.
+// Delay, if set, replaces every stage's own simulated work per item.
+type pipelineConfig struct {
+	Generate int
+	Square   int
+	Filter   int
+	AddTen   int
+	Quiet    bool
+	Metrics  *pipelineMetrics
+	Delay    time.Duration
+}
+
+func (c pipelineConfig) printf(format string, args ...interface{}) {
+	if !c.Quiet {
+		fmt.Fprintf(output(), format, args...)
+	}
+}
+
+// pipelineShape is how many numbers the pipeline example generates and how
+// long its stages work on each: --pipeline-count and --pipeline-delay, or 10
+// and zero, which leaves each stage its own delay
+func pipelineShape() (count int, delay time.Duration) {
+	count = 10
+	if opts.PipelineCount > 0 {
+		count = opts.PipelineCount
+	}
+	return count, opts.PipelineDelay
+}
+
+// stageDelay is how long a stage simulates work per item: c.Delay if it is
+// set, otherwise the stage's own default
+func (c pipelineConfig) stageDelay(def time.Duration) time.Duration {
+	if c.Delay > 0 {
+		return c.Delay
+	}
+	return def
+}
+
+// labeledResult is a pipeline result with the label the last stage gives it
+type labeledResult struct {
+	Value int
+	Label string
+}
+
+// Stage 5: Label each result, changing the type flowing through
+func labelResult(n int) labeledResult {
+	parity := "odd"
+	if n%2 == 0 {
+		parity = "even"
+	}
+	return labeledResult{Value: n, Label: fmt.Sprintf("%d (%s)", n, parity)}
+}
+
+// runCancelledPipeline runs count numbers through generate -> square ->
+// addTen with cfg's buffers, reads stopAfter results, then cancels and walks
+// away without draining. It returns what it read and the goroutines live at
+// the cancel.
+func runCancelledPipeline(ctx context.Context, count, stopAfter int, cfg pipelineConfig) ([]int, int) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	result := pipeline.New[int]().
+		AddStage(func(in <-chan int) <-chan int { return square(ctx, in, cfg, nil) }).
+		AddStage(func(in <-chan int) <-chan int { return addTen(ctx, in, cfg, nil) }).
+		Run(generateNumbers(ctx, count, cfg, nil))
+	var received []int
+	for len(received) < stopAfter {
+		num, ok := <-result
+		if !ok {
+			break
 		}
-	}()
-	return out
+		received = append(received, num)
+	}
+	return received, runtime.NumGoroutine()
 }
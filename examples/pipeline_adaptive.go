@@ -0,0 +1,208 @@
+package examples
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// adaptiveLink connects two pipeline stages and records how long the upstream
+// stage spends blocked on send. Go channels can't be resized, so growing the
+// buffer swaps in a new channel and a forwarding goroutine drains the old one
+// first, keeping items in order.
+type adaptiveLink struct {
+	name    string
+	mu      sync.RWMutex
+	cur     chan int
+	chans   chan chan int
+	out     chan int
+	closed  bool
+	blocked time.Duration
+	statMu  sync.Mutex
+}
+
+func newAdaptiveLink(name string, capacity int) *adaptiveLink {
+	l := &adaptiveLink{
+		name:  name,
+		cur:   make(chan int, capacity),
+		chans: make(chan chan int, 64),
+		out:   make(chan int),
+	}
+	l.chans <- l.cur
+
+	// Forwarder: drain each generation of channel in turn
+	go func() {
+		defer close(l.out)
+		for ch := range l.chans {
+			for v := range ch {
+				l.out <- v
+			}
+		}
+	}()
+
+	return l
+}
+
+// send delivers v downstream, recording any time spent blocked
+func (l *adaptiveLink) send(v int) {
+	l.mu.RLock()
+	start := time.Now()
+	l.cur <- v
+	waited := time.Since(start)
+	l.mu.RUnlock()
+
+	l.statMu.Lock()
+	l.blocked += waited
+	l.statMu.Unlock()
+}
+
+// takeBlocked returns the blocked time since the last call and resets it
+func (l *adaptiveLink) takeBlocked() time.Duration {
+	l.statMu.Lock()
+	defer l.statMu.Unlock()
+	d := l.blocked
+	l.blocked = 0
+	return d
+}
+
+func (l *adaptiveLink) capacity() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return cap(l.cur)
+}
+
+// resize quiesces senders and swaps in a channel with the new capacity
+func (l *adaptiveLink) resize(capacity int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	next := make(chan int, capacity)
+	l.chans <- next
+	close(l.cur)
+	l.cur = next
+}
+
+func (l *adaptiveLink) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	close(l.cur)
+	close(l.chans)
+}
+
+// adaptiveStage is one stage of the adaptive pipeline
+type adaptiveStage struct {
+	name  string
+	fn    func(int) int
+	delay time.Duration
+	// work, when set, is how long the stage takes for each value, replacing
+	// the jittered delay
+	work func(v int) time.Duration
+}
+
+// adaptivePipelineReport describes a finished adaptive pipeline run
+type adaptivePipelineReport struct {
+	elapsed    time.Duration
+	capacities map[string]int
+	results    []int
+}
+
+// runAdaptivePipeline runs count numbers through the stages. When adaptive is
+// set, every interval it checks how long each stage was blocked on send, and a
+// link blocked for over a quarter of two consecutive intervals has its buffer
+// doubled, up to maxBuffer.
+func runAdaptivePipeline(count int, stages []adaptiveStage, adaptive bool, interval time.Duration, maxBuffer int) adaptivePipelineReport {
+	start := time.Now()
+
+	links := make([]*adaptiveLink, len(stages))
+	for i, stage := range stages {
+		links[i] = newAdaptiveLink(stage.name, 0)
+	}
+
+	// Generator feeds the first stage
+	source := make(chan int)
+	go func() {
+		defer close(source)
+		for i := 1; i <= count; i++ {
+			source <- i
+		}
+	}()
+
+	in := (<-chan int)(source)
+	for i, stage := range stages {
+		go func(stage adaptiveStage, in <-chan int, link *adaptiveLink) {
+			defer link.close()
+			for v := range in {
+				if stage.work != nil {
+					time.Sleep(stage.work(v))
+				} else {
+					// Jittered work (0-2x delay) so buffering has bursts to absorb
+					time.Sleep(time.Duration(rand.Int63n(2*int64(stage.delay) + 1)))
+				}
+				link.send(stage.fn(v))
+			}
+		}(stage, in, links[i])
+		in = links[i].out
+	}
+
+	stop := make(chan struct{})
+	var monitorWg sync.WaitGroup
+	if adaptive {
+		monitorWg.Add(1)
+		go func() {
+			defer monitorWg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			streak := make([]int, len(links))
+			for {
+				select {
+				case <-ticker.C:
+					for i, link := range links {
+						if link.takeBlocked() <= interval/4 {
+							streak[i] = 0
+							continue
+						}
+						streak[i]++
+						size := link.capacity()
+						if streak[i] < 2 || size >= maxBuffer {
+							continue
+						}
+						next := size * 2
+						if next == 0 {
+							next = 1
+						}
+						if next > maxBuffer {
+							next = maxBuffer
+						}
+						fmt.Fprintf(output(), "Adaptive: stage %s blocked on send, growing buffer %d -> %d\n", link.name, size, next)
+						link.resize(next)
+						streak[i] = 0
+					}
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	var results []int
+	for v := range in {
+		heartbeat()
+		results = append(results, v)
+	}
+	close(stop)
+	monitorWg.Wait()
+
+	report := adaptivePipelineReport{
+		elapsed:    time.Since(start),
+		capacities: make(map[string]int),
+		results:    results,
+	}
+	for _, link := range links {
+		report.capacities[link.name] = cap(link.cur)
+	}
+	return report
+}
@@ -0,0 +1,125 @@
+package examples
+
+import (
+	"io"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAdaptiveLinkResizeInFlight resizes a link over and over while items
+// flow through it and checks every item arrives once and in order, and that
+// the forwarding goroutine exits after close
+func TestAdaptiveLinkResizeInFlight(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	const items = 2000
+	l := newAdaptiveLink("test", 2)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < items; i++ {
+			l.send(i)
+		}
+	}()
+
+	stopResizing := make(chan struct{})
+	resized := make(chan int)
+	go func() {
+		rng := rand.New(rand.NewSource(5))
+		n := 0
+		for {
+			select {
+			case <-stopResizing:
+				resized <- n
+				return
+			default:
+			}
+			l.resize(rng.Intn(8))
+			n++
+			time.Sleep(time.Duration(rng.Intn(200)) * time.Microsecond)
+		}
+	}()
+
+	got := make([]int, 0, items)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rng := rand.New(rand.NewSource(6))
+		for v := range l.out {
+			got = append(got, v)
+			if rng.Intn(50) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stopResizing)
+	if n := <-resized; n < 10 {
+		t.Fatalf("only %d resizes while items flowed", n)
+	}
+	l.close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("output still open 5s after close, %d items received", len(got))
+	}
+
+	if len(got) != items {
+		t.Fatalf("received %d of %d items", len(got), items)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("item %d is %d: lost, duplicated or reordered", i, v)
+		}
+	}
+	goroutineCountStable(t, goroutines)
+}
+
+// TestAdaptivePipelineGrowsUnderSlowConsumer feeds a steady 5ms stage from
+// one that emits in bursts, and checks the link between them grows to its
+// cap and the adaptive run beats the unbuffered baseline, losing nothing
+func TestAdaptivePipelineGrowsUnderSlowConsumer(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	const items, maxBuffer = 90, 8
+	stages := []adaptiveStage{
+		// Eight values at once, then a 40ms gap
+		{name: "bursty", fn: func(n int) int { return n }, work: func(v int) time.Duration {
+			if v%9 == 0 {
+				return 40 * time.Millisecond
+			}
+			return 0
+		}},
+		{name: "slow", fn: func(n int) int { return n }, work: func(int) time.Duration { return 5 * time.Millisecond }},
+	}
+	baseline := runAdaptivePipeline(items, stages, false, 20*time.Millisecond, maxBuffer)
+	adaptive := runAdaptivePipeline(items, stages, true, 20*time.Millisecond, maxBuffer)
+
+	if got := adaptive.capacities["bursty"]; got != maxBuffer {
+		t.Fatalf("link after the bursty stage ended at capacity %d, cap %d", got, maxBuffer)
+	}
+	if got := adaptive.capacities["slow"]; got != 0 {
+		t.Fatalf("link after the slow stage, never blocked, grew to %d", got)
+	}
+	// Unbuffered, the slow stage idles through every gap; buffered, it works
+	// through the burst the gap follows
+	if adaptive.elapsed > baseline.elapsed*9/10 {
+		t.Fatalf("adaptive run took %v, unbuffered baseline %v", adaptive.elapsed, baseline.elapsed)
+	}
+	for _, r := range []adaptivePipelineReport{baseline, adaptive} {
+		if len(r.results) != items {
+			t.Fatalf("received %d of %d results", len(r.results), items)
+		}
+		for i, v := range r.results {
+			if v != i+1 {
+				t.Fatalf("result %d is %d", i, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package examples
+
+import (
+	"fmt"
+	"time"
+)
+
+// backpressureReport is the timeline of one backpressure run. Offsets are
+// from the start of the run, indexed by item.
+type backpressureReport struct {
+	emitted  []time.Duration
+	consumed []time.Duration
+	// buffered is how many items sat in the links' buffers right after each
+	// emission; capacity is the links' combined buffer size
+	buffered []int
+	capacity int
+}
+
+// runBackpressure runs count numbers through generate -> square -> addTen into
+// a sink that takes one item per sinkInterval. Every link has the given buffer
+// size. The generator never sleeps: with unbuffered links its sends only
+// complete as fast as the sink frees up the stage in front of it, so its rate
+// falls to the sink's; with buffers it races ahead until they are full.
+func runBackpressure(count, buffer int, sinkInterval time.Duration) backpressureReport {
+	start := time.Now()
+	report := backpressureReport{
+		emitted:  make([]time.Duration, count),
+		consumed: make([]time.Duration, count),
+		buffered: make([]int, count),
+		capacity: 3 * buffer,
+	}
+
+	generated := make(chan int, buffer)
+	squared := make(chan int, buffer)
+	added := make(chan int, buffer)
+	occupancy := func() int { return len(generated) + len(squared) + len(added) }
+
+	generatorDone := make(chan struct{})
+	go func() {
+		defer close(generatorDone)
+		defer close(generated)
+		for i := 0; i < count; i++ {
+			generated <- i
+			// Each slot is written by this goroutine alone
+			report.emitted[i] = time.Since(start)
+			report.buffered[i] = occupancy()
+		}
+	}()
+	go func() {
+		defer close(squared)
+		for n := range generated {
+			squared <- n * n
+		}
+	}()
+	go func() {
+		defer close(added)
+		for n := range squared {
+			added <- n + 10
+		}
+	}()
+
+	// The sink is the slow consumer at the end of the line
+	for i := 0; i < count; i++ {
+		<-added
+		heartbeat()
+		report.consumed[i] = time.Since(start)
+		time.Sleep(sinkInterval)
+	}
+	// The generator stamps its last emission after the send completes
+	<-generatorDone
+	return report
+}
+
+// steadyEmitInterval is the generator's mean interval between sends once the
+// pipeline has filled, skipping the first warmup emissions
+func (r backpressureReport) steadyEmitInterval(warmup int) time.Duration {
+	if len(r.emitted)-warmup < 2 {
+		return 0
+	}
+	steady := r.emitted[warmup:]
+	return (steady[len(steady)-1] - steady[0]) / time.Duration(len(steady)-1)
+}
+
+// peakBuffered is the most items ever observed sitting in the links' buffers
+func (r backpressureReport) peakBuffered() int {
+	peak := 0
+	for _, n := range r.buffered {
+		if n > peak {
+			peak = n
+		}
+	}
+	return peak
+}
+
+func printBackpressureTimeline(r backpressureReport) {
+	fmt.Fprintln(output(), "  item   emitted   consumed   buffered")
+	for i := range r.emitted {
+		fmt.Fprintf(output(), "  %4d  %7dms  %8dms  %5d/%d\n", i,
+			r.emitted[i].Milliseconds(), r.consumed[i].Milliseconds(), r.buffered[i], r.capacity)
+	}
+}
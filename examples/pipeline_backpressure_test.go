@@ -0,0 +1,44 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBackpressureUnbuffered checks the generator of an unbuffered pipeline
+// settles into emitting at the sink's pace, even though it never sleeps
+func TestBackpressureUnbuffered(t *testing.T) {
+	const sinkInterval = 20 * time.Millisecond
+	r := runBackpressure(15, 0, sinkInterval)
+	if r.capacity != 0 || r.peakBuffered() != 0 {
+		t.Fatalf("unbuffered links held %d items, capacity %d", r.peakBuffered(), r.capacity)
+	}
+	// Past the items filling the stages in front of the sink, every emission
+	// waits for the sink to take one
+	for i := 5; i < len(r.emitted); i++ {
+		if gap := r.emitted[i] - r.emitted[i-1]; gap < sinkInterval*3/4 || gap > sinkInterval*3 {
+			t.Fatalf("emission %d came %v after the one before, sink interval %v: %v", i, gap, sinkInterval, r.emitted)
+		}
+	}
+	if interval := r.steadyEmitInterval(4); interval < sinkInterval*3/4 || interval > sinkInterval*3/2 {
+		t.Fatalf("generator emitted every %v, sink interval %v", interval, sinkInterval)
+	}
+}
+
+// TestBackpressureBuffered checks the generator of a buffered pipeline runs
+// ahead of the sink until the links' buffers are full
+func TestBackpressureBuffered(t *testing.T) {
+	const items, buffer, sinkInterval = 20, 4, 10 * time.Millisecond
+	r := runBackpressure(items, buffer, sinkInterval)
+	if r.capacity != 3*buffer {
+		t.Fatalf("capacity %d for three links buffered to %d", r.capacity, buffer)
+	}
+	if r.peakBuffered() != r.capacity {
+		t.Fatalf("buffers peaked at %d, capacity %d: %v", r.peakBuffered(), r.capacity, r.buffered)
+	}
+	// The capacity, plus one item held by each stage and the sink, went out
+	// before the sink had taken its second
+	if ahead := 3*buffer + 3; r.emitted[ahead-1] >= r.consumed[1] {
+		t.Fatalf("item %d emitted at %v, after the sink's second item at %v", ahead, r.emitted[ahead-1], r.consumed[1])
+	}
+}
@@ -0,0 +1,33 @@
+package examples
+
+import (
+	"concurrency-model-patterns/pipeline"
+	"context"
+	"time"
+)
+
+// bufferedStages is the buffering the comparison runs with: room for four
+// items after every stage
+var bufferedStages = pipelineConfig{Generate: 4, Square: 4, Filter: 4, AddTen: 4, Quiet: true}
+
+// runBufferedPipeline runs count numbers through generate -> square ->
+// addTen with cfg's buffers into a consumer that stops for pause after every
+// burst results, as a sink flushing batches would. There is no filter, so
+// every run does the same work whatever numbers come up. It returns the
+// results and how long the run took.
+func runBufferedPipeline(ctx context.Context, count int, cfg pipelineConfig, burst int, pause time.Duration) ([]int, time.Duration) {
+	start := time.Now()
+	result := pipeline.New[int]().
+		AddStage(func(in <-chan int) <-chan int { return square(ctx, in, cfg, nil) }).
+		AddStage(func(in <-chan int) <-chan int { return addTen(ctx, in, cfg, nil) }).
+		Run(generateNumbers(ctx, count, cfg, nil))
+	var results []int
+	for r := range result {
+		heartbeat()
+		results = append(results, r)
+		if len(results)%burst == 0 {
+			sleepOrDone(pause, ctx.Done())
+		}
+	}
+	return results, time.Since(start)
+}
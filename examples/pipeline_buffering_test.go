@@ -0,0 +1,46 @@
+package examples
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"concurrency-model-patterns/pipeline"
+)
+
+// TestBufferedDrain cancels a buffered pipeline whose consumer then keeps
+// ranging over the output, and checks the output still closes promptly,
+// yields no more than the items the stages already held, and every stage
+// goroutine exits
+func TestBufferedDrain(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := pipelineConfig{Generate: 4, Square: 4, AddTen: 4, Quiet: true}
+	result := pipeline.New[int]().
+		AddStage(func(in <-chan int) <-chan int { return square(ctx, in, cfg, nil) }).
+		AddStage(func(in <-chan int) <-chan int { return addTen(ctx, in, cfg, nil) }).
+		Run(generateNumbers(ctx, 50, cfg, nil))
+	requireReceives(t, result, 2, 5*time.Second)
+	cancel()
+
+	closed := make(chan int)
+	go func() {
+		n := 0
+		for range result {
+			n++
+		}
+		closed <- n
+	}()
+	select {
+	case n := <-closed:
+		// The addTen buffer, plus one result computed as the cancel landed
+		if n > cfg.AddTen+1 {
+			t.Fatalf("read %d results after cancelling, addTen buffers %d", n, cfg.AddTen)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("output still open a second after cancelling")
+	}
+	goroutineCountStable(t, goroutines)
+}
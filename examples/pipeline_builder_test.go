@@ -0,0 +1,136 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"concurrency-model-patterns/pipeline"
+)
+
+// TestPipelineBuilder checks the generic pipeline builder runs its stages in
+// the order they were added, passes the source through with none, closes its
+// output when the source closes, and leaves no stage goroutine behind
+func TestPipelineBuilder(t *testing.T) {
+	source := func(values ...int) <-chan int {
+		ch := make(chan int)
+		go func() {
+			defer close(ch)
+			for _, v := range values {
+				ch <- v
+			}
+		}()
+		return ch
+	}
+	double := pipeline.Map(func(n int) int { return n * 2 })
+	inc := pipeline.Map(func(n int) int { return n + 1 })
+
+	goroutines := runtime.NumGoroutine()
+	got := pipeline.Collect(pipeline.New[int]().AddStage(double).AddStage(inc).Run(source(1, 2, 3)))
+	if fmt.Sprint(got) != "[3 5 7]" {
+		t.Fatalf("double then increment gave %v, want [3 5 7]", got)
+	}
+	got = pipeline.Collect(pipeline.New[int]().AddStage(inc).AddStage(double).Run(source(1, 2, 3)))
+	if fmt.Sprint(got) != "[4 6 8]" {
+		t.Fatalf("increment then double gave %v, want [4 6 8]", got)
+	}
+	got = pipeline.Collect(pipeline.New[int]().Run(source(1, 2)))
+	if fmt.Sprint(got) != "[1 2]" {
+		t.Fatalf("no stages gave %v, want [1 2]", got)
+	}
+
+	// An empty source still closes the output of every stage
+	if got := pipeline.Collect(pipeline.New[int]().AddStage(double).AddStage(inc).AddStage(double).Run(source())); len(got) != 0 {
+		t.Fatalf("empty source gave %v", got)
+	}
+	goroutineCountStable(t, goroutines)
+}
+
+// TestStageTypes runs the generic Stage across type changes, int to string
+// and string to struct, and checks StageCtx stops once its ctx is done
+func TestStageTypes(t *testing.T) {
+	ints := make(chan int, 3)
+	for _, n := range []int{7, 42, 100} {
+		ints <- n
+	}
+	close(ints)
+	strs := Stage(ints, strconv.Itoa)
+	type word struct {
+		Text string
+		Len  int
+	}
+	words := Stage(strs, func(s string) word { return word{s, len(s)} })
+	var got []word
+	for w := range words {
+		got = append(got, w)
+	}
+	if fmt.Sprint(got) != "[{7 1} {42 2} {100 3}]" {
+		t.Fatalf("int -> string -> struct gave %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	endless := make(chan int)
+	go func() {
+		defer close(endless)
+		for i := 0; ; i++ {
+			if !sendCtx(ctx, endless, i) {
+				return
+			}
+		}
+	}()
+	labels := StageCtx(ctx, endless, labelResult)
+	if first := <-labels; first.Label != "0 (even)" {
+		t.Fatalf("first label %q, want %q", first.Label, "0 (even)")
+	}
+	cancel()
+	// At most one result computed before the cancel can still get through
+	drained := 0
+	for range labels {
+		drained++
+	}
+	if drained > 1 {
+		t.Fatalf("%d results sent after cancelling", drained)
+	}
+}
+
+// TestFilterStage pipes a known sequence through filter and checks only the
+// even numbers come out, in order, that rejected numbers are taken from the
+// input while nobody reads the output, and that the output closes with the
+// input
+func TestFilterStage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	even := func(n int) bool { return n%2 == 0 }
+
+	in := make(chan int)
+	out := filter(ctx, in, even, pipelineConfig{Quiet: true}, nil)
+	fed := make(chan int, 4)
+	go func() {
+		defer close(in)
+		for _, n := range []int{1, 3, 5, 2, 7, 4, 9} {
+			in <- n
+			fed <- n
+			if n == 2 {
+				break
+			}
+		}
+	}()
+	// The odd numbers are dropped without a reader; 2 then waits to be read
+	requireReceives(t, fed, 4, time.Second)
+	if got := pipeline.Collect(out); fmt.Sprint(got) != "[2]" {
+		t.Fatalf("filter passed %v, want [2]", got)
+	}
+
+	source := make(chan int, 10)
+	for n := 1; n <= 10; n++ {
+		source <- n
+	}
+	close(source)
+	if got := pipeline.Collect(filter(ctx, source, even, pipelineConfig{Quiet: true}, nil)); fmt.Sprint(got) != "[2 4 6 8 10]" {
+		t.Fatalf("filtering 1..10 for evens gave %v", got)
+	}
+}
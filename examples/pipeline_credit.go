@@ -0,0 +1,120 @@
+package examples
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// inFlightGauge counts the items between the generator and the sink, and
+// how many entered and left the pipeline one by one
+type inFlightGauge struct {
+	current int64
+	peak    int64
+	// entered is written by the generator alone and exited by the sink alone
+	entered []int
+	exited  []int
+}
+
+func newInFlightGauge(count int) *inFlightGauge {
+	return &inFlightGauge{entered: make([]int, count), exited: make([]int, count)}
+}
+
+func (g *inFlightGauge) enter(item int) {
+	g.entered[item]++
+	n := atomic.AddInt64(&g.current, 1)
+	for {
+		peak := atomic.LoadInt64(&g.peak)
+		if n <= peak || atomic.CompareAndSwapInt64(&g.peak, peak, n) {
+			return
+		}
+	}
+}
+
+func (g *inFlightGauge) exit(item int) {
+	g.exited[item]++
+	atomic.AddInt64(&g.current, -1)
+}
+
+// creditPipelineReport is the outcome of one runCreditPipeline
+type creditPipelineReport struct {
+	Items       int
+	Window      int
+	MaxInFlight int
+	Elapsed     time.Duration
+	gauge       *inFlightGauge
+}
+
+// runCreditPipeline runs count numbers through generate -> square -> addTen
+// into a sink, with links buffered to buffer and each stage taking its delay
+// per item. With a window above zero the pipeline is credit-based: the
+// generator starts with window credits and spends one to emit each item,
+// and the sink sends it back on a side channel once the item is done, so at
+// most window items are anywhere in between however large the buffers are.
+// A window of zero bounds them only by the buffers and the stages.
+func runCreditPipeline(count, window, buffer int, squareDelay, addDelay, sinkDelay time.Duration) creditPipelineReport {
+	start := time.Now()
+	gauge := newInFlightGauge(count)
+	var credits chan struct{}
+	if window > 0 {
+		credits = make(chan struct{}, window)
+		for i := 0; i < window; i++ {
+			credits <- struct{}{}
+		}
+	}
+
+	generated := make(chan int, buffer)
+	squared := make(chan int, buffer)
+	added := make(chan int, buffer)
+	go func() {
+		defer close(generated)
+		for i := 0; i < count; i++ {
+			if credits != nil {
+				<-credits
+			}
+			gauge.enter(i)
+			generated <- i
+		}
+	}()
+	go func() {
+		defer close(squared)
+		for n := range generated {
+			time.Sleep(squareDelay)
+			squared <- n
+		}
+	}()
+	go func() {
+		defer close(added)
+		for n := range squared {
+			time.Sleep(addDelay)
+			added <- n
+		}
+	}()
+
+	// Items travel as their index, so the sink can tell which one finished
+	for item := range added {
+		heartbeat()
+		time.Sleep(sinkDelay)
+		gauge.exit(item)
+		if credits != nil {
+			credits <- struct{}{}
+		}
+	}
+	return creditPipelineReport{
+		Items:       count,
+		Window:      window,
+		MaxInFlight: int(atomic.LoadInt64(&gauge.peak)),
+		Elapsed:     time.Since(start),
+		gauge:       gauge,
+	}
+}
+
+// balanced checks every item entered and left the pipeline exactly once
+func (r creditPipelineReport) balanced() error {
+	for i := 0; i < r.Items; i++ {
+		if r.gauge.entered[i] != 1 || r.gauge.exited[i] != 1 {
+			return fmt.Errorf("item %d entered %d times and left %d times", i, r.gauge.entered[i], r.gauge.exited[i])
+		}
+	}
+	return nil
+}
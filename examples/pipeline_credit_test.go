@@ -0,0 +1,40 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCreditWindow runs the credit-based pipeline with a slow middle stage
+// and with a slow sink, at windows of 1 and 3 with links buffered well past
+// either, and checks in-flight items never exceed the window, reach it, and
+// every item enters and leaves once
+func TestCreditWindow(t *testing.T) {
+	cases := []struct {
+		name              string
+		window            int
+		square, add, sink time.Duration
+	}{
+		{"slow middle stage", 3, 0, 10 * time.Millisecond, 0},
+		{"slow sink", 3, 0, 0, 10 * time.Millisecond},
+		{"window of 1", 1, time.Millisecond, 5 * time.Millisecond, 0},
+	}
+	for _, c := range cases {
+		r := runCreditPipeline(20, c.window, 8, c.square, c.add, c.sink)
+		if r.MaxInFlight != c.window {
+			t.Fatalf("%s: at most %d items in flight, window %d", c.name, r.MaxInFlight, c.window)
+		}
+		if err := r.balanced(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+	}
+
+	// Without credits the same buffers let far more through
+	r := runCreditPipeline(20, 0, 8, 0, 10*time.Millisecond, 0)
+	if r.MaxInFlight <= 3 {
+		t.Fatalf("no window: at most %d items in flight, want more than 3", r.MaxInFlight)
+	}
+	if err := r.balanced(); err != nil {
+		t.Fatal(err)
+	}
+}
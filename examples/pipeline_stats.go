@@ -0,0 +1,101 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// pipelineStages are the pipeline example's stages, in order
+var pipelineStages = []string{"generate", "square", "filter", "addTen"}
+
+// StageStats is how much one pipeline stage did. Busy is the time it spent
+// working on items, not waiting to receive or send them, so ItemsPerSec is
+// the most the stage could handle on its own.
+type StageStats struct {
+	Name        string
+	Items       int
+	Busy        time.Duration
+	ItemsPerSec float64
+}
+
+// PipelineStats is a snapshot of every stage's counters, in pipeline order
+type PipelineStats struct {
+	Stages []StageStats
+}
+
+// Slowest returns the stage that can handle the fewest items per second,
+// which sets the pace of the whole pipeline
+func (s PipelineStats) Slowest() StageStats {
+	var slowest StageStats
+	for _, st := range s.Stages {
+		if st.Items > 0 && (slowest.Items == 0 || st.ItemsPerSec < slowest.ItemsPerSec) {
+			slowest = st
+		}
+	}
+	return slowest
+}
+
+// pipelineMetrics collects counters from the stage goroutines for readers
+// elsewhere. A nil collector records nothing.
+type pipelineMetrics struct {
+	mu    sync.Mutex
+	items map[string]int
+	busy  map[string]time.Duration
+}
+
+// observe records that stage spent took working on one item
+func (m *pipelineMetrics) observe(stage string, took time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.items == nil {
+		m.items = make(map[string]int)
+		m.busy = make(map[string]time.Duration)
+	}
+	m.items[stage]++
+	m.busy[stage] += took
+}
+
+// Stats returns the counters of the example's stages that have seen items
+func (m *pipelineMetrics) Stats() PipelineStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stats PipelineStats
+	for _, name := range pipelineStages {
+		n, ok := m.items[name]
+		if !ok {
+			continue
+		}
+		st := StageStats{Name: name, Items: n, Busy: m.busy[name]}
+		if st.Busy > 0 {
+			st.ItemsPerSec = float64(n) / st.Busy.Seconds()
+		}
+		stats.Stages = append(stats.Stages, st)
+	}
+	return stats
+}
+
+// printPipelineStats prints each stage's throughput against the pipeline's
+// own, which was out items in elapsed
+func printPipelineStats(stats PipelineStats, out int, elapsed time.Duration) {
+	fmt.Fprintln(output(), "Stage throughput (items per second of work):")
+	for _, st := range stats.Stages {
+		// A stage with under a millisecond of work in all is never the
+		// bottleneck, and its rate is only noise
+		rate := "no measurable work"
+		if st.Busy >= time.Millisecond {
+			rate = fmt.Sprintf("%.1f/s", st.ItemsPerSec)
+		}
+		fmt.Fprintf(output(), "  %-8s %3d items, busy %-8v %s\n", st.Name, st.Items, st.Busy.Round(time.Millisecond), rate)
+	}
+	if len(stats.Stages) == 0 {
+		return
+	}
+	in := stats.Stages[0].Items
+	slowest := stats.Slowest()
+	fmt.Fprintf(output(), "Pipeline: %d items in, %d out in %v (%.1f in/s); slowest stage %s at %.1f/s\n",
+		in, out, elapsed.Round(time.Millisecond), float64(in)/elapsed.Seconds(), slowest.Name, slowest.ItemsPerSec)
+}
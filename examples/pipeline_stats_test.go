@@ -0,0 +1,44 @@
+package examples
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPipelineStats runs the stages with square made slower than the rest
+// and checks every stage counted the items it saw, square is reported the
+// slowest, and the whole pipeline ran no faster than square could
+func TestPipelineStats(t *testing.T) {
+	metrics := &pipelineMetrics{}
+	cfg := pipelineConfig{Quiet: true, Metrics: metrics}
+	ctx := context.Background()
+	even := func(n int) bool { return n%2 == 0 }
+	start := time.Now()
+	out := 0
+	for range addTen(ctx, filter(ctx, square(ctx, generateNumbers(ctx, 10, cfg, nil), cfg, nil), even, cfg, nil), cfg, nil) {
+		out++
+	}
+	elapsed := time.Since(start)
+
+	stats := metrics.Stats()
+	if len(stats.Stages) < 3 {
+		t.Fatalf("stats for %v, want generate, square and filter at least", stats.Stages)
+	}
+	for _, st := range stats.Stages[:3] {
+		if st.Items != 10 {
+			t.Fatalf("%s handled %d items, want 10", st.Name, st.Items)
+		}
+	}
+	if out > 0 && (len(stats.Stages) != 4 || stats.Stages[3].Items != out) {
+		t.Fatalf("addTen stats %v for %d results", stats.Stages, out)
+	}
+	// square defaults to 150ms an item, the others to 100ms or less
+	slowest := stats.Slowest()
+	if slowest.Name != "square" {
+		t.Fatalf("slowest stage %s, want square (stats %v)", slowest.Name, stats.Stages)
+	}
+	if rate := 10 / elapsed.Seconds(); rate > slowest.ItemsPerSec*1.05 {
+		t.Fatalf("pipeline ran at %.1f items/s, faster than its slowest stage at %.1f/s", rate, slowest.ItemsPerSec)
+	}
+}
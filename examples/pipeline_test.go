@@ -0,0 +1,79 @@
+package examples
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestPipelineCancel cancels the pipeline, unbuffered and buffered, before
+// reading anything and part way through, and checks every stage goroutine
+// exits either way
+func TestPipelineCancel(t *testing.T) {
+	configs := []struct {
+		name string
+		cfg  pipelineConfig
+	}{
+		{"unbuffered", pipelineConfig{Quiet: true}},
+		{"buffered", pipelineConfig{Generate: 4, Square: 4, AddTen: 4, Quiet: true}},
+	}
+	for _, c := range configs {
+		for _, stopAfter := range []int{0, 2} {
+			goroutines := runtime.NumGoroutine()
+			ctx, cancel := context.WithCancel(context.Background())
+			if stopAfter == 0 {
+				cancel()
+			}
+			received, live := runCancelledPipeline(ctx, 10, stopAfter, c.cfg)
+			cancel()
+			// Buffered stages may already have finished the whole run
+			if c.cfg.Generate == 0 && stopAfter > 0 && live < goroutines+3 {
+				t.Fatalf("%s, stopping after %d: only %d goroutines live, %d before; stages already gone",
+					c.name, stopAfter, live, goroutines)
+			}
+			if len(received) != stopAfter {
+				t.Fatalf("%s, stopping after %d: read %v", c.name, stopAfter, received)
+			}
+			if err := waitForGoroutines(goroutines, time.Second); err != nil {
+				t.Fatalf("%s, stopping after %d: %v", c.name, stopAfter, err)
+			}
+		}
+	}
+}
+
+// TestPipelineShape checks the count and delay options default to 10 values
+// and each stage's own delay, and that when set, the generator emits exactly
+// count values and every stage works for the delay instead of its default
+func TestPipelineShape(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts = Options{}
+	if count, delay := pipelineShape(); count != 10 || delay != 0 {
+		t.Fatalf("defaults are %d values and a %v delay, want 10 and none", count, delay)
+	}
+	if d := (pipelineConfig{}).stageDelay(150 * time.Millisecond); d != 150*time.Millisecond {
+		t.Fatalf("unset delay gives %v, want the stage's own 150ms", d)
+	}
+
+	opts = Options{PipelineCount: 7, PipelineDelay: 20 * time.Millisecond}
+	count, delay := pipelineShape()
+	if count != 7 || delay != 20*time.Millisecond {
+		t.Fatalf("options give %d values and a %v delay, want 7 and 20ms", count, delay)
+	}
+	ctx := context.Background()
+	cfg := pipelineConfig{Quiet: true, Delay: delay}
+	start := time.Now()
+	results := 0
+	for range addTen(ctx, square(ctx, generateNumbers(ctx, count, cfg, nil), cfg, nil), cfg, nil) {
+		results++
+	}
+	elapsed := time.Since(start)
+	if results != count {
+		t.Fatalf("%d results for a count of %d", results, count)
+	}
+	// The generator alone sleeps the delay after each value; at the default
+	// delays square alone would need over a second
+	if elapsed < time.Duration(count)*delay || elapsed > 600*time.Millisecond {
+		t.Fatalf("%d values at %v a stage took %v", count, delay, elapsed)
+	}
+}
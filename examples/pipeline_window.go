@@ -0,0 +1,155 @@
+package examples
+
+import (
+	"sort"
+	"time"
+)
+
+// Sample is a timestamped reading fed to the window stage
+type Sample struct {
+	At    time.Time
+	Value float64
+}
+
+// WindowResult is the aggregate of the samples in [Start, End). A Correction
+// replaces an earlier result for the same window after a late sample arrived.
+// Dropped is the running count of samples that came too late to be counted.
+type WindowResult struct {
+	Start      time.Time
+	End        time.Time
+	Count      int
+	Sum        float64
+	Mean       float64
+	Max        float64
+	Correction bool
+	Dropped    int
+}
+
+// windowAgg accumulates one window
+type windowAgg struct {
+	count int
+	sum   float64
+	max   float64
+}
+
+func (a *windowAgg) add(v float64) {
+	if a.count == 0 || v > a.max {
+		a.max = v
+	}
+	a.count++
+	a.sum += v
+}
+
+// window groups samples into overlapping windows of the given size starting
+// every slide, by sample timestamp rather than arrival time. A window is
+// emitted once a sample at or past its end has been seen. Samples up to one
+// slide late still count: the window they belong to is re-emitted as a
+// correction. Anything later is dropped and counted. The returned channel is
+// closed after the windows still open when in closes have been emitted.
+func window(in <-chan Sample, size, slide time.Duration) <-chan WindowResult {
+	return windowWithLateness(in, size, slide, slide)
+}
+
+// windowWithLateness is window with an explicit allowed lateness
+func windowWithLateness(in <-chan Sample, size, slide, lateness time.Duration) <-chan WindowResult {
+	out := make(chan WindowResult)
+	go func() {
+		defer close(out)
+		open := make(map[int64]*windowAgg)
+		emitted := make(map[int64]*windowAgg)
+		var watermark int64
+		started := false
+		dropped := 0
+
+		result := func(start int64, a *windowAgg, correction bool) WindowResult {
+			return WindowResult{
+				Start:      time.Unix(0, start),
+				End:        time.Unix(0, start+int64(size)),
+				Count:      a.count,
+				Sum:        a.sum,
+				Mean:       a.sum / float64(a.count),
+				Max:        a.max,
+				Correction: correction,
+				Dropped:    dropped,
+			}
+		}
+
+		// emitUpTo emits, in start order, every open window that ends by limit
+		emitUpTo := func(limit int64) {
+			var starts []int64
+			for start := range open {
+				if start+int64(size) <= limit {
+					starts = append(starts, start)
+				}
+			}
+			sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+			for _, start := range starts {
+				out <- result(start, open[start], false)
+				emitted[start] = open[start]
+				delete(open, start)
+			}
+		}
+
+		for s := range in {
+			t := s.At.UnixNano()
+			tooLate := false
+
+			// Every window [start, start+size) with start a multiple of slide that contains t
+			last := t - mod(t, int64(slide))
+			for start := last; start+int64(size) > t; start -= int64(slide) {
+				end := start + int64(size)
+				switch {
+				case open[start] != nil:
+					open[start].add(s.Value)
+				case !started || end > watermark:
+					open[start] = &windowAgg{}
+					open[start].add(s.Value)
+				case watermark < end+int64(lateness):
+					// Already emitted but within the allowed lateness: revise it
+					a, revised := emitted[start]
+					if !revised {
+						a = &windowAgg{}
+						emitted[start] = a
+					}
+					a.add(s.Value)
+					out <- result(start, a, revised)
+				default:
+					tooLate = true
+				}
+			}
+			if tooLate {
+				dropped++
+			}
+
+			if !started || t > watermark {
+				watermark = t
+				started = true
+			}
+			emitUpTo(watermark)
+
+			// Windows past their lateness can no longer be corrected
+			for start := range emitted {
+				if start+int64(size)+int64(lateness) <= watermark {
+					delete(emitted, start)
+				}
+			}
+		}
+
+		for start := range open {
+			if start+int64(size) > watermark {
+				watermark = start + int64(size)
+			}
+		}
+		emitUpTo(watermark)
+	}()
+	return out
+}
+
+// mod is the remainder of a/b rounded towards negative infinity
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
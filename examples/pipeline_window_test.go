@@ -0,0 +1,64 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWindowScriptedTimeline feeds 10s windows sliding every 5s a scripted
+// timeline with a late sample inside the allowed lateness, one that is
+// partly too late and one far too late, and checks every emitted result:
+// boundaries, aggregates, corrections and the running dropped count
+func TestWindowScriptedTimeline(t *testing.T) {
+	at := func(s int64) time.Time { return time.Unix(s, 0) }
+	samples := []Sample{
+		{at(1), 1},
+		{at(6), 2},
+		{at(12), 3},  // closes [-5,5) and [0,10)
+		{at(8), 10},  // late for [0,10), within the 5s lateness: a correction
+		{at(3), 100}, // corrects [0,10) again, too late for [-5,5): dropped
+		{at(20), 4},  // closes [5,15) and [10,20)
+		{at(4), 7},   // too late for every window it falls in
+	}
+	in := make(chan Sample)
+	go func() {
+		defer close(in)
+		for _, s := range samples {
+			in <- s
+		}
+	}()
+
+	result := func(start, count int, sum, max float64, correction bool, dropped int) WindowResult {
+		return WindowResult{
+			Start: at(int64(start)), End: at(int64(start + 10)),
+			Count: count, Sum: sum, Mean: sum / float64(count), Max: max,
+			Correction: correction, Dropped: dropped,
+		}
+	}
+	want := []WindowResult{
+		result(-5, 1, 1, 1, false, 0),
+		result(0, 2, 3, 2, false, 0),
+		result(0, 3, 13, 10, true, 0),
+		result(0, 4, 113, 100, true, 0),
+		result(5, 3, 15, 10, false, 1),
+		result(10, 1, 3, 3, false, 1),
+		// Still open when the input closes
+		result(15, 1, 4, 4, false, 2),
+		result(20, 1, 4, 4, false, 2),
+	}
+
+	var got []WindowResult
+	for r := range window(in, 10*time.Second, 5*time.Second) {
+		got = append(got, r)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) ||
+			got[i].Count != want[i].Count || got[i].Sum != want[i].Sum || got[i].Mean != want[i].Mean ||
+			got[i].Max != want[i].Max || got[i].Correction != want[i].Correction || got[i].Dropped != want[i].Dropped {
+			t.Fatalf("result %d is %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// fnvKeyHash is the default key hash for GetForKey
+func fnvKeyHash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// preferredConnection is the id of the connection key maps to. Keys spread
+// over every slot up to maxSize, so a key's connection doesn't move as the
+// pool grows.
+func (p *dbConnectionPool) preferredConnection(key string) int {
+	hash := p.hashKey
+	if hash == nil {
+		hash = fnvKeyHash
+	}
+	return int(hash(key)%uint32(p.maxSize)) + 1
+}
+
+// GetForKey gets the connection key hashes to, so repeated requests for the
+// same key, such as one user's session, land on the same connection while it
+// is free. If that connection is checked out or hasn't been dialed yet it
+// falls back to any available connection, like getConnection.
+func (p *dbConnectionPool) GetForKey(key string) *dbConnection {
+	if conn := p.takeIdle(p.preferredConnection(key)); conn != nil {
+		return conn
+	}
+	conn, _ := p.getConnectionCtx(context.Background())
+	return conn
+}
+
+// takeIdle removes the idle connection with the given id from the pool, or
+// returns nil if it isn't idle. Idle connections live in a channel, so it
+// cycles through them and puts back the ones it passes over.
+func (p *dbConnectionPool) takeIdle(id int) *dbConnection {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	var found *dbConnection
+	for n := len(p.connections); n > 0; n-- {
+		var conn *dbConnection
+		select {
+		case conn = <-p.connections:
+		default:
+			// A concurrent getter took the rest
+		}
+		if conn == nil {
+			break
+		}
+		if found == nil && conn.id == id {
+			found = conn
+			continue
+		}
+		p.connections <- conn // never blocks: we just took a slot
+	}
+	if found != nil {
+		p.inUse++
+		found.lastUsed = time.Now()
+	}
+	return found
+}
+
+// runStickySessions sends requests for a few session keys through GetForKey
+func runStickySessions() {
+	pool := newDBConnectionPool(0, 4)
+	pool.Warmup(4)
+
+	sessions := []string{"alice", "bob", "carol", "dave", "erin"}
+	for round := 1; round <= 2; round++ {
+		for _, session := range sessions {
+			conn := pool.GetForKey(session)
+			fmt.Fprintf(output(), "Round %d: session %-5s -> connection %d\n", round, session, conn.id)
+			pool.releaseConnection(conn)
+		}
+	}
+
+	// Hold a session's connection so its next request has to fall back
+	held := pool.GetForKey("alice")
+	other := pool.GetForKey("alice")
+	fmt.Fprintf(output(), "Session alice's connection %d is busy, second request fell back to connection %d\n", held.id, other.id)
+	pool.releaseConnection(other)
+	pool.releaseConnection(held)
+
+	pool.close()
+}
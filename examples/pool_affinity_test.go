@@ -0,0 +1,43 @@
+package examples
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestStickySessions checks that a key maps to the same connection on every
+// call while that connection is free, and that a busy one falls back
+func TestStickySessions(t *testing.T) {
+	pool := newDBConnectionPool(0, 5)
+	pool.Warmup(5)
+	defer pool.close()
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("session-%d", i)
+		want := pool.preferredConnection(key)
+		for call := 1; call <= 3; call++ {
+			conn := pool.GetForKey(key)
+			id := conn.id
+			pool.releaseConnection(conn)
+			if id != want {
+				t.Fatalf("call %d for %s got connection %d, want %d", call, key, id, want)
+			}
+		}
+	}
+
+	held := pool.GetForKey("session-0")
+	other := pool.GetForKey("session-0")
+	pool.releaseConnection(other)
+	pool.releaseConnection(held)
+	if other.id == held.id {
+		t.Fatalf("busy connection %d was handed out twice", held.id)
+	}
+
+	// A custom hash is used as given
+	pool.hashKey = func(string) uint32 { return 2 }
+	conn := pool.GetForKey("anything")
+	defer pool.releaseConnection(conn)
+	if conn.id != 3 {
+		t.Fatalf("custom hash of 2 picked connection %d, want 3", conn.id)
+	}
+}
@@ -0,0 +1,61 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errBufferUnavailable is the setup failure the worker hook examples inject
+var errBufferUnavailable = errors.New("scratch buffer unavailable")
+
+// runJobPoolHooks gives each worker of a job pool a scratch buffer for its
+// lifetime; worker 2 can't get one, so the pool runs on the other two
+func runJobPoolHooks() {
+	fmt.Fprintln(output(), "\nPer-worker setup hooks (worker 2's setup fails):")
+	var mu sync.Mutex
+	buffers := make(map[int][]byte)
+	pool, err := newHookedJobPool(3, func(workerID, job int) string {
+		mu.Lock()
+		buf := buffers[workerID]
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		return fmt.Sprintf("Job %d completed by worker %d using its %dB buffer", job, workerID, len(buf))
+	}, func(workerID int) error {
+		if workerID == 2 {
+			return errBufferUnavailable
+		}
+		mu.Lock()
+		buffers[workerID] = make([]byte, 4096)
+		mu.Unlock()
+		fmt.Fprintf(output(), "Worker %d: allocated its buffer\n", workerID)
+		return nil
+	}, func(workerID int) {
+		mu.Lock()
+		delete(buffers, workerID)
+		mu.Unlock()
+		fmt.Fprintf(output(), "Worker %d: released its buffer\n", workerID)
+	})
+	if pool == nil {
+		fail("worker start hooks: %v", err)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(output(), "Startup failures: %v\n", err)
+	}
+	fmt.Fprintf(output(), "Pool running with %d of 3 workers\n", pool.Workers())
+
+	go func() {
+		for i := 1; i <= 6; i++ {
+			pool.submit(i)
+		}
+		pool.close()
+	}()
+	for result := range pool.results {
+		fmt.Fprintf(output(), "Result: %s\n", result)
+	}
+	if len(buffers) != 0 {
+		fail("worker start hooks: %d buffers not released", len(buffers))
+	}
+}
@@ -0,0 +1,73 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestJobPoolHooks fails onStart for one worker and checks the rest run
+// every job, the failure is reported, and onStop runs once for each worker
+// that started. A pool whose workers all fail to start is not returned.
+func TestJobPoolHooks(t *testing.T) {
+	var mu sync.Mutex
+	stopped := make(map[int]int)
+	pool, err := newHookedJobPool(4, func(workerID, job int) string {
+		return strconv.Itoa(workerID)
+	}, func(workerID int) error {
+		if workerID == 3 {
+			return errBufferUnavailable
+		}
+		return nil
+	}, func(workerID int) {
+		mu.Lock()
+		stopped[workerID]++
+		mu.Unlock()
+	})
+	if pool == nil {
+		t.Fatalf("no pool despite 3 workers starting: %v", err)
+	}
+	if !errors.Is(err, errBufferUnavailable) || !strings.Contains(err.Error(), "worker 3") {
+		t.Fatalf("startup error %v doesn't report worker 3's failure", err)
+	}
+	if pool.Workers() != 3 {
+		t.Fatalf("pool runs %d workers, want 3", pool.Workers())
+	}
+
+	goLabeled(t, "submitter", func() {
+		for i := 1; i <= 40; i++ {
+			pool.submit(i)
+		}
+		pool.close()
+	})
+	ran := 0
+	for result := range pool.results {
+		ran++
+		if result == "3" {
+			t.Fatalf("worker 3 ran a job after failing to start")
+		}
+	}
+	if ran != 40 {
+		t.Fatalf("%d of 40 jobs ran", ran)
+	}
+	var ids []int
+	for id, n := range stopped {
+		if n != 1 {
+			t.Fatalf("onStop ran %d times for worker %d", n, id)
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	if fmt.Sprint(ids) != "[1 2 4]" {
+		t.Fatalf("onStop ran for workers %v, want [1 2 4]", ids)
+	}
+
+	pool, err = newHookedJobPool(2, func(_, job int) string { return "" }, func(int) error { return errBufferUnavailable }, nil)
+	if pool != nil || !errors.Is(err, errBufferUnavailable) {
+		t.Fatalf("all workers failing to start returned pool %v, error %v", pool, err)
+	}
+}
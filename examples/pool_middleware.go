@@ -0,0 +1,127 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// JobFunc is a pool job that can be cancelled through its context
+type JobFunc func(ctx context.Context, job int) (string, error)
+
+// JobMiddleware wraps a JobFunc, so behavior like a deadline can be added to
+// every job without editing it
+type JobMiddleware func(next JobFunc) JobFunc
+
+// WithTimeout runs each job under a deadline of d. A job that overruns it
+// fails with an error wrapping ErrTimeout and context.DeadlineExceeded, even
+// if it ignored its context and finished anyway; a job that watches its
+// context is cut short at the deadline.
+func WithTimeout(d time.Duration) JobMiddleware {
+	return func(next JobFunc) JobFunc {
+		return func(ctx context.Context, job int) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			result, err := next(ctx, job)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", fmt.Errorf("job %d ran past its %v deadline: %w: %w", job, d, ErrTimeout, context.DeadlineExceeded)
+			}
+			return result, err
+		}
+	}
+}
+
+// ctxJobPool is a worker pool for context-aware jobs. Every job runs under
+// the pool's context, wrapped in its middleware, and reports a jobOutcome.
+// Once the context ends the workers stop taking jobs.
+type ctxJobPool struct {
+	ctx     context.Context
+	jobs    chan int
+	results chan jobOutcome
+	fn      JobFunc
+	wg      sync.WaitGroup
+	// lifecycles runs each worker's stop hooks before it counts as exited
+	lifecycles workerSet
+}
+
+// newCtxJobPool starts numWorkers workers running fn. Middleware wraps fn
+// first entry outermost; cancelling ctx cancels the running jobs.
+func newCtxJobPool(ctx context.Context, numWorkers int, fn JobFunc, middleware ...JobMiddleware) *ctxJobPool {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		fn = middleware[i](fn)
+	}
+	p := &ctxJobPool{
+		ctx:     ctx,
+		jobs:    make(chan int, numWorkers),
+		results: make(chan jobOutcome, numWorkers),
+		fn:      fn,
+	}
+	for i := 1; i <= numWorkers; i++ {
+		p.wg.Add(1)
+		go p.worker(i)
+	}
+	go func() {
+		p.wg.Wait()
+		close(p.results)
+	}()
+	return p
+}
+
+// submit queues a job. Once the pool's context has ended it fails instead,
+// with an error wrapping ErrPoolClosed.
+func (p *ctxJobPool) submit(job int) error {
+	select {
+	case p.jobs <- job:
+		return nil
+	case <-p.ctx.Done():
+		return fmt.Errorf("submit job %d: %w: %w", job, ErrPoolClosed, p.ctx.Err())
+	}
+}
+
+// close stops accepting jobs; results closes once the queue is drained
+func (p *ctxJobPool) close() {
+	close(p.jobs)
+}
+
+func (p *ctxJobPool) worker(id int) {
+	defer p.wg.Done()
+	p.lifecycles.started(id)
+	for {
+		select {
+		case <-p.ctx.Done():
+			p.lifecycles.stopped(id, Cancelled)
+			return
+		case job, ok := <-p.jobs:
+			if !ok {
+				p.lifecycles.stopped(id, Completed)
+				return
+			}
+			heartbeat()
+			result, err := p.fn(p.ctx, job)
+			p.results <- jobOutcome{Job: job, WorkerID: id, Result: result, Err: err}
+		}
+	}
+}
+
+// OnWorkerStart calls fn with the lifecycle of every worker in the pool, so
+// it can register OnStop cleanup
+func (p *ctxJobPool) OnWorkerStart(fn func(id int, w *WorkerLifecycle)) {
+	p.lifecycles.OnWorkerStart(fn)
+}
+
+// sleepJob simulates work that takes 30ms, except for the job slow, which
+// takes slowFor; both give up when ctx is done
+func sleepJob(slow int, slowFor time.Duration) JobFunc {
+	return func(ctx context.Context, job int) (string, error) {
+		work := 30 * time.Millisecond
+		if job == slow {
+			work = slowFor
+		}
+		if !sleepOrDone(work, ctx.Done()) {
+			return "", ctx.Err()
+		}
+		return fmt.Sprintf("Job %d done", job), nil
+	}
+}
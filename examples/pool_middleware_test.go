@@ -0,0 +1,41 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestPoolTimeout runs eight jobs under WithTimeout, one of which sleeps
+// past the deadline, and checks that only it reports a deadline error and
+// that it was cut short rather than left running. An outer, looser timeout
+// composed around the inner one changes nothing.
+func TestPoolTimeout(t *testing.T) {
+	pool := newCtxJobPool(context.Background(), 3, sleepJob(5, time.Second),
+		WithTimeout(time.Minute), WithTimeout(100*time.Millisecond))
+	began := time.Now()
+	go func() {
+		for i := 1; i <= 8; i++ {
+			pool.submit(i)
+		}
+		pool.close()
+	}()
+	succeeded := 0
+	for outcome := range pool.results {
+		switch {
+		case outcome.Job == 5 && !errors.Is(outcome.Err, context.DeadlineExceeded):
+			t.Fatalf("job 5 overran its deadline but reported %v", outcome.Err)
+		case outcome.Job != 5 && outcome.Err != nil:
+			t.Fatalf("job %d: %v", outcome.Job, outcome.Err)
+		case outcome.Job != 5:
+			succeeded++
+		}
+	}
+	if succeeded != 7 {
+		t.Fatalf("%d of 7 jobs within their deadline succeeded", succeeded)
+	}
+	if took := time.Since(began); took > 500*time.Millisecond {
+		t.Fatalf("pool took %v: the overrunning job was not cut short", took)
+	}
+}
@@ -0,0 +1,50 @@
+package examples
+
+import "sync/atomic"
+
+// poolResult is a worker's result tagged with its job's submission sequence
+type poolResult struct {
+	seq    int
+	result string
+}
+
+// newOrderedJobPool is newJobPool emitting results in submission order,
+// however the jobs' execution interleaves. At most maxPending jobs may be
+// submitted but not yet emitted: once that many are in flight or waiting on
+// an earlier slow job, submit blocks, so a slow job backpressures the
+// submitter instead of letting the reorder buffer grow.
+func newOrderedJobPool(numWorkers, maxPending int, fn func(workerID, job int) string) *jobPool {
+	p := allocJobPool(numWorkers, fn)
+	// The mode is set before any worker starts, since workers read it unlocked
+	p.window = NewSemaphore(maxPending)
+	p.completed = make(chan poolResult)
+
+	// Reorderer: hold early results until their predecessors arrive
+	go func() {
+		defer close(p.results)
+		pending := make(map[int]string)
+		next := 0
+		for r := range p.completed {
+			pending[r.seq] = r.result
+			for {
+				result, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				p.results <- result
+				p.window.Release()
+				next++
+			}
+		}
+	}()
+	// Without an onStart hook no worker can fail to start
+	pool, _ := p.start(numWorkers, nil)
+	return pool
+}
+
+// MaxPending returns the most jobs that were ever submitted but not yet
+// emitted in ordered mode
+func (p *jobPool) MaxPending() int {
+	return int(atomic.LoadInt64(&p.peakPending))
+}
@@ -0,0 +1,72 @@
+package examples
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestOrderedJobPool runs ordered pools with random job durations and with
+// the first job the slowest, checking that results come out strictly in
+// submission order and that the pending window is never exceeded
+func TestOrderedJobPool(t *testing.T) {
+	run := func(jobs, workers, window int, duration func(job int) time.Duration) ([]int, int) {
+		pool := newOrderedJobPool(workers, window, func(_, job int) string {
+			time.Sleep(duration(job))
+			return strconv.Itoa(job)
+		})
+		go func() {
+			for i := 1; i <= jobs; i++ {
+				pool.submit(i)
+			}
+			pool.close()
+		}()
+		var order []int
+		for r := range pool.results {
+			job, _ := strconv.Atoi(r)
+			order = append(order, job)
+		}
+		return order, pool.MaxPending()
+	}
+	inOrder := func(order []int, jobs int) error {
+		if len(order) != jobs {
+			return fmt.Errorf("%d of %d results emitted", len(order), jobs)
+		}
+		for i, job := range order {
+			if job != i+1 {
+				return fmt.Errorf("result %d is job %d: %v", i+1, job, order)
+			}
+		}
+		return nil
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	durations := make(map[int]time.Duration)
+	for i := 1; i <= 40; i++ {
+		durations[i] = time.Duration(rng.Intn(10)) * time.Millisecond
+	}
+	order, peak := run(40, 4, 6, func(job int) time.Duration { return durations[job] })
+	if err := inOrder(order, 40); err != nil {
+		t.Fatalf("random durations: %v", err)
+	}
+	if peak > 6 {
+		t.Fatalf("random durations: %d jobs pending, window 6", peak)
+	}
+
+	// The first job is the slowest: everything else waits behind it, and the
+	// window fills up rather than the buffer growing
+	order, peak = run(12, 3, 4, func(job int) time.Duration {
+		if job == 1 {
+			return 100 * time.Millisecond
+		}
+		return time.Millisecond
+	})
+	if err := inOrder(order, 12); err != nil {
+		t.Fatalf("slow first job: %v", err)
+	}
+	if peak != 4 {
+		t.Fatalf("slow first job: %d jobs pending at most, want the window of 4 to fill", peak)
+	}
+}
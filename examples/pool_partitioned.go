@@ -0,0 +1,285 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// shardLimits sizes one shard of a PartitionedPool: it is filled to Min when
+// the pool is created, idle reaping never takes it below Min, and it never
+// holds more than Max resources
+type shardLimits struct {
+	Min int
+	Max int
+}
+
+type idleResource[T any] struct {
+	item      T
+	idleSince time.Time
+}
+
+type poolShard[T any] struct {
+	limits shardLimits
+	idle   []idleResource[T] // oldest first
+	total  int
+	inUse  int
+	peak   int
+	waits  int
+}
+
+// PartitionedPool keeps a separate sub-pool per shard key (a hostname, say)
+// under one global cap on the resources held across all shards.
+//
+// When a shard is below its own Max but the global cap is reached, callers
+// queue for the next free global slot in arrival order, whichever shard they
+// want: a busy shard can't starve a quiet one. Idle resources are never moved
+// between shards, so a slot held by an idle resource only frees up once
+// ReapIdle closes it.
+type PartitionedPool[K comparable, T any] struct {
+	mu        sync.Mutex
+	shards    map[K]*poolShard[T]
+	limits    func(key K) shardLimits
+	dial      func(key K) T
+	globalMax int
+	total     int
+	peakTotal int
+	// queue holds the tickets of callers waiting for a global slot, oldest first
+	queue      []uint64
+	nextTicket uint64
+	// changed is closed and replaced whenever a resource or slot frees up
+	changed chan struct{}
+	closed  bool
+	// retries records GetWithRetry's acquisitions
+	retries acquireRecorder
+}
+
+// newPartitionedPool creates a pool holding at most globalMax resources. Each
+// key's shard is sized by limits and filled with dial; keys listed in warm get
+// their shards created and filled to Min up front, others on first use.
+func newPartitionedPool[K comparable, T any](globalMax int, limits func(key K) shardLimits, dial func(key K) T, warm ...K) *PartitionedPool[K, T] {
+	p := &PartitionedPool[K, T]{
+		shards:    make(map[K]*poolShard[T]),
+		limits:    limits,
+		dial:      dial,
+		globalMax: globalMax,
+		changed:   make(chan struct{}),
+	}
+	for _, key := range warm {
+		s := p.shard(key)
+		for s.total < s.limits.Min && p.total < p.globalMax {
+			s.idle = append(s.idle, idleResource[T]{item: dial(key), idleSince: time.Now()})
+			s.total++
+			p.total++
+		}
+		if s.total > s.peak {
+			s.peak = s.total
+		}
+	}
+	p.peakTotal = p.total
+	return p
+}
+
+// shard returns key's shard, creating it if needed; p.mu must be held
+func (p *PartitionedPool[K, T]) shard(key K) *poolShard[T] {
+	s, ok := p.shards[key]
+	if !ok {
+		s = &poolShard[T]{limits: p.limits(key)}
+		p.shards[key] = s
+	}
+	return s
+}
+
+// notify wakes every waiting Get to recheck the pool; p.mu must be held
+func (p *PartitionedPool[K, T]) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// dequeue removes ticket from the global wait queue; p.mu must be held
+func (p *PartitionedPool[K, T]) dequeue(ticket uint64) {
+	for i, t := range p.queue {
+		if t == ticket {
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			if i == 0 {
+				// The next caller in line may be able to go now
+				p.notify()
+			}
+			return
+		}
+	}
+}
+
+// Get takes an idle resource from key's shard, or dials a new one if both the
+// shard and the pool are under their caps, waiting otherwise. It fails with
+// ErrPoolClosed once the pool is closed and with ErrTimeout if ctx ends first.
+func (p *PartitionedPool[K, T]) Get(ctx context.Context, key K) (T, error) {
+	var zero T
+	p.mu.Lock()
+	s := p.shard(key)
+	ticket := p.nextTicket
+	p.nextTicket++
+	waited := false
+	for {
+		if p.closed {
+			p.dequeue(ticket)
+			p.mu.Unlock()
+			return zero, fmt.Errorf("get %v: %w", key, ErrPoolClosed)
+		}
+		if n := len(s.idle); n > 0 {
+			// Most recently used first, so reaping finds the stale ones
+			item := s.idle[n-1].item
+			s.idle = s.idle[:n-1]
+			s.inUse++
+			p.dequeue(ticket)
+			p.mu.Unlock()
+			return item, nil
+		}
+		if s.total < s.limits.Max {
+			if p.total < p.globalMax && (len(p.queue) == 0 || p.queue[0] == ticket) {
+				// Reserve the slot before dialing so concurrent gets can't overshoot
+				p.dequeue(ticket)
+				s.total++
+				s.inUse++
+				p.total++
+				if s.total > s.peak {
+					s.peak = s.total
+				}
+				if p.total > p.peakTotal {
+					p.peakTotal = p.total
+				}
+				p.mu.Unlock()
+				return p.dial(key), nil
+			}
+			if !p.queued(ticket) {
+				p.queue = append(p.queue, ticket)
+			}
+		} else {
+			// Only a release in this shard can help; don't hold up the queue
+			p.dequeue(ticket)
+		}
+		if !waited {
+			waited = true
+			s.waits++
+		}
+
+		changed := p.changed
+		p.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.dequeue(ticket)
+			p.mu.Unlock()
+			return zero, fmt.Errorf("get %v: %w: %w", key, ErrTimeout, ctx.Err())
+		}
+		p.mu.Lock()
+	}
+}
+
+// queued reports whether ticket is waiting for a global slot; p.mu must be held
+func (p *PartitionedPool[K, T]) queued(ticket uint64) bool {
+	for _, t := range p.queue {
+		if t == ticket {
+			return true
+		}
+	}
+	return false
+}
+
+// Put returns a resource taken from key's shard. After Close it is dropped.
+func (p *PartitionedPool[K, T]) Put(key K, item T) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.shard(key)
+	s.inUse--
+	if p.closed {
+		s.total--
+		p.total--
+		return
+	}
+	s.idle = append(s.idle, idleResource[T]{item: item, idleSince: time.Now()})
+	p.notify()
+}
+
+// ReapIdle closes resources that have sat idle for at least maxIdle, never
+// taking a shard below its Min, and returns how many it closed. The global
+// slots they held become available to every shard.
+func (p *PartitionedPool[K, T]) ReapIdle(maxIdle time.Duration) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reaped := 0
+	now := time.Now()
+	for _, s := range p.shards {
+		n := 0
+		for n < len(s.idle) && s.total-n > s.limits.Min && now.Sub(s.idle[n].idleSince) >= maxIdle {
+			n++
+		}
+		s.idle = s.idle[n:]
+		s.total -= n
+		p.total -= n
+		reaped += n
+	}
+	if reaped > 0 {
+		p.notify()
+	}
+	return reaped
+}
+
+// Close drops every idle resource and fails pending and future Gets
+func (p *PartitionedPool[K, T]) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, s := range p.shards {
+		s.total -= len(s.idle)
+		p.total -= len(s.idle)
+		s.idle = nil
+	}
+	p.notify()
+}
+
+// shardStats is a snapshot of one shard of a PartitionedPool
+type shardStats struct {
+	Min   int
+	Max   int
+	Total int
+	InUse int
+	Idle  int
+	Peak  int
+	// Waits counts Gets that had to wait for a resource or a global slot
+	Waits int
+}
+
+// partitionedStats is a consistent snapshot of a PartitionedPool
+type partitionedStats[K comparable] struct {
+	GlobalMax int
+	Total     int
+	PeakTotal int
+	Shards    map[K]shardStats
+}
+
+// Stats returns a snapshot of the pool and each of its shards
+func (p *PartitionedPool[K, T]) Stats() partitionedStats[K] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := partitionedStats[K]{
+		GlobalMax: p.globalMax,
+		Total:     p.total,
+		PeakTotal: p.peakTotal,
+		Shards:    make(map[K]shardStats, len(p.shards)),
+	}
+	for key, s := range p.shards {
+		stats.Shards[key] = shardStats{
+			Min:   s.limits.Min,
+			Max:   s.limits.Max,
+			Total: s.total,
+			InUse: s.inUse,
+			Idle:  len(s.idle),
+			Peak:  s.peak,
+			Waits: s.waits,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,56 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestPartitionedPool checks the per-shard cap, the global cap, and that
+// reaping idle resources in one shard frees global slots for another
+func TestPartitionedPool(t *testing.T) {
+	dials := 0
+	pool := newPartitionedPool(3,
+		func(string) shardLimits { return shardLimits{Min: 0, Max: 2} },
+		func(string) int { dials++; return dials })
+	defer pool.Close()
+
+	getWithin := func(key string, d time.Duration) (int, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), d)
+		defer cancel()
+		return pool.Get(ctx, key)
+	}
+
+	a1, _ := getWithin("a", time.Second)
+	a2, _ := getWithin("a", time.Second)
+	if _, err := getWithin("a", 30*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("third get on shard a with Max 2: got %v, want %v", err, ErrTimeout)
+	}
+	b1, err := getWithin("b", time.Second)
+	if err != nil {
+		t.Fatalf("shard b under both caps: %v", err)
+	}
+	if _, err := getWithin("b", 30*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("fourth resource past the global cap of 3: got %v, want %v", err, ErrTimeout)
+	}
+
+	// Shard a's resources go idle but still hold their global slots
+	pool.Put("a", a1)
+	pool.Put("a", a2)
+	if _, err := getWithin("c", 30*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("shard c while a's idle resources fill the pool: got %v, want %v", err, ErrTimeout)
+	}
+	if reaped := pool.ReapIdle(0); reaped != 2 {
+		t.Fatalf("reaped %d idle resources, want 2", reaped)
+	}
+	if _, err := getWithin("c", time.Second); err != nil {
+		t.Fatalf("shard c after reaping shard a: %v", err)
+	}
+	pool.Put("b", b1)
+
+	stats := pool.Stats()
+	if stats.PeakTotal > 3 || stats.Shards["a"].Peak > 2 {
+		t.Fatalf("peaks of %d overall and %d in shard a exceed the caps", stats.PeakTotal, stats.Shards["a"].Peak)
+	}
+}
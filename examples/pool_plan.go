@@ -0,0 +1,211 @@
+package examples
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// plannedJob is a queued job carrying an estimate of how long it runs.
+// Higher priorities are dispatched first when the pool is prioritized.
+type plannedJob struct {
+	ID       int
+	Estimate time.Duration
+	Priority int
+}
+
+// jobAssignment places one job on a worker, with start and end offsets from
+// the start of the run
+type jobAssignment struct {
+	Job    int
+	Worker int
+	Start  time.Duration
+	End    time.Duration
+}
+
+// poolSchedule is a run of a plannedPool, predicted by Plan or recorded by Run
+type poolSchedule struct {
+	Makespan time.Duration
+	// WorkerLoad is the total job time on each worker
+	WorkerLoad []time.Duration
+	// Assignments are in dispatch order
+	Assignments []jobAssignment
+	// Critical lists, in order, the jobs on the worker that finishes last:
+	// the ones that set the makespan
+	Critical []int
+}
+
+// plannedPool is a worker pool whose queue can be planned before it runs.
+// Workers pull jobs from one shared queue, so a job always goes to whichever
+// worker frees up first; Plan simulates exactly that with the estimates.
+type plannedPool struct {
+	workers     int
+	prioritized bool
+	jobs        []plannedJob
+}
+
+func newPlannedPool(workers int, prioritized bool) *plannedPool {
+	return &plannedPool{workers: workers, prioritized: prioritized}
+}
+
+// Queue adds a job to the pool's queue
+func (p *plannedPool) Queue(job plannedJob) {
+	p.jobs = append(p.jobs, job)
+}
+
+// dispatchOrder is the order workers take the queued jobs in: queue order, or
+// by descending priority (queue order among equals) when prioritized
+func (p *plannedPool) dispatchOrder() []plannedJob {
+	order := append([]plannedJob(nil), p.jobs...)
+	if p.prioritized {
+		sort.SliceStable(order, func(i, j int) bool { return order[i].Priority > order[j].Priority })
+	}
+	return order
+}
+
+// Plan predicts the run without executing anything: each job in dispatch
+// order goes to the earliest-free worker, lowest numbered on a tie, for its
+// estimated duration
+func (p *plannedPool) Plan() poolSchedule {
+	free := make([]time.Duration, p.workers)
+	s := poolSchedule{WorkerLoad: make([]time.Duration, p.workers)}
+	for _, job := range p.dispatchOrder() {
+		w := 0
+		for i := range free {
+			if free[i] < free[w] {
+				w = i
+			}
+		}
+		a := jobAssignment{Job: job.ID, Worker: w + 1, Start: free[w], End: free[w] + job.Estimate}
+		free[w] = a.End
+		s.WorkerLoad[w] += job.Estimate
+		s.Assignments = append(s.Assignments, a)
+		if a.End > s.Makespan {
+			s.Makespan = a.End
+		}
+	}
+	s.Critical = criticalJobs(s.Assignments, s.Makespan)
+	return s
+}
+
+// criticalJobs returns the jobs of the first worker to finish at makespan
+func criticalJobs(assignments []jobAssignment, makespan time.Duration) []int {
+	last := 0
+	for _, a := range assignments {
+		if a.End == makespan && (last == 0 || a.Worker < last) {
+			last = a.Worker
+		}
+	}
+	var jobs []int
+	for _, a := range assignments {
+		if a.Worker == last {
+			jobs = append(jobs, a.Job)
+		}
+	}
+	return jobs
+}
+
+// Run executes the queued jobs with exec on the pool's workers and records
+// what actually happened, in the same form as Plan's prediction
+func (p *plannedPool) Run(exec func(job plannedJob)) poolSchedule {
+	order := p.dispatchOrder()
+	queue := make(chan plannedJob, len(order))
+	for _, job := range order {
+		queue <- job
+	}
+	close(queue)
+
+	var mu sync.Mutex
+	s := poolSchedule{WorkerLoad: make([]time.Duration, p.workers)}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 1; w <= p.workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for job := range queue {
+				heartbeat()
+				began := time.Since(start)
+				exec(job)
+				a := jobAssignment{Job: job.ID, Worker: w, Start: began, End: time.Since(start)}
+
+				mu.Lock()
+				s.WorkerLoad[w-1] += a.End - a.Start
+				s.Assignments = append(s.Assignments, a)
+				if a.End > s.Makespan {
+					s.Makespan = a.End
+				}
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	sort.Slice(s.Assignments, func(i, j int) bool { return s.Assignments[i].Start < s.Assignments[j].Start })
+	s.Critical = criticalJobs(s.Assignments, s.Makespan)
+	return s
+}
+
+// planReport compares a plan with the run's actuals, makespan first and then
+// per worker
+func planReport(predicted, actual poolSchedule) []string {
+	lines := []string{fmt.Sprintf("Makespan: predicted %v, actual %v (%s)",
+		predicted.Makespan.Round(time.Millisecond), actual.Makespan.Round(time.Millisecond), estimateError(predicted.Makespan, actual.Makespan))}
+	for w := range predicted.WorkerLoad {
+		lines = append(lines, fmt.Sprintf("Worker %d load: predicted %v, actual %v (%s)", w+1,
+			predicted.WorkerLoad[w].Round(time.Millisecond), actual.WorkerLoad[w].Round(time.Millisecond), estimateError(predicted.WorkerLoad[w], actual.WorkerLoad[w])))
+	}
+	lines = append(lines, fmt.Sprintf("Critical jobs: predicted %v, actual %v", predicted.Critical, actual.Critical))
+	return lines
+}
+
+// estimateError formats how far actual was from predicted
+func estimateError(predicted, actual time.Duration) string {
+	if predicted == 0 {
+		return "no estimate"
+	}
+	return fmt.Sprintf("%+.0f%%", 100*float64(actual-predicted)/float64(predicted))
+}
+
+// runPoolPlan plans a queue of estimated jobs, runs it with durations that
+// stray from the estimates and prints predicted against actual
+func runPoolPlan() {
+	rng := rand.New(rand.NewSource(7))
+	estimates := []int{80, 20, 20, 60, 40, 10, 90, 30, 0, 50}
+	pool := newPlannedPool(3, true)
+	for i, ms := range estimates {
+		// The long jobs are urgent, so they are dispatched first
+		priority := 0
+		if ms >= 60 {
+			priority = 1
+		}
+		pool.Queue(plannedJob{ID: i + 1, Estimate: time.Duration(ms) * time.Millisecond, Priority: priority})
+	}
+
+	plan := pool.Plan()
+	fmt.Fprintf(output(), "Plan for %d jobs on 3 workers: makespan %v, critical jobs %v\n", len(estimates), plan.Makespan, plan.Critical)
+	for _, a := range plan.Assignments {
+		fmt.Fprintf(output(), "  job %2d -> worker %d at %v-%v\n", a.Job, a.Worker, a.Start, a.End)
+	}
+
+	// Each job really takes between half and one and a half times its estimate
+	actualFor := make(map[int]time.Duration)
+	for _, job := range pool.jobs {
+		actualFor[job.ID] = time.Duration(float64(job.Estimate) * (0.5 + rng.Float64()))
+	}
+	actual := pool.Run(func(job plannedJob) { time.Sleep(actualFor[job.ID]) })
+
+	fmt.Fprintln(output(), "Predicted vs actual:")
+	for _, line := range planReport(plan, actual) {
+		fmt.Fprintf(output(), "  %s\n", line)
+	}
+	record("plan", map[string]interface{}{
+		"predicted_makespan_ms": plan.Makespan.Milliseconds(),
+		"actual_makespan_ms":    actual.Makespan.Milliseconds(),
+	})
+	if len(actual.Assignments) != len(estimates) {
+		fail("pool plan: ran %d of %d jobs", len(actual.Assignments), len(estimates))
+	}
+}
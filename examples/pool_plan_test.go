@@ -0,0 +1,78 @@
+package examples
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPoolPlan checks the simulator against schedules worked out by hand,
+// and that a real run produces the comparison report
+func TestPoolPlan(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	cases := []struct {
+		name        string
+		workers     int
+		prioritized bool
+		jobs        []plannedJob
+		makespan    time.Duration
+		load        []time.Duration
+		critical    []int
+	}{
+		{
+			// 1 and 2 start at 0; 3 follows 2 at 2; 4 follows 1 at 3
+			name: "more jobs than workers", workers: 2,
+			jobs:     []plannedJob{{1, ms(3), 0}, {2, ms(2), 0}, {3, ms(2), 0}, {4, ms(1), 0}},
+			makespan: ms(4), load: []time.Duration{ms(4), ms(4)}, critical: []int{1, 4},
+		},
+		{
+			// Zero-length jobs leave worker 1 free at 0, so it takes 1, 2 and 3
+			name: "zero estimates", workers: 3,
+			jobs:     []plannedJob{{1, 0, 0}, {2, 0, 0}, {3, ms(5), 0}, {4, 0, 0}, {5, ms(1), 0}},
+			makespan: ms(5), load: []time.Duration{ms(5), ms(1), 0}, critical: []int{1, 2, 3},
+		},
+		{
+			// 3 and 4 go first: 3 on worker 1 until 3, 4 on worker 2 until
+			// 2, then 1 on worker 2 until 6 and 2 on worker 1 until 4
+			name: "priority", workers: 2, prioritized: true,
+			jobs:     []plannedJob{{1, ms(4), 0}, {2, ms(1), 0}, {3, ms(3), 5}, {4, ms(2), 5}},
+			makespan: ms(6), load: []time.Duration{ms(4), ms(6)}, critical: []int{4, 1},
+		},
+		{
+			name: "no priority", workers: 2,
+			jobs:     []plannedJob{{1, ms(4), 0}, {2, ms(1), 0}, {3, ms(3), 5}, {4, ms(2), 5}},
+			makespan: ms(6), load: []time.Duration{ms(6), ms(4)}, critical: []int{1, 4},
+		},
+	}
+	for _, c := range cases {
+		pool := newPlannedPool(c.workers, c.prioritized)
+		for _, job := range c.jobs {
+			pool.Queue(job)
+		}
+		plan := pool.Plan()
+		if plan.Makespan != c.makespan {
+			t.Fatalf("%s: makespan %v, want %v", c.name, plan.Makespan, c.makespan)
+		}
+		if fmt.Sprint(plan.WorkerLoad) != fmt.Sprint(c.load) {
+			t.Fatalf("%s: worker load %v, want %v", c.name, plan.WorkerLoad, c.load)
+		}
+		if fmt.Sprint(plan.Critical) != fmt.Sprint(c.critical) {
+			t.Fatalf("%s: critical jobs %v, want %v", c.name, plan.Critical, c.critical)
+		}
+	}
+
+	pool := newPlannedPool(2, false)
+	for i := 1; i <= 4; i++ {
+		pool.Queue(plannedJob{ID: i, Estimate: ms(10)})
+	}
+	plan := pool.Plan()
+	actual := pool.Run(func(job plannedJob) { time.Sleep(job.Estimate) })
+	if len(actual.Assignments) != 4 {
+		t.Fatalf("real run recorded %d of 4 jobs", len(actual.Assignments))
+	}
+	report := planReport(plan, actual)
+	if len(report) != 4 || !strings.HasPrefix(report[0], "Makespan: predicted 20ms, actual ") {
+		t.Fatalf("unexpected comparison report %q", report)
+	}
+}
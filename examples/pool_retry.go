@@ -0,0 +1,191 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BackoffPolicy is how GetWithRetry retries an exhausted pool. Each attempt
+// waits up to AttemptTimeout for a resource; between attempts it sleeps a
+// full-jitter delay, chosen uniformly from zero up to Base doubled for every
+// attempt so far and capped at Max. It gives up after MaxAttempts attempts.
+type BackoffPolicy struct {
+	Base           time.Duration
+	Max            time.Duration
+	MaxAttempts    int
+	AttemptTimeout time.Duration
+	// Seed makes the jitter reproducible
+	Seed int64
+}
+
+// delayCeiling is the most the wait after the given attempt, counted from 1,
+// may be
+func (p BackoffPolicy) delayCeiling(attempt int) time.Duration {
+	return (&ExponentialBackoff{Base: p.Base, Max: p.Max, Factor: 2}).Next(attempt)
+}
+
+// retryClock is the time source of a retry loop; tests swap in a fake one
+type retryClock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// acquireStats describes the acquisitions a pool made through GetWithRetry
+type acquireStats struct {
+	// Attempts counts successful acquisitions by how many attempts they took
+	Attempts map[int]int
+	// WaitHistogram counts them by total wait, in latencyBucket buckets
+	WaitHistogram [latencyBuckets]int
+	TotalWait     time.Duration
+	GaveUp        int
+}
+
+// acquireRecorder keeps a pool's acquireStats
+type acquireRecorder struct {
+	mu    sync.Mutex
+	stats acquireStats
+}
+
+func (r *acquireRecorder) acquired(attempts int, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stats.Attempts == nil {
+		r.stats.Attempts = make(map[int]int)
+	}
+	r.stats.Attempts[attempts]++
+	r.stats.WaitHistogram[latencyBucket(wait)]++
+	r.stats.TotalWait += wait
+}
+
+func (r *acquireRecorder) gaveUp() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.GaveUp++
+}
+
+// snapshot returns a copy of the stats
+func (r *acquireRecorder) snapshot() acquireStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats
+	s.Attempts = make(map[int]int, len(r.stats.Attempts))
+	for n, count := range r.stats.Attempts {
+		s.Attempts[n] = count
+	}
+	return s
+}
+
+// retryAcquire calls get until it succeeds, backing off between attempts as
+// policy says. Only ErrTimeout, an attempt that found the pool exhausted, is
+// retried. It gives up with ErrTimeout once ctx is done, even mid-backoff, or
+// with ErrRetriesExhausted once MaxAttempts attempts have failed, and returns
+// the attempts made.
+func retryAcquire[T any](ctx context.Context, policy BackoffPolicy, clock retryClock, rec *acquireRecorder, get func(ctx context.Context) (T, error)) (T, int, error) {
+	var zero T
+	rng := rand.New(rand.NewSource(policy.Seed))
+	start := clock.Now()
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+		}
+		v, err := get(attemptCtx)
+		cancel()
+		if err == nil {
+			rec.acquired(attempt, clock.Now().Sub(start))
+			return v, attempt, nil
+		}
+		if !errors.Is(err, ErrTimeout) {
+			return zero, attempt, err
+		}
+		if ctx.Err() != nil {
+			rec.gaveUp()
+			return zero, attempt, err
+		}
+		if attempt >= policy.MaxAttempts {
+			rec.gaveUp()
+			return zero, attempt, &ErrRetriesExhausted{Attempts: attempt, Last: err}
+		}
+
+		delay := time.Duration(rng.Int63n(int64(policy.delayCeiling(attempt)) + 1))
+		select {
+		case <-clock.After(delay):
+		case <-ctx.Done():
+			rec.gaveUp()
+			return zero, attempt, fmt.Errorf("backing off after attempt %d: %w: %w", attempt, ErrTimeout, ctx.Err())
+		}
+	}
+}
+
+// GetWithRetry gets a connection, retrying with jittered exponential backoff
+// while the pool is exhausted, and records how many attempts and how long it
+// took in the pool's RetryStats
+func (p *dbConnectionPool) GetWithRetry(ctx context.Context, policy BackoffPolicy) (*dbConnection, error) {
+	conn, _, err := retryAcquire(ctx, policy, realClock{}, &p.retries, p.getConnectionCtx)
+	return conn, err
+}
+
+// RetryStats describes the pool's GetWithRetry acquisitions
+func (p *dbConnectionPool) RetryStats() acquireStats {
+	return p.retries.snapshot()
+}
+
+// GetWithRetry is Get with the backoff of dbConnectionPool.GetWithRetry
+func (p *PartitionedPool[K, T]) GetWithRetry(ctx context.Context, key K, policy BackoffPolicy) (T, error) {
+	item, _, err := retryAcquire(ctx, policy, realClock{}, &p.retries, func(ctx context.Context) (T, error) {
+		return p.Get(ctx, key)
+	})
+	return item, err
+}
+
+// RetryStats describes the pool's GetWithRetry acquisitions
+func (p *PartitionedPool[K, T]) RetryStats() acquireStats {
+	return p.retries.snapshot()
+}
+
+// printAttempts prints how many acquisitions took each number of attempts
+func printAttempts(s acquireStats) {
+	attempts := make([]int, 0, len(s.Attempts))
+	for n := range s.Attempts {
+		attempts = append(attempts, n)
+	}
+	sort.Ints(attempts)
+	for _, n := range attempts {
+		fmt.Fprintf(output(), "  %d attempt(s): %d acquisitions\n", n, s.Attempts[n])
+	}
+	fmt.Fprintf(output(), "  gave up: %d, total wait %v\n", s.GaveUp, s.TotalWait.Round(time.Millisecond))
+}
+
+// fakeRetryClock records the delays it is asked for and fires each at once,
+// unless block is set, in which case it never fires
+type fakeRetryClock struct {
+	now    time.Time
+	delays []time.Duration
+	block  bool
+	asked  chan struct{}
+}
+
+func (c *fakeRetryClock) Now() time.Time { return c.now }
+
+func (c *fakeRetryClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	ch := make(chan time.Time, 1)
+	if c.asked != nil {
+		c.asked <- struct{}{}
+	}
+	if !c.block {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch
+}
@@ -0,0 +1,102 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestGetWithRetry uses a fake clock to check the jittered delays stay in
+// bounds, attempts stop at the maximum, a cancel mid-backoff ends the wait,
+// and a success is recorded with its attempts and wait
+func TestGetWithRetry(t *testing.T) {
+	policy := BackoffPolicy{Base: 10 * time.Millisecond, Max: 80 * time.Millisecond, MaxAttempts: 7, Seed: 1}
+	exhausted := func(context.Context) (int, error) { return 0, fmt.Errorf("get: %w", ErrTimeout) }
+
+	// Always exhausted: every delay is within its ceiling, and it stops at 7
+	clock := &fakeRetryClock{}
+	var rec acquireRecorder
+	calls := 0
+	_, attempts, err := retryAcquire(context.Background(), policy, clock, &rec, func(ctx context.Context) (int, error) {
+		calls++
+		return exhausted(ctx)
+	})
+	var retries *ErrRetriesExhausted
+	if attempts != 7 || calls != 7 || !errors.As(err, &retries) || retries.Attempts != 7 || !errors.Is(err, ErrTimeout) {
+		t.Fatalf("exhausted pool: %d attempts, %d calls, error %v; want 7, 7 and ErrRetriesExhausted wrapping ErrTimeout", attempts, calls, err)
+	}
+	if len(clock.delays) != 6 {
+		t.Fatalf("waited %d times between 7 attempts", len(clock.delays))
+	}
+	for i, d := range clock.delays {
+		if ceiling := policy.delayCeiling(i + 1); d < 0 || d > ceiling {
+			t.Fatalf("delay after attempt %d is %v, want 0 to %v", i+1, d, ceiling)
+		}
+	}
+	if s := rec.snapshot(); s.GaveUp != 1 || len(s.Attempts) != 0 {
+		t.Fatalf("stats after giving up: %+v", s)
+	}
+
+	// Jitter spreads the delays: over many draws they cover the range
+	var low, high bool
+	for seed := int64(0); seed < 200; seed++ {
+		p := policy
+		p.Seed, p.MaxAttempts = seed, 2
+		c := &fakeRetryClock{}
+		retryAcquire(context.Background(), p, c, &acquireRecorder{}, exhausted)
+		low = low || c.delays[0] < policy.Base/4
+		high = high || c.delays[0] > policy.Base*3/4
+	}
+	if !low || !high {
+		t.Fatalf("first delays over 200 seeds don't spread across 0 to %v", policy.Base)
+	}
+
+	// Cancelled while backing off: the wait ends without another attempt
+	ctx, cancel := context.WithCancel(context.Background())
+	blocked := &fakeRetryClock{block: true, asked: make(chan struct{}, 1)}
+	calls = 0
+	done := make(chan error, 1)
+	goLabeled(t, "backing-off-acquire", func() {
+		_, _, err := retryAcquire(ctx, policy, blocked, &acquireRecorder{}, func(ctx context.Context) (int, error) {
+			calls++
+			return exhausted(ctx)
+		})
+		done <- err
+	})
+	requireReceives(t, blocked.asked, 1, time.Second)
+	cancel()
+	if err := requireReceives(t, done, 1, time.Second)[0]; !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("cancel mid-backoff: %d calls, error %v; want 1 and context.Canceled", calls, err)
+	}
+
+	// Succeeds on the third attempt: recorded with its fake-clock wait
+	clock = &fakeRetryClock{}
+	rec = acquireRecorder{}
+	calls = 0
+	v, attempts, err := retryAcquire(context.Background(), policy, clock, &rec, func(ctx context.Context) (int, error) {
+		calls++
+		if calls < 3 {
+			return exhausted(ctx)
+		}
+		return 42, nil
+	})
+	if v != 42 || attempts != 3 || err != nil {
+		t.Fatalf("third-time success returned %d after %d attempts, error %v", v, attempts, err)
+	}
+	s := rec.snapshot()
+	if s.Attempts[3] != 1 || s.TotalWait != clock.delays[0]+clock.delays[1] {
+		t.Fatalf("recorded %v attempts and %v wait, want one 3-attempt acquisition waiting %v", s.Attempts, s.TotalWait, clock.delays[0]+clock.delays[1])
+	}
+
+	// Errors other than exhaustion aren't retried
+	calls = 0
+	_, _, err = retryAcquire(context.Background(), policy, &fakeRetryClock{}, &acquireRecorder{}, func(context.Context) (int, error) {
+		calls++
+		return 0, ErrPoolClosed
+	})
+	if calls != 1 || !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("closed pool: %d calls, error %v", calls, err)
+	}
+}
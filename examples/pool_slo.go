@@ -0,0 +1,256 @@
+package examples
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLOPool is a worker pool that sizes itself to a p99 latency target. A job's
+// latency runs from submit to completion, so it includes time spent queued.
+// Every interval a control loop takes the p99 of the jobs completed since
+// its last look: above the target it adds a worker (up to max), and under
+// half the target it sheds one (down to min).
+type SLOPool struct {
+	min, max  int
+	target    time.Duration
+	interval  time.Duration
+	fn        func(job int)
+	jobs      chan sloJob
+	shed      chan chan struct{}
+	stop      chan struct{}
+	workersWg sync.WaitGroup
+	controlWg sync.WaitGroup
+	// lifecycles runs each worker's stop hooks before it counts as exited
+	lifecycles workerSet
+
+	mu        sync.Mutex
+	workers   int
+	nextID    int
+	latencies []time.Duration
+	// history is the worker count after each adjustment, starting at min
+	history []int
+}
+
+// sloJob is a job with the time it was submitted
+type sloJob struct {
+	job       int
+	submitted time.Time
+}
+
+// NewSLOPool starts min workers running fn and the control loop that
+// defends targetP99
+func NewSLOPool(min, max int, targetP99 time.Duration, fn func(job int)) *SLOPool {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	p := &SLOPool{
+		min:      min,
+		max:      max,
+		target:   targetP99,
+		interval: 50 * time.Millisecond,
+		fn:       fn,
+		jobs:     make(chan sloJob),
+		shed:     make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		history:  []int{min},
+	}
+	for i := 0; i < min; i++ {
+		p.startWorker()
+	}
+	p.controlWg.Add(1)
+	go p.control()
+	return p
+}
+
+// startWorker adds a worker and counts it in p.workers
+func (p *SLOPool) startWorker() {
+	p.mu.Lock()
+	p.workers++
+	p.nextID++
+	id := p.nextID
+	p.mu.Unlock()
+	p.workersWg.Add(1)
+	go func() {
+		defer p.workersWg.Done()
+		p.lifecycles.started(id)
+		for {
+			select {
+			case retired := <-p.shed:
+				p.lifecycles.stopped(id, Retired)
+				close(retired)
+				return
+			case j, ok := <-p.jobs:
+				if !ok {
+					p.lifecycles.stopped(id, Completed)
+					return
+				}
+				heartbeat()
+				p.fn(j.job)
+				p.mu.Lock()
+				p.latencies = append(p.latencies, time.Since(j.submitted))
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// control adjusts the worker count every interval until the pool closes
+func (p *SLOPool) control() {
+	defer p.controlWg.Done()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		samples := p.latencies
+		p.latencies = nil
+		workers := p.workers
+		p.mu.Unlock()
+		if len(samples) == 0 {
+			continue
+		}
+		p99 := percentile(samples, 0.99)
+
+		switch {
+		case p99 > p.target && workers < p.max:
+			p.startWorker()
+			fmt.Fprintf(output(), "SLO pool: p99 %v over target %v, adding a worker (%d -> %d)\n",
+				p99.Round(time.Millisecond), p.target, workers, workers+1)
+		case p99 < p.target/2 && workers > p.min:
+			p.retireWorker()
+			fmt.Fprintf(output(), "SLO pool: p99 %v well under target %v, shedding a worker (%d -> %d)\n",
+				p99.Round(time.Millisecond), p.target, workers, workers-1)
+		default:
+			continue
+		}
+		p.mu.Lock()
+		p.history = append(p.history, p.workers)
+		p.mu.Unlock()
+	}
+}
+
+// retireWorker has one worker exit once it is between jobs, and waits for
+// its stop hooks before counting it gone
+func (p *SLOPool) retireWorker() {
+	retired := make(chan struct{})
+	p.shed <- retired
+	<-retired
+	p.mu.Lock()
+	p.workers--
+	p.mu.Unlock()
+}
+
+// OnWorkerStart calls fn with the lifecycle of every worker the pool runs,
+// so it can register OnStop cleanup
+func (p *SLOPool) OnWorkerStart(fn func(id int, w *WorkerLifecycle)) {
+	p.lifecycles.OnWorkerStart(fn)
+}
+
+// Submit queues a job, blocking until a worker takes it
+func (p *SLOPool) Submit(job int) {
+	p.jobs <- sloJob{job: job, submitted: time.Now()}
+}
+
+// Close stops accepting jobs and waits for the workers and control loop
+func (p *SLOPool) Close() {
+	close(p.stop)
+	p.controlWg.Wait()
+	close(p.jobs)
+	p.workersWg.Wait()
+}
+
+// Workers returns the current worker count
+func (p *SLOPool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.workers
+}
+
+// History returns the worker count after each adjustment, starting at min
+func (p *SLOPool) History() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]int(nil), p.history...)
+}
+
+// percentile returns the q-th quantile (0 < q <= 1) of samples, sorting them
+func percentile(samples []time.Duration, q float64) time.Duration {
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(float64(len(samples))*q+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(samples) {
+		i = len(samples) - 1
+	}
+	return samples[i]
+}
+
+// sloPoolReport is the worker count through an SLO pool run
+type sloPoolReport struct {
+	// Peak is the most workers at the end of any phase
+	Peak int
+	// Final is the worker count once latency had fallen again
+	Final   int
+	History []int
+}
+
+// runSLOPool submits a job every 5ms to an SLOPool while the jobs' own
+// latency rises from 2ms to 20ms and falls back again
+func runSLOPool(target time.Duration) sloPoolReport {
+	var mu sync.Mutex
+	work := 2 * time.Millisecond
+	pool := NewSLOPool(1, 8, target, func(int) {
+		mu.Lock()
+		d := work
+		mu.Unlock()
+		time.Sleep(d)
+	})
+	phases := []struct {
+		work time.Duration
+		jobs int
+	}{{2 * time.Millisecond, 60}, {20 * time.Millisecond, 200}, {2 * time.Millisecond, 150}}
+	var report sloPoolReport
+	job := 0
+	for _, phase := range phases {
+		fmt.Fprintf(output(), "SLO pool: jobs now take %v\n", phase.work)
+		mu.Lock()
+		work = phase.work
+		mu.Unlock()
+		for i := 0; i < phase.jobs; i++ {
+			job++
+			pool.Submit(job)
+			time.Sleep(5 * time.Millisecond)
+		}
+		if n := pool.Workers(); n > report.Peak {
+			report.Peak = n
+		}
+	}
+	report.Final = pool.Workers()
+	pool.Close()
+	report.History = pool.History()
+	return report
+}
+
+// defended checks that the pool grew to defend its SLO while jobs were slow
+// and shed workers once they sped up again
+func (r sloPoolReport) defended() error {
+	// 20ms jobs every 5ms need at least four workers
+	if r.Peak < 4 {
+		return fmt.Errorf("pool peaked at %d workers under rising latency (history %v), want at least 4", r.Peak, r.History)
+	}
+	if r.Final >= r.Peak {
+		return fmt.Errorf("pool kept %d workers after latency fell (history %v)", r.Final, r.History)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package examples
+
+import (
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSLOPoolGrowsUnderRisingLatency submits a job every 5ms while each
+// job's latency climbs from 2ms to 20ms, and checks the pool adds enough
+// workers to keep up, then sheds some once jobs are fast again
+func TestSLOPoolGrowsUnderRisingLatency(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	var work int64 = int64(2 * time.Millisecond)
+	pool := NewSLOPool(1, 8, 25*time.Millisecond, func(int) {
+		time.Sleep(time.Duration(atomic.LoadInt64(&work)))
+	})
+	defer pool.Close()
+
+	job := 0
+	submit := func(n int) {
+		for i := 0; i < n; i++ {
+			job++
+			pool.Submit(job)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	for ms := 2; ms <= 20; ms += 3 {
+		atomic.StoreInt64(&work, int64(time.Duration(ms)*time.Millisecond))
+		submit(25)
+	}
+	// 20ms jobs every 5ms need at least four workers
+	submit(50)
+	peak := pool.Workers()
+	history := pool.History()
+	if peak < 4 {
+		t.Fatalf("%d workers after latency rose to 20ms (history %v), want at least 4", peak, history)
+	}
+
+	atomic.StoreInt64(&work, int64(time.Millisecond))
+	submit(50)
+	if n := pool.Workers(); n >= peak {
+		t.Fatalf("pool kept %d workers after jobs sped up again (history %v)", n, pool.History())
+	}
+}
@@ -0,0 +1,192 @@
+package examples
+
+import (
+	"sync"
+	"time"
+)
+
+// tenantJob is a job submitted on behalf of one tenant
+type tenantJob struct {
+	ID       int
+	TenantID string
+	Work     time.Duration
+
+	submitted time.Time
+}
+
+// tenantStats is what the pool saw of one tenant's jobs
+type tenantStats struct {
+	Jobs         int
+	TotalWait    time.Duration
+	PeakInFlight int
+}
+
+// AverageWait is the mean time the tenant's jobs queued before starting
+func (s tenantStats) AverageWait() time.Duration {
+	if s.Jobs == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Jobs)
+}
+
+// tenantPool shares a fixed set of workers between tenants without letting
+// one of them take all of the workers. A dispatcher goroutine keeps a FIFO
+// queue per tenant and hands jobs to the workers round-robin across tenants,
+// skipping any tenant that already has capPerTenant jobs running; that
+// tenant's excess waits in its own queue while other tenants' jobs proceed.
+type tenantPool struct {
+	capPerTenant int
+	submits      chan tenantJob
+	work         chan tenantJob
+	finished     chan tenantJob
+	wg           sync.WaitGroup
+
+	mu    sync.Mutex
+	stats map[string]*tenantStats
+}
+
+func newTenantPool(numWorkers, capPerTenant int) *tenantPool {
+	if capPerTenant < 1 {
+		capPerTenant = 1
+	}
+	p := &tenantPool{
+		capPerTenant: capPerTenant,
+		submits:      make(chan tenantJob),
+		work:         make(chan tenantJob),
+		finished:     make(chan tenantJob, numWorkers),
+		stats:        make(map[string]*tenantStats),
+	}
+	for i := 0; i < numWorkers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	go p.dispatch()
+	return p
+}
+
+func (p *tenantPool) submit(job tenantJob) {
+	job.submitted = time.Now()
+	p.submits <- job
+}
+
+// close stops accepting jobs and waits for every queued job to finish
+func (p *tenantPool) close() {
+	close(p.submits)
+	p.wg.Wait()
+}
+
+// dispatch owns the queues and in-flight counts, so none of them need a lock
+func (p *tenantPool) dispatch() {
+	defer close(p.work)
+
+	queues := make(map[string][]tenantJob)
+	running := make(map[string]int)
+	var tenants []string // in order of first submission, for round-robin
+	nextTenant := 0
+	queued, inFlight := 0, 0
+	submits := p.submits
+
+	for submits != nil || queued > 0 || inFlight > 0 {
+		// Find the next tenant, round-robin, with a queued job and spare cap
+		var work chan tenantJob
+		var next tenantJob
+		pick := -1
+		for i := range tenants {
+			t := (nextTenant + i) % len(tenants)
+			tenant := tenants[t]
+			if len(queues[tenant]) > 0 && running[tenant] < p.capPerTenant {
+				pick, next, work = t, queues[tenant][0], p.work
+				break
+			}
+		}
+
+		select {
+		case job, ok := <-submits:
+			if !ok {
+				submits = nil
+				continue
+			}
+			if _, seen := queues[job.TenantID]; !seen {
+				tenants = append(tenants, job.TenantID)
+			}
+			queues[job.TenantID] = append(queues[job.TenantID], job)
+			queued++
+
+		case work <- next:
+			queues[next.TenantID] = queues[next.TenantID][1:]
+			queued--
+			running[next.TenantID]++
+			inFlight++
+			nextTenant = pick + 1
+			p.started(next, running[next.TenantID])
+
+		case job := <-p.finished:
+			running[job.TenantID]--
+			inFlight--
+		}
+	}
+}
+
+// started records a job leaving its queue with inFlight of its tenant's jobs running
+func (p *tenantPool) started(job tenantJob, inFlight int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats[job.TenantID]
+	if s == nil {
+		s = &tenantStats{}
+		p.stats[job.TenantID] = s
+	}
+	s.Jobs++
+	s.TotalWait += time.Since(job.submitted)
+	if inFlight > s.PeakInFlight {
+		s.PeakInFlight = inFlight
+	}
+}
+
+func (p *tenantPool) worker() {
+	defer p.wg.Done()
+	for job := range p.work {
+		heartbeat()
+		// Simulate the job's work
+		time.Sleep(job.Work)
+		p.finished <- job
+	}
+}
+
+// Stats returns a snapshot of every tenant's stats
+func (p *tenantPool) Stats() map[string]tenantStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]tenantStats, len(p.stats))
+	for tenant, s := range p.stats {
+		out[tenant] = *s
+	}
+	return out
+}
+
+// runTenantFlood runs the pools example's tenant mix through a 4-worker
+// tenantPool with the given cap: bulk submits 16 100ms jobs at once while
+// alpha and beta each submit a 50ms job every 80ms, five in all
+func runTenantFlood(capPerTenant int) map[string]tenantStats {
+	tenants := newTenantPool(4, capPerTenant)
+	var submitters sync.WaitGroup
+	submitters.Add(3)
+	go func() {
+		defer submitters.Done()
+		for i := 1; i <= 16; i++ {
+			tenants.submit(tenantJob{ID: i, TenantID: "bulk", Work: 100 * time.Millisecond})
+		}
+	}()
+	for _, tenant := range []string{"alpha", "beta"} {
+		go func(tenant string) {
+			defer submitters.Done()
+			for i := 1; i <= 5; i++ {
+				time.Sleep(80 * time.Millisecond)
+				tenants.submit(tenantJob{ID: i, TenantID: tenant, Work: 50 * time.Millisecond})
+			}
+		}(tenant)
+	}
+	submitters.Wait()
+	tenants.close()
+	return tenants.Stats()
+}
@@ -0,0 +1,36 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTenantPoolCaps floods the tenant pool from one tenant while two light
+// tenants trickle in, and checks no tenant ever runs more jobs at once than
+// its cap, that the flood does fill its cap, and that with a cap below the
+// worker count the light tenants barely wait. The wait bound is several
+// times what they see in practice, while without the cap they queue behind
+// the flood for most of a 100ms job.
+func TestTenantPoolCaps(t *testing.T) {
+	for _, capPerTenant := range []int{2, 4} {
+		stats := runTenantFlood(capPerTenant)
+		for tenant, s := range stats {
+			if s.PeakInFlight > capPerTenant {
+				t.Fatalf("cap %d: %s had %d jobs in flight", capPerTenant, tenant, s.PeakInFlight)
+			}
+		}
+		if stats["bulk"].Jobs != 16 || stats["alpha"].Jobs != 5 || stats["beta"].Jobs != 5 {
+			t.Fatalf("cap %d: ran %+v, want 16 bulk and 5 each for alpha and beta", capPerTenant, stats)
+		}
+		if peak := stats["bulk"].PeakInFlight; peak != capPerTenant {
+			t.Fatalf("cap %d: the flood peaked at %d in flight, want it to fill its cap", capPerTenant, peak)
+		}
+		if capPerTenant < 4 {
+			for _, tenant := range []string{"alpha", "beta"} {
+				if wait := stats[tenant].AverageWait(); wait > 40*time.Millisecond {
+					t.Fatalf("cap %d: light tenant %s waited %v on average during the flood", capPerTenant, tenant, wait)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package examples
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hillClimber picks a pool size from the throughput measured at each size,
+// one control interval at a time. From the size it is at it tries one more
+// worker and keeps it if throughput rose by more than noise (a fraction),
+// climbing on while that holds; if the first step up doesn't help it reverts
+// and tries one fewer the same way. Once a step in either direction fails it
+// reverts and settles. Settled, it probes again only if throughput drifts
+// more than twice noise from a running average of what it has measured
+// since, so under a steady load it stops moving instead of oscillating
+// around the optimum.
+type hillClimber struct {
+	min, max int
+	noise    float64
+	size     int
+	// base is the throughput at the last size kept
+	base float64
+	// dir is the step being tried, or 0 while measuring a new base
+	dir int
+	// climbed is set once a step from the last base was kept
+	climbed bool
+	settled bool
+	history []int
+}
+
+func newHillClimber(min, max, start int, noise float64) *hillClimber {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	if start < min {
+		start = min
+	}
+	if start > max {
+		start = max
+	}
+	return &hillClimber{min: min, max: max, noise: noise, size: start, history: []int{start}}
+}
+
+// step takes the throughput measured at the current size over the last
+// interval and returns the size to run at for the next one
+func (h *hillClimber) step(throughput float64) int {
+	switch {
+	case h.settled && math.Abs(throughput-h.base) <= 2*h.noise*h.base:
+		// Still steady: let the base follow the measurements, so one lucky
+		// interval when it settled doesn't later read as drift
+		h.base += (throughput - h.base) / 4
+	case h.settled || h.dir == 0:
+		// A new base: measured for the first time, or drifted since settling
+		h.base, h.climbed, h.settled = throughput, false, false
+		if !h.try(1) && !h.try(-1) {
+			h.settle()
+		}
+	case throughput > h.base*(1+h.noise):
+		// The step helped: keep it and take another the same way
+		h.base, h.climbed = throughput, true
+		if !h.try(h.dir) {
+			h.settle()
+		}
+	case h.dir > 0 && !h.climbed:
+		// One more worker didn't help: go back and try one fewer
+		h.size--
+		if !h.try(-1) {
+			h.settle()
+		}
+	default:
+		h.size -= h.dir
+		h.settle()
+	}
+	h.history = append(h.history, h.size)
+	return h.size
+}
+
+// try moves one worker in dir if the bounds allow it
+func (h *hillClimber) try(dir int) bool {
+	next := h.size + dir
+	if next < h.min || next > h.max {
+		return false
+	}
+	h.size, h.dir = next, dir
+	return true
+}
+
+func (h *hillClimber) settle() {
+	h.dir = 0
+	h.settled = true
+}
+
+// History returns the size chosen at every step, starting with the first
+func (h *hillClimber) History() []int {
+	return append([]int(nil), h.history...)
+}
+
+// tunedStep is one control interval of a TunedPool
+type tunedStep struct {
+	Workers    int
+	JobsPerSec float64
+}
+
+// TunedPool is a worker pool that sizes itself for the most completed jobs
+// per second. Every interval its control loop counts the jobs completed
+// since the last look, hands the rate to a hillClimber, and starts or
+// retires workers to reach the size it picks. Workers retire between jobs,
+// the same way the SLO pool sheds them.
+type TunedPool struct {
+	fn        func(job int)
+	interval  time.Duration
+	climber   *hillClimber
+	jobs      chan int
+	shed      chan chan struct{}
+	stop      chan struct{}
+	workersWg sync.WaitGroup
+	controlWg sync.WaitGroup
+	completed int64
+
+	mu         sync.Mutex
+	workers    int
+	trajectory []tunedStep
+}
+
+// NewTunedPool starts start workers running fn, and the control loop that
+// resizes the pool between min and max every interval
+func NewTunedPool(min, max, start int, interval time.Duration, fn func(job int)) *TunedPool {
+	p := &TunedPool{
+		fn:       fn,
+		interval: interval,
+		climber:  newHillClimber(min, max, start, 0.08),
+		jobs:     make(chan int),
+		shed:     make(chan chan struct{}),
+		stop:     make(chan struct{}),
+	}
+	p.resize(p.climber.size)
+	p.controlWg.Add(1)
+	go p.control()
+	return p
+}
+
+func (p *TunedPool) startWorker() {
+	p.mu.Lock()
+	p.workers++
+	p.mu.Unlock()
+	p.workersWg.Add(1)
+	go func() {
+		defer p.workersWg.Done()
+		for {
+			select {
+			case retired := <-p.shed:
+				close(retired)
+				return
+			case job, ok := <-p.jobs:
+				if !ok {
+					return
+				}
+				heartbeat()
+				p.fn(job)
+				atomic.AddInt64(&p.completed, 1)
+			}
+		}
+	}()
+}
+
+// resize starts or retires workers until the pool runs n
+func (p *TunedPool) resize(n int) {
+	for p.Workers() < n {
+		p.startWorker()
+	}
+	for p.Workers() > n {
+		retired := make(chan struct{})
+		p.shed <- retired
+		<-retired
+		p.mu.Lock()
+		p.workers--
+		p.mu.Unlock()
+	}
+}
+
+// control measures throughput and resizes the pool every interval until it
+// closes
+func (p *TunedPool) control() {
+	defer p.controlWg.Done()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			done := atomic.SwapInt64(&p.completed, 0)
+			rate := float64(done) / now.Sub(last).Seconds()
+			last = now
+			workers := p.Workers()
+			next := p.climber.step(rate)
+			p.mu.Lock()
+			p.trajectory = append(p.trajectory, tunedStep{Workers: workers, JobsPerSec: rate})
+			p.mu.Unlock()
+			if next != workers {
+				fmt.Fprintf(output(), "Tuned pool: %d workers completed %.0f jobs/s, trying %d\n", workers, rate, next)
+			}
+			p.resize(next)
+		}
+	}
+}
+
+// Submit queues a job, blocking until a worker takes it
+func (p *TunedPool) Submit(job int) {
+	p.jobs <- job
+}
+
+// Close stops the control loop and waits for the workers to drain the queue
+func (p *TunedPool) Close() {
+	close(p.stop)
+	p.controlWg.Wait()
+	close(p.jobs)
+	p.workersWg.Wait()
+}
+
+// Workers returns the current worker count
+func (p *TunedPool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.workers
+}
+
+// Trajectory returns the worker count and throughput of every interval
+func (p *TunedPool) Trajectory() []tunedStep {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]tunedStep(nil), p.trajectory...)
+}
+
+// contendedResource is a resource every job needs a turn at, which slows
+// down the more callers queue for it, as a lock convoy or a thrashing cache
+// does: each turn holds it for hold plus perWaiter for every caller waiting
+// behind. Adding workers helps until it saturates, then hurts.
+type contendedResource struct {
+	hold, perWaiter time.Duration
+	waiting         int64
+	mu              sync.Mutex
+}
+
+func (r *contendedResource) use() {
+	atomic.AddInt64(&r.waiting, 1)
+	r.mu.Lock()
+	behind := atomic.AddInt64(&r.waiting, -1)
+	time.Sleep(r.hold + time.Duration(behind)*r.perWaiter)
+	r.mu.Unlock()
+}
+
+// runTunedPool feeds a TunedPool as fast as it takes jobs for the duration.
+// Each job works alone for 8ms, then takes a turn at a shared resource.
+func runTunedPool(duration time.Duration) []tunedStep {
+	shared := &contendedResource{hold: time.Millisecond, perWaiter: 300 * time.Microsecond}
+	pool := NewTunedPool(1, 16, 2, 300*time.Millisecond, func(int) {
+		time.Sleep(8 * time.Millisecond)
+		shared.use()
+	})
+	deadline := time.Now().Add(duration)
+	for job := 0; time.Now().Before(deadline); job++ {
+		pool.Submit(job)
+	}
+	pool.Close()
+	return pool.Trajectory()
+}
+
+// uslThroughput is the Universal Scalability Law: the throughput of n
+// workers given contention sigma and coherency cost kappa. It peaks at
+// sqrt((1-sigma)/kappa) workers and falls beyond.
+func uslThroughput(n int, sigma, kappa float64) float64 {
+	w := float64(n)
+	return 100 * w / (1 + sigma*(w-1) + kappa*w*(w-1))
+}
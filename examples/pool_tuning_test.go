@@ -0,0 +1,42 @@
+package examples
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestHillClimber drives the climber with throughput from a scalability
+// model with a known optimum plus seeded measurement noise, starting below
+// and above it, and checks it settles within one worker of the optimum and
+// then stops moving
+func TestHillClimber(t *testing.T) {
+	models := []struct{ sigma, kappa float64 }{{0.05, 0.02}, {0.02, 0.03}, {0.1, 0.02}, {0.05, 0.05}}
+	for _, m := range models {
+		best := 1
+		for n := 2; n <= 32; n++ {
+			if uslThroughput(n, m.sigma, m.kappa) > uslThroughput(best, m.sigma, m.kappa) {
+				best = n
+			}
+		}
+		for _, start := range []int{1, 32} {
+			rng := rand.New(rand.NewSource(int64(start)))
+			h := newHillClimber(1, 32, start, 0.01)
+			size := start
+			for i := 0; i < 80; i++ {
+				noise := 1 + (rng.Float64()-0.5)*0.006
+				size = h.step(uslThroughput(size, m.sigma, m.kappa) * noise)
+			}
+			history := h.History()
+			if size < best-1 || size > best+1 {
+				t.Fatalf("sigma %v, kappa %v from %d: settled at %d, optimum %d (history %v)",
+					m.sigma, m.kappa, start, size, best, history)
+			}
+			for _, n := range history[len(history)-20:] {
+				if n != size {
+					t.Fatalf("sigma %v, kappa %v from %d: still moving at the end (history %v)",
+						m.sigma, m.kappa, start, history)
+				}
+			}
+		}
+	}
+}
@@ -1,25 +1,90 @@
 package examples
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Pools demonstrates the worker pools pattern
-func RunPools() {
-	fmt.Println("=== Worker Pools Pattern Example ===")
+func RunPools(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Worker Pools Pattern Example ===")
+
+	if opts.Soak > 0 {
+		soakPools(opts.Soak)
+		return
+	}
+	if fi := chaosInjector(); fi != nil {
+		printChaosSummary("pools", runChaosPool(fi, 3, 20, 3))
+		return
+	}
 
-	// Configuration
 	numWorkers := 3
+
+	phase("pool")
+	runWorkerPool(ctx, numWorkers)
+
+	phase("cost")
+	runCostBalancing(numWorkers)
+
+	phase("plan")
+	// Dry-run planning: predict the schedule from estimates, then run it
+	fmt.Fprintln(output(), "\nPlanning a prioritized queue before running it:")
+	runPoolPlan()
+
+	phase("rate")
+	runRateLimitedWorkers(numWorkers)
+	runLiveThroughput(numWorkers)
+
+	phase("ordered")
+	runOrderedPool(numWorkers)
+
+	phase("slo")
+	runSLOTargeting()
+
+	phase("tuned")
+	runPoolTuning()
+
+	phase("middleware")
+	runTimeoutMiddleware(numWorkers)
+
+	// Workers that hold a resource for their lifetime clean up however they stop
+	runWorkerCleanup()
+
+	// Per-worker setup that can fail: the pool runs without that worker
+	runJobPoolHooks()
+
+	phase("supervised")
+	runSupervisedWorkers(numWorkers)
+
+	phase("admission")
+	runAdmissionControl(ctx)
+	runCPUCancellation()
+
+	phase("tenants")
+	runTenantFairness()
+
+	printTrace()
+}
+
+// runWorkerPool sends 15 traced jobs through numWorkers workers and
+// collects their results
+func runWorkerPool(ctx context.Context, numWorkers int) {
+	// Configuration
 	numJobs := 15
 
-	// Create job channel
-	jobs := make(chan int, numJobs)
+	// Create job channel; each job gets a TraceID from the run
+	jobs := make(chan poolTask, numJobs)
+	rc := RunContextFrom(ctx)
+	traceIDs := make([]string, numJobs+1)
 
 	// Create result channel
-	results := make(chan string, numJobs)
+	results := make(chan jobResult, numJobs)
 
 	// Start the worker pool
 	var wg sync.WaitGroup
@@ -27,15 +92,17 @@ func RunPools() {
 	// Launch workers
 	for i := 1; i <= numWorkers; i++ {
 		wg.Add(1)
-		go workerPool(i, jobs, results, &wg)
+		go workerPool(i, jobs, results, &wg, nil, nil)
 	}
 
 	// Send jobs to the pool
 	go func() {
 		defer close(jobs)
 		for i := 1; i <= numJobs; i++ {
-			fmt.Printf("Sending job %d to pool\n", i)
-			jobs <- i
+			fmt.Fprintf(output(), "Sending job %d to pool\n", i)
+			traceIDs[i] = rc.NextTraceID()
+			traceHop(traceIDs[i], "submitted")
+			jobs <- poolTask{ID: i, TraceID: traceIDs[i]}
 			time.Sleep(100 * time.Millisecond) // Simulate job generation time
 		}
 	}()
@@ -47,34 +114,681 @@ func RunPools() {
 	}()
 
 	// Collect results
-	fmt.Printf("\nWorker pool with %d workers processing %d jobs:\n", numWorkers, numJobs)
-	fmt.Println()
+	fmt.Fprintf(output(), "\nWorker pool with %d workers processing %d jobs:\n", numWorkers, numJobs)
+	fmt.Fprintln(output())
 
 	count := 0
+	var completedJobs []jobResult
+	var resultLines []string
 	for result := range results {
-		fmt.Printf("Result: %s\n", result)
+		fmt.Fprintf(output(), "Result: %s\n", result)
+		if result.Job >= 1 && result.Job <= numJobs {
+			traceHop(traceIDs[result.Job], "collected")
+		}
 		count++
+		completedJobs = append(completedJobs, result)
+		resultLines = append(resultLines, result.String())
+	}
+	record("results", resultLines)
+	verify("pools", func() error {
+		ids := make([]int, len(completedJobs))
+		for i, result := range completedJobs {
+			if result.WorkerID < 1 || result.WorkerID > numWorkers {
+				return fmt.Errorf("job %d completed by worker %d, pool has %d", result.Job, result.WorkerID, numWorkers)
+			}
+			ids[i] = result.Job
+		}
+		return checkIDs(ids, numJobs)
+	})
+
+	fmt.Fprintf(output(), "\nWorker pool completed! Processed %d jobs.\n", count)
+}
+
+// runCostBalancing routes jobs of skewed cost to the least-loaded worker
+func runCostBalancing(numWorkers int) {
+	fmt.Fprintln(output(), "\nCost-balanced scheduling (jobs routed to the least-loaded worker):")
+	costs := []int{8, 1, 1, 7, 2, 1, 9, 1, 2, 3, 1, 6}
+	costResults := make(chan string, len(costs))
+	balanced := newCostBalancedPool(numWorkers, costResults)
+
+	total := 0
+	for i, cost := range costs {
+		id := balanced.submit(costJob{ID: i + 1, Cost: cost})
+		fmt.Fprintf(output(), "Job %d (cost %d) assigned to worker %d\n", i+1, cost, id)
+		total += cost
+	}
+	balanced.close()
+	close(costResults)
+
+	for result := range costResults {
+		fmt.Fprintf(output(), "Result: %s\n", result)
+	}
+
+	assigned := balanced.assignedCosts()
+	maxCost := 0
+	for i, cost := range assigned {
+		fmt.Fprintf(output(), "Worker %d total cost: %d\n", i+1, cost)
+		if cost > maxCost {
+			maxCost = cost
+		}
+	}
+	fmt.Fprintf(output(), "Max per-worker cost %d (balanced minimum %d)\n", maxCost, (total+numWorkers-1)/numWorkers)
+	record("cost_balanced", map[string]interface{}{"assigned_costs": assigned, "max_cost": maxCost})
+}
+
+// runRateLimitedWorkers paces each worker with its own 2 jobs/sec token bucket
+func runRateLimitedWorkers(numWorkers int) {
+	fmt.Fprintln(output(), "\nPer-worker rate limiting (3 workers, 2 jobs/sec each):")
+	limitedJobs := make(chan poolTask, 18)
+	limitedResults := make(chan jobResult, 18)
+	for i := 1; i <= 18; i++ {
+		limitedJobs <- poolTask{ID: i}
+	}
+	close(limitedJobs)
+
+	start := time.Now()
+	var limitedWg sync.WaitGroup
+	for i := 1; i <= numWorkers; i++ {
+		limiter := newTokenBucketLimiter(2, 1)
+		limitedWg.Add(1)
+		go func(id int) {
+			// Each worker owns its limiter, so it stops it once it has no more jobs
+			defer limiter.Stop()
+			workerPool(id, limitedJobs, limitedResults, &limitedWg, limiter, nil)
+		}(i)
+	}
+	limitedWg.Wait()
+	close(limitedResults)
+
+	elapsed := time.Since(start)
+	limitedCount := len(limitedResults)
+	fmt.Fprintf(output(), "Processed %d jobs in %v: %.1f jobs/sec aggregate\n", limitedCount, elapsed.Round(time.Millisecond), float64(limitedCount)/elapsed.Seconds())
+	record("rate_limited", map[string]interface{}{"jobs": limitedCount, "jobs_per_second": float64(limitedCount) / elapsed.Seconds()})
+}
+
+// runLiveThroughput reports a job pool's throughput from its sliding-window
+// meter while it works
+func runLiveThroughput(numWorkers int) {
+	fmt.Fprintln(output(), "\nLive throughput (1s sliding window):")
+	pool := newJobPool(numWorkers, func(workerID, job int) string {
+		time.Sleep(time.Duration(rand.Intn(50)+50) * time.Millisecond)
+		return fmt.Sprintf("Job %d completed by worker %d", job, workerID)
+	})
+	go func() {
+		for i := 1; i <= 60; i++ {
+			pool.submit(i)
+		}
+		pool.close()
+	}()
+
+	ticker := time.NewTicker(300 * time.Millisecond)
+	defer ticker.Stop()
+	completed := 0
+	for completed < 60 {
+		select {
+		case <-pool.results:
+			completed++
+		case <-ticker.C:
+			fmt.Fprintf(output(), "Completed %d jobs, current rate %.1f jobs/sec\n", completed, pool.JobsPerSecond())
+		}
+	}
+	fmt.Fprintf(output(), "Live throughput run completed %d jobs.\n", completed)
+	record("live_throughput_jobs", completed)
+}
+
+// runOrderedPool runs jobs that finish out of order through an ordered
+// pool, which emits them in submission order
+func runOrderedPool(numWorkers int) {
+	fmt.Fprintln(output(), "\nOrdered results (job 1 is the slowest, at most 4 pending):")
+	began := time.Now()
+	ordered := newOrderedJobPool(numWorkers, 4, func(workerID, job int) string {
+		work := time.Duration(rand.Intn(80)+20) * time.Millisecond
+		if job == 1 {
+			work = 300 * time.Millisecond
+		}
+		time.Sleep(work)
+		finished := time.Since(began).Round(time.Millisecond)
+		fmt.Fprintf(output(), "Job %d finished at +%v\n", job, finished)
+		return fmt.Sprintf("Job %d (worker %d, finished at +%v)", job, workerID, finished)
+	})
+	go func() {
+		for i := 1; i <= 8; i++ {
+			ordered.submit(i)
+		}
+		ordered.close()
+	}()
+	var emitted []string
+	for result := range ordered.results {
+		emitted = append(emitted, result)
+		fmt.Fprintf(output(), "Emitted at +%v: %s\n", time.Since(began).Round(time.Millisecond), result)
+	}
+	fmt.Fprintf(output(), "Ordered run emitted %d results with at most %d pending\n", len(emitted), ordered.MaxPending())
+	record("ordered_pool", map[string]int{"emitted": len(emitted), "max_pending": ordered.MaxPending()})
+	verify("ordered pool", func() error {
+		for i, result := range emitted {
+			if !strings.HasPrefix(result, fmt.Sprintf("Job %d ", i+1)) {
+				return fmt.Errorf("result %d is %q", i+1, result)
+			}
+		}
+		return nil
+	})
+}
+
+// runSLOTargeting has an SLO pool follow job latency against a p99 target
+func runSLOTargeting() {
+	fmt.Fprintln(output(), "\nSLO pool (p99 target 25ms, 1-8 workers, a job every 5ms):")
+	slo := runSLOPool(25 * time.Millisecond)
+	fmt.Fprintf(output(), "Worker count over the run: %v\n", slo.History)
+	record("slo_pool", slo)
+	verify("SLO pool", slo.defended)
+}
+
+// runPoolTuning hill-climbs toward the worker count with the most
+// throughput, where a shared resource that slows under contention sets a
+// real optimum
+func runPoolTuning() {
+	fmt.Fprintln(output(), "\nTuned pool (1-16 workers from 2; each job works 8ms alone, then 1ms at a shared resource that slows 0.3ms per waiter):")
+	trajectory := runTunedPool(6 * time.Second)
+	var sizes []int
+	for _, s := range trajectory {
+		sizes = append(sizes, s.Workers)
+	}
+	fmt.Fprintf(output(), "Worker count over the run: %v\n", sizes)
+	// Average the run of intervals at the size it ended on
+	final := sizes[len(sizes)-1]
+	var rate float64
+	intervals := 0
+	for i := len(trajectory) - 1; i >= 0 && trajectory[i].Workers == final; i-- {
+		rate += trajectory[i].JobsPerSec
+		intervals++
+	}
+	fmt.Fprintf(output(), "Settled at %d workers, averaging %.0f jobs/s over its last %d intervals\n", final, rate/float64(intervals), intervals)
+	record("tuned_pool", trajectory)
+}
+
+// runTimeoutMiddleware gives every job a deadline through middleware
+func runTimeoutMiddleware(numWorkers int) {
+	fmt.Fprintln(output(), "\nPer-job timeout middleware (100ms deadline, job 5 takes 1s):")
+	timed := newCtxJobPool(context.Background(), numWorkers, sleepJob(5, time.Second), WithTimeout(100*time.Millisecond))
+	go func() {
+		for i := 1; i <= 8; i++ {
+			timed.submit(i)
+		}
+		timed.close()
+	}()
+	timedOut := 0
+	for outcome := range timed.results {
+		if outcome.Err != nil {
+			timedOut++
+			fmt.Fprintf(output(), "Worker %d: %v (%s)\n", outcome.WorkerID, outcome.Err, errorKind(outcome.Err))
+			continue
+		}
+		fmt.Fprintf(output(), "Worker %d: %s\n", outcome.WorkerID, outcome.Result)
+	}
+	record("timed_out_jobs", timedOut)
+	verify("timeout middleware", func() error {
+		if timedOut != 1 {
+			return fmt.Errorf("%d jobs timed out, want 1", timedOut)
+		}
+		return nil
+	})
+}
+
+// runSupervisedWorkers replaces panicking workers and reports their jobs
+func runSupervisedWorkers(numWorkers int) {
+	fmt.Fprintln(output(), "\nSupervised pool (job 7 panics every time, job 9 only on its first try):")
+	var firstTry sync.Once
+	supervised := newSupervisedPool(numWorkers, true, func(job int) string {
+		time.Sleep(50 * time.Millisecond)
+		if job == 7 {
+			panic("corrupt input")
+		}
+		if job == 9 {
+			firstTry.Do(func() { panic("transient fault") })
+		}
+		return fmt.Sprintf("Job %d done", job)
+	})
+	go func() {
+		for i := 1; i <= 12; i++ {
+			supervised.submit(i)
+		}
+		supervised.close()
+	}()
+	succeeded, failed := 0, 0
+	for outcome := range supervised.results {
+		if outcome.Err != nil {
+			failed++
+			fmt.Fprintf(output(), "Failed: %v\n", outcome.Err)
+			continue
+		}
+		succeeded++
+	}
+	fmt.Fprintf(output(), "Supervised pool: %d succeeded, %d failed, %d restarts\n", succeeded, failed, supervised.Restarts())
+	record("supervised", map[string]int{"succeeded": succeeded, "failed": failed, "restarts": supervised.Restarts()})
+	verify("supervised pool", func() error {
+		if succeeded != 11 || failed != 1 {
+			return fmt.Errorf("%d succeeded and %d failed, want 11 and 1", succeeded, failed)
+		}
+		return nil
+	})
+}
+
+// runAdmissionControl admits jobs that need a slot and as many tokens as
+// they cost
+func runAdmissionControl(ctx context.Context) {
+	fmt.Fprintln(output(), "\nAdmission-controlled pool (2 slots, 10 tokens/sec, burst 5, mixed-cost jobs):")
+	admission := newAdmissionController(2, 10, 5)
+	defer admission.Stop()
+	jobCosts := []int{1, 4, 2, 5, 1, 3, 2, 1}
+	start := time.Now()
+	var admittedWg sync.WaitGroup
+	for i, cost := range jobCosts {
+		admittedWg.Add(1)
+		go func(job, cost int) {
+			defer admittedWg.Done()
+			release, err := admission.Admit(context.Background(), cost)
+			if err != nil {
+				fmt.Fprintf(output(), "Job %d rejected: %v\n", job, err)
+				return
+			}
+			defer release()
+			heartbeat()
+			fmt.Fprintf(output(), "Job %d (cost %d) admitted at +%v\n", job, cost, time.Since(start).Round(10*time.Millisecond))
+			time.Sleep(100 * time.Millisecond)
+		}(i+1, cost)
+	}
+	admittedWg.Wait()
+	_, peak := admission.Stats()
+	fmt.Fprintf(output(), "All jobs done in %v, peak concurrency %d\n", time.Since(start).Round(10*time.Millisecond), peak)
+	record("admission", map[string]interface{}{"jobs": len(jobCosts), "peak_concurrency": peak})
+
+	// A job too expensive to be admitted in time gives its slot back
+	admitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	_, err := admission.Admit(admitCtx, 50)
+	cancel()
+	inFlight, _ := admission.Stats()
+	fmt.Fprintf(output(), "Cost-50 job (%s): %v; slots in use afterwards: %d\n", errorKind(err), err, admission.slots.InUse())
+	verify("admission control", func() error {
+		if peak > 2 || inFlight != 0 || admission.slots.InUse() != 0 {
+			return fmt.Errorf("peak %d, in flight %d, slots held %d", peak, inFlight, admission.slots.InUse())
+		}
+		return nil
+	})
+}
+
+// runCPUCancellation cancels CPU-bound jobs, which have nothing to select
+// on and so check ctx themselves
+func runCPUCancellation() {
+	fmt.Fprintln(output(), "\nCooperative cancellation in a CPU-bound job (SHA-256 chain, cancelled after 50ms):")
+	var latencies []cancelLatency
+	for _, every := range []int{1000, 1000000} {
+		l, err := hashUntilCancelled(every, 50*time.Millisecond)
+		fmt.Fprintf(output(), "Checkpoint every %7d iterations: stopped after %d iterations, %v after cancel (%v)\n",
+			every, l.Completed, l.Latency.Round(10*time.Microsecond), err)
+		verify("cpu cancellation", func() error {
+			if err == nil || l.Completed%every != 0 {
+				return fmt.Errorf("checkpoint every %d stopped after %d iterations (%v), want a cancel at a checkpoint", every, l.Completed, err)
+			}
+			return nil
+		})
+		latencies = append(latencies, l)
+	}
+	record("cpu_cancellation", latencies)
+}
+
+// runTenantFairness has one tenant flood the pool while two light tenants
+// trickle in
+func runTenantFairness() {
+	fmt.Fprintln(output(), "\nMulti-tenant pool (4 workers; bulk floods 16 jobs, alpha and beta send 5 each):")
+	tenantWaits := make(map[string]map[string]tenantStats)
+	for _, capPerTenant := range []int{4, 2} {
+		stats := runTenantFlood(capPerTenant)
+		fmt.Fprintf(output(), "Cap %d per tenant:\n", capPerTenant)
+		for _, tenant := range []string{"bulk", "alpha", "beta"} {
+			s := stats[tenant]
+			fmt.Fprintf(output(), "  %-5s %2d jobs, average wait %v, peak in flight %d\n",
+				tenant, s.Jobs, s.AverageWait().Round(time.Millisecond), s.PeakInFlight)
+		}
+		verify("tenant pool", func() error {
+			for tenant, s := range stats {
+				if s.PeakInFlight > capPerTenant {
+					return fmt.Errorf("%s had %d jobs in flight, cap %d", tenant, s.PeakInFlight, capPerTenant)
+				}
+			}
+			return nil
+		})
+		tenantWaits[fmt.Sprintf("cap_%d", capPerTenant)] = stats
 	}
+	record("tenants", tenantWaits)
+}
+
+// poolTask is a job for workerPool, with a TraceID if it is traced
+type poolTask struct {
+	ID      int
+	TraceID string
+	// Attempt counts deliveries of the job under --chaos, from 1
+	Attempt int
+}
 
-	fmt.Printf("\nWorker pool completed! Processed %d jobs.\n", count)
+// jobResult is what workerPool reports for each job it completes
+type jobResult struct {
+	Job      int
+	WorkerID int
+	Took     time.Duration
 }
 
-// Worker function for the pool
-func workerPool(id int, jobs <-chan int, results chan<- string, wg *sync.WaitGroup) {
+func (r jobResult) String() string {
+	return fmt.Sprintf("Job %d completed by worker %d in %v", r.Job, r.WorkerID, r.Took)
+}
+
+// Worker function for the pool. A non-nil limiter paces this worker on its
+// own, independent of the other workers. A non-nil chaos runs each job under
+// fault injection and reports only jobs that are settled: a failed attempt
+// goes back on jobs and is reported by whichever worker finishes it.
+func workerPool(id int, jobs <-chan poolTask, results chan<- jobResult, wg *sync.WaitGroup, limiter *tokenBucketLimiter, chaos *poolChaos) {
 	defer wg.Done()
 
-	fmt.Printf("Worker %d started\n", id)
+	fmt.Fprintf(output(), "Worker %d started\n", id)
+
+	for task := range jobs {
+		heartbeat()
+		job := task.ID
+		if limiter != nil {
+			limiter.Wait()
+			heartbeat()
+			fmt.Fprintf(output(), "Worker %d took a token for job %d at %v\n", id, job, time.Now().Format("15:04:05.000"))
+		}
+
+		span := tracer.Start("pool.job")
+		span.SetTag("worker", id)
+		span.SetTag("job", job)
 
-	for job := range jobs {
 		// Simulate work processing
 		processingTime := time.Duration(rand.Intn(300)+200) * time.Millisecond
-		fmt.Printf("Worker %d processing job %d (will take %v)\n", id, job, processingTime)
+		fmt.Fprintf(output(), "Worker %d processing job %d (will take %v)\n", id, job, processingTime)
 
-		time.Sleep(processingTime)
+		if chaos == nil {
+			pause(processingTime)
+		} else {
+			retry := poolTask{ID: job, TraceID: task.TraceID, Attempt: task.Attempt + 1}
+			outcome := chaos.tracker.attempt(id, chaosAttempt{item: job, attempt: task.Attempt},
+				func() { pause(processingTime) }, chaos.redelivery.redeliver(retry))
+			if outcome == chaosRedelivered {
+				span.End()
+				continue
+			}
+		}
+		span.End()
+		traceHop(task.TraceID, fmt.Sprintf("processed by worker %d", id))
 
-		result := fmt.Sprintf("Job %d completed by worker %d in %v", job, id, processingTime)
-		results <- result
+		results <- jobResult{Job: job, WorkerID: id, Took: processingTime}
 	}
 
-	fmt.Printf("Worker %d finished\n", id)
+	fmt.Fprintf(output(), "Worker %d finished\n", id)
+}
+
+// poolChaos is what workerPool needs to run its jobs under --chaos: the
+// tracker deciding each attempt's faults and the redelivery that puts
+// failed jobs back on the pool's queue
+type poolChaos struct {
+	tracker    *chaosTracker
+	redelivery *chaosRedelivery[poolTask]
+}
+
+// runChaosPool runs numJobs through workerPool under fault injection, each
+// job retried up to maxAttempts, and returns what was retried and lost. It
+// closes the pool once every job has succeeded or been dead-lettered.
+func runChaosPool(fi *FaultInjector, numWorkers, numJobs, maxAttempts int) chaosSummary {
+	// Room for every job, so neither a submit nor a redelivery blocks
+	jobs := make(chan poolTask, numJobs)
+	results := make(chan jobResult, numJobs)
+	chaos := &poolChaos{
+		tracker:    newChaosTracker("pools", fi, maxAttempts),
+		redelivery: newChaosRedelivery[poolTask](numJobs, jobs),
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= numWorkers; i++ {
+		wg.Add(1)
+		go workerPool(i, jobs, results, &wg, nil, chaos)
+	}
+	for i := 1; i <= numJobs; i++ {
+		chaos.tracker.produced()
+		jobs <- poolTask{ID: i, Attempt: 1}
+	}
+	for settled := 0; settled < numJobs; settled++ {
+		<-results
+	}
+	chaos.redelivery.close()
+	close(jobs)
+	wg.Wait()
+	return chaos.tracker.Summary()
+}
+
+// costJob is a job that carries an estimated cost
+type costJob struct {
+	ID   int
+	Cost int
+}
+
+// costBalancedPool gives each worker its own queue and routes every job to the
+// worker with the smallest total assigned cost, so a few expensive jobs don't
+// all pile up on the same worker.
+type costBalancedPool struct {
+	queues   []chan costJob
+	assigned []int
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func newCostBalancedPool(numWorkers int, results chan<- string) *costBalancedPool {
+	p := &costBalancedPool{
+		queues:   make([]chan costJob, numWorkers),
+		assigned: make([]int, numWorkers),
+	}
+
+	for i := range p.queues {
+		p.queues[i] = make(chan costJob, 16)
+		p.wg.Add(1)
+		go p.worker(i+1, p.queues[i], results)
+	}
+
+	return p
+}
+
+// submit routes the job to the least-loaded worker and returns that worker's ID
+func (p *costBalancedPool) submit(job costJob) int {
+	p.mu.Lock()
+	target := 0
+	for i, cost := range p.assigned {
+		if cost < p.assigned[target] {
+			target = i
+		}
+	}
+	p.assigned[target] += job.Cost
+	p.mu.Unlock()
+
+	p.queues[target] <- job
+	return target + 1
+}
+
+// close stops accepting jobs and waits for the workers to drain their queues
+func (p *costBalancedPool) close() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+}
+
+// assignedCosts returns the total cost routed to each worker
+func (p *costBalancedPool) assignedCosts() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]int, len(p.assigned))
+	copy(out, p.assigned)
+	return out
+}
+
+func (p *costBalancedPool) worker(id int, jobs <-chan costJob, results chan<- string) {
+	defer p.wg.Done()
+
+	for job := range jobs {
+		heartbeat()
+		// Simulate work proportional to the job's cost
+		time.Sleep(time.Duration(job.Cost) * 20 * time.Millisecond)
+		results <- fmt.Sprintf("Job %d (cost %d) completed by worker %d", job.ID, job.Cost, id)
+	}
+}
+
+// jobPool is the worker pool packaged as a reusable type: a fixed set of
+// workers pull jobs from a shared queue, run fn on each and send the result
+// on results. Completed jobs feed a sliding-window rate meter.
+type jobPool struct {
+	jobs    chan poolJob
+	results chan string
+	fn      func(workerID, job int) string
+	meter   *RateMeter
+	wg      sync.WaitGroup
+	nextSeq int64
+	// workers counts the workers that started; onStop, if set, runs as
+	// each of them exits
+	workers int
+	onStop  func(workerID int)
+
+	// Set by newOrderedJobPool: workers report to completed, and the reorderer
+	// emits on results in submission order
+	window      *Semaphore
+	completed   chan poolResult
+	peakPending int64
+}
+
+// poolJob is a job tagged with its submission sequence
+type poolJob struct {
+	seq int
+	job int
+}
+
+func newJobPool(numWorkers int, fn func(workerID, job int) string) *jobPool {
+	// Without an onStart hook no worker can fail to start
+	p, _ := newHookedJobPool(numWorkers, fn, nil, nil)
+	return p
+}
+
+// newHookedJobPool is newJobPool with per-worker setup and teardown: each
+// worker runs onStart once as it spins up, before taking any job, and onStop
+// once as it exits. A worker whose onStart fails doesn't start, and its
+// error is returned once every worker has started or failed. The pool runs
+// with the workers that started; only if none did is the pool nil. Either
+// hook may be nil.
+func newHookedJobPool(numWorkers int, fn func(workerID, job int) string, onStart func(workerID int) error, onStop func(workerID int)) (*jobPool, error) {
+	p := allocJobPool(numWorkers, fn)
+	p.onStop = onStop
+	return p.start(numWorkers, onStart)
+}
+
+// allocJobPool returns a pool with no workers running yet, so its mode can be
+// set before start
+func allocJobPool(numWorkers int, fn func(workerID, job int) string) *jobPool {
+	return &jobPool{
+		jobs:    make(chan poolJob, numWorkers),
+		results: make(chan string, numWorkers),
+		fn:      fn,
+		meter:   NewRateMeter(time.Second, 10),
+	}
+}
+
+// start runs numWorkers workers as newHookedJobPool describes. The workers
+// read the pool's mode without locking, so nothing may change it afterwards.
+func (p *jobPool) start(numWorkers int, onStart func(workerID int) error) (*jobPool, error) {
+	startErrs := make(chan error, numWorkers)
+	for i := 1; i <= numWorkers; i++ {
+		p.wg.Add(1)
+		go func(id int) {
+			if onStart != nil {
+				if err := onStart(id); err != nil {
+					startErrs <- fmt.Errorf("worker %d: start: %w", id, err)
+					p.wg.Done()
+					return
+				}
+			}
+			startErrs <- nil
+			p.worker(id)
+		}(i)
+	}
+	var errs []error
+	for i := 0; i < numWorkers; i++ {
+		if err := <-startErrs; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.workers = numWorkers - len(errs)
+	if p.workers == 0 {
+		return nil, fmt.Errorf("no worker started: %w", errors.Join(errs...))
+	}
+
+	// Close results (or, in ordered mode, the reorderer's input) once every
+	// worker has drained the queue
+	go func() {
+		p.wg.Wait()
+		if p.completed != nil {
+			close(p.completed)
+		} else {
+			close(p.results)
+		}
+	}()
+
+	return p, errors.Join(errs...)
+}
+
+// Workers returns how many workers the pool runs
+func (p *jobPool) Workers() int {
+	return p.workers
+}
+
+func (p *jobPool) submit(job int) {
+	if p.window != nil {
+		// Take a window slot before a sequence number, so the job every
+		// later result waits for always holds a slot
+		p.window.Acquire(context.Background())
+		n := int64(p.window.InUse())
+		for {
+			peak := atomic.LoadInt64(&p.peakPending)
+			if n <= peak || atomic.CompareAndSwapInt64(&p.peakPending, peak, n) {
+				break
+			}
+		}
+	}
+	seq := int(atomic.AddInt64(&p.nextSeq, 1)) - 1
+	p.jobs <- poolJob{seq: seq, job: job}
+}
+
+// close stops accepting jobs; results closes once the queue is drained
+func (p *jobPool) close() {
+	close(p.jobs)
+}
+
+// JobsPerSecond reports the pool's current throughput over the last second
+func (p *jobPool) JobsPerSecond() float64 {
+	return p.meter.Rate()
+}
+
+func (p *jobPool) worker(id int) {
+	defer p.wg.Done()
+	if p.onStop != nil {
+		defer p.onStop(id)
+	}
+	for job := range p.jobs {
+		heartbeat()
+		span := tracer.Start("pool.job")
+		span.SetTag("worker", id)
+		span.SetTag("job", job.job)
+		result := p.fn(id, job.job)
+		span.End()
+		p.meter.Add(1)
+		if p.completed != nil {
+			p.completed <- poolResult{seq: job.seq, result: result}
+		} else {
+			p.results <- result
+		}
+	}
 }
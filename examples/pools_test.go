@@ -0,0 +1,115 @@
+package examples
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCostBalancedPool routes heavily skewed costs and checks that, after
+// every submission, no worker's total cost is more than one job ahead of
+// another's, and that every job completes exactly once
+func TestCostBalancedPool(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	costs := make([]int, 40)
+	for i := range costs {
+		// Mostly cheap jobs with the occasional expensive one
+		costs[i] = 1
+		if rng.Intn(5) == 0 {
+			costs[i] = 4 + rng.Intn(5)
+		}
+	}
+
+	results := make(chan string, len(costs))
+	p := newCostBalancedPool(4, results)
+	largest := 0
+	for i, cost := range costs {
+		p.submit(costJob{ID: i + 1, Cost: cost})
+		if cost > largest {
+			largest = cost
+		}
+		assigned := p.assignedCosts()
+		lo, hi := assigned[0], assigned[0]
+		for _, c := range assigned {
+			lo, hi = min(lo, c), max(hi, c)
+		}
+		if hi-lo > largest {
+			t.Fatalf("after job %d, worker costs %v spread by %d, more than the largest job (%d)", i+1, assigned, hi-lo, largest)
+		}
+	}
+	p.close()
+	close(results)
+
+	completed := 0
+	for range results {
+		completed++
+	}
+	if completed != len(costs) {
+		t.Fatalf("%d of %d jobs completed", completed, len(costs))
+	}
+}
+
+// TestWorkerPoolPerWorkerLimit runs three workers each limited to 2 jobs/sec
+// and checks, from the job spans, that no worker starts jobs closer than
+// 500ms apart while the pool as a whole runs faster than one worker could
+func TestWorkerPoolPerWorkerLimit(t *testing.T) {
+	rec := NewRecordingTracer()
+	defer func(prev Tracer) { tracer = prev }(tracer)
+	tracer = rec
+
+	const workers, numJobs = 3, 9
+	jobs := make(chan poolTask, numJobs)
+	results := make(chan jobResult, numJobs)
+	for i := 1; i <= numJobs; i++ {
+		jobs <- poolTask{ID: i}
+	}
+	close(jobs)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 1; i <= workers; i++ {
+		limiter := newTokenBucketLimiter(2, 1)
+		defer limiter.Stop()
+		wg.Add(1)
+		go workerPool(i, jobs, results, &wg, limiter, nil)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if len(results) != numJobs {
+		t.Fatalf("%d of %d jobs completed", len(results), numJobs)
+	}
+	close(results)
+	ids := make([]int, 0, numJobs)
+	for r := range results {
+		if r.WorkerID < 1 || r.WorkerID > workers {
+			t.Fatalf("job %d reported by worker %d, pool has %d", r.Job, r.WorkerID, workers)
+		}
+		ids = append(ids, r.Job)
+	}
+	if err := checkIDs(ids, numJobs); err != nil {
+		t.Fatal(err)
+	}
+
+	starts := make(map[interface{}][]time.Time)
+	for _, s := range rec.Spans() {
+		if s.Name == "pool.job" {
+			starts[s.Tags["worker"]] = append(starts[s.Tags["worker"]], s.Start)
+		}
+	}
+	if len(starts) != workers {
+		t.Fatalf("jobs ran on %d workers, want %d", len(starts), workers)
+	}
+	for worker, times := range starts {
+		for i := 1; i < len(times); i++ {
+			// Allow for ticker jitter under load
+			if gap := times[i].Sub(times[i-1]); gap < 450*time.Millisecond {
+				t.Fatalf("worker %v started jobs %v apart, limit is 2/sec", worker, gap)
+			}
+		}
+	}
+	// One worker at 2 jobs/sec needs 4s for 9 jobs; three share the work
+	if elapsed > 2500*time.Millisecond {
+		t.Fatalf("%d jobs took %v across %d limited workers", numJobs, elapsed, workers)
+	}
+}
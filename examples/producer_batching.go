@@ -0,0 +1,82 @@
+package examples
+
+import "time"
+
+// batchSizer is an AIMD controller for batch size. After every flush it is
+// told how many items went downstream and how long that took:
+//   - a flush over the latency budget halves the size (never below minSize)
+//     and clears the per-item baseline, so growth starts over from there
+//   - otherwise, if the latency per item was no worse than the previous
+//     flush's, the size grows by one (never above maxSize)
+//   - otherwise the size holds
+//
+// An observation with no baseline to compare with counts as improving.
+type batchSizer struct {
+	minSize, maxSize int
+	budget           time.Duration
+	size             int
+	lastPerItem      time.Duration
+}
+
+func newBatchSizer(minSize, maxSize int, budget time.Duration) *batchSizer {
+	if minSize < 1 {
+		minSize = 1
+	}
+	if maxSize < minSize {
+		maxSize = minSize
+	}
+	return &batchSizer{minSize: minSize, maxSize: maxSize, budget: budget, size: minSize}
+}
+
+// Size is the batch size to use for the next flush
+func (s *batchSizer) Size() int {
+	return s.size
+}
+
+// Observe records a flush of items that took latency and returns the new size
+func (s *batchSizer) Observe(items int, latency time.Duration) int {
+	if items < 1 {
+		return s.size
+	}
+	perItem := latency / time.Duration(items)
+	switch {
+	case latency > s.budget:
+		s.size /= 2
+		if s.size < s.minSize {
+			s.size = s.minSize
+		}
+		s.lastPerItem = 0
+		return s.size
+	case s.lastPerItem == 0 || perItem <= s.lastPerItem:
+		if s.size < s.maxSize {
+			s.size++
+		}
+	}
+	s.lastPerItem = perItem
+	return s.size
+}
+
+// consumeBatches reads items into batches sized by sizer and passes each to
+// flush, timing the flush and feeding the latency back to sizer. It returns
+// the size of every batch flushed, in order.
+func consumeBatches(items <-chan Item, sizer *batchSizer, flush func([]Item)) []int {
+	var sizes []int
+	for {
+		batch := make([]Item, 0, sizer.Size())
+		for len(batch) < cap(batch) {
+			item, ok := <-items
+			if !ok {
+				break
+			}
+			batch = append(batch, item)
+		}
+		if len(batch) == 0 {
+			return sizes
+		}
+		start := time.Now()
+		flush(batch)
+		sizer.Observe(len(batch), time.Since(start))
+		heartbeat()
+		sizes = append(sizes, len(batch))
+	}
+}
@@ -0,0 +1,33 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBatchSizer feeds a sizer scripted observations and checks it follows
+// the rules above: grow, cap, halve on a blown budget, hold when per-item
+// latency gets worse, and floor at the minimum
+func TestBatchSizer(t *testing.T) {
+	s := newBatchSizer(1, 4, 50*time.Millisecond)
+	script := []struct {
+		items   int
+		latency time.Duration
+		want    int
+	}{
+		{1, 10 * time.Millisecond, 2},  // no baseline: grow
+		{2, 16 * time.Millisecond, 3},  // 8ms/item < 10ms: grow
+		{3, 21 * time.Millisecond, 4},  // 7ms/item: grow
+		{4, 24 * time.Millisecond, 4},  // 6ms/item, but capped
+		{4, 60 * time.Millisecond, 2},  // over budget: halve
+		{2, 30 * time.Millisecond, 3},  // baseline cleared: grow
+		{3, 48 * time.Millisecond, 3},  // 16ms/item > 15ms: hold
+		{3, 120 * time.Millisecond, 1}, // over budget: halve
+		{1, 120 * time.Millisecond, 1}, // over budget, already at the floor
+	}
+	for i, step := range script {
+		if got := s.Observe(step.items, step.latency); got != step.want {
+			t.Fatalf("step %d: %d items in %v gave size %d, want %d", i+1, step.items, step.latency, got, step.want)
+		}
+	}
+}
@@ -1,57 +1,566 @@
 package examples
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
 
 // RunProducerConsumer demonstrates the producer-consumer pattern with multiple producers and consumers.
-func RunProducerConsumer() {
-	fmt.Println("=== Producer-Consumer Pattern Example ===")
+func RunProducerConsumer(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Producer-Consumer Pattern Example ===")
+
+	if opts.Soak > 0 {
+		soakProducerConsumer(opts.Soak)
+		return
+	}
+	if fi := chaosInjector(); fi != nil {
+		chaos := newChaosTracker("producer-consumer", fi, 3)
+		runProducerConsumer(producerConsumerConfig{Producers: 2, Consumers: 3, Items: 10, Buffer: 5, Chaos: chaos})
+		printChaosSummary("producer-consumer", chaos.Summary())
+		return
+	}
 
-	bufferSize := 5
 	numProducers := 2
-	numConsumers := 3
 	numItems := 10
 
-	buffer := make(chan int, bufferSize)
+	phase("run")
+	produced, consumed := runProducerConsumer(producerConsumerConfig{
+		Producers:    numProducers,
+		Consumers:    3,
+		Items:        numItems,
+		Buffer:       5,
+		ProduceDelay: 300 * time.Millisecond,
+		ConsumeDelay: 400 * time.Millisecond,
+		Verbose:      true,
+		Run:          RunContextFrom(ctx),
+	})
+
+	producedBy := tallyByProducer(produced, numProducers, numItems)
+	consumedBy := tallyByProducer(consumed, numProducers, numItems)
+	for p := 1; p <= numProducers; p++ {
+		fmt.Fprintf(output(), "Producer %d: produced %d, consumed %d\n", p, producedBy[p-1], consumedBy[p-1])
+	}
+	fmt.Fprintf(output(), "Total consumed: %d of %d produced\n", len(consumed), len(produced))
+	record("producers", map[string][]int{"produced": producedBy, "consumed": consumedBy})
+	verify("producer-consumer", func() error {
+		return sameItems(produced, consumed)
+	})
+
+	phase("checkpoint")
+	// Checkpointed run: a fresh run crashes part way through, --resume picks up from the checkpoint
+	fmt.Fprintln(output(), "\nCheckpointed consumption:")
+	path := checkpointPath()
+	resume := opts.Resume
+	if resume {
+		fmt.Fprintf(output(), "Resuming from checkpoint %s\n", path)
+	} else {
+		os.Remove(path)
+	}
+	crashAfter := 6
+	if resume {
+		crashAfter = 0
+	}
+	report, err := runCheckpointed(path, numProducers, 5, crashAfter)
+	if err == nil {
+		record("checkpointed", map[string]interface{}{
+			"crashed":      report.crashed,
+			"processed":    report.processed,
+			"duplicates":   report.duplicates,
+			"started_from": report.startedFrom,
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(output(), "Checkpointed run failed: %v\n", err)
+	} else if report.crashed {
+		fmt.Fprintf(output(), "Simulated crash after %d items (checkpoint: %v). Run again with --resume to continue.\n", report.processed, report.committed)
+	} else {
+		fmt.Fprintf(output(), "Checkpointed run finished: processed %d items, %d duplicates reprocessed, started from %v\n", report.processed, report.duplicates, report.startedFrom)
+		os.Remove(path)
+	}
+
+	phase("batching")
+	// Adaptive batching: the consumer sizes its batches from flush latency
+	fmt.Fprintln(output(), "\nAdaptive batching (AIMD, 60ms budget per flush; downstream slows after 150 items):")
+	items := make(chan Item, 16)
+	go func() {
+		defer close(items)
+		for seq := 1; seq <= 300; seq++ {
+			items <- Item{ProducerID: 1, Seq: seq, Value: rand.Intn(100)}
+		}
+	}()
+	flushed, flushes, slowFrom := 0, 0, -1
+	perItem := 2 * time.Millisecond
+	sizes := consumeBatches(items, newBatchSizer(1, 16, 60*time.Millisecond), func(batch []Item) {
+		if flushed >= 150 && slowFrom < 0 {
+			// The downstream gets three times slower per item
+			perItem = 6 * time.Millisecond
+			slowFrom = flushes
+		}
+		time.Sleep(20*time.Millisecond + perItem*time.Duration(len(batch)))
+		flushed += len(batch)
+		flushes++
+	})
+	fmt.Fprintf(output(), "Batch sizes, fast downstream: %v\n", sizes[:slowFrom])
+	fmt.Fprintf(output(), "Batch sizes, slow downstream: %v\n", sizes[slowFrom:])
+	record("adaptive_batching", map[string][]int{"fast": sizes[:slowFrom], "slow": sizes[slowFrom:]})
+
+	phase("buffer")
+	// Adaptive buffer: the queue grows while bursts keep it full and shrinks when idle
+	fmt.Fprintln(output(), "\nAdaptive buffer (capacity 4-64; 3 bursts of 40 items, 300ms idle between):")
+	queue := newAdaptiveQueue[int](4, 64, 20*time.Millisecond)
+	var idleCaps []int
+	go func() {
+		seq := 0
+		for burst := 0; burst < 3; burst++ {
+			for i := 0; i < 40; i++ {
+				seq++
+				queue.Push(seq)
+			}
+			time.Sleep(300 * time.Millisecond)
+			idleCaps = append(idleCaps, queue.Capacity())
+		}
+		queue.Close()
+	}()
+	var popped []int
+	for {
+		heartbeat()
+		v, ok := queue.Pop()
+		if !ok {
+			break
+		}
+		popped = append(popped, v)
+		time.Sleep(2 * time.Millisecond) // steady consumer
+	}
+	history := queue.History()
+	fmt.Fprintf(output(), "Capacity changes: %v\n", history)
+	fmt.Fprintf(output(), "Capacity after each idle period: %v; %d items delivered\n", idleCaps, len(popped))
+	record("adaptive_buffer", map[string]interface{}{"capacity_history": history, "delivered": len(popped)})
+	verify("adaptive buffer", func() error {
+		peakCap := 0
+		for _, c := range history {
+			if c > peakCap {
+				peakCap = c
+			}
+		}
+		if peakCap <= 4 || idleCaps[len(idleCaps)-1] != 4 {
+			return fmt.Errorf("peaked at %d and ended idle at %d, want growth then a shrink back to 4", peakCap, idleCaps[len(idleCaps)-1])
+		}
+		for i, v := range popped {
+			if v != i+1 {
+				return fmt.Errorf("item %d delivered as %d", i+1, v)
+			}
+		}
+		if len(popped) != 120 {
+			return fmt.Errorf("delivered %d of 120 items", len(popped))
+		}
+		return nil
+	})
+
+	fmt.Fprintln(output(), "Producer-Consumer example completed!")
+}
+
+// Item is a value tagged with the producer that made it and its sequence number
+type Item struct {
+	ProducerID int
+	Seq        int
+	Value      int
+	// TraceID is set when the item is produced under a RunContext
+	TraceID string
+	// Attempt counts deliveries of the item under --chaos, from 1
+	Attempt int
+}
+
+// checkpointEvery is how many processed items pass between checkpoint writes
+const checkpointEvery = 4
+
+// checkpointPath returns the state file used by the checkpointed run, in
+// opts.CheckpointDir or else the system temp directory
+func checkpointPath() string {
+	dir := opts.CheckpointDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "cmp-pattern-checkpoint.json")
+}
+
+// checkpointState is what gets persisted: per producer, the highest contiguous
+// sequence processed plus any processed sequences above it
+type checkpointState struct {
+	Committed map[int]int   `json:"committed"`
+	Pending   map[int][]int `json:"pending"`
+}
+
+// checkpointTracker records processed sequences and only ever commits
+// contiguous progress, so a checkpoint written mid-batch can't skip an item
+// that was still in flight.
+type checkpointTracker struct {
+	mu         sync.Mutex
+	committed  map[int]int
+	pending    map[int]map[int]bool
+	duplicates int
+
+	// saveMu serializes saves, so consumers checkpointing at once don't
+	// share a temp file and a newer snapshot is never overwritten by an
+	// older one
+	saveMu sync.Mutex
+}
+
+func newCheckpointTracker(state checkpointState) *checkpointTracker {
+	t := &checkpointTracker{
+		committed: make(map[int]int),
+		pending:   make(map[int]map[int]bool),
+	}
+	for p, seq := range state.Committed {
+		t.committed[p] = seq
+	}
+	for p, seqs := range state.Pending {
+		t.pending[p] = make(map[int]bool)
+		for _, seq := range seqs {
+			t.pending[p][seq] = true
+		}
+	}
+	return t
+}
+
+// markProcessed records an item and reports whether it had already been processed
+func (t *checkpointTracker) markProcessed(producer, seq int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pending[producer] == nil {
+		t.pending[producer] = make(map[int]bool)
+	}
+	if seq <= t.committed[producer] || t.pending[producer][seq] {
+		t.duplicates++
+		return true
+	}
+	t.pending[producer][seq] = true
+
+	// Advance the contiguous watermark as far as it goes
+	for t.pending[producer][t.committed[producer]+1] {
+		t.committed[producer]++
+		delete(t.pending[producer], t.committed[producer])
+	}
+	return false
+}
+
+func (t *checkpointTracker) snapshot() checkpointState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := checkpointState{
+		Committed: make(map[int]int),
+		Pending:   make(map[int][]int),
+	}
+	for p, seq := range t.committed {
+		state.Committed[p] = seq
+	}
+	for p, seqs := range t.pending {
+		for seq := range seqs {
+			state.Pending[p] = append(state.Pending[p], seq)
+		}
+	}
+	return state
+}
+
+// save writes the checkpoint via a temp file and rename so it is never half written
+func (t *checkpointTracker) save(path string) error {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+	data, err := json.Marshal(t.snapshot())
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadCheckpoint reads a checkpoint file; a missing file means start from zero
+func loadCheckpoint(path string) (checkpointState, error) {
+	var state checkpointState
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// checkpointReport summarizes a checkpointed run
+type checkpointReport struct {
+	startedFrom map[int]int
+	committed   map[int]int
+	processed   int
+	duplicates  int
+	crashed     bool
+	// fresh lists, per producer, the sequences the tracker had not seen
+	// before this run processed them
+	fresh map[int][]int
+}
+
+// runCheckpointed runs producers from their checkpointed sequence up to
+// itemsPerProducer. If crashAfter is positive the run stops abruptly once that
+// many items have been processed, without writing a final checkpoint.
+func runCheckpointed(path string, numProducers, itemsPerProducer, crashAfter int) (checkpointReport, error) {
+	state, err := loadCheckpoint(path)
+	if err != nil {
+		return checkpointReport{}, err
+	}
+	tracker := newCheckpointTracker(state)
+	report := checkpointReport{startedFrom: tracker.snapshot().Committed, fresh: make(map[int][]int)}
+
+	buffer := make(chan Item, 2)
+	crash := make(chan struct{})
+	var crashOnce sync.Once
 	var wg sync.WaitGroup
 
-	// Start producers
+	// Producers start right after the committed sequence
 	for p := 1; p <= numProducers; p++ {
 		wg.Add(1)
-		go func(id int) {
+		go func(id, start int) {
 			defer wg.Done()
-			for i := 0; i < numItems; i++ {
-				item := rand.Intn(100)
-				buffer <- item
-				fmt.Printf("Producer %d produced: %d\n", id, item)
-				time.Sleep(time.Duration(rand.Intn(200)+100) * time.Millisecond)
+			for seq := start; seq <= itemsPerProducer; seq++ {
+				select {
+				case buffer <- Item{ProducerID: id, Seq: seq, Value: rand.Intn(100)}:
+				case <-crash:
+					return
+				}
 			}
-		}(p)
+		}(p, report.startedFrom[p]+1)
 	}
+	go func() {
+		wg.Wait()
+		close(buffer)
+	}()
 
-	// Start consumers
+	var mu sync.Mutex
+	var saveErr error
 	var consumerWg sync.WaitGroup
-	for c := 1; c <= numConsumers; c++ {
+	for c := 1; c <= 2; c++ {
 		consumerWg.Add(1)
 		go func(id int) {
 			defer consumerWg.Done()
+			for {
+				heartbeat()
+				var item Item
+				var ok bool
+				select {
+				case item, ok = <-buffer:
+				case <-crash:
+					return
+				}
+				if !ok {
+					return
+				}
+
+				time.Sleep(time.Duration(rand.Intn(50)+20) * time.Millisecond)
+				dup := tracker.markProcessed(item.ProducerID, item.Seq)
+				fmt.Fprintf(output(), "Consumer %d processed producer %d seq %d (duplicate: %t)\n", id, item.ProducerID, item.Seq, dup)
+
+				mu.Lock()
+				report.processed++
+				n := report.processed
+				if !dup {
+					report.fresh[item.ProducerID] = append(report.fresh[item.ProducerID], item.Seq)
+				}
+				mu.Unlock()
+
+				if crashAfter > 0 && n >= crashAfter {
+					crashOnce.Do(func() { close(crash) })
+					return
+				}
+				if n%checkpointEvery == 0 {
+					if err := tracker.save(path); err != nil {
+						mu.Lock()
+						saveErr = err
+						mu.Unlock()
+					}
+				}
+			}
+		}(c)
+	}
+	consumerWg.Wait()
+
+	select {
+	case <-crash:
+		report.crashed = true
+	default:
+		if err := tracker.save(path); err != nil && saveErr == nil {
+			saveErr = err
+		}
+	}
+
+	// Report what actually made it to disk, which is all a resumed run will see
+	saved, err := loadCheckpoint(path)
+	if err != nil && saveErr == nil {
+		saveErr = err
+	}
+	report.committed = saved.Committed
+	tracker.mu.Lock()
+	report.duplicates = tracker.duplicates
+	tracker.mu.Unlock()
+	return report, saveErr
+}
+
+// producerConsumerConfig sizes a producer-consumer run. The delays are the
+// most a producer waits after each item and a consumer spends on each; the
+// actual waits are random up to them. Zero means no wait.
+type producerConsumerConfig struct {
+	Producers, Consumers int
+	Items                int // per producer
+	Buffer               int
+	ProduceDelay         time.Duration
+	ConsumeDelay         time.Duration
+	Verbose              bool
+	// Run, if set, gives every item a TraceID
+	Run *RunContext
+	// Chaos, if set, consumes each item under fault injection, putting a
+	// failed item back on the buffer until it succeeds or is dead-lettered
+	Chaos *chaosTracker
+}
+
+// runProducerConsumer runs producers and consumers over one buffered channel
+// and shuts down gracefully: the buffer closes only after every producer has
+// returned, and the run returns only after every consumer has drained it.
+// It returns the numbers of the items produced and consumed, where producer
+// p's items are numbered (p-1)*Items to p*Items-1, in the order they were
+// produced and consumed. Under cfg.Chaos an item is consumed once it
+// succeeds; the dead-lettered ones are only counted in the tracker.
+func runProducerConsumer(cfg producerConsumerConfig) (produced, consumed []int) {
+	buffer := make(chan Item, cfg.Buffer)
+	var mu sync.Mutex
+	var redelivery *chaosRedelivery[Item]
+	if cfg.Chaos != nil {
+		redelivery = newChaosRedelivery[Item](cfg.Producers*cfg.Items, buffer)
+	}
+	// unsettled counts items produced but not yet consumed or dead-lettered;
+	// the buffer closes only once it drops to zero, so a redelivery never
+	// hits a closed channel
+	var unsettled sync.WaitGroup
+	wait := func(max time.Duration) {
+		if max > 0 {
+			time.Sleep(max/3 + time.Duration(rand.Int63n(int64(max-max/3))))
+		}
+	}
+
+	// Start producers
+	var producers sync.WaitGroup
+	for p := 1; p <= cfg.Producers; p++ {
+		producers.Add(1)
+		go func(id int) {
+			defer producers.Done()
+			for i := 0; i < cfg.Items; i++ {
+				item := Item{ProducerID: id, Seq: i + 1, Value: rand.Intn(100), TraceID: cfg.Run.NextTraceID(), Attempt: 1}
+				traceHop(item.TraceID, fmt.Sprintf("produced by producer %d", id))
+				unsettled.Add(1)
+				if cfg.Chaos != nil {
+					cfg.Chaos.produced()
+				}
+				buffer <- item
+				mu.Lock()
+				produced = append(produced, (id-1)*cfg.Items+i)
+				mu.Unlock()
+				if cfg.Verbose {
+					fmt.Fprintf(output(), "Producer %d produced: %d\n", id, item.Value)
+				}
+				wait(cfg.ProduceDelay)
+			}
+		}(p)
+	}
+
+	// Start consumers
+	var consumers sync.WaitGroup
+	for c := 1; c <= cfg.Consumers; c++ {
+		consumers.Add(1)
+		go func(id int) {
+			defer consumers.Done()
 			for item := range buffer {
-				fmt.Printf("Consumer %d consumed: %d\n", id, item)
-				time.Sleep(time.Duration(rand.Intn(300)+100) * time.Millisecond)
+				heartbeat()
+				traceHop(item.TraceID, fmt.Sprintf("consumed by consumer %d", id))
+				if cfg.Verbose {
+					fmt.Fprintf(output(), "Consumer %d consumed: %d (from producer %d)\n", id, item.Value, item.ProducerID)
+				}
+				number := (item.ProducerID-1)*cfg.Items + item.Seq - 1
+				if cfg.Chaos != nil {
+					retry := item
+					retry.Attempt++
+					switch cfg.Chaos.attempt(id, chaosAttempt{item: number + 1, attempt: item.Attempt},
+						func() { wait(cfg.ConsumeDelay) }, redelivery.redeliver(retry)) {
+					case chaosRedelivered:
+						continue
+					case chaosDeadLettered:
+						unsettled.Done()
+						continue
+					}
+				}
+				mu.Lock()
+				consumed = append(consumed, number)
+				mu.Unlock()
+				if cfg.Chaos == nil {
+					wait(cfg.ConsumeDelay)
+				}
+				unsettled.Done()
 			}
 		}(c)
 	}
 
-	// Wait for all producers to finish, then close the buffer
-	wg.Wait()
+	if cfg.Verbose {
+		// Only the narrated run is a phase of the example
+		phase("drain")
+	}
+	// Wait for all producers to finish and every item to be settled, then
+	// close the buffer
+	producers.Wait()
+	unsettled.Wait()
+	if redelivery != nil {
+		redelivery.close()
+	}
 	close(buffer)
 
 	// Wait for all consumers to finish
-	consumerWg.Wait()
+	consumers.Wait()
+	return produced, consumed
+}
+
+// tallyByProducer counts the item numbers from runProducerConsumer per
+// producer, indexed from 0 for producer 1; item numbers run in blocks of
+// items per producer
+func tallyByProducer(numbers []int, producers, items int) []int {
+	counts := make([]int, producers)
+	for _, n := range numbers {
+		counts[n/items]++
+	}
+	return counts
+}
 
-	fmt.Println("Producer-Consumer example completed!")
+// sameItems reports an error unless produced and consumed hold the same item
+// numbers the same number of times
+func sameItems(produced, consumed []int) error {
+	p := append([]int(nil), produced...)
+	c := append([]int(nil), consumed...)
+	sort.Ints(p)
+	sort.Ints(c)
+	if len(p) != len(c) {
+		return fmt.Errorf("produced %d items, consumed %d", len(p), len(c))
+	}
+	for i := range p {
+		if p[i] != c[i] {
+			return fmt.Errorf("item %d produced but item %d consumed in its place", p[i], c[i])
+		}
+	}
+	return nil
 }
@@ -0,0 +1,169 @@
+package examples
+
+import (
+	"io"
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestNoItemsLost runs many randomized, quiet producer-consumer shutdowns,
+// including unbuffered ones and more consumers than items, and checks each
+// consumed exactly what was produced
+func TestNoItemsLost(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	for run := 0; run < 200; run++ {
+		cfg := producerConsumerConfig{
+			Producers: rng.Intn(4) + 1,
+			Consumers: rng.Intn(6) + 1,
+			Items:     rng.Intn(40),
+			Buffer:    rng.Intn(5),
+		}
+		if run%10 == 0 {
+			cfg.ProduceDelay = time.Millisecond
+			cfg.ConsumeDelay = time.Millisecond
+		}
+		produced, consumed := runProducerConsumer(cfg)
+		if err := sameItems(produced, consumed); err != nil {
+			t.Fatalf("run %d (%+v): %v", run, cfg, err)
+		}
+		if len(produced) != cfg.Producers*cfg.Items {
+			t.Fatalf("run %d (%+v): produced %d items", run, cfg, len(produced))
+		}
+	}
+}
+
+// TestPerProducerCounts checks that, tallied by the producer ID each item
+// carries, every producer's consumed count matches what it produced and the
+// counts add up to the total produced
+func TestPerProducerCounts(t *testing.T) {
+	for _, cfg := range []producerConsumerConfig{
+		{Producers: 1, Consumers: 3, Items: 12, Buffer: 0},
+		{Producers: 4, Consumers: 2, Items: 25, Buffer: 3},
+		{Producers: 5, Consumers: 5, Items: 7, Buffer: 10, ProduceDelay: time.Millisecond},
+	} {
+		produced, consumed := runProducerConsumer(cfg)
+		producedBy := tallyByProducer(produced, cfg.Producers, cfg.Items)
+		consumedBy := tallyByProducer(consumed, cfg.Producers, cfg.Items)
+		total := 0
+		for p := range consumedBy {
+			if producedBy[p] != cfg.Items || consumedBy[p] != producedBy[p] {
+				t.Fatalf("%+v: producer %d produced %d and had %d consumed, want %d of each", cfg, p+1, producedBy[p], consumedBy[p], cfg.Items)
+			}
+			total += consumedBy[p]
+		}
+		if total != len(produced) {
+			t.Fatalf("%+v: per-producer counts add up to %d, %d produced", cfg, total, len(produced))
+		}
+	}
+}
+
+// TestCheckpointResume crashes a checkpointed run after 6 items, resumes it
+// from the checkpoint on disk, and checks that the two runs together
+// process every item, each run at most once, and that the only items both
+// processed are ones the crashed run never checkpointed
+func TestCheckpointResume(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	const producers, items = 2, 5
+
+	crashed, err := runCheckpointed(path, producers, items, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !crashed.crashed || crashed.processed < 6 {
+		t.Fatalf("first run crashed %v after %d items, want a crash after 6", crashed.crashed, crashed.processed)
+	}
+	saved, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpointed := func(p, seq int) bool {
+		if seq <= saved.Committed[p] {
+			return true
+		}
+		for _, s := range saved.Pending[p] {
+			if s == seq {
+				return true
+			}
+		}
+		return false
+	}
+
+	resumed, err := runCheckpointed(path, producers, items, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.crashed {
+		t.Fatalf("resumed run crashed")
+	}
+	for p := 1; p <= producers; p++ {
+		if resumed.startedFrom[p] != saved.Committed[p] {
+			t.Fatalf("producer %d resumed from %d, checkpoint says %d", p, resumed.startedFrom[p], saved.Committed[p])
+		}
+		if resumed.committed[p] != items {
+			t.Fatalf("producer %d committed %d after resuming, want %d", p, resumed.committed[p], items)
+		}
+		first := make(map[int]bool)
+		for _, seq := range crashed.fresh[p] {
+			if first[seq] {
+				t.Fatalf("crashed run processed producer %d seq %d twice", p, seq)
+			}
+			first[seq] = true
+		}
+		union := make(map[int]bool)
+		for seq := range first {
+			union[seq] = true
+		}
+		for _, seq := range resumed.fresh[p] {
+			if union[seq] && !first[seq] {
+				t.Fatalf("resumed run processed producer %d seq %d twice", p, seq)
+			}
+			if first[seq] && checkpointed(p, seq) {
+				t.Fatalf("producer %d seq %d was checkpointed but processed again", p, seq)
+			}
+			union[seq] = true
+		}
+		if len(union) != items {
+			t.Fatalf("producer %d: the two runs processed %v then %v, want all of 1-%d", p, crashed.fresh[p], resumed.fresh[p], items)
+		}
+	}
+}
+
+// TestCheckpointConcurrentSaves saves one tracker from many goroutines at
+// once while it keeps advancing, and checks every save succeeds and the file
+// left behind is a complete checkpoint
+func TestCheckpointConcurrentSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	tracker := newCheckpointTracker(checkpointState{})
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 1; i <= 64; i++ {
+		wg.Add(1)
+		go func(seq int) {
+			defer wg.Done()
+			tracker.markProcessed(1, seq)
+			errs <- tracker.save(path)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent save: %v", err)
+		}
+	}
+	if err := tracker.save(path); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Committed[1] != 64 || len(saved.Pending[1]) != 0 {
+		t.Fatalf("checkpoint after 64 items: committed %d, pending %v", saved.Committed[1], saved.Pending[1])
+	}
+}
@@ -1,91 +1,549 @@
 package examples
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // RunPubSub demonstrates the publish-subscribe (pub/sub) pattern.
-func RunPubSub() {
-	fmt.Println("=== Publish-Subscribe (Pub/Sub) Pattern Example ===")
+func RunPubSub(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Publish-Subscribe (Pub/Sub) Pattern Example ===")
 
-	// Create a broadcaster
-	b := newBroadcaster()
+	if opts.Soak > 0 {
+		soakPubSub(opts.Soak)
+		return
+	}
+
+	phase("subscribe")
+	// Create a broadcaster; every message carries a TraceID from the run
+	b := newBroadcaster[pubsubMessage]()
+	rc := RunContextFrom(ctx)
+	publishStats("broadcaster", func() interface{} { return b.Stats() })
 
 	numSubscribers := 3
 	var wg sync.WaitGroup
+	chaos := chaosInjector()
+	received := make([]int, numSubscribers+1)
 
 	// Start subscribers
 	for i := 1; i <= numSubscribers; i++ {
 		ch := b.subscribe()
+		slow := chaos.SlowSubscriber("pubsub", i)
 		wg.Add(1)
-		go func(id int, ch <-chan string) {
+		go func(id int, ch <-chan pubsubMessage) {
 			defer wg.Done()
+			n := 0
 			for msg := range ch {
-				fmt.Printf("Subscriber %d received: %s\n", id, msg)
+				heartbeat()
+				n++
+				if slow {
+					time.Sleep(chaos.Delay("pubsub", id, n))
+				}
+				traceHop(msg.TraceID, fmt.Sprintf("consumed by subscriber %d", id))
+				fmt.Fprintf(output(), "Subscriber %d received: %s\n", id, msg.Text)
 			}
-			fmt.Printf("Subscriber %d done.\n", id)
+			received[id] = n
+			fmt.Fprintf(output(), "Subscriber %d done.\n", id)
 		}(i, ch)
 	}
 
+	phase("publish")
 	// Start publisher
+	var blocked time.Duration
 	go func() {
 		for i := 1; i <= 5; i++ {
-			msg := fmt.Sprintf("Message %d", i)
-			fmt.Printf("Publisher sending: %s\n", msg)
+			msg := pubsubMessage{Text: fmt.Sprintf("Message %d", i), TraceID: rc.NextTraceID()}
+			fmt.Fprintf(output(), "Publisher sending: %s\n", msg.Text)
+			traceHop(msg.TraceID, "published")
+			start := time.Now()
 			b.publish(msg)
+			blocked += time.Since(start)
 			time.Sleep(400 * time.Millisecond)
 		}
 		b.close()
 	}()
 
 	wg.Wait()
-	fmt.Println("Pub/Sub example completed!")
+	if chaos != nil {
+		for i := 1; i <= numSubscribers; i++ {
+			fmt.Fprintf(output(), "[chaos pubsub] subscriber %d (slow: %t) received %d of 5, lost %d\n",
+				i, chaos.SlowSubscriber("pubsub", i), received[i], 5-received[i])
+		}
+		fmt.Fprintf(output(), "[chaos pubsub] publisher spent %v blocked on slow subscribers\n", blocked.Round(time.Millisecond))
+	}
+	stats := b.Stats()
+	record("received_per_subscriber", received[1:])
+	if chaos == nil {
+		verify("pubsub", func() error {
+			for i := 1; i <= numSubscribers; i++ {
+				if received[i] != 5 {
+					return fmt.Errorf("subscriber %d received %d of 5 messages", i, received[i])
+				}
+			}
+			return nil
+		})
+	}
+	record("broadcaster", stats)
+	fmt.Fprintf(output(), "Broadcaster stats: %d subscribers, %d published, %d delivered, %d dropped\n",
+		stats.Subscribers, stats.Published, stats.Delivered, stats.Dropped)
+
+	phase("stalled")
+	// A publish to a stalled subscriber gives up when its context ends
+	fmt.Fprintln(output(), "\nPublishing to a stalled subscriber with a 200ms deadline:")
+	stalled := newBroadcaster[string]()
+	stalledSub := stalled.subscribe() // not read until every publish is done
+	stalled.subscribe()               // never read
+	for i := 1; i <= 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		err := stalled.PublishCtx(ctx, fmt.Sprintf("Message %d", i))
+		cancel()
+		if err != nil {
+			fmt.Fprintf(output(), "Publish of message %d abandoned: %v\n", i, err)
+		} else {
+			fmt.Fprintf(output(), "Publish of message %d delivered\n", i)
+		}
+	}
+
+	// Only what fit in the stalled subscriber's buffer was delivered; every
+	// publish has returned, so whatever was delivered is already buffered
+	var held []string
+	for len(stalledSub) > 0 {
+		held = append(held, <-stalledSub)
+	}
+	fmt.Fprintf(output(), "Stalled subscriber's buffer held: %v\n", held)
+	record("stalled_subscriber_buffer", held)
+	verify("stalled subscriber", func() error {
+		if len(held) != 2 {
+			return fmt.Errorf("%d messages delivered, its buffer holds 2", len(held))
+		}
+		return nil
+	})
+	stalled.close()
+
+	phase("extensions")
+	runBroadcasterStress()
+	runOrderedPubSub()
+	runHeavySubscriber()
+	runTypedTopics()
+	runGapDetection()
+	runPublishReceipts()
+
+	fmt.Fprintln(output(), "Pub/Sub example completed!")
+}
+
+// pubsubMessage is a message in the main example with its TraceID
+type pubsubMessage struct {
+	Text    string
+	TraceID string
 }
 
-// broadcaster manages subscriptions and publishing
-// Not thread-safe for subscribe after close
+// runBroadcasterStress publishes from 8 goroutines to 16 subscribers while
+// some subscribers leave mid-stream, then closes the broadcaster while a
+// second wave of publishes is still running
+func runBroadcasterStress() {
+	const publishers, perPublisher, stable, churners = 8, 200, 12, 4
+	fmt.Fprintf(output(), "\nConcurrent publishing (%d publishers, %d subscribers, %d leave mid-stream):\n", publishers, stable+churners, churners)
+	b := newBroadcaster[string]()
+
+	received := make([]int, stable)
+	var readers sync.WaitGroup
+	for i := 0; i < stable; i++ {
+		ch := b.subscribe()
+		readers.Add(1)
+		go func(i int) {
+			defer readers.Done()
+			for range ch {
+				received[i]++
+			}
+		}(i)
+	}
+	for i := 0; i < churners; i++ {
+		ch := b.subscribe()
+		readers.Add(1)
+		go func(leaveAfter int) {
+			defer readers.Done()
+			for n := 0; n < leaveAfter; n++ {
+				<-ch
+			}
+			// Stop reading; publishes blocked on this subscriber give up on it
+			b.unsubscribe(ch)
+		}(10 * (i + 1))
+	}
+
+	start := time.Now()
+	var pubs sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		pubs.Add(1)
+		go func(p int) {
+			defer pubs.Done()
+			for i := 0; i < perPublisher; i++ {
+				b.publish(fmt.Sprintf("publisher %d message %d", p, i))
+			}
+		}(p)
+	}
+	pubs.Wait()
+	elapsed := time.Since(start)
+
+	// A second wave races with close; late publishes are simply dropped
+	stop := make(chan struct{})
+	for p := 0; p < publishers; p++ {
+		pubs.Add(1)
+		go func() {
+			defer pubs.Done()
+			for !stopped(stop) {
+				b.publish("late")
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	b.close()
+	close(stop)
+	pubs.Wait()
+	readers.Wait()
 
-type broadcaster struct {
-	subscribers []chan string
-	closed      bool
-	mu          sync.Mutex
+	stats := b.Stats()
+	fmt.Fprintf(output(), "%d messages published to every subscriber in %v; %d subscribers left at close\n",
+		publishers*perPublisher, elapsed.Round(time.Millisecond), stats.Subscribers)
+	record("stress", map[string]interface{}{"published": stats.Published, "elapsed_ms": elapsed.Milliseconds(), "subscribers_at_close": stats.Subscribers})
+	verify("broadcaster stress", func() error {
+		for i, n := range received {
+			if n < publishers*perPublisher {
+				return fmt.Errorf("subscriber %d received %d of %d messages", i+1, n, publishers*perPublisher)
+			}
+		}
+		if stats.Subscribers != stable || !stats.Closed {
+			return fmt.Errorf("%d subscribers at close (closed: %t), want %d", stats.Subscribers, stats.Closed, stable)
+		}
+		return nil
+	})
 }
 
-func newBroadcaster() *broadcaster {
-	return &broadcaster{
-		subscribers: make([]chan string, 0),
+// runOrderedPubSub shows ordered, acked delivery: subscriber A disconnects
+// after message 2 and resumes from its ack, while B stays connected
+func runOrderedPubSub() {
+	fmt.Fprintln(output(), "\nOrdered delivery with acks and resume (retention 3):")
+	ob := newOrderedBroadcaster(3)
+	subA, _ := ob.Subscribe(0)
+	subB, _ := ob.Subscribe(0)
+
+	var seqsB []uint64
+	bDone := make(chan struct{})
+	go func() {
+		defer close(bDone)
+		for m := range subB.C {
+			seqsB = append(seqsB, m.Seq)
+			subB.Ack(m.Seq)
+		}
+	}()
+
+	var seqsA []uint64
+	readA := func(sub *orderedSubscription, n int) {
+		timeout := time.After(time.Second)
+		for i := 0; i < n; i++ {
+			select {
+			case m := <-sub.C:
+				fmt.Fprintf(output(), "Subscriber A received #%d: %s\n", m.Seq, m.Msg)
+				seqsA = append(seqsA, m.Seq)
+				sub.Ack(m.Seq)
+			case <-timeout:
+				fmt.Fprintf(output(), "Subscriber A: received %d of %d messages: %v\n", i, n, ErrTimeout)
+				return
+			}
+		}
 	}
+
+	for i := 1; i <= 2; i++ {
+		ob.Publish(fmt.Sprintf("Message %d", i))
+	}
+	readA(subA, 2)
+	ob.Unsubscribe(subA)
+	fmt.Fprintf(output(), "Subscriber A disconnected, resume token %d\n", subA.Acked())
+
+	for i := 3; i <= 5; i++ {
+		ob.Publish(fmt.Sprintf("Message %d", i))
+	}
+
+	resumed, err := ob.Subscribe(subA.Acked())
+	if err != nil {
+		fmt.Fprintf(output(), "Subscriber A could not resume: %v\n", err)
+	} else {
+		readA(resumed, 3)
+	}
+
+	// Message 2 has already been dropped from retention
+	_, gapErr := ob.Subscribe(1)
+	fmt.Fprintf(output(), "Subscriber C resuming after #1 (%s): %v\n", errorKind(gapErr), gapErr)
+
+	ob.close()
+	<-bDone
+	fmt.Fprintf(output(), "Subscriber A saw %v, subscriber B saw %v\n", seqsA, seqsB)
+	record("ordered", map[string]interface{}{"subscriber_a": seqsA, "subscriber_b": seqsB, "gap_error": errorKind(gapErr)})
+
+	verify("ordered pubsub", func() error {
+		want := []uint64{1, 2, 3, 4, 5}
+		if fmt.Sprint(seqsA) != fmt.Sprint(want) || fmt.Sprint(seqsB) != fmt.Sprint(want) {
+			return fmt.Errorf("A saw %v and B saw %v, want %v each", seqsA, seqsB, want)
+		}
+		var gap *ErrRetentionGap
+		if !errors.As(gapErr, &gap) || gap.Oldest != 3 {
+			return fmt.Errorf("resume past retention returned %v, want a gap at seq 3", gapErr)
+		}
+		return nil
+	})
 }
 
-func (b *broadcaster) subscribe() <-chan string {
+// broadcaster manages subscriptions and publishing of messages of type T. The subscriber list is
+// copy-on-write: subscribe, unsubscribe and close build a new subscriberSet
+// and swap it in, while publishes load the current set and send to it
+// without taking a lock, so publishers never contend with each other.
+type broadcaster[T any] struct {
+	set atomic.Pointer[subscriberSet[T]]
+	// publishing counts publishes in progress. close waits on drained, which
+	// the last of them closes, before closing any subscriber channel.
+	publishing int64
+	drained    chan struct{}
+	drainOnce  sync.Once
+	// closing is closed by close, releasing publishes blocked on a full
+	// subscriber so close never waits on a reader
+	closing chan struct{}
+
+	// counters are striped so concurrent publishes rarely share a lock;
+	// Stats sums them
+	counters  [statShards]statShard
+	nextShard uint32
+
+	// mu serializes changes to the set
+	mu     sync.Mutex
+	nextID int
+}
+
+// statShards is how many ways a broadcaster's counters are striped
+const statShards = 16
+
+// statShard holds the counts of the publishes assigned to it. One publish
+// is counted under one lock, so each shard, and so their sum, always
+// accounts for every delivery of a whole publish. The padding keeps shards
+// on separate cache lines.
+type statShard struct {
+	mu        sync.Mutex
+	published int
+	delivered int
+	dropped   int
+	_         [32]byte
+}
+
+// subscriberSet is an immutable snapshot of the subscribers. Closed lives in
+// the same snapshot, so a publish that sees the set open can't race with
+// close into a send on a closed channel.
+type subscriberSet[T any] struct {
+	subs   []*subscriber[T]
+	closed bool
+}
+
+type subscriber[T any] struct {
+	id int
+	ch chan T
+	// gone is closed on unsubscribe, releasing publishes blocked on ch
+	gone chan struct{}
+	// closing is the broadcaster's, closed when it closes
+	closing <-chan struct{}
+	// policy is what a publish does when ch is full
+	policy subscriberPolicy
+}
+
+// subscriberPolicy is how publishes treat a subscriber whose buffer is full.
+// Drop is Block, waiting for room (for at most Timeout, if set), or
+// DropNewest, dropping the message at once. DropOldest isn't offered: the
+// evicted message may already have a receipt saying it was delivered.
+type subscriberPolicy struct {
+	Buffer  int
+	Drop    DropPolicy
+	Timeout time.Duration
+}
+
+// defaultSubscriberPolicy is what subscribe uses: a small buffer, then block
+var defaultSubscriberPolicy = subscriberPolicy{Buffer: 2, Drop: Block}
+
+// broadcasterStats is a snapshot of a broadcaster. Each publish is counted
+// as a whole, so with a fixed set of subscribers Delivered+Dropped always
+// equals Published*Subscribers.
+type broadcasterStats struct {
+	Subscribers int
+	Published   int
+	Delivered   int
+	Dropped     int
+	Closed      bool
+}
+
+func newBroadcaster[T any]() *broadcaster[T] {
+	b := &broadcaster[T]{drained: make(chan struct{}), closing: make(chan struct{})}
+	b.set.Store(&subscriberSet[T]{})
+	return b
+}
+
+// Stats sums the counter shards without taking b.mu, so it never waits
+// behind a subscribe or a publish that is blocked on a slow subscriber
+func (b *broadcaster[T]) Stats() broadcasterStats {
+	set := b.set.Load()
+	s := broadcasterStats{Subscribers: len(set.subs), Closed: set.closed}
+	for i := range b.counters {
+		shard := &b.counters[i]
+		shard.mu.Lock()
+		s.Published += shard.published
+		s.Delivered += shard.delivered
+		s.Dropped += shard.dropped
+		shard.mu.Unlock()
+	}
+	return s
+}
+
+// subscribe adds a subscriber. Subscribing to a closed broadcaster returns a
+// closed channel.
+func (b *broadcaster[T]) subscribe() <-chan T {
+	ch, _ := b.subscribeWith(defaultSubscriberPolicy)
+	return ch
+}
+
+// subscribeWith is subscribe with the subscriber's buffer size and what
+// publishes do once that buffer is full
+func (b *broadcaster[T]) subscribeWith(policy subscriberPolicy) (<-chan T, error) {
+	if policy.Drop != Block && policy.Drop != DropNewest {
+		return nil, fmt.Errorf("subscriber drop policy %v: want block or drop-newest", policy.Drop)
+	}
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	ch := make(chan string, 2)
-	b.subscribers = append(b.subscribers, ch)
-	return ch
+	ch := make(chan T, policy.Buffer)
+	old := b.set.Load()
+	if old.closed {
+		close(ch)
+		return ch, nil
+	}
+	b.nextID++
+	subs := make([]*subscriber[T], len(old.subs), len(old.subs)+1)
+	copy(subs, old.subs)
+	subs = append(subs, &subscriber[T]{id: b.nextID, ch: ch, gone: make(chan struct{}), closing: b.closing, policy: policy})
+	b.set.Store(&subscriberSet[T]{subs: subs})
+	return ch, nil
 }
 
-func (b *broadcaster) publish(msg string) {
+// unsubscribe removes the subscriber reading ch. The channel is not closed,
+// since a publish that loaded the old set may still hold it; such a publish
+// gives up on it instead of blocking.
+func (b *broadcaster[T]) unsubscribe(ch <-chan T) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	if b.closed {
-		return
+	old := b.set.Load()
+	subs := make([]*subscriber[T], 0, len(old.subs))
+	for _, sub := range old.subs {
+		if sub.ch == ch {
+			close(sub.gone)
+			continue
+		}
+		subs = append(subs, sub)
 	}
-	for _, ch := range b.subscribers {
-		ch <- msg
+	b.set.Store(&subscriberSet[T]{subs: subs, closed: old.closed})
+}
+
+func (b *broadcaster[T]) publish(msg T) {
+	b.PublishCtx(context.Background(), msg)
+}
+
+// publishError reports which subscribers a cancelled publish never reached
+type publishError struct {
+	Undelivered []int
+	Err         error
+}
+
+func (e *publishError) Error() string {
+	return fmt.Sprintf("publish cancelled, subscribers %v not delivered: %v", e.Undelivered, e.Err)
+}
+
+func (e *publishError) Unwrap() error {
+	return e.Err
+}
+
+// PublishCtx delivers msg to every subscriber, blocking on slow ones until
+// ctx is done. Subscribers not reached by then are counted as dropped and
+// listed (numbered from 1 in subscription order) in the returned error, which
+// wraps ctx.Err(). A subscriber that unsubscribes mid-publish is skipped, and
+// one subscribed with a drop policy or timeout is dropped as that says, but
+// only a cancelled delivery is listed in the error. A publish still blocked
+// when the broadcaster closes gives up, counting the rest as dropped.
+func (b *broadcaster[T]) PublishCtx(ctx context.Context, msg T) error {
+	set, ok := b.beginPublish()
+	if !ok {
+		return nil
+	}
+	defer b.endPublish()
+
+	receipts := make([]Receipt, len(set.subs))
+	var undelivered []int
+	for i, sub := range set.subs {
+		receipts[i] = sub.deliver(ctx, msg)
+		if receipts[i].Outcome == ReceiptCancelled {
+			undelivered = append(undelivered, sub.id)
+		}
 	}
+	b.count(receipts)
+
+	if len(undelivered) > 0 {
+		return &publishError{Undelivered: undelivered, Err: ctx.Err()}
+	}
+	return nil
 }
 
-func (b *broadcaster) close() {
+// beginPublish announces a publish and returns the set to publish to, or
+// false once the broadcaster is closed. A true result must be paired with
+// endPublish.
+func (b *broadcaster[T]) beginPublish() (*subscriberSet[T], bool) {
+	// Once close is visible, return without touching the counter, so a
+	// stream of late publishes can't keep close waiting
+	if b.set.Load().closed {
+		return nil, false
+	}
+	// Announce the publish before loading the set: close marks the set
+	// closed before waiting for publishes, so either close waits for this
+	// one or this one sees the set closed
+	atomic.AddInt64(&b.publishing, 1)
+	set := b.set.Load()
+	if set.closed {
+		b.endPublish()
+		return nil, false
+	}
+	return set, true
+}
+
+// endPublish ends a publish, telling close once the last one in progress
+// after it marked the set closed has finished
+func (b *broadcaster[T]) endPublish() {
+	if atomic.AddInt64(&b.publishing, -1) == 0 && b.set.Load().closed {
+		b.drainOnce.Do(func() { close(b.drained) })
+	}
+}
+
+// close stops publishing, releases publishes blocked on full subscribers
+// and closes every subscriber channel once the publishes already in
+// progress have finished
+func (b *broadcaster[T]) close() {
 	b.mu.Lock()
-	defer b.mu.Unlock()
-	if b.closed {
+	old := b.set.Load()
+	if old.closed {
+		b.mu.Unlock()
 		return
 	}
-	for _, ch := range b.subscribers {
-		close(ch)
+	b.set.Store(&subscriberSet[T]{subs: old.subs, closed: true})
+	b.mu.Unlock()
+
+	close(b.closing)
+	if atomic.LoadInt64(&b.publishing) == 0 {
+		b.drainOnce.Do(func() { close(b.drained) })
+	}
+	<-b.drained
+	for _, sub := range old.subs {
+		close(sub.ch)
 	}
-	b.closed = true
 }
@@ -0,0 +1,171 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Range is an inclusive range of sequence numbers
+type Range struct {
+	From, To uint64
+}
+
+func (r Range) String() string {
+	if r.From == r.To {
+		return fmt.Sprintf("#%d", r.From)
+	}
+	return fmt.Sprintf("#%d-#%d", r.From, r.To)
+}
+
+// GapDetector tracks the sequence numbers a subscriber receives, starting
+// from 1, and reports the ones it lost. Arrival may be out of order by up to
+// window: a missing number is only declared lost once a number at least
+// window past it has arrived, so a late one inside the window isn't a false
+// gap. One that turns up after being declared lost is taken out of the gaps
+// again. It holds at most window pending numbers and is safe for concurrent
+// use.
+type GapDetector struct {
+	mu     sync.Mutex
+	window uint64
+	// next is the lowest number not yet received or declared lost
+	next    uint64
+	highest uint64
+	// pending holds numbers received above next
+	pending map[uint64]bool
+	gaps    []Range
+}
+
+// NewGapDetector creates a detector tolerating reordering by up to window
+func NewGapDetector(window uint64) *GapDetector {
+	return &GapDetector{window: window, next: 1, pending: make(map[uint64]bool)}
+}
+
+// Observe records that seq arrived
+func (d *GapDetector) Observe(seq uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if seq < d.next {
+		d.fill(seq)
+		return
+	}
+	d.pending[seq] = true
+	if seq > d.highest {
+		d.highest = seq
+	}
+	d.advance(false)
+}
+
+// Flush declares everything still missing up to the highest number received
+// lost, for when the stream has ended and nothing more can arrive late
+func (d *GapDetector) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.advance(true)
+}
+
+// Gaps returns the ranges declared lost, in order
+func (d *GapDetector) Gaps() []Range {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Range(nil), d.gaps...)
+}
+
+// Lost returns how many numbers the gaps cover
+func (d *GapDetector) Lost() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var n uint64
+	for _, g := range d.gaps {
+		n += g.To - g.From + 1
+	}
+	return n
+}
+
+// advance moves next past received numbers and, once they are out of the
+// reorder window (or always, when flushing), past missing ones as gaps
+func (d *GapDetector) advance(flush bool) {
+	for d.next <= d.highest {
+		if d.pending[d.next] {
+			delete(d.pending, d.next)
+			d.next++
+			continue
+		}
+		if !flush && d.highest-d.next < d.window {
+			return
+		}
+		d.declare(d.next)
+		d.next++
+	}
+}
+
+// declare adds seq to the gaps, extending the last range if it is adjacent
+func (d *GapDetector) declare(seq uint64) {
+	if n := len(d.gaps); n > 0 && d.gaps[n-1].To+1 == seq {
+		d.gaps[n-1].To = seq
+		return
+	}
+	d.gaps = append(d.gaps, Range{seq, seq})
+}
+
+// fill takes a late arrival back out of the gaps
+func (d *GapDetector) fill(seq uint64) {
+	for i, g := range d.gaps {
+		if seq < g.From || seq > g.To {
+			continue
+		}
+		var split []Range
+		if g.From < seq {
+			split = append(split, Range{g.From, seq - 1})
+		}
+		if seq < g.To {
+			split = append(split, Range{seq + 1, g.To})
+		}
+		d.gaps = append(d.gaps[:i], append(split, d.gaps[i+1:]...)...)
+		return
+	}
+}
+
+// runGapDetection publishes numbered messages with a short deadline to a
+// subscriber too slow to keep up, which uses a GapDetector to find out which
+// ones it lost
+func runGapDetection() {
+	fmt.Fprintln(output(), "\nGap detection for a slow subscriber (publishes give up after 5ms):")
+	b := newBroadcaster[sequencedMessage]()
+	sub := b.subscribe()
+	detector := NewGapDetector(4)
+	done := make(chan struct{})
+	received := 0
+	go func() {
+		defer close(done)
+		for m := range sub {
+			received++
+			detector.Observe(m.Seq)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	const messages = 30
+	for seq := uint64(1); seq <= messages; seq++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		if seq == messages {
+			// The last message always gets through, so the tail can be checked
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		b.PublishCtx(ctx, sequencedMessage{Seq: seq, Msg: fmt.Sprintf("Message %d", seq)})
+		cancel()
+		time.Sleep(2 * time.Millisecond)
+	}
+	b.close()
+	<-done
+	detector.Flush()
+
+	stats := b.Stats()
+	fmt.Fprintf(output(), "Received %d of %d; broadcaster dropped %d; detector reports %d lost: %v\n",
+		received, messages, stats.Dropped, detector.Lost(), detector.Gaps())
+	record("gaps", map[string]interface{}{"received": received, "dropped": stats.Dropped, "gaps": detector.Gaps()})
+	if int(detector.Lost()) != stats.Dropped || received+stats.Dropped != messages {
+		fail("gap detector: reported %d lost, broadcaster dropped %d of %d with %d received", detector.Lost(), stats.Dropped, messages, received)
+	}
+}
@@ -0,0 +1,41 @@
+package examples
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestGapDetector feeds a stream with a hole, one reordered within the
+// window, one reordered past it, and a late arrival after its gap
+func TestGapDetector(t *testing.T) {
+	feed := func(window uint64, seqs ...uint64) *GapDetector {
+		d := NewGapDetector(window)
+		for _, s := range seqs {
+			d.Observe(s)
+		}
+		return d
+	}
+
+	// 4 and 5 never arrive
+	if got := feed(2, 1, 2, 3, 6, 7, 8, 9).Gaps(); fmt.Sprint(got) != "[#4-#5]" {
+		t.Fatalf("hole at 4-5: gaps %v", got)
+	}
+	// Every number arrives, at most 3 places out of order
+	if got := feed(3, 2, 1, 5, 3, 4, 8, 6, 7, 9, 10); len(got.Gaps()) != 0 {
+		t.Fatalf("reordering within the window: false gaps %v", got.Gaps())
+	}
+	// Still inside the window at the end: not a gap until flushed
+	d := feed(5, 1, 2, 4)
+	if len(d.Gaps()) != 0 {
+		t.Fatalf("3 still within the window declared lost: %v", d.Gaps())
+	}
+	d.Flush()
+	if fmt.Sprint(d.Gaps()) != "[#3]" {
+		t.Fatalf("after flush: gaps %v, want [#3]", d.Gaps())
+	}
+	// 3 arrives after it was declared lost; 4 never does
+	d = feed(1, 1, 2, 5, 6, 3)
+	if fmt.Sprint(d.Gaps()) != "[#4]" || d.Lost() != 1 {
+		t.Fatalf("late arrival of 3: gaps %v, want [#4]", d.Gaps())
+	}
+}
@@ -0,0 +1,132 @@
+package examples
+
+import "sync"
+
+// sequencedMessage is a message with its place in the publish order
+type sequencedMessage struct {
+	Seq uint64
+	Msg string
+}
+
+// orderedBroadcaster is the broadcaster's ordered, acknowledged mode. Every
+// message gets the next sequence number and the last retention messages are
+// kept, so a subscriber that disconnects can resubscribe with the last
+// sequence it acked and pick up where it left off. Retention is bounded by
+// count only: one subscriber's acks never trim what another can resume from.
+type orderedBroadcaster struct {
+	mu        sync.Mutex
+	retention int
+	retained  []sequencedMessage
+	seq       uint64
+	subs      map[*orderedSubscription]struct{}
+	closed    bool
+}
+
+// orderedSubscription is one subscriber's connection to an orderedBroadcaster
+type orderedSubscription struct {
+	C <-chan sequencedMessage
+
+	ch    chan sequencedMessage
+	mu    sync.Mutex
+	acked uint64
+}
+
+func newOrderedBroadcaster(retention int) *orderedBroadcaster {
+	if retention < 1 {
+		retention = 1
+	}
+	return &orderedBroadcaster{
+		retention: retention,
+		subs:      make(map[*orderedSubscription]struct{}),
+	}
+}
+
+// Subscribe connects a subscriber that has processed everything up to and
+// including resumeAfter; pass 0 for a new subscriber. Retained messages after
+// resumeAfter are delivered first, then new ones as they are published. It
+// fails with an ErrRetentionGap if messages after resumeAfter were already
+// dropped from retention.
+func (b *orderedBroadcaster) Subscribe(resumeAfter uint64) (*orderedSubscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.retained) > 0 && resumeAfter+1 < b.retained[0].Seq {
+		return nil, &ErrRetentionGap{Resume: resumeAfter, Oldest: b.retained[0].Seq}
+	}
+
+	// Room for the whole backlog, so replaying it under the lock never blocks
+	ch := make(chan sequencedMessage, b.retention+2)
+	sub := &orderedSubscription{C: ch, ch: ch, acked: resumeAfter}
+	for _, m := range b.retained {
+		if m.Seq > resumeAfter {
+			ch <- m
+		}
+	}
+	if b.closed {
+		close(ch)
+		return sub, nil
+	}
+	b.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Unsubscribe disconnects sub and closes its channel. Its last ack is its
+// resume token.
+func (b *orderedBroadcaster) Unsubscribe(sub *orderedSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.ch)
+}
+
+// Publish numbers msg, retains it and delivers it to every subscriber in
+// sequence order, blocking on slow subscribers. It returns the sequence number.
+func (b *orderedBroadcaster) Publish(msg string) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0
+	}
+	b.seq++
+	m := sequencedMessage{Seq: b.seq, Msg: msg}
+	b.retained = append(b.retained, m)
+	if len(b.retained) > b.retention {
+		b.retained = b.retained[len(b.retained)-b.retention:]
+	}
+	for sub := range b.subs {
+		sub.ch <- m
+	}
+	return m.Seq
+}
+
+func (b *orderedBroadcaster) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	for sub := range b.subs {
+		close(sub.ch)
+	}
+	b.subs = nil
+	b.closed = true
+}
+
+// Ack records that the subscriber processed everything up to seq
+func (s *orderedSubscription) Ack(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if seq > s.acked {
+		s.acked = seq
+	}
+}
+
+// Acked returns the last acked sequence, the token to resume from
+func (s *orderedSubscription) Acked() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acked
+}
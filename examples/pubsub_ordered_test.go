@@ -0,0 +1,121 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// seqs reads n messages from sub and returns their sequence numbers, acking
+// each one as it goes
+func seqs(t *testing.T, sub *orderedSubscription, n int) []uint64 {
+	t.Helper()
+	var got []uint64
+	for _, m := range requireReceives(t, sub.C, n, time.Second) {
+		if want := fmt.Sprintf("m%d", m.Seq); m.Msg != want {
+			t.Fatalf("message #%d is %q, want %q", m.Seq, m.Msg, want)
+		}
+		got = append(got, m.Seq)
+		sub.Ack(m.Seq)
+	}
+	return got
+}
+
+// TestOrderedResumeWithinRetention disconnects a subscriber part way and
+// resumes it from its ack while the messages it missed are still retained,
+// and checks it gets exactly those, in order, then new ones
+func TestOrderedResumeWithinRetention(t *testing.T) {
+	b := newOrderedBroadcaster(4)
+	defer b.close()
+	sub, err := b.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		b.Publish(fmt.Sprintf("m%d", i))
+	}
+	if got := seqs(t, sub, 2); fmt.Sprint(got) != "[1 2]" {
+		t.Fatalf("before disconnecting got %v", got)
+	}
+	b.Unsubscribe(sub)
+	for i := 3; i <= 6; i++ {
+		b.Publish(fmt.Sprintf("m%d", i))
+	}
+
+	resumed, err := b.Subscribe(sub.Acked())
+	if err != nil {
+		t.Fatalf("resume after #%d with #3-#6 retained: %v", sub.Acked(), err)
+	}
+	b.Publish("m7")
+	if got := seqs(t, resumed, 5); fmt.Sprint(got) != "[3 4 5 6 7]" {
+		t.Fatalf("resumed subscriber got %v, want 3 to 7", got)
+	}
+	requireNoReceive(t, resumed.C, 20*time.Millisecond)
+}
+
+// TestOrderedResumePastRetention resumes from a sequence whose successors
+// were dropped from retention and checks it fails with an ErrRetentionGap
+// naming the oldest message still held, while resuming at the edge works
+func TestOrderedResumePastRetention(t *testing.T) {
+	b := newOrderedBroadcaster(3)
+	defer b.close()
+	for i := 1; i <= 6; i++ {
+		b.Publish(fmt.Sprintf("m%d", i))
+	}
+	// #4 to #6 are retained, so resuming after #2 would skip #3
+	_, err := b.Subscribe(2)
+	var gap *ErrRetentionGap
+	if !errors.As(err, &gap) || gap.Resume != 2 || gap.Oldest != 4 {
+		t.Fatalf("resume after #2 returned %v, want a gap from 2 to 4", err)
+	}
+	edge, err := b.Subscribe(3)
+	if err != nil {
+		t.Fatalf("resume after #3, the last dropped: %v", err)
+	}
+	if got := seqs(t, edge, 3); fmt.Sprint(got) != "[4 5 6]" {
+		t.Fatalf("resume after #3 got %v", got)
+	}
+}
+
+// TestOrderedAcksIsolated checks one subscriber's acks and disconnection
+// neither move another's resume token nor trim what it can resume from
+func TestOrderedAcksIsolated(t *testing.T) {
+	b := newOrderedBroadcaster(5)
+	defer b.close()
+	fast, _ := b.Subscribe(0)
+	slow, _ := b.Subscribe(0)
+	for i := 1; i <= 4; i++ {
+		b.Publish(fmt.Sprintf("m%d", i))
+	}
+	seqs(t, fast, 4)
+	if got := seqs(t, slow, 1); fmt.Sprint(got) != "[1]" {
+		t.Fatalf("slow subscriber's first message: %v", got)
+	}
+	if fast.Acked() != 4 || slow.Acked() != 1 {
+		t.Fatalf("acks: fast %d, slow %d; want 4 and 1", fast.Acked(), slow.Acked())
+	}
+
+	// Acks are monotonic: an old ack doesn't rewind the token
+	fast.Ack(2)
+	if fast.Acked() != 4 {
+		t.Fatalf("acking #2 after #4 rewound the token to %d", fast.Acked())
+	}
+
+	// The slow subscriber drops and resumes from its own ack, not the fast one's
+	b.Unsubscribe(slow)
+	resumed, err := b.Subscribe(slow.Acked())
+	if err != nil {
+		t.Fatalf("slow subscriber resuming after #%d: %v", slow.Acked(), err)
+	}
+	if got := seqs(t, resumed, 3); fmt.Sprint(got) != "[2 3 4]" {
+		t.Fatalf("slow subscriber resumed with %v, want 2 to 4", got)
+	}
+	if fast.Acked() != 4 {
+		t.Fatalf("the slow subscriber's resume moved the fast one's token to %d", fast.Acked())
+	}
+	b.Publish("m5")
+	if got, other := seqs(t, fast, 1), seqs(t, resumed, 1); fmt.Sprint(got) != "[5]" || fmt.Sprint(other) != "[5]" {
+		t.Fatalf("after #5: fast got %v, resumed slow got %v", got, other)
+	}
+}
@@ -0,0 +1,216 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReceiptOutcome is what became of a message for one subscriber
+type ReceiptOutcome int
+
+const (
+	// ReceiptDelivered means the message is in the subscriber's buffer
+	ReceiptDelivered ReceiptOutcome = iota
+	// ReceiptDropped means the buffer was full and the subscriber's policy is
+	// DropNewest
+	ReceiptDropped
+	// ReceiptTimedOut means the buffer stayed full for the subscriber's whole
+	// timeout
+	ReceiptTimedOut
+	// ReceiptCancelled means the publish's ctx was done before there was room
+	ReceiptCancelled
+	// ReceiptUnsubscribed means the subscriber left mid-publish
+	ReceiptUnsubscribed
+	// ReceiptClosed means the broadcaster closed before there was room
+	ReceiptClosed
+)
+
+func (o ReceiptOutcome) String() string {
+	switch o {
+	case ReceiptDelivered:
+		return "delivered"
+	case ReceiptDropped:
+		return "dropped"
+	case ReceiptTimedOut:
+		return "timed out"
+	case ReceiptCancelled:
+		return "cancelled"
+	case ReceiptUnsubscribed:
+		return "unsubscribed"
+	case ReceiptClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("ReceiptOutcome(%d)", int(o))
+}
+
+// Receipt is the outcome of one publish for one subscriber, numbered from 1
+// in subscription order, and how long the publish waited on it
+type Receipt struct {
+	Subscriber int
+	Outcome    ReceiptOutcome
+	Waited     time.Duration
+}
+
+// deliver sends msg to the subscriber as its policy says, giving up when ctx
+// is done or the subscriber leaves
+func (s *subscriber[T]) deliver(ctx context.Context, msg T) Receipt {
+	start := time.Now()
+	receipt := func(o ReceiptOutcome) Receipt {
+		return Receipt{Subscriber: s.id, Outcome: o, Waited: time.Since(start)}
+	}
+	// A done ctx delivers nothing, even to a subscriber with room
+	if ctx.Err() != nil {
+		return receipt(ReceiptCancelled)
+	}
+
+	if s.policy.Drop == DropNewest {
+		select {
+		case s.ch <- msg:
+			return receipt(ReceiptDelivered)
+		case <-s.gone:
+			return receipt(ReceiptUnsubscribed)
+		default:
+			return receipt(ReceiptDropped)
+		}
+	}
+
+	var timeout <-chan time.Time
+	if s.policy.Timeout > 0 {
+		timer := time.NewTimer(s.policy.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case s.ch <- msg:
+		return receipt(ReceiptDelivered)
+	case <-s.gone:
+		return receipt(ReceiptUnsubscribed)
+	case <-timeout:
+		return receipt(ReceiptTimedOut)
+	case <-ctx.Done():
+		return receipt(ReceiptCancelled)
+	case <-s.closing:
+		return receipt(ReceiptClosed)
+	}
+}
+
+// count adds one publish's receipts to the stats, all in one shard. A
+// subscriber that left mid-publish counts as neither delivered nor dropped.
+func (b *broadcaster[T]) count(receipts []Receipt) {
+	var delivered, dropped int
+	for _, r := range receipts {
+		switch r.Outcome {
+		case ReceiptDelivered:
+			delivered++
+		case ReceiptUnsubscribed:
+		default:
+			dropped++
+		}
+	}
+	shard := &b.counters[atomic.AddUint32(&b.nextShard, 1)%statShards]
+	shard.mu.Lock()
+	shard.published++
+	shard.delivered += delivered
+	shard.dropped += dropped
+	shard.mu.Unlock()
+}
+
+// PublishWithReceipts delivers msg to every subscriber at once, each as its
+// policy says, and returns a receipt per subscriber in subscription order. A
+// slow subscriber holds up only its own delivery, so the publish takes as
+// long as the longest of them, bounded by ctx and the subscribers' timeouts.
+// Publishing to a closed broadcaster returns no receipts.
+func (b *broadcaster[T]) PublishWithReceipts(ctx context.Context, msg T) []Receipt {
+	set, ok := b.beginPublish()
+	if !ok {
+		return nil
+	}
+	defer b.endPublish()
+
+	receipts := make([]Receipt, len(set.subs))
+	var wg sync.WaitGroup
+	for i, sub := range set.subs {
+		wg.Add(1)
+		go func(i int, sub *subscriber[T]) {
+			defer wg.Done()
+			receipts[i] = sub.deliver(ctx, msg)
+		}(i, sub)
+	}
+	wg.Wait()
+	b.count(receipts)
+	return receipts
+}
+
+// receiptOutcomes lists the outcomes of a publish, for printing and comparing
+func receiptOutcomes(receipts []Receipt) string {
+	outcomes := make([]string, len(receipts))
+	for i, r := range receipts {
+		outcomes[i] = r.Outcome.String()
+	}
+	return strings.Join(outcomes, ", ")
+}
+
+// receiptSubscribers subscribes the three kinds of subscriber the receipt
+// examples publish to: a fast one that keeps reading, a slow one that never
+// reads and blocks for up to slowTimeout, and one that never reads either
+// but drops once its single-message buffer is full. It returns the fast
+// subscriber's reader, which finishes once the broadcaster closes.
+func receiptSubscribers(b *broadcaster[string], slowTimeout time.Duration) (<-chan struct{}, error) {
+	policies := []subscriberPolicy{
+		defaultSubscriberPolicy,
+		{Buffer: 2, Drop: Block, Timeout: slowTimeout},
+		{Buffer: 1, Drop: DropNewest},
+	}
+	var fast <-chan string
+	for i, policy := range policies {
+		ch, err := b.subscribeWith(policy)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			fast = ch
+		}
+	}
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		for range fast {
+		}
+	}()
+	return read, nil
+}
+
+// runPublishReceipts publishes to a fast, a slow and a full subscriber and
+// prints the receipts of each publish
+func runPublishReceipts() {
+	fmt.Fprintln(output(), "\nDelivery receipts (subscribers: fast, slow with a 40ms timeout, full buffer dropping new messages):")
+	b := newBroadcaster[string]()
+	read, err := receiptSubscribers(b, 40*time.Millisecond)
+	if err != nil {
+		fail("publish receipts: %v", err)
+		return
+	}
+	var all [][]Receipt
+	for i := 1; i <= 4; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		label := ""
+		if i == 4 {
+			ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+			label = " (10ms deadline)"
+		}
+		start := time.Now()
+		receipts := b.PublishWithReceipts(ctx, fmt.Sprintf("Message %d", i))
+		cancel()
+		fmt.Fprintf(output(), "Message %d%s took %v: %s\n", i, label, time.Since(start).Round(time.Millisecond), receiptOutcomes(receipts))
+		all = append(all, receipts)
+	}
+	b.close()
+	<-read
+	stats := b.Stats()
+	fmt.Fprintf(output(), "Broadcaster: %d delivered, %d dropped\n", stats.Delivered, stats.Dropped)
+	record("receipts", all)
+}
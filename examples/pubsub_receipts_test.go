@@ -0,0 +1,66 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestPublishReceipts publishes to a fast, a slow and a full subscriber and
+// checks each receipt: the slow one times out once its buffer is full, the
+// full one is dropped at once, a deadline mid-publish cancels only the
+// delivery still waiting, and the stats agree with the receipts
+func TestPublishReceipts(t *testing.T) {
+	b := newBroadcaster[string]()
+	const slowTimeout = 40 * time.Millisecond
+	read, err := receiptSubscribers(b, slowTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.subscribeWith(subscriberPolicy{Buffer: 1, Drop: DropOldest}); err == nil {
+		t.Fatalf("subscribing with drop-oldest succeeded")
+	}
+
+	want := []string{
+		"delivered, delivered, delivered",
+		"delivered, delivered, dropped",
+		"delivered, timed out, dropped",
+		"delivered, cancelled, dropped",
+	}
+	var last []Receipt
+	for i, outcomes := range want {
+		ctx, cancel := context.WithCancel(context.Background())
+		if i == 3 {
+			ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+		}
+		last = b.PublishWithReceipts(ctx, fmt.Sprintf("Message %d", i+1))
+		cancel()
+		if got := receiptOutcomes(last); got != outcomes {
+			t.Fatalf("message %d: receipts %s, want %s", i+1, got, outcomes)
+		}
+		for j, r := range last {
+			if r.Subscriber != j+1 {
+				t.Fatalf("message %d: receipt %d is for subscriber %d", i+1, j, r.Subscriber)
+			}
+		}
+		if i == 2 && last[1].Waited < slowTimeout {
+			t.Fatalf("slow subscriber timed out after %v, timeout %v", last[1].Waited, slowTimeout)
+		}
+	}
+	if waited := last[1].Waited; waited >= slowTimeout {
+		t.Fatalf("10ms deadline released the slow subscriber after %v", waited)
+	}
+	if last[2].Waited > 5*time.Millisecond {
+		t.Fatalf("dropping for the full subscriber waited %v", last[2].Waited)
+	}
+
+	b.close()
+	<-read
+	if s := b.Stats(); s.Published != 4 || s.Delivered != 7 || s.Dropped != 5 {
+		t.Fatalf("stats %+v, want 4 published, 7 delivered, 5 dropped", s)
+	}
+	if receipts := b.PublishWithReceipts(context.Background(), "late"); receipts != nil {
+		t.Fatalf("publish after close returned receipts %v", receipts)
+	}
+}
@@ -0,0 +1,236 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestBroadcasterStatsSnapshot publishes from several goroutines to a
+// blocking and a dropping subscriber while snapshotting the stats, and
+// checks every snapshot accounts for each publish to each subscriber
+func TestBroadcasterStatsSnapshot(t *testing.T) {
+	b := newBroadcaster[int]()
+	blocking, err := b.subscribeWith(subscriberPolicy{Buffer: 4, Drop: Block})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropping, err := b.subscribeWith(subscriberPolicy{Buffer: 1, Drop: DropNewest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var readers sync.WaitGroup
+	for _, ch := range []<-chan int{blocking, dropping} {
+		readers.Add(1)
+		go func(ch <-chan int) {
+			defer readers.Done()
+			for range ch {
+			}
+		}(ch)
+	}
+
+	const publishers, each = 4, 500
+	var wg sync.WaitGroup
+	for i := 0; i < publishers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < each; n++ {
+				b.publish(n)
+			}
+		}()
+	}
+	published := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(published)
+	}()
+
+	check := func(s broadcasterStats) {
+		if s.Delivered+s.Dropped != s.Published*s.Subscribers {
+			t.Fatalf("torn snapshot: %d delivered + %d dropped, %d published to %d subscribers", s.Delivered, s.Dropped, s.Published, s.Subscribers)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case <-published:
+			done = true
+		default:
+			check(b.Stats())
+			if time.Now().After(deadline) {
+				t.Fatalf("publishers still running after 5s")
+			}
+		}
+	}
+	s := b.Stats()
+	check(s)
+	if s.Published != publishers*each || s.Subscribers != 2 {
+		t.Fatalf("final stats %+v, want %d published to 2 subscribers", s, publishers*each)
+	}
+	b.close()
+	readers.Wait()
+}
+
+// TestPublishCtxCancel publishes to a fast and a blocked subscriber, cancels
+// the publish, and checks it returns the context error promptly, naming
+// only the blocked subscriber as undelivered
+func TestPublishCtxCancel(t *testing.T) {
+	b := newBroadcaster[string]()
+	defer b.close()
+	fast, err := b.subscribeWith(subscriberPolicy{Buffer: 4, Drop: Block})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nobody reads this one, so its buffer fills after the first message
+	if _, err := b.subscribeWith(subscriberPolicy{Buffer: 1, Drop: Block}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.PublishCtx(context.Background(), "fills the buffer"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err = b.PublishCtx(ctx, "blocks")
+	took := time.Since(start)
+
+	var pubErr *publishError
+	if !errors.Is(err, context.Canceled) || !errors.As(err, &pubErr) {
+		t.Fatalf("cancelled publish returned %v, want a publishError wrapping context.Canceled", err)
+	}
+	if took > 500*time.Millisecond {
+		t.Fatalf("cancelled publish took %v to return", took)
+	}
+	if len(pubErr.Undelivered) != 1 || pubErr.Undelivered[0] != 2 {
+		t.Fatalf("undelivered subscribers %v, want [2]", pubErr.Undelivered)
+	}
+	if got := requireReceives(t, fast, 2, time.Second); got[1] != "blocks" {
+		t.Fatalf("fast subscriber got %v, want both messages", got)
+	}
+	if s := b.Stats(); s.Delivered != 3 || s.Dropped != 1 {
+		t.Fatalf("stats %+v, want 3 delivered and the cancelled delivery dropped", s)
+	}
+}
+
+// TestCloseReleasesBlockedPublish closes a broadcaster while a publish with
+// no deadline is blocked on a subscriber nobody reads, and checks close
+// returns, the publish gives up with a closed receipt and every subscriber
+// channel is closed
+func TestCloseReleasesBlockedPublish(t *testing.T) {
+	b := newBroadcaster[string]()
+	stuck, err := b.subscribeWith(subscriberPolicy{Buffer: 1, Drop: Block})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.publish("fills the buffer")
+
+	receipts := make(chan []Receipt, 1)
+	goLabeled(t, "blocked-publish", func() {
+		receipts <- b.PublishWithReceipts(context.Background(), "blocks")
+	})
+	waitUntil(t, func() bool { return atomic.LoadInt64(&b.publishing) == 1 }, time.Second, "publish never started")
+	requireNoReceive(t, receipts, 20*time.Millisecond)
+
+	closed := make(chan struct{}, 1)
+	goLabeled(t, "close", func() {
+		b.close()
+		closed <- struct{}{}
+	})
+	requireReceives(t, closed, 1, time.Second)
+	if got := requireReceives(t, receipts, 1, time.Second)[0]; len(got) != 1 || got[0].Outcome != ReceiptClosed {
+		t.Fatalf("blocked publish got receipts %v, want one closed", got)
+	}
+	if got := requireReceives(t, stuck, 1, time.Second); got[0] != "fills the buffer" {
+		t.Fatalf("stuck subscriber got %v", got)
+	}
+	if _, ok := <-stuck; ok {
+		t.Fatalf("subscriber channel still open after close")
+	}
+	if s := b.Stats(); s.Published != 2 || s.Delivered != 1 || s.Dropped != 1 || !s.Closed {
+		t.Fatalf("stats %+v, want 2 published, 1 delivered, the released one dropped", s)
+	}
+}
+
+// mutexBroadcaster is the broadcaster before copy-on-write: one mutex
+// guards the subscriber slice and is held for every publish, so concurrent
+// publishers queue behind each other. It is kept only to benchmark against.
+type mutexBroadcaster[T any] struct {
+	mu   sync.Mutex
+	subs []chan T
+}
+
+func (b *mutexBroadcaster[T]) subscribe() <-chan T {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan T, defaultSubscriberPolicy.Buffer)
+	b.subs = append(b.subs, ch)
+	return ch
+}
+
+func (b *mutexBroadcaster[T]) publish(msg T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs {
+		ch <- msg
+	}
+}
+
+func (b *mutexBroadcaster[T]) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs {
+		close(ch)
+	}
+}
+
+// BenchmarkBroadcaster publishes from 8 publishers to 16 draining
+// subscribers through the mutex and the copy-on-write broadcasters; each
+// op is one message delivered to every subscriber
+func BenchmarkBroadcaster(b *testing.B) {
+	const publishers, subscribers = 8, 16
+	type broadcasterUnderTest struct {
+		subscribe func() <-chan int
+		publish   func(int)
+		close     func()
+	}
+	run := func(b *testing.B, bc broadcasterUnderTest) {
+		var readers sync.WaitGroup
+		for i := 0; i < subscribers; i++ {
+			ch := bc.subscribe()
+			readers.Add(1)
+			go func() {
+				defer readers.Done()
+				for range ch {
+				}
+			}()
+		}
+		b.ResetTimer()
+		var wg sync.WaitGroup
+		for p := 0; p < publishers; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				for n := p; n < b.N; n += publishers {
+					bc.publish(n)
+				}
+			}(p)
+		}
+		wg.Wait()
+		b.StopTimer()
+		bc.close()
+		readers.Wait()
+	}
+	b.Run("mutex", func(b *testing.B) {
+		m := &mutexBroadcaster[int]{}
+		run(b, broadcasterUnderTest{m.subscribe, m.publish, m.close})
+	})
+	b.Run("copy-on-write", func(b *testing.B) {
+		c := newBroadcaster[int]()
+		run(b, broadcasterUnderTest{c.subscribe, c.publish, c.close})
+	})
+}
@@ -0,0 +1,155 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Topic is a typed handle for a topic on a Broker. Publishing and
+// subscribing go through the handle, so a payload of the wrong type for the
+// topic is a compile error rather than something a subscriber finds out
+// about at runtime.
+//
+// A topic is identified by its name together with its payload type: two
+// handles created with the same name and type refer to the same topic, while
+// NewTopic[A]("x") and NewTopic[B]("x") are distinct topics that never see
+// each other's messages.
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic returns the handle for the topic name carrying payloads of type T
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+// Name returns the topic's name
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// Broker holds one broadcaster per topic, created on first use
+type Broker struct {
+	mu sync.Mutex
+	// topics maps a Topic[T] handle to its *broadcaster[T]; handles of
+	// different payload types are different keys even with the same name
+	topics map[interface{}]interface{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{topics: make(map[interface{}]interface{})}
+}
+
+// broadcaster returns the topic's broadcaster on b, creating it if needed
+func (t Topic[T]) broadcaster(b *Broker) *broadcaster[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if bc, ok := b.topics[t]; ok {
+		return bc.(*broadcaster[T])
+	}
+	bc := newBroadcaster[T]()
+	b.topics[t] = bc
+	return bc
+}
+
+// Publish delivers v to every subscriber of the topic on b. Publishing to a
+// closed topic does nothing.
+func (t Topic[T]) Publish(b *Broker, v T) {
+	t.broadcaster(b).publish(v)
+}
+
+// Subscribe returns a channel of the topic's messages on b. Subscribing to a
+// closed topic returns a closed channel.
+func (t Topic[T]) Subscribe(b *Broker) <-chan T {
+	return t.broadcaster(b).subscribe()
+}
+
+// Close closes the topic on b: its subscribers' channels close once the
+// publishes in progress finish. Other topics are unaffected.
+func (t Topic[T]) Close(b *Broker) {
+	t.broadcaster(b).close()
+}
+
+// Close closes every topic on the broker
+func (b *Broker) Close() {
+	b.mu.Lock()
+	topics := make([]interface{ close() }, 0, len(b.topics))
+	for _, bc := range b.topics {
+		topics = append(topics, bc.(interface{ close() }))
+	}
+	b.mu.Unlock()
+	for _, bc := range topics {
+		bc.close()
+	}
+}
+
+// OrderPlaced is published on the orders topic
+type OrderPlaced struct {
+	OrderID  int
+	Customer string
+	Total    float64
+}
+
+// PaymentReceived is published on the payments topic
+type PaymentReceived struct {
+	OrderID int
+	Amount  float64
+}
+
+var (
+	ordersTopic   = NewTopic[OrderPlaced]("orders")
+	paymentsTopic = NewTopic[PaymentReceived]("payments")
+)
+
+// runTypedTopics publishes orders and payments on typed topics; the billing
+// subscriber matches payments to orders without any type assertions
+func runTypedTopics() {
+	fmt.Fprintln(output(), "\nTyped topics (orders and payments):")
+	broker := NewBroker()
+	orders := ordersTopic.Subscribe(broker)
+	payments := paymentsTopic.Subscribe(broker)
+
+	outstanding := make(map[int]float64)
+	var settled []int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for orders != nil || payments != nil {
+			select {
+			case o, ok := <-orders:
+				if !ok {
+					orders = nil
+					continue
+				}
+				fmt.Fprintf(output(), "Billing: order %d from %s for %.2f\n", o.OrderID, o.Customer, o.Total)
+				outstanding[o.OrderID] += o.Total
+			case p, ok := <-payments:
+				if !ok {
+					payments = nil
+					continue
+				}
+				outstanding[p.OrderID] -= p.Amount
+				fmt.Fprintf(output(), "Billing: payment of %.2f for order %d, %.2f outstanding\n", p.Amount, p.OrderID, outstanding[p.OrderID])
+				if outstanding[p.OrderID] == 0 {
+					settled = append(settled, p.OrderID)
+				}
+			}
+		}
+	}()
+
+	ordersTopic.Publish(broker, OrderPlaced{OrderID: 1, Customer: "ada", Total: 30})
+	ordersTopic.Publish(broker, OrderPlaced{OrderID: 2, Customer: "grace", Total: 12.5})
+	time.Sleep(10 * time.Millisecond)
+	paymentsTopic.Publish(broker, PaymentReceived{OrderID: 1, Amount: 30})
+	paymentsTopic.Publish(broker, PaymentReceived{OrderID: 2, Amount: 10})
+	// paymentsTopic.Publish(broker, OrderPlaced{...}) would not compile
+	broker.Close()
+	<-done
+
+	fmt.Fprintf(output(), "Settled orders: %v\n", settled)
+	record("typed_topics_settled", settled)
+	if fmt.Sprint(settled) != "[1]" {
+		fail("typed topics: settled %v, want only order 1", settled)
+	}
+}
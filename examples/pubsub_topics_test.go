@@ -0,0 +1,48 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTypedTopics checks that topics are isolated from each other, that
+// closing one topic leaves the others open, and that same-named handles are
+// the same topic only when their payload types match
+func TestTypedTopics(t *testing.T) {
+	broker := NewBroker()
+	defer broker.Close()
+
+	a := NewTopic[int]("a")
+	b := NewTopic[int]("b")
+	aStrings := NewTopic[string]("a")
+	subA, subB, subAStrings := a.Subscribe(broker), b.Subscribe(broker), aStrings.Subscribe(broker)
+	subAgain := NewTopic[int]("a").Subscribe(broker)
+
+	a.Publish(broker, 1)
+	if got := requireReceives(t, subA, 1, time.Second); got[0] != 1 {
+		t.Fatalf("subscriber of a got %v, want 1", got)
+	}
+	if got := requireReceives(t, subAgain, 1, time.Second); got[0] != 1 {
+		t.Fatalf("second handle for a got %v, want 1", got)
+	}
+	requireNoReceive(t, subB, 20*time.Millisecond)
+	requireNoReceive(t, subAStrings, 20*time.Millisecond)
+
+	// Closing a closes its subscriptions only
+	a.Close(broker)
+	if _, ok := <-subA; ok {
+		t.Fatalf("subscription to a still open after closing a")
+	}
+	if _, ok := <-a.Subscribe(broker); ok {
+		t.Fatalf("subscribing to closed topic a returned an open channel")
+	}
+	a.Publish(broker, 2) // dropped, not blocked
+	b.Publish(broker, 3)
+	aStrings.Publish(broker, "still open")
+	if got := requireReceives(t, subB, 1, time.Second); got[0] != 3 {
+		t.Fatalf("topic b after closing a got %v, want 3", got)
+	}
+	if got := requireReceives(t, subAStrings, 1, time.Second); got[0] != "still open" {
+		t.Fatalf("string topic a after closing int topic a got %v", got)
+	}
+}
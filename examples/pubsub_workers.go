@@ -0,0 +1,101 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// subscriberWorkers is a small worker pool behind one subscription
+type subscriberWorkers struct {
+	handled int64
+	running int64
+	peak    int64
+	done    chan struct{}
+}
+
+// subscribeWithWorkers subscribes to b and runs handler for each message on
+// one of n goroutines, so a heavy handler doesn't cap the subscriber at one
+// message at a time. With n=1 messages are handled one by one in publish
+// order; with more workers they are handled in parallel and may finish in
+// any order. The workers drain whatever is buffered once the subscription
+// closes, then exit; Wait joins them.
+func (b *broadcaster[T]) subscribeWithWorkers(n int, handler func(msg T)) *subscriberWorkers {
+	if n < 1 {
+		n = 1
+	}
+	ch := b.subscribe()
+	w := &subscriberWorkers{done: make(chan struct{})}
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for msg := range ch {
+				heartbeat()
+				running := atomic.AddInt64(&w.running, 1)
+				for {
+					peak := atomic.LoadInt64(&w.peak)
+					if running <= peak || atomic.CompareAndSwapInt64(&w.peak, peak, running) {
+						break
+					}
+				}
+				handler(msg)
+				atomic.AddInt64(&w.running, -1)
+				atomic.AddInt64(&w.handled, 1)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(w.done)
+	}()
+	return w
+}
+
+// Wait blocks until the subscription has closed and every worker has exited
+func (w *subscriberWorkers) Wait() {
+	<-w.done
+}
+
+// Handled returns how many messages the workers have handled
+func (w *subscriberWorkers) Handled() int {
+	return int(atomic.LoadInt64(&w.handled))
+}
+
+// Peak returns the most handlers that ever ran at once
+func (w *subscriberWorkers) Peak() int {
+	return int(atomic.LoadInt64(&w.peak))
+}
+
+// runHeavySubscriber publishes to a subscriber whose handler is slower than
+// the publish rate, first with one worker and then with four
+func runHeavySubscriber() {
+	const messages, interval, handling = 24, 10 * time.Millisecond, 35 * time.Millisecond
+	fmt.Fprintf(output(), "\nHeavy-handler subscriber (%d messages every %v, %v per message):\n", messages, interval, handling)
+	elapsed := make(map[int]time.Duration)
+	for _, workers := range []int{1, 4} {
+		b := newBroadcaster[string]()
+		sub := b.subscribeWithWorkers(workers, func(string) { time.Sleep(handling) })
+		start := time.Now()
+		for i := 1; i <= messages; i++ {
+			b.publish(fmt.Sprintf("Message %d", i))
+			time.Sleep(interval)
+		}
+		published := time.Since(start)
+		b.close()
+		sub.Wait()
+		elapsed[workers] = time.Since(start)
+		fmt.Fprintf(output(), "%d worker(s): publishing took %v, all handled after %v, %d at once at most\n",
+			workers, published.Round(time.Millisecond), elapsed[workers].Round(time.Millisecond), sub.Peak())
+		if sub.Handled() != messages {
+			fail("heavy subscriber: %d workers handled %d of %d messages", workers, sub.Handled(), messages)
+		}
+	}
+	record("heavy_subscriber_ms", map[string]int64{"1_worker": elapsed[1].Milliseconds(), "4_workers": elapsed[4].Milliseconds()})
+	// Four workers handle a message every ~9ms, enough to keep up
+	if elapsed[4] > 2*messages*interval {
+		fail("heavy subscriber: 4 workers took %v for %d messages published over %v", elapsed[4], messages, messages*interval)
+	}
+}
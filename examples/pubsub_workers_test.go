@@ -0,0 +1,56 @@
+package examples
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSubscribeWithWorkers floods a subscription with four workers and
+// checks that every message is handled exactly once, that all four run at
+// once under load and that closing the broadcaster joins every worker
+func TestSubscribeWithWorkers(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	b := newBroadcaster[string]()
+	var mu sync.Mutex
+	handled := make(map[string]int)
+	// The first four handlers hold until all four are running, so the peak
+	// is reached without relying on how long a handler takes
+	var started int32
+	allBusy := make(chan struct{})
+	sub := b.subscribeWithWorkers(4, func(msg string) {
+		if atomic.AddInt32(&started, 1) == 4 {
+			close(allBusy)
+		}
+		<-allBusy
+		mu.Lock()
+		handled[msg]++
+		mu.Unlock()
+	})
+	for i := 0; i < 40; i++ {
+		b.publish(fmt.Sprintf("m%d", i))
+	}
+	b.close()
+	joined := make(chan struct{}, 1)
+	goLabeled(t, "join-workers", func() {
+		sub.Wait()
+		joined <- struct{}{}
+	})
+	requireReceives(t, joined, 1, time.Second)
+
+	if len(handled) != 40 {
+		t.Fatalf("%d distinct messages handled, want 40", len(handled))
+	}
+	for msg, n := range handled {
+		if n != 1 {
+			t.Fatalf("message %s handled %d times", msg, n)
+		}
+	}
+	if p := sub.Peak(); p != 4 {
+		t.Fatalf("at most %d handlers ran at once, want 4", p)
+	}
+	goroutineCountStable(t, baseline)
+}
@@ -0,0 +1,114 @@
+package examples
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// exactRank is the fraction of sorted values at or below v
+func exactRank(sorted []float64, v float64) float64 {
+	return float64(sort.Search(len(sorted), func(i int) bool { return sorted[i] > v })) / float64(len(sorted))
+}
+
+// checkQuantileEstimates reports an error if any estimate's rank in values
+// is outside the documented tolerance of p
+func checkQuantileEstimates(name string, values []float64, quantile func(p float64) float64) error {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	for _, p := range []float64{0.1, 0.5, 0.9, 0.95, 0.99} {
+		est := quantile(p)
+		rank := exactRank(sorted, est)
+		// One value's worth of slack for where ties and rounding land
+		tol := quantileRankTolerance(p) + 1/float64(len(sorted))
+		if math.Abs(rank-p) > tol {
+			return fmt.Errorf("%s: p%g estimate %.3f has rank %.4f, want within %.4f of %g", name, p*100, est, rank, tol, p)
+		}
+	}
+	return nil
+}
+
+// TestStreamingQuantile feeds known distributions through the estimator,
+// including ascending input that defeats estimators tuned to arrival order,
+// and then observes from several goroutines into a sharded one
+func TestStreamingQuantile(t *testing.T) {
+	// The tolerance is a 4-sigma bound, so an unlucky reservoir can miss it
+	// now and then; fixed seeds keep the test deterministic
+	defer func(seed int64) { quantileSeed = seed }(quantileSeed)
+	quantileSeed = 0
+	rng := rand.New(rand.NewSource(42))
+	const n = 50000
+	distributions := map[string]func(i int) float64{
+		"uniform": func(int) float64 { return rng.Float64() * 100 },
+		// Two tight modes far apart, a third of the values in the upper one
+		"bimodal": func(int) float64 {
+			if rng.Intn(3) == 0 {
+				return 1000 + rng.NormFloat64()*5
+			}
+			return 10 + rng.NormFloat64()
+		},
+		"sorted": func(i int) float64 { return float64(i) },
+	}
+	for _, name := range []string{"uniform", "bimodal", "sorted"} {
+		q := NewStreamingQuantile()
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = distributions[name](i)
+			q.Observe(values[i])
+		}
+		if err := checkQuantileEstimates(name, values, q.Quantile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Below the reservoir size every quantile is exact
+	small := NewStreamingQuantile()
+	for i := 1; i <= 100; i++ {
+		small.Observe(float64(i))
+	}
+	if got := small.Quantile(0.5); got != 50 {
+		t.Fatalf("median of 1..100 is %g, want 50", got)
+	}
+	if !math.IsNaN(NewStreamingQuantile().Quantile(0.5)) {
+		t.Fatalf("empty estimator returned a quantile")
+	}
+
+	// Observers own a shard each and see differently sized, skewed slices of
+	// the range, so the merge has to weight the shards to get it right
+	const observers = 8
+	sharded := NewShardedQuantile(observers)
+	values := make([][]float64, observers)
+	var wg sync.WaitGroup
+	for o := 0; o < observers; o++ {
+		wg.Add(1)
+		go func(o int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(o)))
+			shard := sharded.Shard(o)
+			for i := 0; i < 2000*(o+1); i++ {
+				v := float64(o*100) + r.Float64()*100
+				values[o] = append(values[o], v)
+				shard.Observe(v)
+			}
+		}(o)
+	}
+	// Readers merge while observers are still writing
+	for i := 0; i < 20; i++ {
+		sharded.Quantile(0.5)
+	}
+	wg.Wait()
+
+	var all []float64
+	for _, v := range values {
+		all = append(all, v...)
+	}
+	if got := sharded.Count(); got != int64(len(all)) {
+		t.Fatalf("sharded estimator counted %d values, %d were observed", got, len(all))
+	}
+	if err := checkQuantileEstimates("sharded", all, sharded.Quantile); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,51 +1,80 @@
 package examples
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 )
 
 // RunRateLimiting demonstrates rate limiting patterns.
-func RunRateLimiting() {
-	fmt.Println("=== Rate Limiting Pattern Example ===")
+func RunRateLimiting(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Rate Limiting Pattern Example ===")
 
+	phase("fixed")
 	// Example 1: Fixed rate limiting
-	fmt.Println("\n1. Fixed rate limiting (2 requests per second):")
+	fmt.Fprintln(output(), "\n1. Fixed rate limiting (2 requests per second):")
 	limiter := newFixedRateLimiter(2, time.Second)
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var processedAt []string
 
 	for i := 1; i <= 6; i++ {
+		heartbeat()
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
 			limiter.Wait()
-			fmt.Printf("Request %d processed at %v\n", id, time.Now().Format("15:04:05.000"))
+			heartbeat()
+			at := time.Now().Format("15:04:05.000")
+			fmt.Fprintf(output(), "Request %d processed at %v\n", id, at)
+			mu.Lock()
+			processedAt = append(processedAt, at)
+			mu.Unlock()
 		}(i)
 	}
 
 	wg.Wait()
+	record("fixed_rate_processed_at", processedAt)
 
+	phase("token-bucket")
 	// Example 2: Token bucket rate limiting
-	fmt.Println("\n2. Token bucket rate limiting (3 tokens per second, burst of 5):")
+	fmt.Fprintln(output(), "\n2. Token bucket rate limiting (3 tokens per second, burst of 5):")
 	tokenLimiter := newTokenBucketLimiter(3, 5)
 	var wg2 sync.WaitGroup
+	granted := 0
 
 	for i := 1; i <= 10; i++ {
+		heartbeat()
 		wg2.Add(1)
 		go func(id int) {
 			defer wg2.Done()
 			if tokenLimiter.Allow() {
-				fmt.Printf("Token request %d granted at %v\n", id, time.Now().Format("15:04:05.000"))
+				fmt.Fprintf(output(), "Token request %d granted at %v\n", id, time.Now().Format("15:04:05.000"))
+				mu.Lock()
+				granted++
+				mu.Unlock()
 			} else {
-				fmt.Printf("Token request %d denied at %v\n", id, time.Now().Format("15:04:05.000"))
+				fmt.Fprintf(output(), "Token request %d denied at %v\n", id, time.Now().Format("15:04:05.000"))
 			}
 		}(i)
 	}
 
 	wg2.Wait()
+	record("token_bucket", map[string]int{"requests": 10, "granted": granted})
+
+	phase("bounded-wait")
+	// Example 3: Waiting a bounded time for a token
+	fmt.Fprintln(output(), "\n3. Bounded waits (token bucket drained, 3 tokens per second):")
+	for _, maxWait := range []time.Duration{100 * time.Millisecond, 500 * time.Millisecond} {
+		heartbeat()
+		start := time.Now()
+		ok := tokenLimiter.AllowWithin(context.Background(), maxWait)
+		fmt.Fprintf(output(), "AllowWithin(%v): granted %t after %v\n", maxWait, ok, time.Since(start).Round(time.Millisecond))
+	}
+	tokenLimiter.Stop()
 
-	fmt.Println("\nRate Limiting example completed!")
+	fmt.Fprintln(output(), "\nRate Limiting example completed!")
 }
 
 // Fixed rate limiter using time.Ticker
@@ -78,6 +107,7 @@ type tokenBucketLimiter struct {
 	burst      int
 	mu         sync.Mutex
 	lastRefill time.Time
+	stop       chan struct{}
 }
 
 func newTokenBucketLimiter(rate int, burst int) *tokenBucketLimiter {
@@ -86,6 +116,7 @@ func newTokenBucketLimiter(rate int, burst int) *tokenBucketLimiter {
 		rate:       time.Second / time.Duration(rate),
 		burst:      burst,
 		lastRefill: time.Now(),
+		stop:       make(chan struct{}),
 	}
 
 	// Fill the bucket initially
@@ -103,12 +134,17 @@ func (t *tokenBucketLimiter) refill() {
 	ticker := time.NewTicker(t.rate)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
 		select {
-		case t.tokens <- struct{}{}:
-			// Token added successfully
-		default:
-			// Bucket is full, skip
+		case <-ticker.C:
+			select {
+			case t.tokens <- struct{}{}:
+				// Token added successfully
+			default:
+				// Bucket is full, skip
+			}
+		case <-t.stop:
+			return
 		}
 	}
 }
@@ -125,3 +161,48 @@ func (t *tokenBucketLimiter) Allow() bool {
 func (t *tokenBucketLimiter) Wait() {
 	<-t.tokens
 }
+
+// WaitCtx takes a token, giving up with ErrTimeout once ctx is done
+func (t *tokenBucketLimiter) WaitCtx(ctx context.Context) error {
+	select {
+	case <-t.tokens:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for token: %w: %w", ErrTimeout, ctx.Err())
+	}
+}
+
+// AllowWithin takes a token if one is available, otherwise waits up to
+// maxWait for the next refill. It reports false if no token arrived in time
+// or ctx ended first: a middle ground between Allow and WaitCtx.
+func (t *tokenBucketLimiter) AllowWithin(ctx context.Context, maxWait time.Duration) bool {
+	if t.Allow() {
+		return true
+	}
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	select {
+	case <-t.tokens:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// refund returns up to n unused tokens to the bucket; any that don't fit are dropped
+func (t *tokenBucketLimiter) refund(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case t.tokens <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// Stop ends the refill goroutine
+func (t *tokenBucketLimiter) Stop() {
+	close(t.stop)
+}
@@ -0,0 +1,28 @@
+package examples
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestAllowWithin drains a bucket refilled every 100ms and checks that
+// AllowWithin gives up on budgets shorter than that and succeeds on longer ones
+func TestAllowWithin(t *testing.T) {
+	limiter := newTokenBucketLimiter(10, 1)
+	defer limiter.Stop()
+	limiter.Wait()
+
+	ctx := context.Background()
+	if limiter.AllowWithin(ctx, 20*time.Millisecond) {
+		t.Fatalf("got a token within 20ms of draining a bucket refilled every 100ms")
+	}
+	if !limiter.AllowWithin(ctx, 300*time.Millisecond) {
+		t.Fatalf("no token within 300ms from a bucket refilled every 100ms")
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if limiter.AllowWithin(cancelled, time.Second) {
+		t.Fatalf("got a token from an empty bucket with a cancelled context")
+	}
+}
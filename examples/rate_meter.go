@@ -0,0 +1,95 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateMeter measures events per second over a sliding window. The window is a
+// ring of fixed-width buckets; as time moves on, the oldest buckets are
+// cleared and reused, so memory stays constant however long it runs.
+type RateMeter struct {
+	mu        sync.Mutex
+	buckets   []int64
+	width     time.Duration
+	head      int
+	headStart time.Time
+	start     time.Time
+	now       func() time.Time
+}
+
+// NewRateMeter creates a meter over window split into the given number of
+// buckets. It panics unless there is at least one bucket and each bucket is
+// at least a nanosecond wide.
+func NewRateMeter(window time.Duration, buckets int) *RateMeter {
+	return newRateMeterWithClock(window, buckets, time.Now)
+}
+
+func newRateMeterWithClock(window time.Duration, buckets int, now func() time.Time) *RateMeter {
+	if buckets < 1 {
+		panic(fmt.Sprintf("rate meter: %d buckets, need at least 1", buckets))
+	}
+	width := window / time.Duration(buckets)
+	if width <= 0 {
+		panic(fmt.Sprintf("rate meter: window %v is too short for %d buckets", window, buckets))
+	}
+	t := now()
+	return &RateMeter{
+		buckets:   make([]int64, buckets),
+		width:     width,
+		headStart: t,
+		start:     t,
+		now:       now,
+	}
+}
+
+// Add records n events at the current time
+func (m *RateMeter) Add(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advance(m.now())
+	m.buckets[m.head] += int64(n)
+}
+
+// Rate returns the events per second seen over the window. Until a full
+// window has passed, it averages over the time since the meter was created.
+func (m *RateMeter) Rate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.advance(now)
+
+	var total int64
+	for _, n := range m.buckets {
+		total += n
+	}
+
+	span := m.width * time.Duration(len(m.buckets))
+	if elapsed := now.Sub(m.start); elapsed < span {
+		span = elapsed
+	}
+	if span <= 0 {
+		return 0
+	}
+	return float64(total) / span.Seconds()
+}
+
+// advance moves the head bucket up to now, clearing the buckets it passes
+func (m *RateMeter) advance(now time.Time) {
+	steps := int(now.Sub(m.headStart) / m.width)
+	if steps <= 0 {
+		return
+	}
+	if steps >= len(m.buckets) {
+		for i := range m.buckets {
+			m.buckets[i] = 0
+		}
+	} else {
+		for i := 1; i <= steps; i++ {
+			m.buckets[(m.head+i)%len(m.buckets)] = 0
+		}
+	}
+	m.head = (m.head + steps) % len(m.buckets)
+	m.headStart = m.headStart.Add(time.Duration(steps) * m.width)
+}
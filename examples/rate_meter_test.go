@@ -0,0 +1,62 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRateMeterAgesOutBuckets drives the meter with a fake clock and checks
+// events stop counting once their bucket falls out of the window
+func TestRateMeterAgesOutBuckets(t *testing.T) {
+	clock := time.Unix(0, 0)
+	m := newRateMeterWithClock(10*time.Second, 10, func() time.Time { return clock })
+	at := func(d time.Duration) { clock = time.Unix(0, 0).Add(d) }
+
+	m.Add(10)
+	at(time.Second)
+	if r := m.Rate(); r != 10 {
+		t.Fatalf("rate after 1s is %v, want 10", r)
+	}
+	at(5 * time.Second)
+	m.Add(20)
+	if r := m.Rate(); r != 6 {
+		t.Fatalf("rate after 5s is %v, want 6 (30 events over 5s)", r)
+	}
+	// The first bucket has aged out; the events at 5s are still in the window
+	at(10 * time.Second)
+	if r := m.Rate(); r != 2 {
+		t.Fatalf("rate after 10s is %v, want 2 (20 events over 10s)", r)
+	}
+	at(14500 * time.Millisecond)
+	if r := m.Rate(); r != 2 {
+		t.Fatalf("rate after 14.5s is %v, want 2", r)
+	}
+	at(15500 * time.Millisecond)
+	if r := m.Rate(); r != 0 {
+		t.Fatalf("rate after 15.5s is %v, want 0 once every bucket aged out", r)
+	}
+	// A long idle gap clears the whole ring in one step
+	m.Add(5)
+	at(time.Hour)
+	if r := m.Rate(); r != 0 {
+		t.Fatalf("rate after an hour idle is %v, want 0", r)
+	}
+}
+
+// TestRateMeterRejectsBadShape checks zero buckets and a window too short to
+// split both panic instead of dividing by zero later
+func TestRateMeterRejectsBadShape(t *testing.T) {
+	for _, c := range []struct {
+		window  time.Duration
+		buckets int
+	}{{time.Second, 0}, {time.Second, -3}, {5 * time.Nanosecond, 10}, {0, 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("NewRateMeter(%v, %d) did not panic", c.window, c.buckets)
+				}
+			}()
+			NewRateMeter(c.window, c.buckets)
+		}()
+	}
+}
@@ -0,0 +1,58 @@
+package examples
+
+import "sync"
+
+// The examples record their key results as they go so main.go can print them
+// as JSON with --json. The human-readable prints stay as they are; in JSON
+// mode main.go discards them.
+var (
+	recordedMu sync.Mutex
+	recorded   = make(map[string]interface{})
+	// byPattern keeps each example's results apart, for its golden file
+	byPattern = make(map[string]map[string]interface{})
+)
+
+// record stores a named result, replacing any earlier value under that name
+func record(name string, value interface{}) {
+	heartbeat()
+	key := runningPattern()
+	recordedMu.Lock()
+	defer recordedMu.Unlock()
+	recorded[name] = value
+	if key == "" {
+		return
+	}
+	if byPattern[key] == nil {
+		byPattern[key] = make(map[string]interface{})
+	}
+	byPattern[key][name] = value
+}
+
+// patternResults returns what the example key recorded
+func patternResults(key string) map[string]interface{} {
+	recordedMu.Lock()
+	defer recordedMu.Unlock()
+	out := make(map[string]interface{}, len(byPattern[key]))
+	for k, v := range byPattern[key] {
+		out[k] = v
+	}
+	return out
+}
+
+// Results returns everything the example recorded
+func Results() map[string]interface{} {
+	recordedMu.Lock()
+	defer recordedMu.Unlock()
+	out := make(map[string]interface{}, len(recorded))
+	for k, v := range recorded {
+		out[k] = v
+	}
+	return out
+}
+
+// Failures returns the messages of every failed check
+func Failures() []string {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	return append([]string(nil), failures...)
+}
@@ -1,133 +1,505 @@
 package examples
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // RunResourcePooling demonstrates the resource pooling pattern.
-func RunResourcePooling() {
-	fmt.Println("=== Resource Pooling Pattern Example ===")
+func RunResourcePooling(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Resource Pooling Pattern Example ===")
 
+	phase("db")
 	// Example 1: Database Connection Pool
-	fmt.Println("\n1. Database Connection Pool Example:")
+	fmt.Fprintln(output(), "\n1. Database Connection Pool Example:")
 	dbPool := newDBConnectionPool(3, 5)
+	publishStats("db_pool", func() interface{} { return dbPool.Stats() })
 
+	// Eight workers share at most five connections, so some retry: each
+	// attempt waits 100ms, then backs off with jitter
+	retry := BackoffPolicy{Base: 50 * time.Millisecond, Max: 400 * time.Millisecond, MaxAttempts: 10, AttemptTimeout: 100 * time.Millisecond}
 	var wg sync.WaitGroup
 	for i := 1; i <= 8; i++ {
+		heartbeat()
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
-			conn := dbPool.getConnection()
-			fmt.Printf("Worker %d: Got DB connection %d\n", id, conn.id)
+			policy := retry
+			policy.Seed = int64(id)
+			conn, err := dbPool.GetWithRetry(ctx, policy)
+			if err != nil {
+				fmt.Fprintf(output(), "Worker %d: no DB connection (%s): %v\n", id, errorKind(err), err)
+				return
+			}
+			fmt.Fprintf(output(), "Worker %d: Got DB connection %d\n", id, conn.id)
 
 			// Simulate database operation
-			time.Sleep(time.Duration(rand.Intn(500)+200) * time.Millisecond)
-			fmt.Printf("Worker %d: Executing query on connection %d\n", id, conn.id)
+			pause(time.Duration(rand.Intn(500)+200) * time.Millisecond)
+			fmt.Fprintf(output(), "Worker %d: Executing query on connection %d\n", id, conn.id)
 
 			dbPool.releaseConnection(conn)
-			fmt.Printf("Worker %d: Released DB connection %d\n", id, conn.id)
+			fmt.Fprintf(output(), "Worker %d: Released DB connection %d\n", id, conn.id)
 		}(i)
 	}
 	wg.Wait()
+	stats := dbPool.Stats()
+	fmt.Fprintf(output(), "DB pool stats: max %d, created %d, in use %d, idle %d\n", stats.MaxSize, stats.Created, stats.InUse, stats.Idle)
+	record("db_pool", stats)
+	fmt.Fprintln(output(), "Attempts per acquisition:")
+	retryStats := dbPool.RetryStats()
+	printAttempts(retryStats)
+	record("db_pool_retries", retryStats)
 	dbPool.close()
 
+	phase("http")
 	// Example 2: HTTP Client Pool
-	fmt.Println("\n2. HTTP Client Pool Example:")
+	fmt.Fprintln(output(), "\n2. HTTP Client Pool Example:")
 	clientPool := newHTTPClientPool(2, 4)
 
 	for i := 1; i <= 6; i++ {
+		heartbeat()
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
 			client := clientPool.getClient()
-			fmt.Printf("Worker %d: Got HTTP client %d\n", id, client.id)
+			fmt.Fprintf(output(), "Worker %d: Got HTTP client %d\n", id, client.id)
 
 			// Simulate API request
-			time.Sleep(time.Duration(rand.Intn(300)+100) * time.Millisecond)
-			fmt.Printf("Worker %d: Making API request with client %d\n", id, client.id)
+			pause(time.Duration(rand.Intn(300)+100) * time.Millisecond)
+			fmt.Fprintf(output(), "Worker %d: Making API request with client %d\n", id, client.id)
 
 			clientPool.releaseClient(client)
-			fmt.Printf("Worker %d: Released HTTP client %d\n", id, client.id)
+			fmt.Fprintf(output(), "Worker %d: Released HTTP client %d\n", id, client.id)
 		}(i)
 	}
 	wg.Wait()
 	clientPool.close()
 
-	fmt.Println("\nResource Pooling example completed!")
+	phase("errors")
+	// Example 3: Failure paths surface typed errors
+	fmt.Fprintln(output(), "\n3. Pool failure paths:")
+	smallPool := newDBConnectionPool(1, 1)
+	held, _ := smallPool.getConnectionCtx(context.Background())
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	_, err := smallPool.getConnectionCtx(waitCtx)
+	cancel()
+	fmt.Fprintf(output(), "Exhausted pool (%s): %v\n", errorKind(err), err)
+	smallPool.releaseConnection(held)
+	smallPool.close()
+	_, err = smallPool.getConnectionCtx(context.Background())
+	fmt.Fprintf(output(), "Closed pool (%s): %v\n", errorKind(err), err)
+	runCircuitBreaker()
+
+	phase("degraded")
+	// Example 4: Degraded mode serves best-effort connections under exhaustion
+	fmt.Fprintln(output(), "\n4. Degraded mode under exhaustion:")
+	degradedPool := newDBConnectionPool(2, 2)
+	degradedPool.enableDegradedMode()
+	var pooled, degraded []*dbConnection
+	for i := 1; i <= 5; i++ {
+		heartbeat()
+		conn := degradedPool.getConnection()
+		if conn.degraded {
+			fmt.Fprintf(output(), "Request %d: pool exhausted, got degraded connection d%d (best-effort)\n", i, conn.id)
+			degraded = append(degraded, conn)
+		} else {
+			fmt.Fprintf(output(), "Request %d: got pooled connection %d\n", i, conn.id)
+			pooled = append(pooled, conn)
+		}
+	}
+	for _, conn := range append(pooled, degraded...) {
+		degradedPool.releaseConnection(conn)
+	}
+	stats = degradedPool.Stats()
+	record("degraded_pool", stats)
+	fmt.Fprintf(output(), "Degraded pool stats: created %d, idle %d, degraded served %d, degraded in use %d\n",
+		stats.Created, stats.Idle, stats.Degraded, stats.DegradedInUse)
+	verify("degraded pool", func() error {
+		if len(degraded) != 3 || stats.Idle != 2 || stats.DegradedInUse != 0 {
+			return fmt.Errorf("got %d degraded connections and %d idle after release, want 3 and 2", len(degraded), stats.Idle)
+		}
+		return nil
+	})
+	degradedPool.close()
+
+	phase("warmup")
+	// Example 5: Warming up a pool whose connections are slow to dial
+	fmt.Fprintln(output(), "\n5. Warmup before the first requests (dial takes 100ms):")
+	slowDial := func(id int) *dbConnection {
+		time.Sleep(100 * time.Millisecond)
+		return dialDBConnection(id)
+	}
+	coldPool := newDBConnectionPool(0, 3)
+	coldPool.dial = slowDial
+	start := time.Now()
+	conn := coldPool.getConnection()
+	coldWait := time.Since(start)
+	coldPool.releaseConnection(conn)
+	coldPool.close()
+
+	warmPool := newDBConnectionPool(0, 3)
+	warmPool.dial = slowDial
+	warmed := warmPool.Warmup(5)
+	warmStats := warmPool.Stats()
+	start = time.Now()
+	conn = warmPool.getConnection()
+	warmWait := time.Since(start)
+	warmPool.releaseConnection(conn)
+	fmt.Fprintf(output(), "Warmup(5) on a pool of max 3 created %d connections\n", warmed)
+	fmt.Fprintf(output(), "First request: %v on a cold pool, %v on the warmed pool\n",
+		coldWait.Round(time.Millisecond), warmWait.Round(time.Microsecond))
+	record("warmup", map[string]interface{}{
+		"created":      warmStats.Created,
+		"cold_wait_ms": coldWait.Milliseconds(),
+		"warm_wait_us": warmWait.Microseconds(),
+	})
+	verify("pool warmup", func() error {
+		if warmed != 3 || warmStats.Created != 3 || warmStats.Idle != 3 {
+			return fmt.Errorf("warmed %d, created %d, idle %d, want 3 of each", warmed, warmStats.Created, warmStats.Idle)
+		}
+		return nil
+	})
+	warmPool.close()
+
+	phase("partitioned")
+	// Example 6: One sub-pool per host under a global connection cap
+	fmt.Fprintln(output(), "\n6. Per-host sub-pools under a global cap of 6:")
+	runPartitionedPool()
+
+	phase("sticky")
+	// Example 7: Sticky sessions pin each key to one connection
+	fmt.Fprintln(output(), "\n7. Sticky sessions on a pool of 4:")
+	runStickySessions()
+
+	fmt.Fprintln(output(), "\nResource Pooling example completed!")
+}
+
+// runCircuitBreaker sends requests through a circuit breaker to an upstream
+// that is down for its first four calls: three failures open the breaker,
+// requests fail fast while it cools down, and a trial after the upstream
+// recovers closes it again
+func runCircuitBreaker() {
+	breaker := newCircuitBreaker("upstream", 3, 100*time.Millisecond)
+	calls := 0
+	request := func() error {
+		calls++
+		if calls <= 4 {
+			return errors.New("upstream unavailable")
+		}
+		return nil
+	}
+	var kinds []string
+	send := func(i int) {
+		err := breaker.Do(request)
+		kinds = append(kinds, errorKind(err))
+		fmt.Fprintf(output(), "Request %d (%s, breaker %v): %v\n", i, errorKind(err), breaker.State(), err)
+	}
+	for i := 1; i <= 5; i++ {
+		send(i)
+	}
+	// The trial after the first cooldown still finds the upstream down
+	for _, i := range []int{6, 7} {
+		pause(110 * time.Millisecond)
+		send(i)
+	}
+	fmt.Fprintf(output(), "Upstream called %d times for %d requests\n", calls, len(kinds))
+	record("circuit_breaker", map[string]interface{}{"kinds": kinds, "upstream_calls": calls})
+}
+
+// hostConnection is a connection to one host in the partitioned pool example
+type hostConnection struct {
+	host string
+	id   int
+}
+
+// runPartitionedPool sends uneven load at three hosts sharing one pool
+func runPartitionedPool() {
+	limits := map[string]shardLimits{
+		"api.example.com":   {Min: 1, Max: 4},
+		"db.example.com":    {Min: 1, Max: 2},
+		"cache.example.com": {Min: 0, Max: 3},
+	}
+	load := map[string]int{"api.example.com": 12, "db.example.com": 6, "cache.example.com": 3}
+	hosts := []string{"api.example.com", "db.example.com", "cache.example.com"}
+
+	var dialed sync.Map
+	pool := newPartitionedPool(6,
+		func(host string) shardLimits { return limits[host] },
+		func(host string) *hostConnection {
+			n, _ := dialed.LoadOrStore(host, new(int64))
+			return &hostConnection{host: host, id: int(atomic.AddInt64(n.(*int64), 1))}
+		},
+		hosts...)
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		for i := 1; i <= load[host]; i++ {
+			heartbeat()
+			wg.Add(1)
+			go func(host string, id int) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				defer cancel()
+				conn, err := pool.Get(ctx, host)
+				if err != nil {
+					fmt.Fprintf(output(), "Request %d to %s: %v\n", id, host, err)
+					return
+				}
+				time.Sleep(time.Duration(rand.Intn(40)+20) * time.Millisecond)
+				pool.Put(host, conn)
+			}(host, i)
+		}
+	}
+	wg.Wait()
+
+	stats := pool.Stats()
+	for _, host := range hosts {
+		s := stats.Shards[host]
+		fmt.Fprintf(output(), "%-18s %2d requests, min %d max %d: peak %d, %d idle, %d requests waited\n",
+			host, load[host], s.Min, s.Max, s.Peak, s.Idle, s.Waits)
+	}
+	fmt.Fprintf(output(), "Global: %d of %d connections open, peak %d\n", stats.Total, stats.GlobalMax, stats.PeakTotal)
+	verify("partitioned pool", func() error {
+		for _, host := range hosts {
+			if s := stats.Shards[host]; s.Peak > s.Max {
+				return fmt.Errorf("%s peaked at %d connections, max %d", host, s.Peak, s.Max)
+			}
+		}
+		if stats.PeakTotal > stats.GlobalMax {
+			return fmt.Errorf("peaked at %d connections, global cap %d", stats.PeakTotal, stats.GlobalMax)
+		}
+		return nil
+	})
+
+	reaped := pool.ReapIdle(0)
+	fmt.Fprintf(output(), "Reaping idle connections closed %d, leaving %d (each host's minimum)\n", reaped, pool.Stats().Total)
+	record("partitioned_pool", stats)
+	pool.Close()
 }
 
 // Database Connection Pool
 type dbConnection struct {
 	id       int
 	lastUsed time.Time
+	// degraded marks a throwaway connection handed out while the pool was
+	// exhausted; it is only good for best-effort work and never pooled
+	degraded bool
 }
 
 type dbConnectionPool struct {
 	connections chan *dbConnection
 	maxSize     int
 	created     int
-	mu          sync.Mutex
+	inUse       int
+	discarded   int
+	closed      bool
+	// degradedMode hands out degraded connections instead of blocking when
+	// every connection is in use
+	degradedMode  bool
+	degradedInUse int
+	degradedTotal int
+	// dial opens a new connection; it is called without the lock held, so
+	// a slow dial doesn't hold up the rest of the pool
+	dial func(id int) *dbConnection
+	// hashKey maps GetForKey's keys to connections; nil means FNV-1a
+	hashKey func(key string) uint32
+	// retries records GetWithRetry's acquisitions
+	retries acquireRecorder
+	mu      sync.Mutex
+}
+
+// poolStats is a snapshot of a connection pool. Every field is read under the
+// pool's lock, so InUse+Idle never exceeds MaxSize in any snapshot.
+type poolStats struct {
+	MaxSize   int
+	Created   int
+	InUse     int
+	Idle      int
+	Discarded int
+	// Degraded counts degraded connections handed out, DegradedInUse those
+	// not yet released; neither is part of InUse
+	Degraded      int
+	DegradedInUse int
+}
+
+// Stats returns a consistent snapshot of the pool's counters
+func (p *dbConnectionPool) Stats() poolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return poolStats{
+		MaxSize:       p.maxSize,
+		Created:       p.created,
+		InUse:         p.inUse,
+		Idle:          p.created - p.inUse - p.discarded,
+		Discarded:     p.discarded,
+		Degraded:      p.degradedTotal,
+		DegradedInUse: p.degradedInUse,
+	}
+}
+
+// enableDegradedMode makes an exhausted pool serve degraded connections
+// rather than queueing callers until a connection is released
+func (p *dbConnectionPool) enableDegradedMode() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.degradedMode = true
 }
 
 func newDBConnectionPool(initial, maxSize int) *dbConnectionPool {
 	pool := &dbConnectionPool{
 		connections: make(chan *dbConnection, maxSize),
 		maxSize:     maxSize,
+		dial:        dialDBConnection,
 	}
 
 	// Pre-populate with initial connections
 	for i := 0; i < initial; i++ {
-		pool.connections <- &dbConnection{
-			id:       i + 1,
-			lastUsed: time.Now(),
-		}
+		pool.connections <- pool.dial(i + 1)
 		pool.created++
 	}
 
 	return pool
 }
 
+// dialDBConnection is the default dial: connecting costs nothing
+func dialDBConnection(id int) *dbConnection {
+	return &dbConnection{id: id, lastUsed: time.Now()}
+}
+
+// Warmup eagerly dials up to n idle connections, so the first requests don't
+// pay the connection setup latency. It never takes the pool past maxSize,
+// may run alongside getConnection, and returns how many it created.
+func (p *dbConnectionPool) Warmup(n int) int {
+	warmed := 0
+	for ; warmed < n; warmed++ {
+		p.mu.Lock()
+		if p.closed || p.created >= p.maxSize {
+			p.mu.Unlock()
+			break
+		}
+		// Reserve the slot before dialing so concurrent gets can't overshoot
+		p.created++
+		id := p.created
+		p.mu.Unlock()
+
+		conn := p.dial(id)
+
+		p.mu.Lock()
+		if p.closed {
+			p.discarded++
+			p.mu.Unlock()
+			break
+		}
+		p.connections <- conn // never blocks: idle connections never outnumber maxSize
+		p.mu.Unlock()
+	}
+	return warmed
+}
+
 func (p *dbConnectionPool) getConnection() *dbConnection {
+	conn, _ := p.getConnectionCtx(context.Background())
+	return conn
+}
+
+// getConnectionCtx gets a connection, creating one if the pool is empty and
+// under max size. It fails with ErrPoolClosed once the pool is closed and with
+// ErrTimeout if ctx ends while waiting for a connection to be released.
+func (p *dbConnectionPool) getConnectionCtx(ctx context.Context) (*dbConnection, error) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("get connection: %w", ErrPoolClosed)
+	}
+
 	select {
-	case conn := <-p.connections:
-		conn.lastUsed = time.Now()
-		return conn
+	case conn, ok := <-p.connections:
+		if !ok {
+			return nil, fmt.Errorf("get connection: %w", ErrPoolClosed)
+		}
+		p.checkedOut(conn)
+		return conn, nil
 	default:
 		// Create new connection if pool is empty and under max size
 		p.mu.Lock()
 		if p.created < p.maxSize {
 			p.created++
+			p.inUse++
+			id := p.created
+			p.mu.Unlock()
+			return p.dial(id), nil
+		}
+		if p.degradedMode {
+			// Serve reduced functionality rather than queueing
+			p.degradedTotal++
+			p.degradedInUse++
+			id := p.degradedTotal
 			p.mu.Unlock()
 			return &dbConnection{
-				id:       p.created,
+				id:       id,
 				lastUsed: time.Now(),
-			}
+				degraded: true,
+			}, nil
 		}
 		p.mu.Unlock()
 
 		// Wait for available connection
-		conn := <-p.connections
-		conn.lastUsed = time.Now()
-		return conn
+		select {
+		case conn, ok := <-p.connections:
+			if !ok {
+				return nil, fmt.Errorf("get connection: %w", ErrPoolClosed)
+			}
+			p.checkedOut(conn)
+			return conn, nil
+		case <-ctx.Done():
+			return nil, fmt.Errorf("get connection: %w: %w", ErrTimeout, ctx.Err())
+		}
 	}
 }
 
+// checkedOut records that an idle connection was handed to a caller
+func (p *dbConnectionPool) checkedOut(conn *dbConnection) {
+	p.mu.Lock()
+	p.inUse++
+	p.mu.Unlock()
+	conn.lastUsed = time.Now()
+}
+
 func (p *dbConnectionPool) releaseConnection(conn *dbConnection) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn.degraded {
+		// Degraded connections are throwaway and never rejoin the pool
+		p.degradedInUse--
+		return
+	}
+	p.inUse--
+	if p.closed {
+		// Pool is closed, discard connection
+		p.discarded++
+		return
+	}
 	select {
 	case p.connections <- conn:
 		// Successfully returned to pool
 	default:
 		// Pool is full, discard connection
-		fmt.Printf("Pool full, discarding connection %d\n", conn.id)
+		p.discarded++
+		fmt.Fprintf(output(), "Pool full, discarding connection %d\n", conn.id)
 	}
 }
 
 func (p *dbConnectionPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
 	close(p.connections)
-	fmt.Printf("DB pool closed. Total connections created: %d\n", p.created)
+	fmt.Fprintf(output(), "DB pool closed. Total connections created: %d\n", p.created)
 }
 
 // HTTP Client Pool
@@ -171,9 +543,10 @@ func (p *httpClientPool) getClient() *httpClient {
 		p.mu.Lock()
 		if p.created < p.maxSize {
 			p.created++
+			id := p.created
 			p.mu.Unlock()
 			return &httpClient{
-				id:       p.created,
+				id:       id,
 				lastUsed: time.Now(),
 			}
 		}
@@ -192,11 +565,11 @@ func (p *httpClientPool) releaseClient(client *httpClient) {
 		// Successfully returned to pool
 	default:
 		// Pool is full, discard client
-		fmt.Printf("Pool full, discarding HTTP client %d\n", client.id)
+		fmt.Fprintf(output(), "Pool full, discarding HTTP client %d\n", client.id)
 	}
 }
 
 func (p *httpClientPool) close() {
 	close(p.clients)
-	fmt.Printf("HTTP client pool closed. Total clients created: %d\n", p.created)
+	fmt.Fprintf(output(), "HTTP client pool closed. Total clients created: %d\n", p.created)
 }
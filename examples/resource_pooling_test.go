@@ -0,0 +1,161 @@
+package examples
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPoolStatsSnapshot hammers a small pool with concurrent gets and
+// releases while snapshotting it, and checks no snapshot shows more
+// connections in use and idle than the pool's maximum
+func TestPoolStatsSnapshot(t *testing.T) {
+	p := newDBConnectionPool(1, 3)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				p.releaseConnection(p.getConnection())
+			}
+		}()
+	}
+
+	snapshots := 0
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		s := p.Stats()
+		if s.InUse < 0 || s.Idle < 0 || s.InUse+s.Idle > s.MaxSize {
+			close(stop)
+			wg.Wait()
+			t.Fatalf("torn snapshot after %d: %+v", snapshots, s)
+		}
+		snapshots++
+	}
+	close(stop)
+	wg.Wait()
+	if s := p.Stats(); s.InUse != 0 || s.Created > s.MaxSize {
+		t.Fatalf("after the workers stopped: %+v", s)
+	}
+}
+
+// TestPoolDegradedMode exhausts a degraded-mode pool of 2 with 20 jobs that
+// all hold a connection at once, and checks none of them queues: 2 get pooled
+// connections and 18 degraded ones, every job completes in about one job's
+// time, and releasing a degraded connection discards it rather than pooling it
+func TestPoolDegradedMode(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	const jobs, maxSize, work = 20, 2, 50 * time.Millisecond
+	p := newDBConnectionPool(0, maxSize)
+	defer p.close()
+	p.enableDegradedMode()
+
+	// Every job holds its connection until all have one, so the pool stays
+	// exhausted for the later ones
+	var acquired, done sync.WaitGroup
+	acquired.Add(jobs)
+	done.Add(jobs)
+	conns := make([]*dbConnection, jobs)
+	start := time.Now()
+	for i := 0; i < jobs; i++ {
+		go func(i int) {
+			defer done.Done()
+			conns[i] = p.getConnection()
+			acquired.Done()
+			acquired.Wait()
+			time.Sleep(work)
+			p.releaseConnection(conns[i])
+		}(i)
+	}
+	finished := make(chan struct{})
+	go func() {
+		done.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("jobs still running after 5s on an exhausted pool: %+v", p.Stats())
+	}
+	// Queued for the two pooled connections, 20 jobs would take 10 rounds
+	if elapsed := time.Since(start); elapsed > 4*work {
+		t.Fatalf("%d jobs took %v on a pool of %d, each works %v", jobs, elapsed, maxSize, work)
+	}
+
+	degraded := 0
+	for _, c := range conns {
+		if c.degraded {
+			degraded++
+		}
+	}
+	if degraded != jobs-maxSize {
+		t.Fatalf("%d of %d jobs got degraded connections, want %d", degraded, jobs, jobs-maxSize)
+	}
+	s := p.Stats()
+	if s.Created != maxSize || s.Idle != maxSize || s.InUse != 0 || s.Degraded != jobs-maxSize || s.DegradedInUse != 0 {
+		t.Fatalf("after every release: %+v", s)
+	}
+
+	// Only the pooled connections come back; the next get past them is a
+	// fresh degraded connection
+	for i := 0; i < maxSize; i++ {
+		if c := p.getConnection(); c.degraded {
+			t.Fatalf("get %d after release returned degraded connection d%d with pooled ones idle", i+1, c.id)
+		}
+	}
+	if c := p.getConnection(); !c.degraded || c.id != jobs-maxSize+1 {
+		t.Fatalf("get on the exhausted pool returned connection %d (degraded %t), want new degraded d%d", c.id, c.degraded, jobs-maxSize+1)
+	}
+}
+
+// TestPoolWarmup warms a pool whose dial takes 50ms and checks it created
+// exactly its maximum of 3 before any Get, and that the first Gets are
+// served from them without dialing
+func TestPoolWarmup(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	var dials int32
+	p := newDBConnectionPool(0, 3)
+	defer p.close()
+	p.dial = func(id int) *dbConnection {
+		atomic.AddInt32(&dials, 1)
+		time.Sleep(50 * time.Millisecond)
+		return dialDBConnection(id)
+	}
+
+	if warmed := p.Warmup(5); warmed != 3 {
+		t.Fatalf("Warmup(5) on a pool of max 3 created %d", warmed)
+	}
+	if s := p.Stats(); s.Created != 3 || s.Idle != 3 || s.InUse != 0 || dials != 3 {
+		t.Fatalf("after warmup: %+v with %d dials, want 3 created and idle", s, dials)
+	}
+	if warmed := p.Warmup(1); warmed != 0 {
+		t.Fatalf("Warmup on a full pool created %d more", warmed)
+	}
+
+	for i := 1; i <= 3; i++ {
+		start := time.Now()
+		conn := p.getConnection()
+		if took := time.Since(start); took > 10*time.Millisecond {
+			t.Fatalf("get %d on a warmed pool took %v, a dial takes 50ms", i, took)
+		}
+		if conn.degraded {
+			t.Fatalf("get %d returned a degraded connection", i)
+		}
+	}
+	if n := atomic.LoadInt32(&dials); n != 3 {
+		t.Fatalf("%d dials after three gets on a warmed pool, want the warmup's 3", n)
+	}
+}
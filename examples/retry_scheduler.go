@@ -0,0 +1,182 @@
+package examples
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// retryEntry is an item waiting in a retryScheduler
+type retryEntry[T any] struct {
+	item T
+	due  time.Time
+	seq  uint64
+}
+
+// retryHeap orders entries by due time, then by the order they were scheduled
+type retryHeap[T any] []retryEntry[T]
+
+func (h retryHeap[T]) Len() int { return len(h) }
+func (h retryHeap[T]) Less(i, j int) bool {
+	if !h[i].due.Equal(h[j].due) {
+		return h[i].due.Before(h[j].due)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h retryHeap[T]) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *retryHeap[T]) Push(x interface{}) { *h = append(*h, x.(retryEntry[T])) }
+func (h *retryHeap[T]) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// retryScheduler puts items back on a queue after a delay. Scheduled items
+// wait in a heap behind a single timer, which is only reset when the earliest
+// due time changes, and come out on C in due order; the owner forwards them
+// into its own queue. It holds at most capacity items: Schedule rejects the
+// rest with ErrQueueFull, so the caller decides what to do with them, such as
+// failing the item or dead-lettering it.
+type retryScheduler[T any] struct {
+	// C delivers items once they are due; it closes after Close
+	C   <-chan T
+	out chan T
+
+	mu       sync.Mutex
+	items    retryHeap[T]
+	held     int // scheduled but not yet delivered, including one being sent
+	capacity int
+	seq      uint64
+	closed   bool
+	drain    bool
+	// wake nudges the run loop after a change; its buffer of one coalesces
+	// any number of changes into a single wakeup
+	wake chan struct{}
+}
+
+func newRetryScheduler[T any](capacity int) *retryScheduler[T] {
+	s := &retryScheduler[T]{
+		out:      make(chan T),
+		capacity: capacity,
+		wake:     make(chan struct{}, 1),
+	}
+	s.C = s.out
+	go s.run()
+	return s
+}
+
+// errSchedulerClosed is returned when an item is scheduled after Close
+var errSchedulerClosed = errors.New("retry scheduler closed")
+
+// Schedule delivers item on C once after has passed. It never blocks.
+func (s *retryScheduler[T]) Schedule(item T, after time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errSchedulerClosed
+	}
+	if s.held >= s.capacity {
+		return fmt.Errorf("schedule retry: %w", ErrQueueFull)
+	}
+	s.seq++
+	s.held++
+	heap.Push(&s.items, retryEntry[T]{item: item, due: time.Now().Add(after), seq: s.seq})
+	s.nudge()
+	return nil
+}
+
+// nudge wakes the run loop without ever blocking; s.mu must be held
+func (s *retryScheduler[T]) nudge() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops accepting items. With drain set, items already scheduled are
+// still delivered at their due times before C closes; otherwise they are
+// dropped and C closes right away. It returns how many waiting items were
+// dropped; an item already due and waiting for a reader on C is dropped too.
+func (s *retryScheduler[T]) Close(drain bool) int {
+	s.mu.Lock()
+	s.closed = true
+	s.drain = drain
+	dropped := 0
+	if !drain {
+		dropped = len(s.items)
+		s.held -= dropped
+		s.items = nil
+	}
+	s.nudge()
+	s.mu.Unlock()
+	return dropped
+}
+
+// Len returns how many items are scheduled and not yet delivered
+func (s *retryScheduler[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.held
+}
+
+// run delivers due items on C until the scheduler is closed and has nothing
+// left to deliver
+func (s *retryScheduler[T]) run() {
+	defer close(s.out)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	var armed time.Time // due time the timer is set for, zero if stopped
+
+	for {
+		s.mu.Lock()
+		if len(s.items) == 0 && s.closed {
+			s.mu.Unlock()
+			return
+		}
+		var due time.Time
+		if len(s.items) > 0 {
+			due = s.items[0].due
+		}
+		if !due.IsZero() && !due.After(time.Now()) {
+			e := heap.Pop(&s.items).(retryEntry[T])
+			s.mu.Unlock()
+
+			select {
+			case s.out <- e.item:
+				s.mu.Lock()
+				s.held--
+				s.mu.Unlock()
+			case <-s.wake:
+				s.mu.Lock()
+				if s.closed && !s.drain {
+					s.held--
+				} else {
+					// Still the earliest: anything scheduled since is due later
+					heap.Push(&s.items, e)
+				}
+				s.mu.Unlock()
+			}
+			continue
+		}
+		s.mu.Unlock()
+
+		// Only touch the timer when the earliest due time changed
+		if !due.Equal(armed) {
+			if !armed.IsZero() && !timer.Stop() {
+				<-timer.C
+			}
+			armed = due
+			if !due.IsZero() {
+				timer.Reset(time.Until(due))
+			}
+		}
+		select {
+		case <-timer.C:
+			armed = time.Time{}
+		case <-s.wake:
+		}
+	}
+}
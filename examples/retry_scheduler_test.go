@@ -0,0 +1,57 @@
+package examples
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRetryScheduler schedules items at staggered delays and checks they
+// come out in due order and on time, that a full scheduler rejects items,
+// and what Close does with items still waiting
+func TestRetryScheduler(t *testing.T) {
+	const tolerance = 30 * time.Millisecond
+	s := newRetryScheduler[int](4)
+	start := time.Now()
+	for _, ms := range []int{80, 20, 60, 40} {
+		if err := s.Schedule(ms, time.Duration(ms)*time.Millisecond); err != nil {
+			t.Fatalf("scheduling %dms: %v", ms, err)
+		}
+	}
+	if err := s.Schedule(100, 100*time.Millisecond); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("fifth item in a scheduler of capacity 4: got %v, want %v", err, ErrQueueFull)
+	}
+	got := requireReceives(t, s.C, 4, time.Second)
+	for i, want := range []int{20, 40, 60, 80} {
+		if got[i] != want {
+			t.Fatalf("delivered %v, want items in due order [20 40 60 80]", got)
+		}
+	}
+	if late := time.Since(start) - 80*time.Millisecond; late > tolerance {
+		t.Fatalf("last item delivered %v after it was due", late)
+	}
+
+	// Closing with drain still delivers what was scheduled, on time
+	s.Schedule(1, 30*time.Millisecond)
+	s.Close(true)
+	if err := s.Schedule(2, 0); !errors.Is(err, errSchedulerClosed) {
+		t.Fatalf("schedule after close: got %v, want %v", err, errSchedulerClosed)
+	}
+	if got := requireReceives(t, s.C, 1, time.Second); got[0] != 1 {
+		t.Fatalf("drained %v, want the item scheduled before close", got)
+	}
+	if _, ok := <-s.C; ok {
+		t.Fatalf("C still open after draining")
+	}
+
+	// Closing without drain drops what was scheduled
+	s = newRetryScheduler[int](4)
+	s.Schedule(1, 50*time.Millisecond)
+	s.Schedule(2, 50*time.Millisecond)
+	if dropped := s.Close(false); dropped != 2 {
+		t.Fatalf("close dropped %d items, want 2", dropped)
+	}
+	if _, ok := <-s.C; ok {
+		t.Fatalf("delivered an item after a discarding close")
+	}
+}
@@ -0,0 +1,120 @@
+package examples
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// runTraceLimit is how many trace IDs a RunContext keeps the hops of. Later
+// IDs are still printed under --trace, but a long run's hop log stays bounded.
+const runTraceLimit = 1000
+
+// RunContext identifies one invocation of the examples. It carries a short
+// RunID and hands out TraceIDs derived from it, so lines from interleaved
+// runs and from items in flight at the same time can be told apart. Items,
+// jobs, events and messages carry their TraceID, and every stage that
+// touches one records a hop against it.
+type RunContext struct {
+	RunID string
+	seq   uint64
+
+	mu   sync.Mutex
+	hops map[string][]string
+}
+
+// runContexts finds the RunContext a TraceID belongs to, so a stage only
+// needs the item's TraceID to record a hop
+var runContexts sync.Map
+
+// NewRunContext creates a RunContext with a fresh random RunID
+func NewRunContext() *RunContext {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("run ID: %v", err))
+	}
+	rc := &RunContext{RunID: hex.EncodeToString(b), hops: make(map[string][]string)}
+	runContexts.Store(rc.RunID, rc)
+	return rc
+}
+
+// NextTraceID returns the next TraceID of the run. A nil RunContext returns
+// the empty TraceID, which traceHop ignores.
+func (rc *RunContext) NextTraceID() string {
+	if rc == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", rc.RunID, atomic.AddUint64(&rc.seq, 1))
+}
+
+// Hops returns the hops recorded for traceID, in the order they happened
+func (rc *RunContext) Hops(traceID string) []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]string(nil), rc.hops[traceID]...)
+}
+
+// HopLog returns every kept trace's hops, keyed by TraceID
+func (rc *RunContext) HopLog() map[string][]string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	log := make(map[string][]string, len(rc.hops))
+	for id, hops := range rc.hops {
+		log[id] = append([]string(nil), hops...)
+	}
+	return log
+}
+
+// TraceIDs returns the IDs in the hop log, sorted
+func (rc *RunContext) TraceIDs() []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	ids := make([]string, 0, len(rc.hops))
+	for id := range rc.hops {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (rc *RunContext) hop(traceID, hop string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if _, ok := rc.hops[traceID]; ok || len(rc.hops) < runTraceLimit {
+		rc.hops[traceID] = append(rc.hops[traceID], hop)
+	}
+}
+
+// traceHop records that the item with traceID reached hop, and prints it
+// under --trace. Items without a TraceID are not traced.
+func traceHop(traceID, hop string) {
+	if traceID == "" {
+		return
+	}
+	if opts.Trace {
+		fmt.Fprintf(output(), "[trace %s] %s\n", traceID, hop)
+	}
+	runID, _, _ := strings.Cut(traceID, "-")
+	if rc, ok := runContexts.Load(runID); ok {
+		rc.(*RunContext).hop(traceID, hop)
+	}
+}
+
+type runContextKey struct{}
+
+// WithRunContext returns a copy of ctx carrying rc
+func WithRunContext(ctx context.Context, rc *RunContext) context.Context {
+	return context.WithValue(ctx, runContextKey{}, rc)
+}
+
+// RunContextFrom returns the RunContext ctx carries, or nil, whose
+// NextTraceID hands out empty IDs that are not traced
+func RunContextFrom(ctx context.Context) *RunContext {
+	rc, _ := ctx.Value(runContextKey{}).(*RunContext)
+	return rc
+}
@@ -0,0 +1,57 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+)
+
+// Semaphore bounds how many goroutines hold a slot at once. It is a buffered
+// channel: acquiring sends into it and releasing receives, so a full channel
+// means every slot is taken.
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// NewSemaphore returns a semaphore with n slots; n below 1 is treated as 1
+func NewSemaphore(n int) *Semaphore {
+	if n < 1 {
+		n = 1
+	}
+	return &Semaphore{slots: make(chan struct{}, n)}
+}
+
+// Acquire waits for a slot. If ctx ends first it gives up without holding
+// one and the error wraps ErrTimeout.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("acquire: %w: %w", ErrTimeout, ctx.Err())
+	}
+}
+
+// TryAcquire takes a slot if one is free and reports whether it did
+func (s *Semaphore) TryAcquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees a slot. Releasing more slots than were acquired is a bug in
+// the caller, so it panics rather than letting the bound drift.
+func (s *Semaphore) Release() {
+	select {
+	case <-s.slots:
+	default:
+		panic("semaphore: release without a matching acquire")
+	}
+}
+
+// InUse returns how many slots are held
+func (s *Semaphore) InUse() int {
+	return len(s.slots)
+}
@@ -0,0 +1,56 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestSemaphore checks that acquires and releases balance, that TryAcquire
+// fails when every slot is held, that a blocked Acquire gives up when its
+// context is cancelled, and that an over-release panics
+func TestSemaphore(t *testing.T) {
+	sem := NewSemaphore(2)
+	for i := 0; i < 3; i++ {
+		if err := sem.Acquire(context.Background()); err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+		if !sem.TryAcquire() {
+			t.Fatalf("round %d: TryAcquire failed with a slot free", i)
+		}
+		if sem.TryAcquire() {
+			t.Fatalf("round %d: TryAcquire succeeded with every slot held", i)
+		}
+		sem.Release()
+		sem.Release()
+		if n := sem.InUse(); n != 0 {
+			t.Fatalf("round %d: %d slots still held after releasing all", i, n)
+		}
+	}
+
+	sem.TryAcquire()
+	sem.TryAcquire()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- sem.Acquire(ctx) }()
+	requireNoReceive(t, errs, 20*time.Millisecond)
+	cancel()
+	got := requireReceives(t, errs, 1, time.Second)
+	if !errors.Is(got[0], ErrTimeout) {
+		t.Fatalf("cancelled acquire returned %v, want ErrTimeout", got[0])
+	}
+	if n := sem.InUse(); n != 2 {
+		t.Fatalf("cancelled acquire left %d slots held, want 2", n)
+	}
+	sem.Release()
+	sem.Release()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("releasing an unheld slot did not panic")
+		}
+	}()
+	sem.Release()
+}
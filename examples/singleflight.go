@@ -1,15 +1,18 @@
 package examples
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // RunSingleflight demonstrates the singleflight (spaceflight) pattern.
-func RunSingleflight() {
-	fmt.Println("=== Singleflight (Spaceflight) Pattern Example ===")
+func RunSingleflight(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Singleflight (Spaceflight) Pattern Example ===")
 
+	phase("dedup")
 	// Create a singleflight group
 	sf := newSingleflight()
 
@@ -19,61 +22,222 @@ func RunSingleflight() {
 
 	var wg sync.WaitGroup
 	results := make([]string, numRequests)
+	var expensiveCalls int32
 
-	fmt.Printf("Making %d concurrent requests for key: %s\n", numRequests, key)
+	fmt.Fprintf(output(), "Making %d concurrent requests for key: %s\n", numRequests, key)
 
 	// Launch concurrent requests
 	for i := 0; i < numRequests; i++ {
+		heartbeat()
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
-			fmt.Printf("Request %d: Starting...\n", id)
+			fmt.Fprintf(output(), "Request %d: Starting...\n", id)
 
 			result := sf.Do(key, func() (interface{}, error) {
 				// Simulate expensive operation (e.g., database query, API call)
-				fmt.Printf("Request %d: Executing expensive operation...\n", id)
-				time.Sleep(2 * time.Second)
+				atomic.AddInt32(&expensiveCalls, 1)
+				fmt.Fprintf(output(), "Request %d: Executing expensive operation...\n", id)
+				pause(2 * time.Second)
 				return fmt.Sprintf("Data for %s (processed by request %d)", key, id), nil
 			})
 
 			results[id] = result.(string)
-			fmt.Printf("Request %d: Completed with result: %s\n", id, result)
+			fmt.Fprintf(output(), "Request %d: Completed with result: %s\n", id, result)
 		}(i)
 	}
 
 	wg.Wait()
+	record("results", results)
+	verify("singleflight", func() error {
+		if expensiveCalls != 1 {
+			return fmt.Errorf("expensive operation ran %d times for %d concurrent requests", expensiveCalls, numRequests)
+		}
+		for i, result := range results {
+			if result != results[0] {
+				return fmt.Errorf("request %d got %q, request 0 got %q", i, result, results[0])
+			}
+		}
+		return nil
+	})
 
 	// Show that all results are the same (same execution)
-	fmt.Println("\nAll results should be identical:")
+	fmt.Fprintln(output(), "\nAll results should be identical:")
 	for i, result := range results {
-		fmt.Printf("  Request %d: %s\n", i, result)
+		fmt.Fprintf(output(), "  Request %d: %s\n", i, result)
 	}
 
+	phase("keys")
 	// Test with different keys
-	fmt.Println("\nTesting with different keys:")
+	fmt.Fprintln(output(), "\nTesting with different keys:")
 	keys := []string{"user:123", "user:456", "user:123"}
 
 	for i, key := range keys {
+		heartbeat()
 		wg.Add(1)
 		go func(id int, k string) {
 			defer wg.Done()
 			result := sf.Do(k, func() (interface{}, error) {
-				fmt.Printf("Request %d: Executing for key %s...\n", id, k)
-				time.Sleep(1 * time.Second)
+				fmt.Fprintf(output(), "Request %d: Executing for key %s...\n", id, k)
+				pause(1 * time.Second)
 				return fmt.Sprintf("Data for %s", k), nil
 			})
-			fmt.Printf("Request %d: Key %s -> %s\n", id, k, result)
+			fmt.Fprintf(output(), "Request %d: Key %s -> %s\n", id, k, result)
 		}(i, key)
 	}
 
 	wg.Wait()
-	fmt.Println("\nSingleflight example completed!")
+
+	phase("barrier")
+	// A barrier in onEnter holds the flight until every caller has joined,
+	// so the dedup doesn't depend on the callers' timing
+	fmt.Fprintln(output(), "\nDeterministic dedup (onEnter barrier, no sleeps):")
+	barrier := newSingleflight()
+	var arrived sync.WaitGroup
+	arrived.Add(numRequests)
+	barrier.onEnter = func(string) {
+		arrived.Done()
+		arrived.Wait()
+	}
+	var executions int32
+	for i := 0; i < numRequests; i++ {
+		heartbeat()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			barrier.Do("report:42", func() (interface{}, error) {
+				atomic.AddInt32(&executions, 1)
+				return "report", nil
+			})
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintf(output(), "%d callers, %d execution, %d duplicates\n", numRequests, executions, int32(numRequests)-executions)
+	record("barrier", map[string]int32{"callers": int32(numRequests), "executions": executions})
+	verify("singleflight barrier", func() error {
+		if executions != 1 {
+			return fmt.Errorf("%d executions, want 1", executions)
+		}
+		return nil
+	})
+
+	phase("stampede")
+	// Many keys expiring together: dedup alone would run all of them at once
+	fmt.Fprintln(output(), "\nStampede control (DoLimited, 20 distinct keys, limit 3, up to 50ms jitter):")
+	const distinctKeys, limit = 20, 3
+	limited := newLimitedSingleflight(limit, 50*time.Millisecond)
+	start := time.Now()
+	for i := 0; i < distinctKeys; i++ {
+		heartbeat()
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			limited.DoLimited(fmt.Sprintf("product:%d", id), func() (interface{}, error) {
+				heartbeat()
+				time.Sleep(100 * time.Millisecond)
+				return id, nil
+			})
+		}(i)
+	}
+	wg.Wait()
+	fmt.Fprintf(output(), "%d keys refreshed in %v, at most %d at once\n", distinctKeys, time.Since(start).Round(time.Millisecond), limited.Peak())
+	record("stampede", map[string]int{"keys": distinctKeys, "limit": limit, "peak": limited.Peak()})
+	verify("singleflight stampede", func() error {
+		if limited.Peak() > limit {
+			return fmt.Errorf("%d concurrent executions, limit %d", limited.Peak(), limit)
+		}
+		return nil
+	})
+
+	phase("cancel")
+	// Callers that give up take the flight down with them once the last one leaves
+	fmt.Fprintln(output(), "\nAbandoned flight (DoCtx, a 1s operation, callers give up after 150ms and 250ms):")
+	cancelable := newCancelableSingleflight()
+	stoppedAfter := make(chan time.Duration, 1)
+	start = time.Now()
+	for i, timeout := range []time.Duration{150 * time.Millisecond, 250 * time.Millisecond} {
+		heartbeat()
+		wg.Add(1)
+		go func(id int, timeout time.Duration) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			_, err := cancelable.DoCtx(ctx, "report:monthly", func(ctx context.Context) (interface{}, error) {
+				for step := 1; step <= 10; step++ {
+					if !sleepOrDone(100*time.Millisecond, ctx.Done()) {
+						fmt.Fprintf(output(), "Operation: every caller left, stopping after step %d of 10\n", step-1)
+						stoppedAfter <- time.Since(start)
+						return nil, ctx.Err()
+					}
+				}
+				stoppedAfter <- time.Since(start)
+				return "monthly report", nil
+			})
+			fmt.Fprintf(output(), "Caller %d: gave up after %v (%s)\n", id, timeout, errorKind(err))
+		}(i+1, timeout)
+	}
+	wg.Wait()
+	ran := <-stoppedAfter
+	fmt.Fprintf(output(), "Operation stopped after %v instead of running for 1s\n", ran.Round(time.Millisecond))
+	record("abandoned_flight_ms", ran.Milliseconds())
+	verify("abandoned flight", func() error {
+		if ran > 500*time.Millisecond {
+			return fmt.Errorf("abandoned operation ran for %v", ran)
+		}
+		return nil
+	})
+
+	phase("loader")
+	// A cache in front of the flights, remembering failures as well as values
+	fmt.Fprintln(output(), "\nCache loader (values cached 1s, errors 200ms; sku-13 always fails):")
+	loader := NewLoader(func(key string) (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		if key == "sku-13" {
+			return nil, fmt.Errorf("inventory %s: %w", key, errBackendDown)
+		}
+		return fmt.Sprintf("%s: 12 in stock", key), nil
+	}, time.Second, 200*time.Millisecond)
+	for round := 1; round <= 4; round++ {
+		heartbeat()
+		for i := 0; i < 10; i++ {
+			key := "sku-7"
+			if i%2 == 1 {
+				key = "sku-13"
+			}
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				loader.Get(key)
+			}(key)
+		}
+		wg.Wait()
+		fmt.Fprintf(output(), "Round %d: 10 requests, %d backend loads so far\n", round, loader.Loads())
+		time.Sleep(90 * time.Millisecond)
+	}
+	_, err := loader.Get("sku-13")
+	fmt.Fprintf(output(), "sku-13 is served from the negative cache: %v\n", err)
+	record("loader_backend_loads", loader.Loads())
+	// One value load, and one failing load per 200ms of negative TTL
+	verify("cache loader", func() error {
+		if n := loader.Loads(); n < 2 || n > 4 {
+			return fmt.Errorf("40 requests made %d backend loads, want 2 to 4", n)
+		}
+		return nil
+	})
+
+	fmt.Fprintln(output(), "\nSingleflight example completed!")
+
+	printTrace()
 }
 
 // Singleflight ensures only one execution per key
 type singleflight struct {
 	mu    sync.Mutex
 	calls map[string]*call
+	// onEnter, if set, is called by every caller once it has joined a flight,
+	// as leader or duplicate, and before the leader runs fn. Blocking in it
+	// lets a caller hold the flight open until every expected caller joined.
+	onEnter func(key string)
 }
 
 type call struct {
@@ -90,15 +254,24 @@ func newSingleflight() *singleflight {
 }
 
 func (sf *singleflight) Do(key string, fn func() (interface{}, error)) interface{} {
+	val, _ := sf.do(key, fn)
+	return val
+}
+
+// do is Do returning the flight's error along with its value
+func (sf *singleflight) do(key string, fn func() (interface{}, error)) (interface{}, error) {
 	sf.mu.Lock()
 
 	if c, exists := sf.calls[key]; exists {
 		// Another call is in progress for this key
 		c.dups++
 		sf.mu.Unlock()
-		fmt.Printf("Duplicate call for key %s, waiting for result...\n", key)
+		if sf.onEnter != nil {
+			sf.onEnter(key)
+		}
+		fmt.Fprintf(output(), "Duplicate call for key %s, waiting for result...\n", key)
 		c.wg.Wait()
-		return c.val
+		return c.val, c.err
 	}
 
 	// Create new call
@@ -106,15 +279,22 @@ func (sf *singleflight) Do(key string, fn func() (interface{}, error)) interface
 	c.wg.Add(1)
 	sf.calls[key] = c
 	sf.mu.Unlock()
+	if sf.onEnter != nil {
+		sf.onEnter(key)
+	}
 
 	// Execute the function
+	span := tracer.Start("singleflight.flight")
+	span.SetTag("key", key)
 	c.val, c.err = fn()
 	c.wg.Done()
 
 	// Clean up
 	sf.mu.Lock()
 	delete(sf.calls, key)
+	span.SetTag("dups", c.dups)
 	sf.mu.Unlock()
+	span.End()
 
-	return c.val
+	return c.val, c.err
 }
@@ -0,0 +1,86 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// cancelableSingleflight collapses concurrent calls per key like singleflight,
+// but each caller can give up on its own context, and a flight nobody is
+// waiting for any more is cancelled instead of running to completion for no
+// one.
+//
+// A flight counts its callers. When the last one leaves, the context passed to
+// fn is cancelled and the flight is forgotten at once: a caller arriving
+// afterwards always starts a fresh flight rather than joining the dying one,
+// even if the old fn hasn't noticed its cancellation yet.
+type cancelableSingleflight struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done   chan struct{} // closed once val and err are set
+	val    interface{}
+	err    error
+	refs   int
+	cancel context.CancelFunc
+}
+
+func newCancelableSingleflight() *cancelableSingleflight {
+	return &cancelableSingleflight{flights: make(map[string]*flight)}
+}
+
+// DoCtx returns the result of fn for key, sharing a single execution with any
+// concurrent callers for the same key. fn runs on its own goroutine with a
+// context that is cancelled once every caller has left. A caller whose ctx
+// ends first gets an error matching ErrTimeout.
+func (sf *cancelableSingleflight) DoCtx(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	sf.mu.Lock()
+	f, joined := sf.flights[key]
+	if !joined {
+		flightCtx, cancel := context.WithCancel(context.Background())
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		sf.flights[key] = f
+		go sf.run(flightCtx, key, f, fn)
+	}
+	f.refs++
+	sf.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		sf.leave(key, f)
+		return nil, fmt.Errorf("singleflight %s: %w: %w", key, ErrTimeout, ctx.Err())
+	}
+}
+
+// run executes fn for a flight and publishes its result
+func (sf *cancelableSingleflight) run(ctx context.Context, key string, f *flight, fn func(ctx context.Context) (interface{}, error)) {
+	f.val, f.err = fn(ctx)
+	close(f.done)
+	f.cancel()
+
+	sf.mu.Lock()
+	if sf.flights[key] == f {
+		delete(sf.flights, key)
+	}
+	sf.mu.Unlock()
+}
+
+// leave drops a caller from a flight, cancelling the flight if it was the last
+func (sf *cancelableSingleflight) leave(key string, f *flight) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	f.refs--
+	if f.refs > 0 {
+		return
+	}
+	if sf.flights[key] == f {
+		// Nobody can join the flight from here on
+		delete(sf.flights, key)
+	}
+	f.cancel()
+}
@@ -0,0 +1,62 @@
+package examples
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCancelableSingleflight checks that fn's context is cancelled only once
+// the last caller leaves, and that a caller arriving after that starts a
+// fresh flight instead of joining the abandoned one
+func TestCancelableSingleflight(t *testing.T) {
+	sf := newCancelableSingleflight()
+	var executions int32
+	flightCtxs := make(chan context.Context, 2)
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		n := atomic.AddInt32(&executions, 1)
+		flightCtxs <- ctx
+		select {
+		case <-release:
+		case <-ctx.Done():
+			// Slow to notice, so a late caller could still find this flight
+			time.Sleep(50 * time.Millisecond)
+		}
+		return n, nil
+	}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	defer cancelFirst()
+	defer cancelSecond()
+	errs := make(chan error, 2)
+	go func() { _, err := sf.DoCtx(first, "k", fn); errs <- err }()
+	flightCtx := <-flightCtxs
+	go func() { _, err := sf.DoCtx(second, "k", fn); errs <- err }()
+	waitUntil(t, func() bool {
+		sf.mu.Lock()
+		defer sf.mu.Unlock()
+		return sf.flights["k"] != nil && sf.flights["k"].refs == 2
+	}, time.Second, "second caller never joined the flight")
+
+	cancelFirst()
+	<-errs
+	if pollUntil(func() bool { return flightCtx.Err() != nil }, 5*time.Millisecond, 50*time.Millisecond) {
+		t.Fatalf("flight cancelled while a caller was still waiting")
+	}
+	cancelSecond()
+	<-errs
+	waitUntil(t, func() bool { return flightCtx.Err() != nil }, time.Second, "flight not cancelled after every caller left")
+
+	// The abandoned fn is still winding down; a new caller gets its own flight
+	go func() {
+		<-flightCtxs
+		close(release)
+	}()
+	val, err := sf.DoCtx(context.Background(), "k", fn)
+	if err != nil || val != int32(2) {
+		t.Fatalf("late caller got %v, %v; want a fresh flight's result 2", val, err)
+	}
+}
@@ -0,0 +1,58 @@
+package examples
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// limitedSingleflight adds a cap on distinct-key executions to singleflight.
+// Singleflight alone collapses callers per key, but when many keys expire at
+// once every one of them still hits the backend together; the semaphore
+// bounds how many run at a time and the jitter spreads their starts.
+type limitedSingleflight struct {
+	*singleflight
+	sem       *Semaphore
+	maxJitter time.Duration
+
+	running int32
+	peak    int32
+}
+
+func newLimitedSingleflight(limit int, maxJitter time.Duration) *limitedSingleflight {
+	return &limitedSingleflight{
+		singleflight: newSingleflight(),
+		sem:          NewSemaphore(limit),
+		maxJitter:    maxJitter,
+	}
+}
+
+// DoLimited is Do with the flight's leader taking a slot and sleeping a
+// random jitter before it runs fn. Duplicates wait on the flight as usual
+// and never hold a slot.
+func (l *limitedSingleflight) DoLimited(key string, fn func() (interface{}, error)) interface{} {
+	return l.Do(key, func() (interface{}, error) {
+		l.sem.Acquire(context.Background())
+		defer l.sem.Release()
+
+		if l.maxJitter > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(l.maxJitter))))
+		}
+
+		n := atomic.AddInt32(&l.running, 1)
+		defer atomic.AddInt32(&l.running, -1)
+		for {
+			peak := atomic.LoadInt32(&l.peak)
+			if n <= peak || atomic.CompareAndSwapInt32(&l.peak, peak, n) {
+				break
+			}
+		}
+		return fn()
+	})
+}
+
+// Peak returns the most executions that ever ran at once
+func (l *limitedSingleflight) Peak() int {
+	return int(atomic.LoadInt32(&l.peak))
+}
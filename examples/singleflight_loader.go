@@ -0,0 +1,85 @@
+package examples
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Loader is a read-through cache in front of a slow or failing backend.
+// Concurrent misses for a key share one load through a singleflight, and
+// the outcome is cached: values for positiveTTL, errors for negativeTTL, so
+// a failing backend sees one attempt per negativeTTL rather than one per
+// request.
+type Loader struct {
+	load        func(key string) (interface{}, error)
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+	flights     *singleflight
+	loads       int32
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached load outcome, a value or an error
+type cacheEntry struct {
+	val     interface{}
+	err     error
+	expires time.Time
+}
+
+func NewLoader(load func(key string) (interface{}, error), positiveTTL, negativeTTL time.Duration) *Loader {
+	return &Loader{
+		load:        load,
+		positiveTTL: positiveTTL,
+		negativeTTL: negativeTTL,
+		flights:     newSingleflight(),
+		entries:     make(map[string]cacheEntry),
+	}
+}
+
+// Get returns key's value, or the error loading it, from the cache while the
+// entry is fresh, and loads it otherwise
+func (l *Loader) Get(key string) (interface{}, error) {
+	if e, ok := l.cached(key); ok {
+		return e.val, e.err
+	}
+	return l.flights.do(key, func() (interface{}, error) {
+		// A flight that finished just before this one started may already
+		// have filled the cache
+		if e, ok := l.cached(key); ok {
+			return e.val, e.err
+		}
+		atomic.AddInt32(&l.loads, 1)
+		val, err := l.load(key)
+		ttl := l.positiveTTL
+		if err != nil {
+			ttl = l.negativeTTL
+		}
+		l.mu.Lock()
+		l.entries[key] = cacheEntry{val: val, err: err, expires: time.Now().Add(ttl)}
+		l.mu.Unlock()
+		return val, err
+	})
+}
+
+// cached returns key's entry if it has not expired
+func (l *Loader) cached(key string) (cacheEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok || !time.Now().Before(e.expires) {
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+// Loads returns how many times the backend was called
+func (l *Loader) Loads() int {
+	return int(atomic.LoadInt32(&l.loads))
+}
+
+// errBackendDown is what the demo backend returns for a key it can't serve
+var errBackendDown = errors.New("backend unavailable")
@@ -0,0 +1,58 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLoaderNegativeCache checks that concurrent Gets for a failing key
+// share one attempt, that the error is then served from the negative cache
+// without calling the backend, and that one new attempt is made once the
+// negative TTL expires
+func TestLoaderNegativeCache(t *testing.T) {
+	loader := NewLoader(func(key string) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return nil, fmt.Errorf("load %s: %w", key, errBackendDown)
+	}, time.Second, 100*time.Millisecond)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := loader.Get("sku-42")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if !errors.Is(err, errBackendDown) {
+			t.Fatalf("concurrent Get returned %v, want the backend error", err)
+		}
+	}
+	if n := loader.Loads(); n != 1 {
+		t.Fatalf("8 concurrent Gets made %d attempts, want 1", n)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := loader.Get("sku-42"); !errors.Is(err, errBackendDown) {
+			t.Fatalf("cached Get returned %v, want the backend error", err)
+		}
+	}
+	if n := loader.Loads(); n != 1 {
+		t.Fatalf("Gets within the negative TTL made %d attempts, want 1", n)
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	if _, err := loader.Get("sku-42"); !errors.Is(err, errBackendDown) {
+		t.Fatalf("Get after the negative TTL returned %v", err)
+	}
+	if n := loader.Loads(); n != 2 {
+		t.Fatalf("Get after the negative TTL expired made %d attempts in all, want 2", n)
+	}
+}
@@ -0,0 +1,125 @@
+package examples
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSingleflightOnEnterBarrier holds every flight in onEnter until all
+// callers have joined, over many rounds, and checks each round runs fn
+// exactly once with the other N-1 callers counted as duplicates and given
+// its value
+func TestSingleflightOnEnterBarrier(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	const callers = 16
+	for round := 0; round < 50; round++ {
+		sf := newSingleflight()
+		var arrived sync.WaitGroup
+		arrived.Add(callers)
+		var entered int32
+		sf.onEnter = func(string) {
+			atomic.AddInt32(&entered, 1)
+			arrived.Done()
+			arrived.Wait()
+		}
+
+		var executions int32
+		key := fmt.Sprintf("report:%d", round)
+		values := make([]interface{}, callers)
+		var wg sync.WaitGroup
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				values[i] = sf.Do(key, func() (interface{}, error) {
+					atomic.AddInt32(&executions, 1)
+					// Every caller has joined by now, so the count is final
+					sf.mu.Lock()
+					defer sf.mu.Unlock()
+					return sf.calls[key].dups, nil
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		if executions != 1 || entered != callers {
+			t.Fatalf("round %d: %d executions and %d callers entered, want 1 and %d", round, executions, entered, callers)
+		}
+		for i, v := range values {
+			if v != callers-1 {
+				t.Fatalf("round %d: caller %d got %v, want the flight's %d duplicates", round, i, v, callers-1)
+			}
+		}
+		if len(sf.calls) != 0 {
+			t.Fatalf("round %d: %d flights left behind", round, len(sf.calls))
+		}
+	}
+}
+
+// TestDoLimitedBoundsConcurrency refreshes many distinct keys, each asked
+// for by several callers, and checks no more than limit executions ever run
+// at once, the limit is reached, and each key executes exactly once
+func TestDoLimitedBoundsConcurrency(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	const keys, callersPerKey, limit = 24, 3, 3
+	l := newLimitedSingleflight(limit, 2*time.Millisecond)
+	var running, peak int32
+	var execMu sync.Mutex
+	executions := make(map[string]int)
+
+	// Each key's callers join one flight before its leader runs
+	var arrived sync.WaitGroup
+	arrived.Add(keys * callersPerKey)
+	l.onEnter = func(string) {
+		arrived.Done()
+		arrived.Wait()
+	}
+
+	var wg sync.WaitGroup
+	for k := 0; k < keys; k++ {
+		for c := 0; c < callersPerKey; c++ {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				v := l.DoLimited(key, func() (interface{}, error) {
+					n := atomic.AddInt32(&running, 1)
+					defer atomic.AddInt32(&running, -1)
+					for p := atomic.LoadInt32(&peak); n > p && !atomic.CompareAndSwapInt32(&peak, p, n); p = atomic.LoadInt32(&peak) {
+					}
+					execMu.Lock()
+					executions[key]++
+					execMu.Unlock()
+					time.Sleep(10 * time.Millisecond)
+					return key, nil
+				})
+				if v != key {
+					t.Errorf("caller of %s got %v", key, v)
+				}
+			}(fmt.Sprintf("product:%d", k))
+		}
+	}
+	wg.Wait()
+
+	if peak > limit || l.Peak() > limit {
+		t.Fatalf("%d executions ran at once (DoLimited saw %d), limit %d", peak, l.Peak(), limit)
+	}
+	if peak != limit {
+		t.Fatalf("at most %d executions ran at once with %d keys waiting, want the limit %d", peak, keys, limit)
+	}
+	if len(executions) != keys {
+		t.Fatalf("%d of %d keys executed", len(executions), keys)
+	}
+	for key, n := range executions {
+		if n != 1 {
+			t.Fatalf("%s executed %d times for %d callers", key, n, callersPerKey)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// soakReportInterval is how often a soak run prints a health report. Short
+// soaks report more often so they still produce a few samples.
+func soakReportInterval(d time.Duration) time.Duration {
+	if d < 40*time.Second {
+		return d / 4
+	}
+	return 10 * time.Second
+}
+
+// soakShards is how many latency estimator shards a soak run spreads its
+// observers over
+const soakShards = 4
+
+// soakMonitor prints one-line health reports during a soak run and keeps the
+// samples it needs to spot leaks. Latencies go into a streaming estimator, so
+// a long run's reports cost the same memory as a short one's.
+type soakMonitor struct {
+	name       string
+	start      time.Time
+	processed  *int64
+	latency    *ShardedQuantile
+	depths     func() string
+	lastCount  int64
+	lastTime   time.Time
+	goroutines []int
+	heaps      []uint64
+}
+
+func newSoakMonitor(name string, processed *int64, latency *ShardedQuantile, depths func() string) *soakMonitor {
+	now := time.Now()
+	return &soakMonitor{
+		name:      name,
+		start:     now,
+		processed: processed,
+		latency:   latency,
+		depths:    depths,
+		lastTime:  now,
+	}
+}
+
+// run reports every interval until ctx is done
+func (m *soakMonitor) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.report()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *soakMonitor) report() {
+	now := time.Now()
+	count := atomic.LoadInt64(m.processed)
+	rate := float64(count-m.lastCount) / now.Sub(m.lastTime).Seconds()
+	m.lastCount, m.lastTime = count, now
+
+	// Collect first so the heap figure is live data, not garbage awaiting GC
+	runtime.GC()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	goroutines := runtime.NumGoroutine()
+	m.goroutines = append(m.goroutines, goroutines)
+	m.heaps = append(m.heaps, mem.HeapAlloc)
+
+	fmt.Fprintf(output(), "[soak %s] t=%v processed=%d throughput=%.1f/s latency=%s queues=%s goroutines=%d heap=%.1fKB\n",
+		m.name, now.Sub(m.start).Round(100*time.Millisecond), count, rate, latencySummary(m.latency), m.depths(), goroutines, float64(mem.HeapAlloc)/1024)
+}
+
+// latencySummary formats the p50 and p99 of latencies observed in ms
+func latencySummary(latency *ShardedQuantile) string {
+	if latency.Count() == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("p50:%.1fms,p99:%.1fms", latency.Quantile(0.5), latency.Quantile(0.99))
+}
+
+// observeSince records the time since start in ms
+func observeSince(q *StreamingQuantile, start time.Time) {
+	q.Observe(float64(time.Since(start)) / float64(time.Millisecond))
+}
+
+// leakError reports goroutine counts or heap sizes that grew at every sample
+func (m *soakMonitor) leakError() error {
+	if len(m.goroutines) >= 4 && strictlyIncreasing(m.goroutines) {
+		return fmt.Errorf("goroutine count grew at every report: %v", m.goroutines)
+	}
+	// Live heap is noisier, so also require meaningful overall growth
+	if len(m.heaps) >= 5 && strictlyIncreasing(m.heaps) && m.heaps[len(m.heaps)-1] > m.heaps[0]*5/4 {
+		return fmt.Errorf("heap grew at every report: %v", m.heaps)
+	}
+	return nil
+}
+
+func strictlyIncreasing[T int | uint64](samples []T) bool {
+	for i := 1; i < len(samples); i++ {
+		if samples[i] <= samples[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// waitForGoroutines waits briefly for the goroutine count to settle back to baseline
+func waitForGoroutines(baseline int, within time.Duration) error {
+	if pollUntil(func() bool { return runtime.NumGoroutine() <= baseline }, 10*time.Millisecond, within) {
+		return nil
+	}
+	return fmt.Errorf("%d goroutines still running, baseline was %d", runtime.NumGoroutine(), baseline)
+}
+
+// runSoak drives a soak run: start launches the workload and returns a
+// function that stops it and waits for everything to drain. The workload
+// observes each item's end-to-end latency into latency.
+func runSoak(name string, d time.Duration, processed *int64, latency *ShardedQuantile, depths func() string, start func(ctx context.Context) (stop func())) {
+	fmt.Fprintf(output(), "Soaking %s for %v...\n", name, d)
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	monitor := newSoakMonitor(name, processed, latency, depths)
+	publishStats("soak", func() interface{} {
+		return map[string]interface{}{
+			"example":    name,
+			"processed":  atomic.LoadInt64(processed),
+			"latency":    latencySummary(latency),
+			"queues":     depths(),
+			"goroutines": runtime.NumGoroutine(),
+		}
+	})
+	var monitorWg sync.WaitGroup
+	monitorWg.Add(1)
+	go func() {
+		defer monitorWg.Done()
+		monitor.run(ctx, soakReportInterval(d))
+	}()
+
+	stop := start(ctx)
+	<-ctx.Done()
+	stop()
+	monitorWg.Wait()
+
+	fmt.Fprintf(output(), "[soak %s] finished: processed %d items, latency %s\n", name, atomic.LoadInt64(processed), latencySummary(latency))
+	if err := monitor.leakError(); err != nil {
+		fail("soak %s: %v", name, err)
+	}
+	if err := waitForGoroutines(baseline, time.Second); err != nil {
+		fail("soak %s: %v", name, err)
+	}
+}
+
+// soakItem is an Item stamped with when it entered the buffer
+type soakItem struct {
+	Item
+	Enqueued time.Time
+}
+
+// soakProducerConsumer runs producers until the deadline and then drains the buffer
+func soakProducerConsumer(d time.Duration) {
+	var processed int64
+	latency := NewShardedQuantile(soakShards)
+	buffer := make(chan soakItem, 5)
+
+	runSoak("producer-consumer", d, &processed, latency, func() string {
+		return fmt.Sprintf("buffer:%d", len(buffer))
+	}, func(ctx context.Context) func() {
+		var producers sync.WaitGroup
+		for p := 1; p <= 2; p++ {
+			producers.Add(1)
+			go func(id int) {
+				defer producers.Done()
+				ticker := time.NewTicker(2 * time.Millisecond)
+				defer ticker.Stop()
+				for seq := 1; ; seq++ {
+					select {
+					case <-ticker.C:
+					case <-ctx.Done():
+						return
+					}
+					select {
+					case buffer <- soakItem{Item{ProducerID: id, Seq: seq, Value: rand.Intn(100)}, time.Now()}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}(p)
+		}
+
+		var consumers sync.WaitGroup
+		for c := 1; c <= 3; c++ {
+			consumers.Add(1)
+			go func(shard *StreamingQuantile) {
+				defer consumers.Done()
+				for item := range buffer {
+					time.Sleep(time.Duration(rand.Intn(3)+1) * time.Millisecond)
+					observeSince(shard, item.Enqueued)
+					atomic.AddInt64(&processed, 1)
+				}
+			}(latency.Shard(c))
+		}
+
+		return func() {
+			producers.Wait()
+			close(buffer)
+			consumers.Wait()
+		}
+	})
+}
+
+// soakPubSub publishes until the deadline and then closes the broadcaster
+func soakPubSub(d time.Duration) {
+	var processed int64
+	latency := NewShardedQuantile(soakShards)
+	// Messages carry their publish time
+	b := newBroadcaster[time.Time]()
+	var subs []<-chan time.Time
+	for i := 0; i < 3; i++ {
+		subs = append(subs, b.subscribe())
+	}
+
+	runSoak("pubsub", d, &processed, latency, func() string {
+		depths := make([]string, len(subs))
+		for i, ch := range subs {
+			depths[i] = fmt.Sprintf("sub%d:%d", i+1, len(ch))
+		}
+		return strings.Join(depths, ",")
+	}, func(ctx context.Context) func() {
+		var subscribers sync.WaitGroup
+		for i, ch := range subs {
+			subscribers.Add(1)
+			go func(ch <-chan time.Time, shard *StreamingQuantile) {
+				defer subscribers.Done()
+				for published := range ch {
+					observeSince(shard, published)
+					atomic.AddInt64(&processed, 1)
+				}
+			}(ch, latency.Shard(i))
+		}
+
+		publisherDone := make(chan struct{})
+		go func() {
+			defer close(publisherDone)
+			ticker := time.NewTicker(time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					b.publish(time.Now())
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return func() {
+			<-publisherDone
+			b.close()
+			subscribers.Wait()
+		}
+	})
+}
+
+// soakPools feeds the worker pool until the deadline and then lets it drain
+func soakPools(d time.Duration) {
+	var processed int64
+	latency := NewShardedQuantile(soakShards)
+	// Jobs carry their submit time
+	jobs := make(chan time.Time, 10)
+
+	runSoak("pools", d, &processed, latency, func() string {
+		return fmt.Sprintf("jobs:%d", len(jobs))
+	}, func(ctx context.Context) func() {
+		var workers sync.WaitGroup
+		for i := 1; i <= 3; i++ {
+			workers.Add(1)
+			go func(shard *StreamingQuantile) {
+				defer workers.Done()
+				for submitted := range jobs {
+					time.Sleep(time.Duration(rand.Intn(4)+1) * time.Millisecond)
+					observeSince(shard, submitted)
+					atomic.AddInt64(&processed, 1)
+				}
+			}(latency.Shard(i))
+		}
+
+		senderDone := make(chan struct{})
+		go func() {
+			defer close(senderDone)
+			for {
+				select {
+				case jobs <- time.Now():
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return func() {
+			<-senderDone
+			close(jobs)
+			workers.Wait()
+		}
+	})
+}
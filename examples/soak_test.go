@@ -0,0 +1,101 @@
+package examples
+
+import (
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// soakReportLine matches one periodic health report of the pools soak
+var soakReportLine = regexp.MustCompile(`^\[soak pools\] t=\S+ processed=(\d+) throughput=[\d.]+/s latency=p50:[\d.]+ms,p99:[\d.]+ms queues=jobs:\d+ goroutines=(\d+) heap=([\d.]+)KB$`)
+
+// TestSoakReports runs a short pools soak and checks it prints a well-formed
+// report each interval with a growing processed count and bounded goroutine
+// and heap figures, then a summary, and leaves no goroutines behind
+func TestSoakReports(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	var buf lockedBuffer
+	opts = Options{Out: &buf}
+	baseline := runtime.NumGoroutine()
+
+	const d = 400 * time.Millisecond
+	soakPools(d)
+
+	out := buf.String()
+	if strings.Contains(out, "FAIL:") {
+		t.Fatalf("soak recorded a failure:\n%s", out)
+	}
+	var reports int
+	var lastProcessed int
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !strings.HasPrefix(line, "[soak pools] t=") {
+			continue
+		}
+		m := soakReportLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("malformed report line: %q", line)
+		}
+		reports++
+		processed, _ := strconv.Atoi(m[1])
+		goroutines, _ := strconv.Atoi(m[2])
+		heapKB, _ := strconv.ParseFloat(m[3], 64)
+		if processed <= lastProcessed {
+			t.Fatalf("processed went from %d to %d: %q", lastProcessed, processed, line)
+		}
+		lastProcessed = processed
+		// The sender, three workers and the monitor
+		if goroutines > baseline+5 {
+			t.Fatalf("%d goroutines during the soak, baseline %d: %q", goroutines, baseline, line)
+		}
+		if heapKB > 64*1024 {
+			t.Fatalf("live heap of %.0fKB during the soak: %q", heapKB, line)
+		}
+	}
+	// A report every d/4; the last may lose the race with the deadline
+	if reports < 3 || reports > 4 {
+		t.Fatalf("%d reports in a %v soak, want 3 or 4:\n%s", reports, d, out)
+	}
+	if !regexp.MustCompile(`\[soak pools\] finished: processed \d+ items, latency p50:`).MatchString(out) {
+		t.Fatalf("no summary line:\n%s", out)
+	}
+	goroutineCountStable(t, baseline)
+}
+
+// TestSoakMonitorLeak samples a monitor while goroutines pile up and checks
+// it reports the leak, and that steady samples report none
+func TestSoakMonitorLeak(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	var buf lockedBuffer
+	opts = Options{Out: &buf}
+
+	var processed int64
+	monitor := newSoakMonitor("leak", &processed, NewShardedQuantile(1), func() string { return "-" })
+	stop := make(chan struct{})
+	defer close(stop)
+	for i := 0; i < 4; i++ {
+		monitor.report()
+		for j := 0; j < 3; j++ {
+			go func() { <-stop }()
+		}
+	}
+	if err := monitor.leakError(); err == nil {
+		t.Fatalf("goroutines %v grew at every report, no leak reported", monitor.goroutines)
+	}
+
+	steady := &soakMonitor{goroutines: []int{10, 12, 11, 12}, heaps: []uint64{100, 130, 110, 120, 125}}
+	if err := steady.leakError(); err != nil {
+		t.Fatalf("steady samples reported a leak: %v", err)
+	}
+	// Heap growth only counts once it is meaningful overall
+	creeping := &soakMonitor{heaps: []uint64{100, 101, 102, 103, 104}}
+	if err := creeping.leakError(); err != nil {
+		t.Fatalf("heap creeping 4%% reported a leak: %v", err)
+	}
+	growing := &soakMonitor{heaps: []uint64{100, 120, 140, 160, 180}}
+	if err := growing.leakError(); err == nil {
+		t.Fatalf("heap growing 80%% at every report, no leak reported")
+	}
+}
@@ -0,0 +1,79 @@
+package examples
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	statsMu      sync.Mutex
+	statsSources = make(map[string]func() interface{})
+	expvarOnce   sync.Once
+)
+
+// publishStats registers a component's stats under name. fn must be a cheap
+// snapshot (no waiting on the component's work), since it is called on every
+// /stats request.
+func publishStats(name string, fn func() interface{}) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	statsSources[name] = fn
+}
+
+// StatsSnapshot returns the current stats of every registered component
+func StatsSnapshot() map[string]interface{} {
+	statsMu.Lock()
+	sources := make(map[string]func() interface{}, len(statsSources))
+	for name, fn := range statsSources {
+		sources[name] = fn
+	}
+	statsMu.Unlock()
+
+	snapshot := make(map[string]interface{}, len(sources))
+	for name, fn := range sources {
+		snapshot[name] = fn()
+	}
+	return snapshot
+}
+
+// StartStatsServer serves the component stats as JSON at /stats and the
+// standard expvar variables at /debug/vars. It returns the address it
+// listens on and a channel that closes once the server has shut down after
+// ctx is done.
+func StartStatsServer(ctx context.Context, addr string) (string, <-chan struct{}, error) {
+	expvarOnce.Do(func() {
+		expvar.Publish("components", expvar.Func(func() interface{} {
+			return StatsSnapshot()
+		}))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(StatsSnapshot())
+	})
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	srv := &http.Server{Handler: mux}
+	stopped := make(chan struct{})
+	go srv.Serve(ln)
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	return ln.Addr().String(), stopped, nil
+}
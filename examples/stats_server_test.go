@@ -0,0 +1,79 @@
+package examples
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestStatsServer starts the server on a random port, runs the pub/sub
+// example and GETs /stats and /debug/vars while it runs, checking both decode
+// and carry the broadcaster's section, then checks the server stops with ctx
+func TestStatsServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, stopped, err := StartStatsServer(ctx, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		RunPubSub(context.Background())
+	}()
+
+	get := func(path string, v interface{}) error {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s: %s", path, resp.Status)
+		}
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+
+	var stats map[string]json.RawMessage
+	midRun := pollUntil(func() bool {
+		stats = nil
+		return get("/stats", &stats) == nil && stats["broadcaster"] != nil
+	}, 5*time.Millisecond, 2*time.Second)
+	select {
+	case <-finished:
+		t.Fatalf("example finished before /stats showed the broadcaster mid-run")
+	default:
+	}
+	if !midRun {
+		t.Fatalf("/stats never showed a broadcaster section: %v", stats)
+	}
+	var broadcaster broadcasterStats
+	if err := json.Unmarshal(stats["broadcaster"], &broadcaster); err != nil {
+		t.Fatalf("decoding the broadcaster section: %v", err)
+	}
+
+	var vars struct {
+		Components map[string]json.RawMessage `json:"components"`
+	}
+	if err := get("/debug/vars", &vars); err != nil {
+		t.Fatal(err)
+	}
+	if vars.Components["broadcaster"] == nil {
+		t.Fatalf("/debug/vars components has no broadcaster section: %v", vars.Components)
+	}
+
+	<-finished
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("server still running 2s after ctx was cancelled")
+	}
+	if _, err := http.Get("http://" + addr + "/stats"); err == nil {
+		t.Fatalf("server still answering after shutdown")
+	}
+}
@@ -0,0 +1,200 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// retryDelay is how long a crashed job waits before it is re-queued
+const retryDelay = 20 * time.Millisecond
+
+// jobOutcome is the result of one job run by a supervisedPool
+type jobOutcome struct {
+	Job      int
+	WorkerID int
+	Result   string
+	Err      error
+}
+
+// workerCrash is what a panicking worker reports to the supervisor
+type workerCrash struct {
+	workerID int
+	job      int
+	value    interface{}
+}
+
+// supervisedPool is a worker pool with a supervisor: a worker that panics is
+// replaced so the pool keeps its configured size. The crashed worker's job is
+// reported as failed, or with requeue set it is retried once on another
+// worker after retryDelay.
+type supervisedPool struct {
+	jobs     chan int
+	results  chan jobOutcome
+	crashes  chan workerCrash
+	fn       func(job int) string
+	requeue  bool
+	retries  *retryScheduler[int]
+	requeued chan struct{} // closed once retries stops feeding jobs
+	inflight sync.WaitGroup
+	workers  sync.WaitGroup
+	// lifecycles runs each worker's stop hooks before it counts as exited
+	lifecycles workerSet
+
+	mu       sync.Mutex
+	nextID   int
+	alive    int
+	restarts int
+	retried  map[int]bool
+}
+
+func newSupervisedPool(numWorkers int, requeue bool, fn func(job int) string) *supervisedPool {
+	p := &supervisedPool{
+		jobs:    make(chan int),
+		results: make(chan jobOutcome),
+		crashes: make(chan workerCrash),
+		fn:      fn,
+		requeue: requeue,
+		retried: make(map[int]bool),
+		// A crashed job that finds the scheduler full fails instead
+		retries:  newRetryScheduler[int](numWorkers),
+		requeued: make(chan struct{}),
+	}
+	go func() {
+		defer close(p.requeued)
+		for job := range p.retries.C {
+			p.jobs <- job
+		}
+	}()
+	for i := 0; i < numWorkers; i++ {
+		p.spawn()
+	}
+
+	supervisorDone := make(chan struct{})
+	go p.supervise(supervisorDone)
+
+	// Close down once every submitted job has an outcome and close was called
+	go func() {
+		<-supervisorDone
+		close(p.results)
+	}()
+
+	return p
+}
+
+// spawn starts a new worker with the next ID
+func (p *supervisedPool) spawn() {
+	p.mu.Lock()
+	p.nextID++
+	p.alive++
+	id := p.nextID
+	p.mu.Unlock()
+
+	p.workers.Add(1)
+	go p.worker(id)
+}
+
+func (p *supervisedPool) worker(id int) {
+	defer p.workers.Done()
+	p.lifecycles.started(id)
+	for job := range p.jobs {
+		result, crash := p.run(id, job)
+		if crash != nil {
+			// Count the worker gone before the supervisor hears of it
+			p.lifecycles.stopped(id, Panicked)
+			p.exited()
+			p.crashes <- *crash
+			return
+		}
+		p.results <- jobOutcome{Job: job, WorkerID: id, Result: result}
+		p.inflight.Done()
+	}
+	p.lifecycles.stopped(id, Completed)
+	p.exited()
+}
+
+// OnWorkerStart calls fn with the lifecycle of every worker, replacements
+// included, so it can register OnStop cleanup
+func (p *supervisedPool) OnWorkerStart(fn func(id int, w *WorkerLifecycle)) {
+	p.lifecycles.OnWorkerStart(fn)
+}
+
+func (p *supervisedPool) exited() {
+	p.mu.Lock()
+	p.alive--
+	p.mu.Unlock()
+}
+
+// run calls the job function, turning a panic into a crash report
+func (p *supervisedPool) run(id, job int) (result string, crash *workerCrash) {
+	defer func() {
+		if r := recover(); r != nil {
+			crash = &workerCrash{workerID: id, job: job, value: r}
+		}
+	}()
+	return p.fn(job), nil
+}
+
+// supervise replaces crashed workers and settles their jobs
+func (p *supervisedPool) supervise(done chan<- struct{}) {
+	defer close(done)
+	for c := range p.crashes {
+		fmt.Fprintf(output(), "Supervisor: worker %d crashed on job %d (%v), starting a replacement\n", c.workerID, c.job, c.value)
+		p.mu.Lock()
+		p.restarts++
+		retry := p.requeue && !p.retried[c.job]
+		p.retried[c.job] = true
+		p.mu.Unlock()
+
+		// The replacement starts before the job is settled, so the pool
+		// never runs short while jobs are still outstanding
+		p.spawn()
+		fmt.Fprintf(output(), "Supervisor: pool back to %d workers\n", p.Workers())
+
+		err := fmt.Errorf("job %d: worker %d crashed: %v", c.job, c.workerID, c.value)
+		if retry {
+			scheduleErr := p.retries.Schedule(c.job, retryDelay)
+			if scheduleErr == nil {
+				fmt.Fprintf(output(), "Supervisor: re-queueing job %d in %v\n", c.job, retryDelay)
+				continue
+			}
+			err = fmt.Errorf("%w; not retried: %w", err, scheduleErr)
+		}
+		p.results <- jobOutcome{Job: c.job, WorkerID: c.workerID, Err: err}
+		p.inflight.Done()
+	}
+}
+
+// submit queues a job; it blocks until a worker takes it
+func (p *supervisedPool) submit(job int) {
+	p.inflight.Add(1)
+	p.jobs <- job
+}
+
+// close stops accepting jobs. The results channel closes once every submitted
+// job has an outcome and the workers have exited.
+func (p *supervisedPool) close() {
+	go func() {
+		p.inflight.Wait()
+		// Every job has an outcome, so no retries are waiting
+		p.retries.Close(false)
+		<-p.requeued
+		close(p.jobs)
+		p.workers.Wait()
+		close(p.crashes)
+	}()
+}
+
+// Workers returns how many workers are currently running
+func (p *supervisedPool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.alive
+}
+
+// Restarts returns how many crashed workers were replaced
+func (p *supervisedPool) Restarts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.restarts
+}
@@ -0,0 +1,96 @@
+package examples
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSupervisedPoolReplacesCrashedWorkers runs jobs through a pool of 3
+// where jobs 5 and 11 always panic and job 8 panics on its first try only,
+// and checks each crash is reported and replaced so the pool stays at 3
+// workers, the crashed jobs fail or are retried, and every other job completes
+func TestSupervisedPoolReplacesCrashedWorkers(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	const workers, jobs = 3, 20
+	var firstTry sync.Once
+	p := newSupervisedPool(workers, true, func(job int) string {
+		time.Sleep(5 * time.Millisecond)
+		switch job {
+		case 5, 11:
+			panic(fmt.Sprintf("bad input %d", job))
+		case 8:
+			firstTry.Do(func() { panic("transient fault") })
+		}
+		return fmt.Sprintf("job %d done", job)
+	})
+	go func() {
+		for i := 1; i <= jobs; i++ {
+			p.submit(i)
+		}
+		p.close()
+	}()
+
+	done := make(map[int]int)
+	failed := make(map[int]error)
+	outcomes := make(chan jobOutcome)
+	goLabeled(t, "supervised-results", func() {
+		for o := range p.results {
+			outcomes <- o
+		}
+		close(outcomes)
+	})
+	timeout := time.After(5 * time.Second)
+	for collecting := true; collecting; {
+		select {
+		case o, ok := <-outcomes:
+			if !ok {
+				collecting = false
+				break
+			}
+			if o.Err != nil {
+				failed[o.Job] = o.Err
+				// The replacement is running before the failure is reported;
+				// only the last outcome lets the pool wind down
+				if n := p.Workers(); n != workers && len(done)+len(failed) < jobs {
+					t.Fatalf("%d workers after job %d crashed, want %d", n, o.Job, workers)
+				}
+				continue
+			}
+			done[o.Job]++
+			if o.Result != fmt.Sprintf("job %d done", o.Job) {
+				t.Fatalf("job %d returned %q", o.Job, o.Result)
+			}
+		case <-timeout:
+			t.Fatalf("pool still running after 5s: %d done, %d failed, %d workers", len(done), len(failed), p.Workers())
+		}
+	}
+
+	for job := 1; job <= jobs; job++ {
+		crashes := job == 5 || job == 11
+		if crashes {
+			if err := failed[job]; err == nil || !strings.Contains(err.Error(), "crashed: bad input") {
+				t.Fatalf("job %d that always panics reported %v", job, err)
+			}
+			if done[job] != 0 {
+				t.Fatalf("job %d that always panics completed", job)
+			}
+			continue
+		}
+		if done[job] != 1 || failed[job] != nil {
+			t.Fatalf("job %d completed %d times, failed with %v", job, done[job], failed[job])
+		}
+	}
+	// Jobs 5 and 11 crash on their try and retry, job 8 on its first try
+	if n := p.Restarts(); n != 5 {
+		t.Fatalf("%d workers replaced, want 5", n)
+	}
+	if n := p.Workers(); n != 0 {
+		t.Fatalf("%d workers still running after the results closed", n)
+	}
+}
@@ -1,63 +1,313 @@
 package examples
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 // RunSupervisor demonstrates the supervisor/restart pattern.
-func RunSupervisor() {
-	fmt.Println("=== Supervisor/Restart Pattern Example ===")
+func RunSupervisor(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Supervisor/Restart Pattern Example ===")
+
+	phase("restart")
+	// The worker fails its first three runs, then heals
+	worker := &healingWorker{failFirst: 3, workTime: 300 * time.Millisecond}
+	sup := newSupervisor(NewExponentialBackoff(100*time.Millisecond, time.Second), worker.run)
+	sup.chaos = chaosInjector()
+	publishStats("supervisor", func() interface{} {
+		return map[string]int32{
+			"restarts": atomic.LoadInt32(&sup.restarts),
+			"panics":   atomic.LoadInt32(&sup.panics),
+		}
+	})
 
-	var restarts int32
 	stop := make(chan struct{})
 	done := make(chan struct{})
+	go sup.run(stop, done)
+
+	// Let the supervisor run for a while
+	pause(4 * time.Second)
+	close(stop)
+	<-done
+
+	restarts, panics := atomic.LoadInt32(&sup.restarts), atomic.LoadInt32(&sup.panics)
+	record("restarts", restarts)
+	record("panics", panics)
+	if sup.chaos != nil {
+		fmt.Fprintf(output(), "[chaos supervisor] %d restarts, %d caused by injected panics\n", restarts, panics)
+	}
+	verify("supervisor", func() error {
+		// Once the worker heals, the supervisor has nothing left to restart
+		if sup.chaos == nil && (restarts != int32(worker.failFirst) || worker.Runs() != worker.failFirst+1) {
+			return fmt.Errorf("%d restarts over %d runs, want %d restarts and %d runs",
+				restarts, worker.Runs(), worker.failFirst, worker.failFirst+1)
+		}
+		return nil
+	})
+
+	phase("flapping")
+	runFlappingSupervisor()
+	phase("group")
+	runSupervisorGroup()
+	phase("dependencies")
+	runSupervisorDependencies()
+
+	fmt.Fprintf(output(), "Supervisor example completed! Worker was restarted %d times.\n", restarts)
+}
+
+// runSupervisorGroup supervises three workers one-for-one, lets one of them
+// crash, then rolls out a new config twice: once cleanly, and once with a
+// config the second worker never becomes ready on
+func runSupervisorGroup() {
+	fmt.Fprintln(output(), "\nSupervised group (one-for-one, rolling restart):")
+	var badConfig int32
+	specs := make([]workerSpec, 0, 3)
+	for _, name := range []string{"ingest", "index", "notify"} {
+		name := name
+		specs = append(specs, workerSpec{
+			Name: name,
+			Run: func(incarnation int, stop <-chan struct{}) error {
+				// The first index worker crashes shortly after starting
+				if name == "index" && incarnation == 1 {
+					if sleepOrDone(150*time.Millisecond, stop) {
+						return errors.New("index corrupted")
+					}
+					return nil
+				}
+				<-stop
+				return nil
+			},
+			Ready: func(incarnation int) bool {
+				return name != "index" || atomic.LoadInt32(&badConfig) == 0
+			},
+		})
+	}
+	g, err := newSupervisorGroup(50*time.Millisecond, 200*time.Millisecond, specs...)
+	if err != nil {
+		fail("supervisor group: %v", err)
+		return
+	}
+	defer g.close()
+
+	if !pollUntil(func() bool { return g.Incarnation("index") == 2 }, 10*time.Millisecond, time.Second) {
+		fail("supervisor group: the crashed index worker was not restarted")
+	}
+	if err := g.RollingRestart(); err != nil {
+		fail("supervisor group: clean rollout: %v", err)
+	}
+	atomic.StoreInt32(&badConfig, 1)
+	err = g.RollingRestart()
+	fmt.Fprintf(output(), "Rollout with a bad config: %v\n", err)
+	if err == nil || g.Incarnation("notify") != 2 {
+		fail("supervisor group: the bad rollout reached notify#%d (err %v)", g.Incarnation("notify"), err)
+	}
+	record("group_history", g.History())
+}
+
+// supervisor restarts a failed worker, waiting between restarts as its
+// backoff dictates
+type supervisor struct {
+	backoff Backoff
+	chaos   *FaultInjector
+	// worker runs one incarnation with the config schedule gives it and
+	// sends on done when it exits; it must return once stop is closed
+	worker func(cfg incarnationConfig, done chan<- struct{}, stop <-chan struct{})
+	// stableAfter is how long an incarnation must run before the backoff
+	// starts over
+	stableAfter time.Duration
+	// schedule, if set, adapts each incarnation's grace and deadline to how
+	// the previous ones fared; without it every incarnation gets a zero config
+	schedule *timeoutSchedule
 
-	// Supervisor goroutine
-	go func() {
+	restarts int32
+	panics   int32
+}
+
+func newSupervisor(backoff Backoff, worker func(cfg incarnationConfig, done chan<- struct{}, stop <-chan struct{})) *supervisor {
+	return &supervisor{backoff: backoff, worker: worker, stableAfter: 2 * time.Second}
+}
+
+// run supervises workers until stop is closed, then closes done
+func (s *supervisor) run(stop <-chan struct{}, done chan<- struct{}) {
+	attempt := 0
+	for incarnation := 1; ; incarnation++ {
+		heartbeat()
+		// Buffered so a worker that exits after the supervisor stopped doesn't block forever
+		workerDone := make(chan struct{}, 1)
+		cfg := s.schedule.next()
+		go func(n int) {
+			// A panicking worker is reported to the supervisor like any other failure
+			defer func() {
+				if r := recover(); r != nil {
+					atomic.AddInt32(&s.panics, 1)
+					fmt.Fprintf(output(), "Worker: Panicked: %v\n", r)
+					workerDone <- struct{}{}
+				}
+			}()
+			if s.chaos != nil {
+				time.Sleep(s.chaos.Delay("supervisor", n))
+				if s.chaos.Panics("supervisor", n) {
+					panic(fmt.Sprintf("injected panic in incarnation %d", n))
+				}
+			}
+			s.worker(cfg, workerDone, stop)
+		}(incarnation)
+
+		stable := time.After(s.stableAfter)
+		failed := false
+	watch:
 		for {
-			workerDone := make(chan struct{})
-			go workerWithFailure(workerDone, stop)
 			select {
+			case <-stable:
+				// The worker has been healthy for a while; start the backoff over
+				if attempt > 0 {
+					fmt.Fprintf(output(), "Supervisor: Worker stable for %v, resetting backoff\n", s.stableAfter)
+				}
+				attempt = 0
+				s.backoff.Reset()
+				if s.schedule.reset() {
+					fmt.Fprintf(output(), "Supervisor: Reverting to %v for the next incarnation\n", s.schedule.defaults)
+				}
+				stable = nil
 			case <-workerDone:
-				atomic.AddInt32(&restarts, 1)
-				fmt.Println("Supervisor: Worker failed, restarting...")
-				// Restart after a short delay
-				time.Sleep(500 * time.Millisecond)
+				// A worker exiting because supervision is ending didn't fail
+				failed = !stopped(stop)
+				break watch
 			case <-stop:
-				fmt.Println("Supervisor: Stopping worker supervision.")
-				close(done)
-				return
+				break watch
 			}
 		}
-	}()
+		if failed {
+			if stable != nil {
+				// Failed before it was stable: the worker is flapping
+				s.schedule.flapped()
+			}
+			atomic.AddInt32(&s.restarts, 1)
+			attempt++
+			delay := s.backoff.Next(attempt)
+			fmt.Fprintf(output(), "Supervisor: Worker failed, restarting in %v...\n", delay)
+			if pauseOrDone(delay, stop) {
+				continue
+			}
+		}
+		fmt.Fprintln(output(), "Supervisor: Stopping worker supervision.")
+		close(done)
+		return
+	}
+}
 
-	// Let the supervisor run for a while
-	time.Sleep(4 * time.Second)
-	close(stop)
-	<-done
+// stopped reports whether stop has been closed
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
 
-	fmt.Printf("Supervisor example completed! Worker was restarted %d times.\n", restarts-1)
+// healingWorker fails its first failFirst runs, each after workTime, then
+// works healthily until stopped. Its run counter is the only state deciding
+// whether a run fails, so the same worker always fails the same way.
+type healingWorker struct {
+	failFirst int
+	workTime  time.Duration
+	runs      int32
 }
 
-// workerWithFailure simulates a worker that randomly fails
-func workerWithFailure(done chan<- struct{}, stop <-chan struct{}) {
-	fmt.Println("Worker: Started")
-	workTime := time.Duration(rand.Intn(1200)+400) * time.Millisecond
-	select {
-	case <-time.After(workTime):
-		// Simulate random failure
-		if rand.Float32() < 0.6 {
-			fmt.Println("Worker: Simulated failure!")
-			done <- struct{}{}
+// Runs reports how many incarnations the worker has started
+func (w *healingWorker) Runs() int {
+	return int(atomic.LoadInt32(&w.runs))
+}
+
+// run is one incarnation of the worker, matching supervisor.worker
+func (w *healingWorker) run(_ incarnationConfig, done chan<- struct{}, stop <-chan struct{}) {
+	run := int(atomic.AddInt32(&w.runs, 1))
+	fmt.Fprintf(output(), "Worker: Started (run %d)\n", run)
+	defer func() { done <- struct{}{} }()
+	ticker := time.NewTicker(w.workTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			heartbeat()
+			if run <= w.failFirst {
+				fmt.Fprintln(output(), "Worker: Simulated failure!")
+				return
+			}
+			fmt.Fprintln(output(), "Worker: Completed work successfully.")
+		case <-stop:
+			fmt.Fprintln(output(), "Worker: Received stop signal.")
 			return
 		}
-		fmt.Println("Worker: Completed work successfully.")
-	case <-stop:
-		fmt.Println("Worker: Received stop signal.")
 	}
-	// Signal normal exit
-	done <- struct{}{}
+}
+
+// runSupervisorDependencies supervises a three-worker chain: the writer
+// needs the connection manager, and the reporter needs the writer. The
+// connection manager drops its connection mid-run, and the restart cascades
+// to the writer, which can't keep using the old connection.
+func runSupervisorDependencies() {
+	fmt.Fprintln(output(), "\nSupervised chain (connection-manager <- writer <- reporter):")
+	var mu sync.Mutex
+	ready := map[string]int{}
+	spec := func(name string, deps ...string) workerSpec {
+		return workerSpec{
+			Name:      name,
+			DependsOn: deps,
+			Run: func(incarnation int, stop <-chan struct{}) error {
+				// Connecting or warming up before the worker reports ready
+				if !sleepOrDone(50*time.Millisecond, stop) {
+					return nil
+				}
+				mu.Lock()
+				ready[name] = incarnation
+				mu.Unlock()
+				if name == "connection-manager" && incarnation == 1 {
+					if sleepOrDone(300*time.Millisecond, stop) {
+						return errors.New("connection reset by peer")
+					}
+					return nil
+				}
+				<-stop
+				return nil
+			},
+			Ready: func(incarnation int) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return ready[name] >= incarnation
+			},
+		}
+	}
+	writer := spec("writer", "connection-manager")
+	writer.Cascade = true
+	// Registered out of order; the group starts them by dependency
+	g, err := newSupervisorGroup(50*time.Millisecond, time.Second,
+		spec("reporter", "writer"), writer, spec("connection-manager"))
+	if err != nil {
+		fail("supervisor chain: %v", err)
+		return
+	}
+	defer g.close()
+
+	// Wait on the group's own record of the restart, so the history read
+	// below always ends with it
+	restarted := func() bool {
+		for _, entry := range g.History() {
+			if entry == "writer#2 ready" {
+				return true
+			}
+		}
+		return false
+	}
+	if !pollUntil(restarted, 10*time.Millisecond, 2*time.Second) {
+		fail("supervisor chain: the writer was not restarted with the connection manager")
+	}
+	fmt.Fprintf(output(), "Incarnations: connection-manager #%d, writer #%d, reporter #%d\n",
+		g.Incarnation("connection-manager"), g.Incarnation("writer"), g.Incarnation("reporter"))
+	record("dependency_history", g.History())
 }
@@ -0,0 +1,330 @@
+package examples
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// workerSpec describes one member of a supervisorGroup
+type workerSpec struct {
+	Name string
+	// Run is one incarnation of the worker, numbered from 1. It returns nil
+	// once stop is closed, or an error if the worker failed.
+	Run func(incarnation int, stop <-chan struct{}) error
+	// Ready is the readiness probe for an incarnation; nil means ready at once
+	Ready func(incarnation int) bool
+	// DependsOn names the workers that must be running and ready before this
+	// one starts
+	DependsOn []string
+	// Cascade restarts this worker whenever one of its dependencies is
+	// restarted after a failure
+	Cascade bool
+}
+
+// groupMember is the running incarnation of a worker
+type groupMember struct {
+	incarnation int
+	stop        chan struct{}
+	done        chan struct{}
+	stopping    bool
+}
+
+// supervisorGroup supervises several workers one-for-one: a worker that
+// fails is restarted on its own after restartDelay, along with any
+// dependents that cascade, and the others keep running. Workers start in
+// dependency order, each only once its dependencies are ready.
+// RollingRestart replaces them one at a time.
+type supervisorGroup struct {
+	specs        []workerSpec // in dependency order
+	restartDelay time.Duration
+	probeTimeout time.Duration
+	// restartMu serializes crash restarts and rolling restarts
+	restartMu sync.Mutex
+
+	mu       sync.Mutex
+	members  map[string]*groupMember
+	restarts map[string]int
+	history  []string
+	closed   bool
+	watchers sync.WaitGroup
+}
+
+// newSupervisorGroup checks the workers' dependencies and starts them in
+// dependency order, waiting for each to pass its readiness probe before
+// starting the workers that depend on it. An unknown dependency or a cycle
+// is rejected before anything starts; a worker that doesn't become ready
+// stops the group and fails with ErrTimeout.
+func newSupervisorGroup(restartDelay, probeTimeout time.Duration, specs ...workerSpec) (*supervisorGroup, error) {
+	ordered, err := dependencyOrder(specs)
+	if err != nil {
+		return nil, err
+	}
+	g := &supervisorGroup{
+		specs:        ordered,
+		restartDelay: restartDelay,
+		probeTimeout: probeTimeout,
+		members:      make(map[string]*groupMember),
+		restarts:     make(map[string]int),
+	}
+	// A worker failing during startup is restarted once startup is done
+	g.restartMu.Lock()
+	defer g.restartMu.Unlock()
+	for _, spec := range ordered {
+		if err := g.startReady(spec); err != nil {
+			g.close()
+			return nil, fmt.Errorf("starting the group: %w", err)
+		}
+	}
+	return g, nil
+}
+
+// dependencyOrder sorts specs so every worker comes after its dependencies,
+// keeping the given order where dependencies allow
+func dependencyOrder(specs []workerSpec) ([]workerSpec, error) {
+	known := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		known[spec.Name] = true
+	}
+	for _, spec := range specs {
+		for _, dep := range spec.DependsOn {
+			if !known[dep] {
+				return nil, fmt.Errorf("worker %s depends on unknown worker %s", spec.Name, dep)
+			}
+		}
+	}
+
+	placed := make(map[string]bool, len(specs))
+	var ordered []workerSpec
+	for len(ordered) < len(specs) {
+		progress := false
+		for _, spec := range specs {
+			if placed[spec.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range spec.DependsOn {
+				ready = ready && placed[dep]
+			}
+			if ready {
+				placed[spec.Name] = true
+				ordered = append(ordered, spec)
+				progress = true
+			}
+		}
+		if !progress {
+			var cycle []string
+			for _, spec := range specs {
+				if !placed[spec.Name] {
+					cycle = append(cycle, spec.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle among workers %s", strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+// note appends to the group's history; g.mu must be held
+func (g *supervisorGroup) note(format string, args ...interface{}) {
+	entry := fmt.Sprintf(format, args...)
+	g.history = append(g.history, entry)
+	fmt.Fprintf(output(), "Group supervisor: %s\n", entry)
+}
+
+// startLocked starts the next incarnation of spec; g.mu must be held
+func (g *supervisorGroup) startLocked(spec workerSpec) *groupMember {
+	m := &groupMember{stop: make(chan struct{}), done: make(chan struct{})}
+	if old, ok := g.members[spec.Name]; ok {
+		m.incarnation = old.incarnation
+	}
+	m.incarnation++
+	g.members[spec.Name] = m
+	g.note("start %s#%d", spec.Name, m.incarnation)
+
+	g.watchers.Add(1)
+	go func() {
+		defer g.watchers.Done()
+		err := spec.Run(m.incarnation, m.stop)
+		close(m.done)
+
+		g.mu.Lock()
+		if m.stopping || g.closed || g.members[spec.Name] != m {
+			g.mu.Unlock()
+			return
+		}
+		g.note("%s#%d failed (%v), restarting it", spec.Name, m.incarnation, err)
+		g.restarts[spec.Name]++
+		g.mu.Unlock()
+		if sleepOrDone(g.restartDelay, m.stop) {
+			g.restartFailed(spec, m)
+		}
+	}()
+	return m
+}
+
+// startReady starts the next incarnation of spec and waits for it to pass
+// its readiness probe
+func (g *supervisorGroup) startReady(spec workerSpec) error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return fmt.Errorf("%s: supervisor group closed", spec.Name)
+	}
+	m := g.startLocked(spec)
+	g.mu.Unlock()
+
+	if !g.awaitReady(spec, m.incarnation) {
+		g.mu.Lock()
+		g.note("%s#%d not ready within %v", spec.Name, m.incarnation, g.probeTimeout)
+		g.mu.Unlock()
+		return fmt.Errorf("%s#%d not ready: %w", spec.Name, m.incarnation, ErrTimeout)
+	}
+	g.mu.Lock()
+	g.note("%s#%d ready", spec.Name, m.incarnation)
+	g.mu.Unlock()
+	return nil
+}
+
+// restartFailed restarts the worker whose incarnation m failed. Its
+// cascading dependents are stopped first, dependents before what they
+// depend on, and started again in dependency order once it is ready.
+func (g *supervisorGroup) restartFailed(spec workerSpec, m *groupMember) {
+	g.restartMu.Lock()
+	defer g.restartMu.Unlock()
+	g.mu.Lock()
+	// A rolling restart or close may have replaced it in the meantime
+	current := !g.closed && g.members[spec.Name] == m
+	g.mu.Unlock()
+	if !current {
+		return
+	}
+
+	dependents := g.cascadingDependents(spec.Name)
+	for i := len(dependents) - 1; i >= 0; i-- {
+		g.stopMember(dependents[i].Name)
+	}
+	if g.startReady(spec) != nil {
+		return
+	}
+	for _, dep := range dependents {
+		if g.startReady(dep) != nil {
+			return
+		}
+	}
+}
+
+// cascadingDependents returns, in dependency order, the workers that are
+// restarted along with name: those with Cascade set that depend on it or on
+// another worker restarted with it
+func (g *supervisorGroup) cascadingDependents(name string) []workerSpec {
+	restarted := map[string]bool{name: true}
+	var dependents []workerSpec
+	for _, spec := range g.specs {
+		if !spec.Cascade {
+			continue
+		}
+		for _, dep := range spec.DependsOn {
+			if restarted[dep] {
+				restarted[spec.Name] = true
+				dependents = append(dependents, spec)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// stopMember stops a worker's current incarnation and waits for it to exit
+func (g *supervisorGroup) stopMember(name string) {
+	g.mu.Lock()
+	m := g.members[name]
+	if !m.stopping {
+		m.stopping = true
+		close(m.stop)
+	}
+	g.note("stop %s#%d", name, m.incarnation)
+	g.mu.Unlock()
+	<-m.done
+}
+
+// awaitReady polls spec's readiness probe for the incarnation until it
+// passes or probeTimeout runs out
+func (g *supervisorGroup) awaitReady(spec workerSpec, incarnation int) bool {
+	if spec.Ready == nil {
+		return true
+	}
+	return pollUntil(func() bool { return spec.Ready(incarnation) }, 5*time.Millisecond, g.probeTimeout)
+}
+
+// pollUntil checks cond every interval until it holds or timeout passes,
+// reporting whether it held. Waiting on a condition with a deadline is
+// progress, so it beats as it polls.
+func pollUntil(cond func() bool, interval, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		heartbeat()
+		if cond() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(interval)
+	}
+}
+
+// RollingRestart restarts the workers one at a time in dependency order,
+// waiting for each new incarnation to pass its readiness probe before
+// touching the next, so at most one worker is ever down. If a restarted
+// worker doesn't become ready the rollout halts there and the remaining
+// workers keep their current incarnations.
+func (g *supervisorGroup) RollingRestart() error {
+	g.restartMu.Lock()
+	defer g.restartMu.Unlock()
+	for _, spec := range g.specs {
+		g.stopMember(spec.Name)
+		if err := g.startReady(spec); err != nil {
+			g.mu.Lock()
+			g.note("rollout halted at %s", spec.Name)
+			g.mu.Unlock()
+			return fmt.Errorf("rolling restart halted: %w", err)
+		}
+	}
+	return nil
+}
+
+// History returns what the group supervisor did, in order
+func (g *supervisorGroup) History() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.history...)
+}
+
+// Incarnation returns the current incarnation number of a worker
+func (g *supervisorGroup) Incarnation(name string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.members[name].incarnation
+}
+
+// close stops every worker and waits for the supervision goroutines to exit
+func (g *supervisorGroup) close() {
+	g.mu.Lock()
+	g.closed = true
+	for _, m := range g.members {
+		if !m.stopping {
+			m.stopping = true
+			close(m.stop)
+		}
+	}
+	g.mu.Unlock()
+	g.watchers.Wait()
+}
+
+// idleWorker runs until stopped
+func idleWorker(_ int, stop <-chan struct{}) error {
+	<-stop
+	return nil
+}
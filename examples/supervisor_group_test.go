@@ -0,0 +1,147 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRollingRestart restarts three workers and checks that each one is
+// stopped only after the previous one is ready again, then that a failing
+// probe halts the rollout before the next worker is touched
+func TestRollingRestart(t *testing.T) {
+	var mu sync.Mutex
+	failFrom := map[string]int{} // incarnation from which a worker's probe fails
+	probe := func(name string) func(int) bool {
+		return func(incarnation int) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			from, ok := failFrom[name]
+			return !ok || incarnation < from
+		}
+	}
+	var specs []workerSpec
+	for _, name := range []string{"a", "b", "c"} {
+		specs = append(specs, workerSpec{Name: name, Run: idleWorker, Ready: probe(name)})
+	}
+	g, err := newSupervisorGroup(10*time.Millisecond, 50*time.Millisecond, specs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.close()
+
+	skip := len(g.History())
+	if err := g.RollingRestart(); err != nil {
+		t.Fatalf("first rollout: %v", err)
+	}
+	want := []string{
+		"stop a#1", "start a#2", "a#2 ready",
+		"stop b#1", "start b#2", "b#2 ready",
+		"stop c#1", "start c#2", "c#2 ready",
+	}
+	if got := g.History()[skip:]; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("first rollout went %q, want %q", got, want)
+	}
+
+	mu.Lock()
+	failFrom["b"] = 3
+	mu.Unlock()
+	if err := g.RollingRestart(); err == nil {
+		t.Fatalf("second rollout succeeded although b's probe fails")
+	}
+	if a, b, c := g.Incarnation("a"), g.Incarnation("b"), g.Incarnation("c"); a != 3 || b != 3 || c != 2 {
+		t.Fatalf("after the halted rollout incarnations are a#%d b#%d c#%d, want a#3 b#3 c#2", a, b, c)
+	}
+}
+
+// TestDependencyOrdering registers a chain c -> b -> a out of order and
+// checks that the workers start in dependency order, each only once its
+// dependency is ready, that a failure of a restarts b (which cascades) but
+// not c (which doesn't), and that a dependency cycle is rejected before
+// anything starts
+func TestDependencyOrdering(t *testing.T) {
+	var mu sync.Mutex
+	ready := map[string]int{} // the latest incarnation of each worker that is ready
+	isReady := func(name string, incarnation int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return ready[name] >= incarnation
+	}
+	var gated []string
+	worker := func(name string, deps []string, failFirst bool) workerSpec {
+		return workerSpec{
+			Name:      name,
+			DependsOn: deps,
+			Run: func(incarnation int, stop <-chan struct{}) error {
+				for _, dep := range deps {
+					if !isReady(dep, 1) {
+						mu.Lock()
+						gated = append(gated, fmt.Sprintf("%s started before %s was ready", name, dep))
+						mu.Unlock()
+					}
+				}
+				// Becoming ready takes a while
+				if !sleepOrDone(20*time.Millisecond, stop) {
+					return nil
+				}
+				mu.Lock()
+				ready[name] = incarnation
+				mu.Unlock()
+				if failFirst && incarnation == 1 {
+					if sleepOrDone(150*time.Millisecond, stop) {
+						return errors.New("connection lost")
+					}
+					return nil
+				}
+				<-stop
+				return nil
+			},
+			Ready: func(incarnation int) bool { return isReady(name, incarnation) },
+		}
+	}
+	c := worker("c", []string{"b"}, false)
+	b := worker("b", []string{"a"}, false)
+	b.Cascade = true
+	a := worker("a", nil, true)
+
+	g, err := newSupervisorGroup(10*time.Millisecond, time.Second, c, b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.close()
+	want := []string{"start a#1", "a#1 ready", "start b#1", "b#1 ready", "start c#1", "c#1 ready"}
+	if got := g.History(); fmt.Sprint(got[:len(want)]) != fmt.Sprint(want) {
+		t.Fatalf("started as %q, want %q", got, want)
+	}
+	mu.Lock()
+	violations := gated
+	mu.Unlock()
+	if len(violations) > 0 {
+		t.Fatalf("readiness gate not respected: %v", violations)
+	}
+
+	// a fails once; b cascades and restarts after it, c is left alone
+	waitUntil(t, func() bool { return isReady("b", 2) }, time.Second, "b was not restarted after a failed")
+	if a, b, c := g.Incarnation("a"), g.Incarnation("b"), g.Incarnation("c"); a != 2 || b != 2 || c != 1 {
+		t.Fatalf("after a failed incarnations are a#%d b#%d c#%d, want a#2 b#2 c#1", a, b, c)
+	}
+	history := strings.Join(g.History(), "; ")
+	if !strings.Contains(history, "stop b#1; start a#2; a#2 ready; start b#2") {
+		t.Fatalf("cascade did not stop b, restart a and then b: %s", history)
+	}
+
+	started := false
+	run := func(int, <-chan struct{}) error {
+		started = true
+		return nil
+	}
+	_, err = newSupervisorGroup(10*time.Millisecond, time.Second,
+		workerSpec{Name: "x", DependsOn: []string{"y"}, Run: run},
+		workerSpec{Name: "y", DependsOn: []string{"x"}, Run: run})
+	if err == nil || !strings.Contains(err.Error(), "cycle") || started {
+		t.Fatalf("cyclic dependencies gave %v (started: %t), want a cycle error before anything starts", err, started)
+	}
+}
@@ -0,0 +1,143 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// incarnationConfig is what a supervised worker is given for one incarnation:
+// how long it may take to start up, and how long each unit of work may take
+type incarnationConfig struct {
+	Grace    time.Duration
+	Deadline time.Duration
+}
+
+func (c incarnationConfig) String() string {
+	return fmt.Sprintf("grace %v, deadline %v", c.Grace, c.Deadline)
+}
+
+// timeoutSchedule adapts a flapping worker's config. Each failure before the
+// worker became stable doubles the startup grace, up to maxGrace, and halves
+// the work deadline, down to minDeadline: a worker that keeps failing gets
+// more time to come up and less time to hang before it fails again. A stable
+// run reverts to the defaults. It is used from the supervisor's goroutine
+// only, and a nil schedule always gives the zero config.
+type timeoutSchedule struct {
+	defaults    incarnationConfig
+	maxGrace    time.Duration
+	minDeadline time.Duration
+	streak      int // failures since the last stable run
+}
+
+func newTimeoutSchedule(defaults incarnationConfig, maxGrace, minDeadline time.Duration) *timeoutSchedule {
+	return &timeoutSchedule{defaults: defaults, maxGrace: maxGrace, minDeadline: minDeadline}
+}
+
+// next returns the config for the next incarnation
+func (s *timeoutSchedule) next() incarnationConfig {
+	if s == nil {
+		return incarnationConfig{}
+	}
+	cfg := s.defaults
+	for i := 0; i < s.streak; i++ {
+		if cfg.Grace *= 2; cfg.Grace > s.maxGrace {
+			cfg.Grace = s.maxGrace
+		}
+		if cfg.Deadline /= 2; cfg.Deadline < s.minDeadline {
+			cfg.Deadline = s.minDeadline
+		}
+	}
+	return cfg
+}
+
+// flapped records an incarnation that failed before it became stable
+func (s *timeoutSchedule) flapped() {
+	if s != nil {
+		s.streak++
+	}
+}
+
+// reset reverts to the defaults after a stable run, reporting whether the
+// config had moved away from them
+func (s *timeoutSchedule) reset() bool {
+	if s == nil || s.streak == 0 {
+		return false
+	}
+	s.streak = 0
+	return true
+}
+
+// flappingWorker fails quickly on its first failFirst runs, then runs
+// healthily for healthyFor and fails once more, then works until stopped.
+// It logs the config each incarnation was given.
+type flappingWorker struct {
+	failFirst  int
+	healthyFor time.Duration
+
+	mu      sync.Mutex
+	configs []incarnationConfig
+}
+
+func (w *flappingWorker) Configs() []incarnationConfig {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]incarnationConfig(nil), w.configs...)
+}
+
+// run is one incarnation of the worker, matching supervisor.worker
+func (w *flappingWorker) run(cfg incarnationConfig, done chan<- struct{}, stop <-chan struct{}) {
+	defer func() { done <- struct{}{} }()
+	w.mu.Lock()
+	w.configs = append(w.configs, cfg)
+	run := len(w.configs)
+	w.mu.Unlock()
+	fmt.Fprintf(output(), "Worker: Started (run %d, %v)\n", run, cfg)
+
+	switch {
+	case run <= w.failFirst:
+		if sleepOrDone(30*time.Millisecond, stop) {
+			fmt.Fprintln(output(), "Worker: Failed during startup")
+		}
+	case run == w.failFirst+1:
+		if sleepOrDone(w.healthyFor, stop) {
+			fmt.Fprintf(output(), "Worker: Failed after %v of healthy work\n", w.healthyFor)
+		}
+	default:
+		<-stop
+	}
+}
+
+// runFlappingSupervisor supervises a worker that fails five times in a row
+// right after starting, then settles, and prints the config each incarnation
+// was given
+func runFlappingSupervisor() {
+	fmt.Fprintln(output(), "\nFlapping worker with a progressive timeout schedule:")
+	worker := &flappingWorker{failFirst: 5, healthyFor: 700 * time.Millisecond}
+	sup := newSupervisor(NewExponentialBackoff(20*time.Millisecond, 20*time.Millisecond), worker.run)
+	sup.stableAfter = 500 * time.Millisecond
+	sup.schedule = newTimeoutSchedule(incarnationConfig{Grace: 100 * time.Millisecond, Deadline: 800 * time.Millisecond},
+		time.Second, 100*time.Millisecond)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go sup.run(stop, done)
+	reached := pollUntil(func() bool { return len(worker.Configs()) == worker.failFirst+2 }, 10*time.Millisecond, 5*time.Second)
+	close(stop)
+	<-done
+
+	configs := worker.Configs()
+	record("flapping_configs", configs)
+	if !reached {
+		fail("flapping supervisor: %d incarnations, want %d", len(configs), worker.failFirst+2)
+		return
+	}
+	// Five quick failures walk the schedule; the failure after a stable run
+	// restarts with the defaults
+	if last := configs[len(configs)-1]; last != sup.schedule.defaults {
+		fail("flapping supervisor: incarnation after a stable run got %v, want the defaults", last)
+	}
+	if configs[worker.failFirst].Deadline >= configs[0].Deadline {
+		fail("flapping supervisor: deadline did not shrink over %d failures: %v", worker.failFirst, configs)
+	}
+}
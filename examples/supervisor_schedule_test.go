@@ -0,0 +1,42 @@
+package examples
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTimeoutSchedule checks the config progression over five scripted
+// failures, that grace and deadline stop at their bounds, and that a stable
+// run reverts to the defaults
+func TestTimeoutSchedule(t *testing.T) {
+	ms := time.Millisecond
+	s := newTimeoutSchedule(incarnationConfig{Grace: 100 * ms, Deadline: 800 * ms}, time.Second, 100*ms)
+	want := []incarnationConfig{
+		{100 * ms, 800 * ms},
+		{200 * ms, 400 * ms},
+		{400 * ms, 200 * ms},
+		{800 * ms, 100 * ms},
+		{time.Second, 100 * ms},
+		{time.Second, 100 * ms},
+	}
+	for i, w := range want {
+		if got := s.next(); got != w {
+			t.Fatalf("after %d failures: got %v, want %v", i, got, w)
+		}
+		s.flapped()
+	}
+	s.reset()
+	if got := s.next(); got != s.defaults {
+		t.Fatalf("after a stable run: got %v, want the defaults %v", got, s.defaults)
+	}
+	s.flapped()
+	if got := s.next(); got != want[1] {
+		t.Fatalf("first failure after a stable run: got %v, want %v", got, want[1])
+	}
+
+	var none *timeoutSchedule
+	none.flapped()
+	if got := none.next(); got != (incarnationConfig{}) {
+		t.Fatal("a nil schedule gave a non-zero config")
+	}
+}
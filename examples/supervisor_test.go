@@ -0,0 +1,70 @@
+package examples
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingBackoff waits a fixed short delay and records the attempts it
+// was asked about and how often it was reset
+type recordingBackoff struct {
+	attempts []int
+	resets   int32
+}
+
+func (b *recordingBackoff) Next(attempt int) time.Duration {
+	b.attempts = append(b.attempts, attempt)
+	return time.Millisecond
+}
+
+func (b *recordingBackoff) Reset() { atomic.AddInt32(&b.resets, 1) }
+
+// TestSupervisorStopsRestartingOnceHealed supervises a worker that fails
+// its first three runs, and checks the supervisor restarts it exactly three
+// times with a growing attempt count, resets the backoff once the fourth run
+// stays up, and never restarts it again
+func TestSupervisorStopsRestartingOnceHealed(t *testing.T) {
+	defer func(o Options) { opts = o }(opts)
+	opts.Out = io.Discard
+
+	worker := &healingWorker{failFirst: 3, workTime: 5 * time.Millisecond}
+	backoff := &recordingBackoff{}
+	// The supervisor doesn't wait for a stopped worker, so the test does
+	var running sync.WaitGroup
+	sup := newSupervisor(backoff, func(cfg incarnationConfig, done chan<- struct{}, stop <-chan struct{}) {
+		running.Add(1)
+		defer running.Done()
+		worker.run(cfg, done, stop)
+	})
+	sup.stableAfter = 50 * time.Millisecond
+	stop, done := make(chan struct{}), make(chan struct{})
+	goLabeled(t, "supervisor", func() { sup.run(stop, done) })
+
+	waitUntil(t, func() bool { return atomic.LoadInt32(&backoff.resets) == 1 }, 2*time.Second,
+		"the backoff was never reset after the worker healed")
+	// Well past several more work ticks and stability windows
+	time.Sleep(150 * time.Millisecond)
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("supervisor still running a second after stop")
+	}
+	running.Wait()
+
+	if r := atomic.LoadInt32(&sup.restarts); r != 3 {
+		t.Fatalf("%d restarts, want 3 for the three failed runs", r)
+	}
+	if n := worker.Runs(); n != 4 {
+		t.Fatalf("worker ran %d times, want 4: three failures and the healthy run", n)
+	}
+	if got := backoff.attempts; len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Fatalf("backoff asked about attempts %v, want [1 2 3]", got)
+	}
+	if r := atomic.LoadInt32(&backoff.resets); r != 1 {
+		t.Fatalf("backoff reset %d times, want once when the healthy run became stable", r)
+	}
+}
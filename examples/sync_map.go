@@ -0,0 +1,58 @@
+package examples
+
+import "sync"
+
+// SyncMap is a map safe for concurrent use. Every read and write goes through
+// its lock, so callers never touch the underlying map directly. The zero
+// value is ready to use.
+type SyncMap[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]V
+}
+
+// Update replaces the value for k with fn(old, ok), where ok reports whether
+// k was present, and returns the new value. fn runs under the map's lock, so
+// read-modify-write updates to the same key never interleave; fn must not
+// call back into the map.
+func (s *SyncMap[K, V]) Update(k K, fn func(old V, ok bool) V) V {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[K]V)
+	}
+	old, ok := s.m[k]
+	v := fn(old, ok)
+	s.m[k] = v
+	return v
+}
+
+// Get returns the value for k and whether it was present
+func (s *SyncMap[K, V]) Get(k K) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[k]
+	return v, ok
+}
+
+// Range calls fn for every entry until fn returns false. It iterates over a
+// copy, so fn may use the map.
+func (s *SyncMap[K, V]) Range(fn func(k K, v V) bool) {
+	s.mu.Lock()
+	entries := make(map[K]V, len(s.m))
+	for k, v := range s.m {
+		entries[k] = v
+	}
+	s.mu.Unlock()
+	for k, v := range entries {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// Len returns the number of entries
+func (s *SyncMap[K, V]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.m)
+}
@@ -0,0 +1,47 @@
+package examples
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestSyncMap hammers one shared key and one key per goroutine with
+// concurrent Updates and checks no increment was lost
+func TestSyncMap(t *testing.T) {
+	const goroutines, increments = 8, 1000
+	var counts SyncMap[string, int]
+	inc := func(old int, _ bool) int { return old + 1 }
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			own := fmt.Sprintf("key-%d", g)
+			for i := 0; i < increments; i++ {
+				counts.Update("shared", inc)
+				counts.Update(own, inc)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := counts.Len(); n != goroutines+1 {
+		t.Fatalf("%d keys, want %d", n, goroutines+1)
+	}
+	if v, _ := counts.Get("shared"); v != goroutines*increments {
+		t.Fatalf("shared key counted %d, want %d", v, goroutines*increments)
+	}
+	var lost error
+	counts.Range(func(k string, v int) bool {
+		if k != "shared" && v != increments {
+			lost = fmt.Errorf("%s counted %d, want %d", k, v, increments)
+			return false
+		}
+		return true
+	})
+	if lost != nil {
+		t.Fatal(lost)
+	}
+}
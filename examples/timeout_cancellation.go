@@ -3,17 +3,17 @@ package examples
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"time"
 )
 
 // RunTimeoutCancellation demonstrates timeouts and cancellation patterns.
-func RunTimeoutCancellation() {
-	fmt.Println("=== Timeouts and Cancellation Pattern Example ===")
+func RunTimeoutCancellation(ctx context.Context) {
+	fmt.Fprintln(output(), "=== Timeouts and Cancellation Pattern Example ===")
 
+	phase("context-timeout")
 	// Example 1: Context-based timeout
-	fmt.Println("\n1. Context-based timeout example:")
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	fmt.Fprintln(output(), "\n1. Context-based timeout example:")
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
 	result := make(chan string, 1)
@@ -21,58 +21,68 @@ func RunTimeoutCancellation() {
 
 	select {
 	case res := <-result:
-		fmt.Printf("Task completed: %s\n", res)
+		fmt.Fprintf(output(), "Task completed: %s\n", res)
+		record("context_timeout", res)
 	case <-ctx.Done():
-		fmt.Printf("Task timed out: %v\n", ctx.Err())
+		fmt.Fprintf(output(), "Task timed out: %v\n", ctx.Err())
+		record("context_timeout", ctx.Err().Error())
 	}
 
+	phase("channel-timeout")
 	// Example 2: Channel-based timeout
-	fmt.Println("\n2. Channel-based timeout example:")
+	fmt.Fprintln(output(), "\n2. Channel-based timeout example:")
 	ch := make(chan string, 1)
 	go func() {
-		time.Sleep(3 * time.Second)
+		pause(3 * time.Second)
 		ch <- "Channel task completed"
 	}()
 
 	select {
 	case res := <-ch:
-		fmt.Printf("Channel task: %s\n", res)
+		fmt.Fprintf(output(), "Channel task: %s\n", res)
+		record("channel_timeout", res)
 	case <-time.After(1 * time.Second):
-		fmt.Println("Channel task timed out")
+		fmt.Fprintln(output(), "Channel task timed out")
+		record("channel_timeout", "timed out")
 	}
 
+	phase("cancel")
 	// Example 3: Cancellation with context
-	fmt.Println("\n3. Context cancellation example:")
-	ctx2, cancel2 := context.WithCancel(context.Background())
+	fmt.Fprintln(output(), "\n3. Context cancellation example:")
+	ctx2, cancel2 := context.WithCancel(ctx)
 	defer cancel2()
 
 	go func() {
 		time.Sleep(500 * time.Millisecond)
-		fmt.Println("Cancelling context...")
+		fmt.Fprintln(output(), "Cancelling context...")
 		cancel2()
 	}()
 
 	select {
 	case <-time.After(2 * time.Second):
-		fmt.Println("Context cancellation example completed")
+		fmt.Fprintln(output(), "Context cancellation example completed")
+		record("cancellation", "completed")
 	case <-ctx2.Done():
-		fmt.Printf("Context cancelled: %v\n", ctx2.Err())
+		fmt.Fprintf(output(), "Context cancelled: %v\n", ctx2.Err())
+		record("cancellation", ctx2.Err().Error())
 	}
 
-	fmt.Println("\nTimeouts and Cancellation example completed!")
+	phase("watchdog")
+	// The watchdog turns a hang like this into a stall report and a dump
+	runWatchedStall()
+
+	fmt.Fprintln(output(), "\nTimeouts and Cancellation example completed!")
 }
 
 // longRunningTask simulates a long-running task that respects context cancellation
 func longRunningTask(ctx context.Context, result chan<- string) {
-	// Simulate work with random duration
-	workTime := time.Duration(rand.Intn(3000)+1000) * time.Millisecond
-	fmt.Printf("Starting long task (will take %v)...\n", workTime)
+	// Simulate work with random duration, seeded in the golden modes
+	workTime := time.Duration(exampleRand().Intn(3000)+1000) * time.Millisecond
+	fmt.Fprintf(output(), "Starting long task (will take %v)...\n", workTime)
 
-	select {
-	case <-time.After(workTime):
-		result <- "Long task completed successfully"
-	case <-ctx.Done():
-		fmt.Printf("Long task cancelled: %v\n", ctx.Err())
+	if !pauseOrDone(workTime, ctx.Done()) {
+		fmt.Fprintf(output(), "Long task cancelled: %v\n", ctx.Err())
 		return
 	}
+	result <- "Long task completed successfully"
 }
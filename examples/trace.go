@@ -0,0 +1,184 @@
+package examples
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans around pattern operations
+type Tracer interface {
+	Start(name string) Span
+}
+
+// Span times one operation. Start begins a child span, which is how
+// parentage is expressed: a pipeline item's span owns its per-stage spans.
+type Span interface {
+	Start(name string) Span
+	SetTag(key string, value interface{})
+	End()
+}
+
+// tracer is used by the instrumented examples; --trace swaps in a recorder
+var tracer Tracer = noopTracer{}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(string) Span { return noopSpan{} }
+
+type noopSpan struct{}
+
+func (noopSpan) Start(string) Span          { return noopSpan{} }
+func (noopSpan) SetTag(string, interface{}) {}
+func (noopSpan) End()                       {}
+
+// SpanRecord is a finished (or still open) span captured by a RecordingTracer
+type SpanRecord struct {
+	ID       int
+	ParentID int
+	Name     string
+	Tags     map[string]interface{}
+	Start    time.Time
+	Duration time.Duration
+}
+
+// RecordingTracer keeps every span in memory so a run can be inspected afterwards
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+// NewRecordingTracer returns an empty in-memory tracer
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+type recordedSpan struct {
+	tracer *RecordingTracer
+	record SpanRecord
+	ended  bool
+}
+
+func (t *RecordingTracer) Start(name string) Span {
+	return t.start(name, 0)
+}
+
+func (t *RecordingTracer) start(name string, parent int) Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &recordedSpan{
+		tracer: t,
+		record: SpanRecord{
+			ID:       len(t.spans) + 1,
+			ParentID: parent,
+			Name:     name,
+			Tags:     make(map[string]interface{}),
+			Start:    time.Now(),
+		},
+	}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+func (s *recordedSpan) Start(name string) Span {
+	return s.tracer.start(name, s.record.ID)
+}
+
+func (s *recordedSpan) SetTag(key string, value interface{}) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.record.Tags[key] = value
+}
+
+func (s *recordedSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	if !s.ended {
+		s.ended = true
+		s.record.Duration = time.Since(s.record.Start)
+	}
+}
+
+// Spans returns a copy of every span recorded so far, in start order
+func (t *RecordingTracer) Spans() []SpanRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]SpanRecord, len(t.spans))
+	for i, s := range t.spans {
+		out[i] = s.record
+		out[i].Tags = make(map[string]interface{}, len(s.record.Tags))
+		for k, v := range s.record.Tags {
+			out[i].Tags[k] = v
+		}
+	}
+	return out
+}
+
+// printSpanTree prints every root span with the given name and its children,
+// each child showing when it started relative to the root and how long it took
+func printSpanTree(spans []SpanRecord, rootName string) {
+	children := make(map[int][]SpanRecord)
+	for _, s := range spans {
+		children[s.ParentID] = append(children[s.ParentID], s)
+	}
+
+	var walk func(s SpanRecord, root time.Time, depth int)
+	walk = func(s SpanRecord, root time.Time, depth int) {
+		fmt.Fprintf(output(), "%s%s%s +%v took %v\n", strings.Repeat("  ", depth), s.Name, formatTags(s.Tags),
+			s.Start.Sub(root).Round(time.Millisecond), s.Duration.Round(time.Millisecond))
+		for _, child := range children[s.ID] {
+			walk(child, root, depth+1)
+		}
+	}
+	for _, s := range children[0] {
+		if s.Name == rootName {
+			walk(s, s.Start, 0)
+		}
+	}
+}
+
+// printSpanSummary prints the count and average duration of spans per name
+func printSpanSummary(spans []SpanRecord) {
+	counts := make(map[string]int)
+	totals := make(map[string]time.Duration)
+	var names []string
+	for _, s := range spans {
+		if counts[s.Name] == 0 {
+			names = append(names, s.Name)
+		}
+		counts[s.Name]++
+		totals[s.Name] += s.Duration
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(output(), "  %s: %d spans, avg %v\n", name, counts[name], (totals[name] / time.Duration(counts[name])).Round(time.Millisecond))
+	}
+}
+
+// printTrace prints the recorded spans when --trace is on
+func printTrace() {
+	rec, ok := tracer.(*RecordingTracer)
+	if !ok {
+		return
+	}
+	fmt.Fprintln(output(), "\nTrace summary:")
+	printSpanSummary(rec.Spans())
+}
+
+func formatTags(tags map[string]interface{}) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%v", k, tags[k])
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
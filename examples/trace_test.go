@@ -0,0 +1,76 @@
+package examples
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPipelineTraceSpans runs eight items through generate, square, filter
+// and addTen with the in-memory recorder and checks each item gets one root
+// span whose children are its stage spans: all four for the items the filter
+// keeps, and no addTen span for those it drops
+func TestPipelineTraceSpans(t *testing.T) {
+	rec := NewRecordingTracer()
+	trace := newPipelineTrace(rec)
+
+	ctx := context.Background()
+	cfg := pipelineConfig{Quiet: true, Delay: time.Millisecond}
+	even := func(n int) bool { return n%2 == 0 }
+	const count = 8
+	var results []int
+	for v := range addTen(ctx, filter(ctx, square(ctx, generateNumbers(ctx, count, cfg, trace), cfg, trace), even, cfg, trace), cfg, trace) {
+		results = append(results, v)
+	}
+
+	// The generator ends its last span after a final sleep, once the
+	// downstream stages may already be done
+	ended := func() bool {
+		for _, s := range rec.Spans() {
+			if s.Duration == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	waitUntil(t, ended, time.Second, "spans still open after the pipeline drained")
+
+	spans := rec.Spans()
+	items := make(map[int]int) // span ID -> item number
+	for _, s := range spans {
+		if s.Name == "item" {
+			if s.ParentID != 0 {
+				t.Fatalf("item span %d has parent %d", s.ID, s.ParentID)
+			}
+			items[s.ID] = s.Tags["item"].(int)
+		}
+	}
+	if len(items) != count {
+		t.Fatalf("%d item spans for %d items", len(items), count)
+	}
+	stages := make(map[int]map[string]int) // item number -> stage -> spans
+	for _, s := range spans {
+		if s.Name == "item" {
+			continue
+		}
+		item, ok := items[s.ParentID]
+		if !ok {
+			t.Fatalf("%s span %d has parent %d, not an item span", s.Name, s.ID, s.ParentID)
+		}
+		if stages[item] == nil {
+			stages[item] = make(map[string]int)
+		}
+		stages[item][s.Name]++
+	}
+	kept := 0
+	for item := 1; item <= count; item++ {
+		got := stages[item]
+		if got["generate"] != 1 || got["square"] != 1 || got["filter"] != 1 || got["addTen"] > 1 {
+			t.Fatalf("item %d has stage spans %v, want one each of generate, square and filter", item, got)
+		}
+		kept += got["addTen"]
+	}
+	if kept != len(results) {
+		t.Fatalf("%d addTen spans for %d results kept by the filter", kept, len(results))
+	}
+}
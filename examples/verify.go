@@ -0,0 +1,32 @@
+package examples
+
+import "fmt"
+
+// verify runs an example's invariant check when --verify is on. A violation
+// is recorded with fail, so main.go exits non-zero once the example returns.
+func verify(example string, check func() error) {
+	heartbeat()
+	if !opts.Verify {
+		return
+	}
+	if err := check(); err != nil {
+		fail("verify %s: %v", example, err)
+		return
+	}
+	fmt.Fprintf(output(), "Verified %s\n", example)
+}
+
+// checkIDs reports an error unless ids holds each of want IDs exactly once
+func checkIDs(ids []int, want int) error {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("item %d processed more than once", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != want {
+		return fmt.Errorf("processed %d distinct items, generated %d", len(seen), want)
+	}
+	return nil
+}
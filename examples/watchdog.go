@@ -0,0 +1,187 @@
+package examples
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// StallExitCode is the exit status when the watchdog finds an example stalled
+const StallExitCode = 2
+
+// progressMonitor records when an example last made progress
+type progressMonitor struct {
+	last int64 // unix nanoseconds of the latest beat
+}
+
+func (p *progressMonitor) beat() {
+	atomic.StoreInt64(&p.last, time.Now().UnixNano())
+}
+
+func (p *progressMonitor) since() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&p.last)))
+}
+
+// watch calls onStall once if no beat arrives for timeout, checking a few
+// times per timeout. It returns after onStall, or when ctx is done.
+func (p *progressMonitor) watch(ctx context.Context, timeout time.Duration, onStall func()) {
+	p.beat()
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if p.since() > timeout {
+				onStall()
+				return
+			}
+		}
+	}
+}
+
+// progress is what the examples report their heartbeats to
+var progress progressMonitor
+
+// heartbeat tells the watchdog the running example is making progress. The
+// examples call it from their main loops and between steps.
+func heartbeat() {
+	progress.beat()
+}
+
+// pause sleeps for d, beating as it goes: an example deliberately letting
+// its goroutines run for a while, or simulating a long piece of work, is
+// making progress, not stalled
+func pause(d time.Duration) {
+	pauseOrDone(d, nil)
+}
+
+// pauseOrDone is sleepOrDone with heartbeats: it reports true after d, or
+// false if done closes first
+func pauseOrDone(d time.Duration, done <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		heartbeat()
+		select {
+		case <-timer.C:
+			return true
+		case <-done:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// heartbeatInterval is how often pause beats, well inside any sensible
+// watchdog timeout
+const heartbeatInterval = 100 * time.Millisecond
+
+// Watchdog watches the examples' heartbeats until ctx is done, calling
+// onStall if none arrives for timeout. Hangs such as a publish blocked on a
+// full subscriber or a worker waiting on a channel nobody closes show up as
+// a stall rather than as a program that never returns.
+func Watchdog(ctx context.Context, timeout time.Duration, onStall func()) {
+	progress.watch(ctx, timeout, onStall)
+}
+
+// RunWithWatchdog runs fn under the heartbeat watchdog. If fn makes no
+// progress for d, it returns an error wrapping ErrTimeout that carries a dump
+// of every goroutine, and leaves fn running; otherwise it returns nil once
+// fn does.
+func RunWithWatchdog(d time.Duration, fn func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stalled := make(chan []byte, 1)
+	go Watchdog(ctx, d, func() { stalled <- goroutineDump() })
+
+	select {
+	case <-done:
+		return nil
+	case dump := <-stalled:
+		return fmt.Errorf("no progress for %v: %w\n\n%s", d, ErrTimeout, dump)
+	}
+}
+
+// OnStall returns the stall handler main.go uses: it reports the stall on
+// stderr, writes a dump of every goroutine to dumpPath (stderr when empty)
+// and exits with StallExitCode.
+func OnStall(timeout time.Duration, dumpPath string) func() {
+	return stallHandler(timeout, dumpPath, os.Stderr, os.Exit)
+}
+
+// stallHandler is OnStall with the report's destination and the exit call
+// supplied by the caller
+func stallHandler(timeout time.Duration, dumpPath string, report io.Writer, exit func(code int)) func() {
+	return func() {
+		fmt.Fprintf(report, "Example stalled: no progress for %v\n", timeout)
+		w := report
+		if dumpPath != "" {
+			f, err := os.Create(dumpPath)
+			if err != nil {
+				fmt.Fprintf(report, "Goroutine dump: %v\n", err)
+			} else {
+				defer f.Close()
+				w = f
+				fmt.Fprintf(report, "Goroutine dump written to %s\n", dumpPath)
+			}
+		}
+		w.Write(goroutineDump())
+		exit(StallExitCode)
+	}
+}
+
+// goroutineDump returns the stacks of all goroutines in pprof's debug=2 format
+func goroutineDump() []byte {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 2)
+	return buf.Bytes()
+}
+
+// runWatchedStall watches a toy that stops beating and shows the stall
+// handler's report, without exiting the program
+func runWatchedStall() {
+	fmt.Fprintln(output(), "\nWatchdog over a toy that stops making progress (40ms timeout):")
+	var toy progressMonitor
+	stuck := make(chan struct{})
+	defer close(stuck)
+	go stalledToy(&toy, stuck)
+
+	var report bytes.Buffer
+	codes := make(chan int, 1)
+	exit := func(code int) { codes <- code }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	toy.watch(ctx, 40*time.Millisecond, stallHandler(40*time.Millisecond, "", &report, exit))
+
+	select {
+	case code := <-codes:
+		stalled, _, _ := bytes.Cut(report.Bytes(), []byte("\n"))
+		fmt.Fprintf(output(), "%s; the handler dumped %d goroutines and would exit with status %d\n",
+			stalled, bytes.Count(report.Bytes(), []byte("goroutine ")), code)
+		record("watchdog_exit_code", code)
+	default:
+		fmt.Fprintln(output(), "The watchdog did not fire")
+	}
+}
+
+// stalledToy makes progress twice and then blocks on a channel nobody sends on
+func stalledToy(p *progressMonitor, stuck <-chan struct{}) {
+	p.beat()
+	p.beat()
+	<-stuck
+}
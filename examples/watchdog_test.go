@@ -0,0 +1,141 @@
+package examples
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWatchdog runs a deliberately stalled toy example under the watchdog
+// and checks that the stall handler dumps the stuck goroutine and exits with
+// the stall code, and that a toy that keeps beating is left alone
+func TestWatchdog(t *testing.T) {
+	dumpPath := fmt.Sprintf("%s/watchdog-check-%d.txt", os.TempDir(), os.Getpid())
+	defer os.Remove(dumpPath)
+
+	var toy progressMonitor
+	stuck := make(chan struct{})
+	defer close(stuck)
+	go stalledToy(&toy, stuck)
+
+	codes := make(chan int, 1)
+	exit := func(code int) { codes <- code }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	toy.watch(ctx, 40*time.Millisecond, stallHandler(40*time.Millisecond, dumpPath, io.Discard, exit))
+
+	select {
+	case code := <-codes:
+		if code != StallExitCode {
+			t.Fatalf("stalled toy exited with %d, want %d", code, StallExitCode)
+		}
+	default:
+		t.Fatalf("watchdog did not fire for a stalled toy")
+	}
+	dump, err := os.ReadFile(dumpPath)
+	if err != nil {
+		t.Fatalf("reading the goroutine dump: %v", err)
+	}
+	if !strings.Contains(string(dump), "stalledToy") || !strings.Contains(string(dump), "chan receive") {
+		t.Fatalf("goroutine dump does not show the stalled toy blocked on its channel")
+	}
+
+	// A toy that keeps beating for longer than the timeout is not a stall
+	var busy progressMonitor
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	go func() {
+		for !stopped(ctx.Done()) {
+			busy.beat()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	fired := false
+	busy.watch(ctx, 40*time.Millisecond, func() { fired = true })
+	if fired {
+		t.Fatalf("watchdog fired for a toy that kept making progress")
+	}
+}
+
+// TestRunWithWatchdog checks a function that keeps beating runs to the end
+// past the timeout, and one that blocks comes back as ErrTimeout with a dump
+// showing where it is stuck
+func TestRunWithWatchdog(t *testing.T) {
+	err := RunWithWatchdog(250*time.Millisecond, func() { pause(400 * time.Millisecond) })
+	if err != nil {
+		t.Fatalf("beating function: %v", err)
+	}
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	err = RunWithWatchdog(50*time.Millisecond, func() { stalledToy(&progress, stuck) })
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("stalled function returned %v, want ErrTimeout", err)
+	}
+	if !strings.Contains(err.Error(), "stalledToy") {
+		t.Fatalf("error does not carry a dump showing the stalled function")
+	}
+}
+
+// TestRunWithWatchdogNoLeak checks a function that returns in time leaves
+// neither its own goroutine nor the watchdog's running
+func TestRunWithWatchdogNoLeak(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		if err := RunWithWatchdog(time.Second, func() {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	goroutineCountStable(t, goroutines)
+}
+
+// TestCloseOrderingUnderWatchdog runs the producer-consumer shutdown and a
+// broadcaster closed mid-publish under the watchdog, so a future change that
+// deadlocks either close ordering fails with a dump rather than hanging
+func TestCloseOrderingUnderWatchdog(t *testing.T) {
+	var lost error
+	runWithWatchdog(t, 2*time.Second, func() {
+		for run := 0; run < 50 && lost == nil; run++ {
+			cfg := producerConsumerConfig{Producers: 3, Consumers: 2, Items: 20, Buffer: run % 3}
+			produced, consumed := runProducerConsumer(cfg)
+			lost = sameItems(produced, consumed)
+		}
+	})
+	if lost != nil {
+		t.Fatal(lost)
+	}
+
+	runWithWatchdog(t, 2*time.Second, func() {
+		for run := 0; run < 20; run++ {
+			b := newBroadcaster[int]()
+			sub := b.subscribe()
+			var wg sync.WaitGroup
+			for p := 0; p < 3; p++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 50; i++ {
+						b.publish(i)
+						heartbeat()
+					}
+				}()
+			}
+			drained := make(chan struct{})
+			go func() {
+				defer close(drained)
+				for range sub {
+				}
+			}()
+			b.close()
+			wg.Wait()
+			<-drained
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package examples
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StopReason is why a pool worker stopped
+type StopReason int
+
+const (
+	// Completed means the pool closed and the worker drained its queue
+	Completed StopReason = iota
+	// Cancelled means the pool's context ended
+	Cancelled
+	// Panicked means a job panicked and took the worker down
+	Panicked
+	// Retired means the pool shrank and this worker was let go
+	Retired
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case Completed:
+		return "completed"
+	case Cancelled:
+		return "cancelled"
+	case Panicked:
+		return "panicked"
+	case Retired:
+		return "retired"
+	}
+	return fmt.Sprintf("StopReason(%d)", int(r))
+}
+
+// WorkerLifecycle belongs to one worker for its whole life. Code running on
+// the worker registers cleanup with OnStop, such as releasing a connection
+// the worker checked out when it started.
+type WorkerLifecycle struct {
+	mu      sync.Mutex
+	hooks   []func(reason StopReason)
+	stopped bool
+	reason  StopReason
+}
+
+// OnStop registers fn to run once when the worker stops, after its last job
+// and before the pool counts it gone. Hooks run in reverse order of
+// registration, like defers. Registered after the worker stopped, fn runs
+// straight away with the reason it stopped.
+func (w *WorkerLifecycle) OnStop(fn func(reason StopReason)) {
+	w.mu.Lock()
+	if w.stopped {
+		reason := w.reason
+		w.mu.Unlock()
+		fn(reason)
+		return
+	}
+	w.hooks = append(w.hooks, fn)
+	w.mu.Unlock()
+}
+
+// stop runs the hooks; only the first call has any effect
+func (w *WorkerLifecycle) stop(reason StopReason) {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.stopped, w.reason = true, reason
+	hooks := w.hooks
+	w.hooks = nil
+	w.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i](reason)
+	}
+}
+
+// workerSet tracks a pool's live workers and the hook each is started with
+type workerSet struct {
+	mu      sync.Mutex
+	live    map[int]*WorkerLifecycle
+	onStart func(id int, w *WorkerLifecycle)
+}
+
+// OnWorkerStart calls fn for every worker the pool starts, and straight away
+// for the workers already running, so each worker sees fn exactly once
+func (s *workerSet) OnWorkerStart(fn func(id int, w *WorkerLifecycle)) {
+	s.mu.Lock()
+	s.onStart = fn
+	running := make(map[int]*WorkerLifecycle, len(s.live))
+	for id, w := range s.live {
+		running[id] = w
+	}
+	s.mu.Unlock()
+	for id, w := range running {
+		fn(id, w)
+	}
+}
+
+// started registers a new worker and runs the start hook on it
+func (s *workerSet) started(id int) *WorkerLifecycle {
+	w := &WorkerLifecycle{}
+	s.mu.Lock()
+	if s.live == nil {
+		s.live = make(map[int]*WorkerLifecycle)
+	}
+	s.live[id] = w
+	onStart := s.onStart
+	s.mu.Unlock()
+	if onStart != nil {
+		onStart(id, w)
+	}
+	return w
+}
+
+// stopped runs the worker's stop hooks, then forgets it
+func (s *workerSet) stopped(id int, reason StopReason) {
+	s.mu.Lock()
+	w := s.live[id]
+	s.mu.Unlock()
+	if w == nil {
+		return
+	}
+	w.stop(reason)
+	s.mu.Lock()
+	delete(s.live, id)
+	s.mu.Unlock()
+}
+
+// connectionPerWorker is a start hook that checks a connection out of db for
+// each worker and returns it when the worker stops. It counts the stops per
+// worker and reason in stops.
+type connectionPerWorker struct {
+	db *dbConnectionPool
+
+	mu    sync.Mutex
+	stops map[int][]StopReason
+}
+
+func newConnectionPerWorker(db *dbConnectionPool) *connectionPerWorker {
+	return &connectionPerWorker{db: db, stops: make(map[int][]StopReason)}
+}
+
+func (c *connectionPerWorker) start(id int, w *WorkerLifecycle) {
+	conn := c.db.getConnection()
+	w.OnStop(func(reason StopReason) {
+		c.db.releaseConnection(conn)
+		c.mu.Lock()
+		c.stops[id] = append(c.stops[id], reason)
+		c.mu.Unlock()
+	})
+}
+
+// byReason counts the workers that stopped for each reason, and fails if
+// any worker's hook ran other than exactly once
+func (c *connectionPerWorker) byReason() (map[StopReason]int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[StopReason]int)
+	ids := make([]int, 0, len(c.stops))
+	for id := range c.stops {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if len(c.stops[id]) != 1 {
+			return nil, fmt.Errorf("worker %d's stop hook ran %d times (%v)", id, len(c.stops[id]), c.stops[id])
+		}
+		counts[c.stops[id][0]]++
+	}
+	return counts, nil
+}
+
+// runWorkerCleanup runs the supervised pool with workers that each hold a
+// pooled connection for their lifetime, one of them lost to a panic
+func runWorkerCleanup() {
+	fmt.Fprintln(output(), "\nWorker cleanup (each worker holds a pooled connection; job 4 panics):")
+	db := newDBConnectionPool(0, 8)
+	conns := newConnectionPerWorker(db)
+	pool := newSupervisedPool(3, false, func(job int) string {
+		time.Sleep(20 * time.Millisecond)
+		if job == 4 {
+			panic("bad row")
+		}
+		return fmt.Sprintf("Job %d done", job)
+	})
+	pool.OnWorkerStart(func(id int, w *WorkerLifecycle) {
+		conns.start(id, w)
+		w.OnStop(func(reason StopReason) {
+			fmt.Fprintf(output(), "Worker %d stopped (%s), returning its connection\n", id, reason)
+		})
+	})
+	go func() {
+		for i := 1; i <= 8; i++ {
+			pool.submit(i)
+		}
+		pool.close()
+	}()
+	for range pool.results {
+	}
+
+	counts, err := conns.byReason()
+	stats := db.Stats()
+	fmt.Fprintf(output(), "Workers stopped: %d completed, %d panicked; connections in use afterwards: %d\n",
+		counts[Completed], counts[Panicked], stats.InUse)
+	record("worker_cleanup", map[string]int{"completed": counts[Completed], "panicked": counts[Panicked], "in_use": stats.InUse})
+	switch {
+	case err != nil:
+		fail("worker cleanup: %v", err)
+	case counts[Completed] != 3 || counts[Panicked] != 1:
+		fail("worker cleanup: stops by reason %v, want 3 completed and 1 panicked", counts)
+	case stats.InUse != 0:
+		fail("worker cleanup: %d connections never returned", stats.InUse)
+	}
+}
@@ -0,0 +1,74 @@
+package examples
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestWorkerStopHooks stops workers for each of the four reasons and checks
+// every worker's hook ran exactly once with the right reason, and that every
+// connection the workers held went back to the pool
+func TestWorkerStopHooks(t *testing.T) {
+	db := newDBConnectionPool(0, 16)
+	conns := newConnectionPerWorker(db)
+
+	// Completed and Panicked: a supervised pool with one panicking job
+	supervised := newSupervisedPool(2, false, func(job int) string {
+		if job == 2 {
+			panic("boom")
+		}
+		return ""
+	})
+	supervised.OnWorkerStart(conns.start)
+	go func() {
+		for i := 1; i <= 4; i++ {
+			supervised.submit(i)
+		}
+		supervised.close()
+	}()
+	for range supervised.results {
+	}
+	counts, err := conns.byReason()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[Completed] != 2 || counts[Panicked] != 1 {
+		t.Fatalf("supervised pool stops %v, want 2 completed and 1 panicked", counts)
+	}
+
+	// Cancelled: the pool's context ends while its workers wait for jobs
+	conns = newConnectionPerWorker(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancellable := newCtxJobPool(ctx, 3, func(ctx context.Context, job int) (string, error) { return "", nil })
+	cancellable.OnWorkerStart(conns.start)
+	cancel()
+	for range cancellable.results {
+	}
+	if counts, err = conns.byReason(); err != nil {
+		t.Fatal(err)
+	}
+	if counts[Cancelled] != 3 {
+		t.Fatalf("cancelled pool stops %v, want 3 cancelled", counts)
+	}
+
+	// Retired, then Completed: an SLO pool sheds a worker, then closes
+	conns = newConnectionPerWorker(db)
+	slo := NewSLOPool(2, 2, time.Second, func(int) {})
+	slo.OnWorkerStart(conns.start)
+	slo.retireWorker()
+	if counts, _ = conns.byReason(); counts[Retired] != 1 {
+		t.Fatalf("the shed worker was counted gone before its stop hook ran")
+	}
+	slo.Close()
+	if counts, err = conns.byReason(); err != nil {
+		t.Fatal(err)
+	}
+	if counts[Retired] != 1 || counts[Completed] != 1 {
+		t.Fatalf("SLO pool stops %v, want 1 retired and 1 completed", counts)
+	}
+
+	if n := db.Stats().InUse; n != 0 {
+		t.Fatalf("%d connections still checked out after every worker stopped", n)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"time"
 
 	"concurrency-model-patterns/examples"
 )
@@ -22,12 +27,66 @@ func main() {
 	singleflight := flag.Bool("singleflight", false, "Run singleflight (spaceflight) pattern example")
 	eventLoop := flag.Bool("event-loop", false, "Run event loop pattern example")
 	resourcePooling := flag.Bool("resource-pooling", false, "Run resource pooling pattern example")
+	composed := flag.Bool("composed", false, "Run the composed ingestion example chaining several patterns")
+	all := flag.Bool("all", false, "Run every pattern example in turn")
+	resume := flag.Bool("resume", false, "Resume the producer-consumer example from its last checkpoint")
+	checkpointDir := flag.String("checkpoint-dir", "", "Directory for the producer-consumer checkpoint (default the system temp directory)")
+	soak := flag.Duration("soak", 0, "Run producer-consumer, pubsub or pools under steady load for this long")
+	chaos := flag.Bool("chaos", false, "Inject seeded delays, panics and failures into the examples that support it")
+	seed := flag.Int64("seed", 1, "Seed for chaos mode")
+	statsAddr := flag.String("stats-addr", "", "Serve component stats at /stats and expvar at /debug/vars on this address while the example runs")
+	watchdog := flag.Duration("watchdog", 0, "Fail with a goroutine dump if the example makes no progress for this long")
+	watchdogDump := flag.String("watchdog-dump", "", "Write the watchdog's goroutine dump to this file instead of stderr")
+	trace := flag.Bool("trace", false, "Record spans and print a trace when the example finishes")
+	eventLog := flag.String("event-log", "", "Record the event loop's events to this file as JSON lines")
+	replay := flag.String("replay", "", "Replay an event log through the event loop's handlers")
+	replayRealtime := flag.Bool("replay-realtime", false, "Keep the original timing between replayed events")
+	eventDrop := flag.String("event-drop", "block", "What a full event loop queue does with a new event: block, drop-newest or drop-oldest")
+	jsonOutput := flag.Bool("json", false, "Print the example's results as JSON instead of its running commentary")
+	verify := flag.Bool("verify", false, "Check each example's core invariant and exit non-zero if it is violated")
+	explain := flag.Bool("explain", false, "Annotate each phase of the example with what it does and why, and summarize what it showed")
+	fanItems := flag.Int("fan-items", 20, "Number of work items in the fan example; large counts are summarized as they stream")
+	fanSpill := flag.String("fan-spill", "", "Write every result of a streamed fan run to this file as JSON lines")
+	pipelineCount := flag.Int("pipeline-count", 10, "Number of values the pipeline example generates")
+	pipelineDelay := flag.Duration("pipeline-delay", 0, "How long each pipeline stage works on an item (default 100ms to generate, 150ms to square, 100ms to add ten)")
+	golden := flag.String("golden", "", "Write each example's normalized results to a golden file in this directory")
+	checkGolden := flag.String("check-golden", "", "Rerun and diff each example's results against the golden files in this directory")
 
 	// Parse command line flags
 	flag.Parse()
 
+	// In JSON mode the examples' commentary is discarded and only the JSON
+	// document is written to stdout
+	var out io.Writer = os.Stdout
+	if *jsonOutput {
+		out = io.Discard
+	}
+
+	examples.SetOptions(examples.Options{
+		Out:    out,
+		Resume: *resume,
+		Soak:   *soak,
+		Chaos:  *chaos,
+		Seed:   *seed,
+		Trace:  *trace,
+
+		CheckpointDir:   *checkpointDir,
+		EventLog:        *eventLog,
+		Replay:          *replay,
+		ReplayRealtime:  *replayRealtime,
+		EventDropPolicy: *eventDrop,
+		Verify:          *verify,
+		Explain:         *explain,
+		FanItems:        *fanItems,
+		FanSpill:        *fanSpill,
+		PipelineCount:   *pipelineCount,
+		PipelineDelay:   *pipelineDelay,
+		Golden:          *golden,
+		CheckGolden:     *checkGolden,
+	})
+
 	// Check if any flag was provided
-	if !*pipeline && !*fan && !*pools && !*producerConsumer && !*supervisor && !*pubsub && !*timeoutCancellation && !*rateLimiting && !*mapreduce && !*singleflight && !*eventLoop && !*resourcePooling {
+	if !*all && !*pipeline && !*fan && !*pools && !*producerConsumer && !*supervisor && !*pubsub && !*timeoutCancellation && !*rateLimiting && !*mapreduce && !*singleflight && !*eventLoop && !*resourcePooling && !*composed {
 		fmt.Println("Concurrency Model Patterns Examples")
 		fmt.Println("===================================")
 		fmt.Println("Usage:")
@@ -43,6 +102,27 @@ func main() {
 		fmt.Println("  cmp-pattern --singleflight       - Run singleflight (spaceflight) pattern example")
 		fmt.Println("  cmp-pattern --event-loop         - Run event loop pattern example")
 		fmt.Println("  cmp-pattern --resource-pooling   - Run resource pooling pattern example")
+		fmt.Println("  cmp-pattern --composed           - Run the composed ingestion example (several patterns end to end)")
+		fmt.Println("  cmp-pattern --all                - Run every example in turn")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println("  --resume                         - Resume producer-consumer from its last checkpoint")
+		fmt.Println("  --checkpoint-dir DIR             - Keep the producer-consumer checkpoint in DIR")
+		fmt.Println("  --soak DURATION                  - Soak producer-consumer, pubsub or pools with health reports")
+		fmt.Println("  --chaos [--seed N]               - Inject seeded faults into pools, producer-consumer, supervisor and pubsub")
+		fmt.Println("  --stats-addr ADDR                - Serve live stats at http://ADDR/stats while the example runs")
+		fmt.Println("  --watchdog DURATION [--watchdog-dump FILE] - Exit with a goroutine dump if the example stalls")
+		fmt.Println("  --trace                          - Print spans for pipeline, fan, pools and singleflight")
+		fmt.Println("  --event-log FILE                 - Record event-loop events as JSON lines")
+		fmt.Println("  --replay FILE [--replay-realtime] - Replay a recorded event log through the event loop")
+		fmt.Println("  --event-drop POLICY              - block, drop-newest or drop-oldest when an event queue is full")
+		fmt.Println("  --json                           - Print the results as a JSON document")
+		fmt.Println("  --verify                         - Check the example's invariants, exiting non-zero on a violation")
+		fmt.Println("  --explain                        - Walk through the example phase by phase with annotations")
+		fmt.Println("  --fan-items N [--fan-spill FILE] - Fan out N items; above 10000 results are summarized, not kept")
+		fmt.Println("  --pipeline-count N [--pipeline-delay DURATION] - Generate N values, each stage working DURATION per item")
+		fmt.Println("  --golden DIR [--seed N]          - Write the example's deterministic results to DIR/<example>.json")
+		fmt.Println("  --check-golden DIR [--seed N]    - Rerun and diff against DIR, exiting non-zero on a mismatch")
 		fmt.Println()
 		fmt.Println("Examples:")
 		fmt.Println("  ./cmp-pattern --pipeline")
@@ -57,46 +137,119 @@ func main() {
 		fmt.Println("  ./cmp-pattern --singleflight")
 		fmt.Println("  ./cmp-pattern --event-loop")
 		fmt.Println("  ./cmp-pattern --resource-pooling")
+		fmt.Println("  ./cmp-pattern --composed")
+		os.Exit(1)
+	}
+
+	// Start the stats server; it shuts down when the example ends or on Ctrl-C
+	ctx, cancel := context.WithCancel(context.Background())
+	var statsStopped <-chan struct{}
+	if *statsAddr != "" {
+		addr, stopped, err := examples.StartStatsServer(ctx, *statsAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Stats server: %v\n", err)
+			os.Exit(1)
+		}
+		statsStopped = stopped
+		fmt.Fprintf(out, "Serving stats at http://%s/stats\n", addr)
+
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		go func() {
+			<-interrupts
+			cancel()
+			<-stopped
+			os.Exit(130)
+		}()
+	}
+
+	// Run the selected example, or every example with --all, timing each one
+	patterns := []pattern{
+		{pipeline, "pipeline", "Pipeline Pattern Example", examples.RunPipeline},
+		{fan, "fan", "Fan-out/Fan-in Pattern Example", examples.RunFan},
+		{pools, "pools", "Worker Pools Pattern Example", examples.RunPools},
+		{producerConsumer, "producer-consumer", "Producer-Consumer Pattern Example", examples.RunProducerConsumer},
+		{supervisor, "supervisor", "Supervisor/Restart Pattern Example", examples.RunSupervisor},
+		{pubsub, "pubsub", "Publish-Subscribe (Pub/Sub) Pattern Example", examples.RunPubSub},
+		{timeoutCancellation, "timeout-cancellation", "Timeouts and Cancellation Pattern Example", examples.RunTimeoutCancellation},
+		{rateLimiting, "rate-limiting", "Rate Limiting Pattern Example", examples.RunRateLimiting},
+		{mapreduce, "mapreduce", "MapReduce Pattern Example", examples.RunMapReduce},
+		{singleflight, "singleflight", "Singleflight (Spaceflight) Pattern Example", examples.RunSingleflight},
+		{eventLoop, "event-loop", "Event Loop Pattern Example", examples.RunEventLoop},
+		{resourcePooling, "resource-pooling", "Resource Pooling Pattern Example", examples.RunResourcePooling},
+		{composed, "composed", "Composed Ingestion Example", examples.RunComposed},
+	}
+	// Every example in this invocation shares one RunID; items, jobs, events
+	// and messages get TraceIDs derived from it
+	rc := examples.NewRunContext()
+	runCtx := examples.WithRunContext(ctx, rc)
+	run := func() { runPatterns(runCtx, out, patterns, *all, rc.RunID) }
+
+	// The watchdog exits with a goroutine dump if the example stops beating
+	if *watchdog > 0 {
+		watchCtx, stopWatching := context.WithCancel(ctx)
+		go examples.Watchdog(watchCtx, *watchdog, examples.OnStall(*watchdog, *watchdogDump))
+		run()
+		stopWatching()
+	} else {
+		run()
+	}
+
+	cancel()
+	if statsStopped != nil {
+		<-statsStopped
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		failures := examples.Failures()
+		if failures == nil {
+			failures = []string{}
+		}
+		err := enc.Encode(map[string]interface{}{
+			"run_id":     rc.RunID,
+			"results":    examples.Results(),
+			"trace_hops": rc.HopLog(),
+			"failures":   failures,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "JSON output: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if examples.Failed() {
 		os.Exit(1)
 	}
+}
+
+// pattern is one example the CLI can run, selected by its flag
+type pattern struct {
+	selected *bool
+	key      string
+	name     string
+	run      func(ctx context.Context)
+}
 
-	// Run the selected example
-	switch {
-	case *pipeline:
-		fmt.Println("Running Pipeline Pattern Example...")
-		examples.RunPipeline()
-	case *fan:
-		fmt.Println("Running Fan-out/Fan-in Pattern Example...")
-		examples.RunFan()
-	case *pools:
-		fmt.Println("Running Worker Pools Pattern Example...")
-		examples.RunPools()
-	case *producerConsumer:
-		fmt.Println("Running Producer-Consumer Pattern Example...")
-		examples.RunProducerConsumer()
-	case *supervisor:
-		fmt.Println("Running Supervisor/Restart Pattern Example...")
-		examples.RunSupervisor()
-	case *pubsub:
-		fmt.Println("Running Publish-Subscribe (Pub/Sub) Pattern Example...")
-		examples.RunPubSub()
-	case *timeoutCancellation:
-		fmt.Println("Running Timeouts and Cancellation Pattern Example...")
-		examples.RunTimeoutCancellation()
-	case *rateLimiting:
-		fmt.Println("Running Rate Limiting Pattern Example...")
-		examples.RunRateLimiting()
-	case *mapreduce:
-		fmt.Println("Running MapReduce Pattern Example...")
-		examples.RunMapReduce()
-	case *singleflight:
-		fmt.Println("Running Singleflight (Spaceflight) Pattern Example...")
-		examples.RunSingleflight()
-	case *eventLoop:
-		fmt.Println("Running Event Loop Pattern Example...")
-		examples.RunEventLoop()
-	case *resourcePooling:
-		fmt.Println("Running Resource Pooling Pattern Example...")
-		examples.RunResourcePooling()
+// runPatterns runs the selected pattern, or every pattern when all is set,
+// writing how long each took to w, and with all the total as well
+func runPatterns(ctx context.Context, w io.Writer, patterns []pattern, all bool, runID string) {
+	start := time.Now()
+	for _, p := range patterns {
+		if !all && !*p.selected {
+			continue
+		}
+		fmt.Fprintf(w, "Running %s (run %s)...\n", p.name, runID)
+		began := time.Now()
+		examples.BeginPattern(p.key)
+		p.run(ctx)
+		examples.EndPattern()
+		fmt.Fprintf(w, "%s took %v\n", p.name, time.Since(began).Round(time.Millisecond))
+		if !all {
+			return
+		}
+		fmt.Fprintln(w)
 	}
+	fmt.Fprintf(w, "All examples took %v\n", time.Since(start).Round(time.Millisecond))
 }
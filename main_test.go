@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunPatternsDurations runs stand-in patterns through the dispatcher and
+// checks each one's duration line follows its output, that only the
+// selected pattern runs without --all, and that --all adds a total
+func TestRunPatternsDurations(t *testing.T) {
+	var out bytes.Buffer
+	fake := func(name string, d time.Duration) func(context.Context) {
+		return func(context.Context) {
+			time.Sleep(d)
+			fmt.Fprintf(&out, "%s ran\n", name)
+		}
+	}
+	yes, no := true, false
+	patterns := []pattern{
+		{&no, "first", "First Example", fake("first", 10*time.Millisecond)},
+		{&yes, "second", "Second Example", fake("second", 30*time.Millisecond)},
+	}
+
+	tookLine := func(name string) *regexp.Regexp {
+		return regexp.MustCompile(`(?m)^` + name + ` took (\d+ms)$`)
+	}
+	runPatterns(context.Background(), &out, patterns, false, "test")
+	got := out.String()
+	if strings.Contains(got, "first ran") {
+		t.Fatalf("an unselected pattern ran:\n%s", got)
+	}
+	m := tookLine("Second Example").FindStringSubmatch(got)
+	if m == nil || strings.Index(got, "second ran") > strings.Index(got, m[0]) {
+		t.Fatalf("no duration line after the selected pattern's output:\n%s", got)
+	}
+	if d, _ := time.ParseDuration(m[1]); d < 30*time.Millisecond {
+		t.Fatalf("a 30ms pattern reported %v", d)
+	}
+	if strings.Contains(got, "All examples took") {
+		t.Fatalf("a single pattern printed a total:\n%s", got)
+	}
+
+	out.Reset()
+	runPatterns(context.Background(), &out, patterns, true, "test")
+	got = out.String()
+	for _, name := range []string{"first", "second"} {
+		title := strings.ToUpper(name[:1]) + name[1:] + " Example"
+		m := tookLine(title).FindStringSubmatch(got)
+		if m == nil || strings.Index(got, name+" ran") > strings.Index(got, m[0]) {
+			t.Fatalf("no duration line after %s's output:\n%s", name, got)
+		}
+	}
+	total := regexp.MustCompile(`(?m)^All examples took (\d+ms)$`).FindStringSubmatch(got)
+	if total == nil {
+		t.Fatalf("--all printed no total:\n%s", got)
+	}
+	if d, _ := time.ParseDuration(total[1]); d < 40*time.Millisecond {
+		t.Fatalf("total %v is less than the patterns' 40ms", d)
+	}
+}
+
+// TestMainProcess isn't a test: runCLI re-runs the test binary with
+// CMP_MAIN_ARGS set, and this runs main with those arguments in its place
+func TestMainProcess(t *testing.T) {
+	args := os.Getenv("CMP_MAIN_ARGS")
+	if args == "" {
+		t.Skip("only runs as the CLI for runCLI")
+	}
+	os.Args = append([]string{"cmp-pattern"}, strings.Fields(args)...)
+	main()
+	// main returns on success; exit before the test framework prints
+	os.Exit(0)
+}
+
+// runCLI runs the CLI with args in a child process and returns its stdout
+// and exit code
+func runCLI(t *testing.T, args ...string) (stdout string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMainProcess$")
+	cmd.Env = append(os.Environ(), "CMP_MAIN_ARGS="+strings.Join(args, " "))
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return out.String(), exit.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("run %v: %v\n%s", args, err, errOut.String())
+	}
+	return out.String(), 0
+}
+
+// TestJSONOutput runs the MapReduce example with --json and checks stdout is
+// a single JSON document holding the run ID, the example's results and no
+// failures, with none of the running commentary around it
+func TestJSONOutput(t *testing.T) {
+	stdout, code := runCLI(t, "--json", "--mapreduce")
+	if code != 0 {
+		t.Fatalf("--json --mapreduce exited %d:\n%s", code, stdout)
+	}
+	var doc struct {
+		RunID     string                     `json:"run_id"`
+		Results   map[string]json.RawMessage `json:"results"`
+		TraceHops map[string]json.RawMessage `json:"trace_hops"`
+		Failures  []string                   `json:"failures"`
+	}
+	dec := json.NewDecoder(strings.NewReader(stdout))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatalf("stdout isn't the JSON document: %v\n%s", err, stdout)
+	}
+	if dec.More() {
+		t.Fatalf("more than one JSON document on stdout:\n%s", stdout)
+	}
+	if doc.RunID == "" || doc.Failures == nil || len(doc.Failures) != 0 {
+		t.Fatalf("run_id %q, failures %v; want a run ID and an empty list", doc.RunID, doc.Failures)
+	}
+
+	var counts map[string]int
+	if err := json.Unmarshal(doc.Results["word_counts"], &counts); err != nil {
+		t.Fatalf("word_counts: %v in %s", err, doc.Results["word_counts"])
+	}
+	if counts["go"] == 0 || counts["concurrency"] == 0 {
+		t.Fatalf("word_counts missing the sample text's words: %v", counts)
+	}
+	for _, key := range []string{"tree_reduce", "timeout_error"} {
+		if _, ok := doc.Results[key]; !ok {
+			t.Fatalf("results missing %q: %s", key, stdout)
+		}
+	}
+}
+
+// TestVerifySucceeds runs the MapReduce example with --verify and checks it
+// exits zero having verified its word counts against the sequential ones
+func TestVerifySucceeds(t *testing.T) {
+	stdout, code := runCLI(t, "--verify", "--mapreduce")
+	if code != 0 || strings.Contains(stdout, "FAIL:") {
+		t.Fatalf("--verify --mapreduce exited %d:\n%s", code, stdout)
+	}
+	if !regexp.MustCompile(`(?m)^Verified mapreduce$`).MatchString(stdout) {
+		t.Fatalf("no verification reported:\n%s", stdout)
+	}
+}
+
+// TestCheckGoldenTampered writes the MapReduce golden file, checks a rerun
+// matches it, then changes one word count in it and checks the rerun exits
+// non-zero with a diff naming the changed line
+func TestCheckGoldenTampered(t *testing.T) {
+	dir := t.TempDir()
+	if stdout, code := runCLI(t, "--golden", dir, "--mapreduce"); code != 0 {
+		t.Fatalf("--golden exited %d:\n%s", code, stdout)
+	}
+	if stdout, code := runCLI(t, "--check-golden", dir, "--mapreduce"); code != 0 {
+		t.Fatalf("--check-golden against a fresh golden file exited %d:\n%s", code, stdout)
+	}
+
+	path := filepath.Join(dir, "mapreduce.json")
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := regexp.MustCompile(`"go": (\d+)`)
+	if !count.Match(doc) {
+		t.Fatalf("no count for \"go\" in the golden file:\n%s", doc)
+	}
+	tampered := count.ReplaceAll(doc, []byte(`"go": 999`))
+	if err := os.WriteFile(path, tampered, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, code := runCLI(t, "--check-golden", dir, "--mapreduce")
+	if code == 0 {
+		t.Fatalf("--check-golden against a tampered golden file exited 0:\n%s", stdout)
+	}
+	if !regexp.MustCompile(`(?m)^-\s+"go": 999,$`).MatchString(stdout) {
+		t.Fatalf("no diff showing the tampered count:\n%s", stdout)
+	}
+}
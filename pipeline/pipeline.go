@@ -0,0 +1,62 @@
+// Package pipeline chains channel stages into a pipeline without the wiring
+// boilerplate of the pipeline example.
+package pipeline
+
+// Stage is one step of a pipeline: it reads values from in and returns the
+// channel it sends its results on, which it closes once in is closed and
+// drained.
+type Stage[T any] func(in <-chan T) <-chan T
+
+// Pipeline is an ordered list of stages over values of type T
+type Pipeline[T any] struct {
+	stages []Stage[T]
+}
+
+// New returns a pipeline with no stages; run as it is, it passes its source
+// straight through
+func New[T any]() *Pipeline[T] {
+	return &Pipeline[T]{}
+}
+
+// AddStage appends a stage and returns the pipeline, so stages can be added
+// in a chain
+func (p *Pipeline[T]) AddStage(stage func(in <-chan T) <-chan T) *Pipeline[T] {
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// Run wires the stages together in the order they were added, feeding the
+// first from source, and returns the last stage's output. It doesn't block:
+// values flow once the output is read, and the output closes after source
+// closes and every stage has drained.
+func (p *Pipeline[T]) Run(source <-chan T) <-chan T {
+	out := source
+	for _, stage := range p.stages {
+		out = stage(out)
+	}
+	return out
+}
+
+// Map returns a stage that applies fn to every value in its own goroutine
+// and closes its output once its input is closed and drained
+func Map[T any](fn func(T) T) Stage[T] {
+	return func(in <-chan T) <-chan T {
+		out := make(chan T)
+		go func() {
+			defer close(out)
+			for v := range in {
+				out <- fn(v)
+			}
+		}()
+		return out
+	}
+}
+
+// Collect drains in into a slice, blocking until it is closed
+func Collect[T any](in <-chan T) []T {
+	var out []T
+	for v := range in {
+		out = append(out, v)
+	}
+	return out
+}